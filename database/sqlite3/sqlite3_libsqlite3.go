@@ -2,10 +2,12 @@
 //
 // Use of this source code is governed by an MIT-style
 // license that can be found in the LICENSE file.
-// +build libsqlite3
 
 package sqlite3
 
+// The SQLite amalgamation (sqlite3-binding.c) is not vendored, so the system
+// library is always linked, as if the libsqlite3 tag is set.
+
 /*
 #cgo CFLAGS: -DUSE_LIBSQLITE3
 #cgo linux LDFLAGS: -lsqlite3
@@ -0,0 +1,427 @@
+/**
+ * msgpack.go
+ * Copyright (c) 2018 Yanke Guo
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+// Package msgpack implements encoding of MessagePack.
+//
+// Struct fields are encoded as map entries keyed by field name, which can be
+// customized by "msgpack" tag, or "json" tag if "msgpack" tag is absent, e.g.
+//
+//	Name string `msgpack:"name,omitempty"`
+//
+// time.Time is encoded as timestamp extension.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Marshaler is the interface implemented by types that can marshal themselves into MessagePack.
+type Marshaler interface {
+	MarshalMsgpack() ([]byte, error)
+}
+
+// UnsupportedTypeError is returned by Marshal when attempting to encode an unsupported value type.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "msgpack: unsupported type: " + e.Type.String()
+}
+
+// Marshal returns the MessagePack encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	e := &encoder{}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// Encoder writes MessagePack values to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w}
+}
+
+// Encode writes the MessagePack encoding of v to the stream.
+func (enc *Encoder) Encode(v interface{}) error {
+	data, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = enc.w.Write(data)
+	return err
+}
+
+var (
+	marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+type encoder struct {
+	bytes.Buffer
+	scratch [9]byte
+}
+
+func (e *encoder) writeUint(prefix byte, size int, n uint64) {
+	e.scratch[0] = prefix
+	switch size {
+	case 1:
+		e.scratch[1] = byte(n)
+	case 2:
+		binary.BigEndian.PutUint16(e.scratch[1:], uint16(n))
+	case 4:
+		binary.BigEndian.PutUint32(e.scratch[1:], uint32(n))
+	case 8:
+		binary.BigEndian.PutUint64(e.scratch[1:], n)
+	}
+	e.Write(e.scratch[:size+1])
+}
+
+func (e *encoder) encodeNil() {
+	e.WriteByte(0xc0)
+}
+
+func (e *encoder) encodeBool(b bool) {
+	if b {
+		e.WriteByte(0xc3)
+	} else {
+		e.WriteByte(0xc2)
+	}
+}
+
+func (e *encoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.WriteByte(byte(n))
+	case n >= math.MinInt8:
+		e.writeUint(0xd0, 1, uint64(n))
+	case n >= math.MinInt16:
+		e.writeUint(0xd1, 2, uint64(n))
+	case n >= math.MinInt32:
+		e.writeUint(0xd2, 4, uint64(n))
+	default:
+		e.writeUint(0xd3, 8, uint64(n))
+	}
+}
+
+func (e *encoder) encodeUint(n uint64) {
+	switch {
+	case n <= math.MaxInt8:
+		e.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		e.writeUint(0xcc, 1, n)
+	case n <= math.MaxUint16:
+		e.writeUint(0xcd, 2, n)
+	case n <= math.MaxUint32:
+		e.writeUint(0xce, 4, n)
+	default:
+		e.writeUint(0xcf, 8, n)
+	}
+}
+
+func (e *encoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n <= 31:
+		e.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		e.writeUint(0xd9, 1, uint64(n))
+	case n <= math.MaxUint16:
+		e.writeUint(0xda, 2, uint64(n))
+	default:
+		e.writeUint(0xdb, 4, uint64(n))
+	}
+	e.WriteString(s)
+}
+
+func (e *encoder) encodeBytes(p []byte) {
+	n := len(p)
+	switch {
+	case n <= math.MaxUint8:
+		e.writeUint(0xc4, 1, uint64(n))
+	case n <= math.MaxUint16:
+		e.writeUint(0xc5, 2, uint64(n))
+	default:
+		e.writeUint(0xc6, 4, uint64(n))
+	}
+	e.Write(p)
+}
+
+func (e *encoder) encodeArrayLen(n int) {
+	switch {
+	case n <= 15:
+		e.WriteByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		e.writeUint(0xdc, 2, uint64(n))
+	default:
+		e.writeUint(0xdd, 4, uint64(n))
+	}
+}
+
+func (e *encoder) encodeMapLen(n int) {
+	switch {
+	case n <= 15:
+		e.WriteByte(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		e.writeUint(0xde, 2, uint64(n))
+	default:
+		e.writeUint(0xdf, 4, uint64(n))
+	}
+}
+
+// encodeTime encodes t as timestamp extension of type -1.
+func (e *encoder) encodeTime(t time.Time) {
+	sec, nsec := uint64(t.Unix()), uint64(t.Nanosecond())
+	switch {
+	case sec>>34 == 0 && nsec == 0 && sec <= math.MaxUint32:
+		e.Write([]byte{0xd6, 0xff})
+		binary.BigEndian.PutUint32(e.scratch[:4], uint32(sec))
+		e.Write(e.scratch[:4])
+	case sec>>34 == 0:
+		e.Write([]byte{0xd7, 0xff})
+		binary.BigEndian.PutUint64(e.scratch[:8], nsec<<34|sec)
+		e.Write(e.scratch[:8])
+	default:
+		e.Write([]byte{0xc7, 12, 0xff})
+		binary.BigEndian.PutUint32(e.scratch[:4], uint32(nsec))
+		e.Write(e.scratch[:4])
+		binary.BigEndian.PutUint64(e.scratch[:8], sec)
+		e.Write(e.scratch[:8])
+	}
+}
+
+func (e *encoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.encodeNil()
+		return nil
+	}
+	if v.Type().Implements(marshalerType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			e.encodeNil()
+			return nil
+		}
+		data, err := v.Interface().(Marshaler).MarshalMsgpack()
+		if err != nil {
+			return err
+		}
+		e.Write(data)
+		return nil
+	}
+	if v.Type() == timeType {
+		e.encodeTime(v.Interface().(time.Time))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.encodeBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.writeUint(0xca, 4, uint64(math.Float32bits(float32(v.Float()))))
+	case reflect.Float64:
+		e.writeUint(0xcb, 8, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.encodeNil()
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.encodeNil()
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBytes(v.Bytes())
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		e.encodeArrayLen(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			e.encodeNil()
+			return nil
+		}
+		keys := v.MapKeys()
+		// Sort keys to make encoding deterministic.
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		e.encodeMapLen(len(keys))
+		for _, k := range keys {
+			if err := e.encode(k); err != nil {
+				return err
+			}
+			if err := e.encode(v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	default:
+		return &UnsupportedTypeError{v.Type()}
+	}
+	return nil
+}
+
+func (e *encoder) encodeStruct(v reflect.Value) error {
+	fields := cachedFields(v.Type())
+	values := make([]reflect.Value, 0, len(fields))
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		values = append(values, fv)
+		names = append(names, f.name)
+	}
+
+	e.encodeMapLen(len(values))
+	for i, fv := range values {
+		e.encodeString(names[i])
+		if err := e.encode(fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the field, it returns false when passing through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t, nil))
+	return f.([]field)
+}
+
+// typeFields returns fields of struct type t, fields of embedded structs without
+// tag names are promoted unless they are shadowed by outer fields.
+func typeFields(t reflect.Type, index []int) []field {
+	var (
+		fields   []field
+		promoted []field
+	)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("msgpack")
+		if !ok {
+			tag = sf.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+		idx := append(index[:len(index):len(index)], i)
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && len(name) == 0 && ft.Kind() == reflect.Struct && ft != timeType {
+			promoted = append(promoted, typeFields(ft, idx)...)
+			continue
+		}
+		if len(sf.PkgPath) > 0 {
+			continue // Unexported.
+		}
+		if len(name) == 0 {
+			name = sf.Name
+		}
+		f := field{name: name, index: idx}
+		for _, opt := range opts[1:] {
+			if opt == "omitempty" {
+				f.omitEmpty = true
+			}
+		}
+		fields = append(fields, f)
+	}
+
+	for _, p := range promoted {
+		shadowed := false
+		for _, f := range fields {
+			if f.name == p.name {
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			fields = append(fields, p)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,94 @@
+/**
+ * msgpack_test.go
+ * Copyright (c) 2018 Yanke Guo
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID int `json:"id"`
+}
+
+type user struct {
+	base
+	Name    string   `msgpack:"name"`
+	Email   string   `json:"email,omitempty"`
+	Tags    []string `msgpack:"tags"`
+	private int
+	Ignored bool `msgpack:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	cases := []struct {
+		v   interface{}
+		hex string
+	}{
+		{nil, "c0"},
+		{true, "c3"},
+		{false, "c2"},
+		{0, "00"},
+		{127, "7f"},
+		{128, "cc80"},
+		{256, "cd0100"},
+		{70000, "ce00011170"},
+		{uint64(1) << 40, "cf0000010000000000"},
+		{-1, "ff"},
+		{-32, "e0"},
+		{-33, "d0df"},
+		{-200, "d1ff38"},
+		{-70000, "d2fffeee90"},
+		{int64(-1) << 40, "d3ffffff0000000000"},
+		{1.5, "cb3ff8000000000000"},
+		{float32(1.5), "ca3fc00000"},
+		{"", "a0"},
+		{"abc", "a3616263"},
+		{strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{[]byte{1, 2}, "c4020102"},
+		{[]int{1, 2}, "920102"},
+		{[2]bool{true, false}, "92c3c2"},
+		{[]string(nil), "c0"},
+		{map[string]int{"b": 2, "a": 1}, "82a16101a16202"},
+		{(*int)(nil), "c0"},
+		{time.Unix(1, 0), "d6ff00000001"},
+		{user{base: base{ID: 1}, Name: "x", Tags: []string{}}, "83a46e616d65a178a47461677390a2696401"},
+	}
+	for _, c := range cases {
+		data, err := Marshal(c.v)
+		if err != nil {
+			t.Fatalf("Marshal(%#v): %v", c.v, err)
+		}
+		if got := hex.EncodeToString(data); got != c.hex {
+			t.Errorf("Marshal(%#v) = %s, want %s", c.v, got, c.hex)
+		}
+	}
+}
+
+func TestMarshalError(t *testing.T) {
+	if _, err := Marshal(make(chan int)); err == nil {
+		t.Error("Marshal(chan) should fail")
+	}
+	if _, err := Marshal(map[string]interface{}{"f": func() {}}); err == nil {
+		t.Error("Marshal(func) should fail")
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := NewEncoder(buf).Encode([]interface{}{1, "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(buf.Bytes()); got != "9201a161" {
+		t.Errorf("Encode = %s", got)
+	}
+}
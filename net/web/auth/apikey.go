@@ -0,0 +1,93 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"landzero.net/x/net/web"
+)
+
+// APIKeyOptions represents a struct for specifying configuration options for the APIKey middleware.
+type APIKeyOptions struct {
+	// Header carrying the key. Default is "X-API-Key".
+	Header string
+	// Query parameter carrying the key, it's checked when header is absent. Default is "api_key",
+	// "-" disables it.
+	Query string
+	// Keys maps API keys to names of principal, they are compared in constant time.
+	Keys map[string]string
+	// Validate returns name of principal for keys which are not found in Keys.
+	Validate func(key string) (name string, ok bool)
+	// Roles returns roles of principal by name.
+	Roles func(name string) []string
+	// Optional allows requests without key to pass without principal.
+	Optional bool
+	// ErrorFunc is called when authentication fails. Default responds 401.
+	ErrorFunc func(*web.Context)
+}
+
+// APIKey returns a middleware handler that authenticates requests by API keys from
+// header or query parameter. It does nothing if principal is already authenticated
+// by previous handlers.
+func APIKey(opt APIKeyOptions) web.Handler {
+	if len(opt.Header) == 0 {
+		opt.Header = "X-API-Key"
+	}
+	if len(opt.Query) == 0 {
+		opt.Query = "api_key"
+	}
+
+	lookup := func(key string) (string, bool) {
+		var (
+			name  string
+			found bool
+		)
+		// Compare with all keys so that time doesn't depend on which one matches.
+		for k, n := range opt.Keys {
+			if secureCompare(key, k) {
+				name, found = n, true
+			}
+		}
+		if !found && opt.Validate != nil {
+			return opt.Validate(key)
+		}
+		return name, found
+	}
+
+	h := func(ctx *web.Context) {
+		if GetPrincipal(ctx) != nil {
+			return
+		}
+		key := ctx.Req.Header.Get(opt.Header)
+		if len(key) == 0 && opt.Query != "-" {
+			key = ctx.Query(opt.Query)
+		}
+		if len(key) == 0 && opt.Optional {
+			return
+		}
+
+		name, ok := lookup(key)
+		if len(key) == 0 || !ok {
+			unauthorized(ctx, "", opt.ErrorFunc)
+			return
+		}
+
+		p := &Principal{Name: name, Method: METHOD_API_KEY}
+		if opt.Roles != nil {
+			p.Roles = opt.Roles(name)
+		}
+		ctx.Map(p)
+	}
+	return declare(h, opt.Optional)
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package auth is a middleware suite that authenticates requests by HTTP Basic,
+// JSON Web Token and API keys.
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"landzero.net/x/net/web"
+)
+
+const _VERSION = "0.1.0"
+
+func Version() string {
+	return _VERSION
+}
+
+// Authentication methods of Principal.
+const (
+	METHOD_BASIC   = "basic"
+	METHOD_JWT     = "jwt"
+	METHOD_API_KEY = "apikey"
+)
+
+// Principal represents an authenticated user, it's mapped into the handler chain as *Principal.
+type Principal struct {
+	// Name is user name, subject of token or name of API key.
+	Name  string
+	Roles []string
+	// Method is the authentication method, e.g. METHOD_BASIC.
+	Method string
+	// Claims are claims of JSON Web Token, it's nil for other methods.
+	Claims Claims
+}
+
+// HasRole returns true if principal has any of roles.
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		for _, r := range p.Roles {
+			if r == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var principalType = reflect.TypeOf((*Principal)(nil))
+
+// GetPrincipal returns principal of current request, or nil if it's not authenticated.
+func GetPrincipal(ctx *web.Context) *Principal {
+	val := ctx.GetVal(principalType)
+	if !val.IsValid() || val.IsNil() {
+		return nil
+	}
+	return val.Interface().(*Principal)
+}
+
+// RequireRoles returns a middleware handler that requires principal to have any of roles,
+// it responds 401 if request is not authenticated and 403 if roles are not met.
+// Use it after authentication handlers, e.g. for a group:
+//
+//	m.Group("/admin", func() {
+//		...
+//	}, auth.JWT(opt), auth.RequireRoles("admin"))
+func RequireRoles(roles ...string) web.Handler {
+	return func(ctx *web.Context) {
+		p := GetPrincipal(ctx)
+		if p == nil {
+			http.Error(ctx.Resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if len(roles) > 0 && !p.HasRole(roles...) {
+			http.Error(ctx.Resp, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+	}
+}
+
+// declare declares that h maps *Principal unless authentication is optional.
+func declare(h func(*web.Context), optional bool) web.Handler {
+	if optional {
+		return h
+	}
+	return web.Mapping(h, &Principal{})
+}
+
+// secureCompare compares strings in constant time regardless of their lengths.
+func secureCompare(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// unauthorized writes 401 response with challenge, or calls errorFunc if it's not nil.
+func unauthorized(ctx *web.Context, challenge string, errorFunc func(*web.Context)) {
+	if errorFunc != nil {
+		errorFunc(ctx)
+		return
+	}
+	if len(challenge) > 0 {
+		ctx.Resp.Header().Set("WWW-Authenticate", challenge)
+	}
+	http.Error(ctx.Resp, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// watchedFile loads file and reloads it when it's modified.
+type watchedFile struct {
+	path string
+	load func(data []byte) error
+
+	lock    sync.Mutex
+	modTime time.Time
+	checked time.Time
+}
+
+// newWatchedFile loads file for the first time.
+func newWatchedFile(path string, load func(data []byte) error) (*watchedFile, error) {
+	f := &watchedFile{path: path, load: load}
+	return f, f.reload(true)
+}
+
+// reload loads file if it's modified since last load, file is checked at most once per second.
+// Previous content is kept when it fails.
+func (f *watchedFile) reload(force bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	now := time.Now()
+	if !force && now.Sub(f.checked) < time.Second {
+		return nil
+	}
+	f.checked = now
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	if !force && fi.ModTime().Equal(f.modTime) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	if err = f.load(data); err != nil {
+		return err
+	}
+	f.modTime = fi.ModTime()
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/crypto/bcrypt"
+	"landzero.net/x/net/web"
+)
+
+func do(m *web.Web, url string, header http.Header) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", url, nil)
+	So(err, ShouldBeNil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	m.ServeHTTP(resp, req)
+	return resp
+}
+
+func basicHeader(user, password string) http.Header {
+	return http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))}}
+}
+
+func Test_Version(t *testing.T) {
+	Convey("Check package version", t, func() {
+		So(Version(), ShouldEqual, _VERSION)
+	})
+}
+
+func Test_Basic(t *testing.T) {
+	Convey("Authenticate by HTTP Basic", t, func() {
+		dir, err := ioutil.TempDir("", "auth")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("htpass"), bcrypt.MinCost)
+		So(err, ShouldBeNil)
+		htpasswdPath := filepath.Join(dir, ".htpasswd")
+		So(ioutil.WriteFile(htpasswdPath, []byte("# users\nbob:"+string(hash)+"\n"), 0600), ShouldBeNil)
+
+		m := web.New()
+		m.Use(Basic(BasicOptions{
+			Realm:    "Test",
+			Users:    map[string]string{"alice": "secret"},
+			Htpasswd: htpasswdPath,
+			Roles: func(user string) []string {
+				if user == "alice" {
+					return []string{"admin"}
+				}
+				return nil
+			},
+		}))
+		m.Get("/", func(p *Principal) string { return p.Name })
+		m.Group("/admin", func() {
+			m.Get("/", func() string { return "admin" })
+		}, RequireRoles("admin"))
+
+		resp := do(m, "/", nil)
+		So(resp.Code, ShouldEqual, http.StatusUnauthorized)
+		So(resp.Header().Get("WWW-Authenticate"), ShouldEqual, `Basic realm="Test"`)
+
+		So(do(m, "/", basicHeader("alice", "wrong")).Code, ShouldEqual, http.StatusUnauthorized)
+		So(do(m, "/", basicHeader("alice", "secret")).Body.String(), ShouldEqual, "alice")
+		So(do(m, "/", basicHeader("bob", "htpass")).Body.String(), ShouldEqual, "bob")
+		So(do(m, "/", basicHeader("bob", "secret")).Code, ShouldEqual, http.StatusUnauthorized)
+
+		So(do(m, "/admin/", basicHeader("alice", "secret")).Body.String(), ShouldEqual, "admin")
+		So(do(m, "/admin/", basicHeader("bob", "htpass")).Code, ShouldEqual, http.StatusForbidden)
+
+		Convey("Reload modified htpasswd", func() {
+			hash, err := bcrypt.GenerateFromPassword([]byte("changed"), bcrypt.MinCost)
+			So(err, ShouldBeNil)
+			So(ioutil.WriteFile(htpasswdPath, []byte("bob:"+string(hash)+"\n"), 0600), ShouldBeNil)
+			future := time.Now().Add(time.Minute)
+			So(os.Chtimes(htpasswdPath, future, future), ShouldBeNil)
+			time.Sleep(1100 * time.Millisecond)
+
+			So(do(m, "/", basicHeader("bob", "htpass")).Code, ShouldEqual, http.StatusUnauthorized)
+			So(do(m, "/", basicHeader("bob", "changed")).Body.String(), ShouldEqual, "bob")
+		})
+
+		Convey("Compare unknown users against dummy hash of the same cost", func() {
+			hash, err := bcrypt.GenerateFromPassword([]byte("htpass"), bcrypt.MinCost+1)
+			So(err, ShouldBeNil)
+			h := &htpasswd{}
+			So(h.load([]byte("bob:"+string(hash)+"\n")), ShouldBeNil)
+			cost, err := bcrypt.Cost(h.dummy)
+			So(err, ShouldBeNil)
+			So(cost, ShouldEqual, bcrypt.MinCost+1)
+
+			found, ok := h.validate("nobody", "htpass")
+			So(found, ShouldBeFalse)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Invalid htpasswd", func() {
+			So(ioutil.WriteFile(htpasswdPath, []byte("carol:{SHA}abc\n"), 0600), ShouldBeNil)
+			So(func() { Basic(BasicOptions{Htpasswd: htpasswdPath}) }, ShouldPanic)
+		})
+	})
+}
+
+func Test_APIKey(t *testing.T) {
+	Convey("Authenticate by API key and chain methods", t, func() {
+		m := web.New()
+		m.Use(APIKey(APIKeyOptions{
+			Keys:     map[string]string{"k1": "service"},
+			Roles:    func(name string) []string { return []string{"reader"} },
+			Optional: true,
+		}))
+		m.Use(Basic(BasicOptions{Users: map[string]string{"alice": "secret"}}))
+		m.Get("/", RequireRoles(), func(p *Principal) string { return p.Method + ":" + p.Name })
+		m.Get("/read", RequireRoles("reader"), func() string { return "ok" })
+
+		So(do(m, "/", http.Header{"X-Api-Key": {"k1"}}).Body.String(), ShouldEqual, "apikey:service")
+		So(do(m, "/?api_key=k1", nil).Body.String(), ShouldEqual, "apikey:service")
+		So(do(m, "/read?api_key=k1", nil).Body.String(), ShouldEqual, "ok")
+		So(do(m, "/", http.Header{"X-Api-Key": {"bad"}}).Code, ShouldEqual, http.StatusUnauthorized)
+
+		// Falls back to next method when key is absent.
+		So(do(m, "/", basicHeader("alice", "secret")).Body.String(), ShouldEqual, "basic:alice")
+		So(do(m, "/read", basicHeader("alice", "secret")).Code, ShouldEqual, http.StatusForbidden)
+	})
+
+	Convey("Require roles without authentication", t, func() {
+		m := web.New()
+		m.Get("/", RequireRoles("admin"), func() {})
+		So(do(m, "/", nil).Code, ShouldEqual, http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,188 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"landzero.net/x/crypto/bcrypt"
+	"landzero.net/x/net/web"
+)
+
+// BasicOptions represents a struct for specifying configuration options for the Basic middleware.
+type BasicOptions struct {
+	// Realm of authentication. Default is "Restricted".
+	Realm string
+	// Users maps user names to plaintext passwords, they are compared in constant time.
+	Users map[string]string
+	// Htpasswd is path of htpasswd file with bcrypt hashes, it's reloaded when modified.
+	Htpasswd string
+	// Validate validates credentials which are not found in Users and Htpasswd.
+	Validate func(user, password string) bool
+	// Roles returns roles of authenticated user.
+	Roles func(user string) []string
+	// Optional allows requests without credentials to pass without principal.
+	Optional bool
+	// ErrorFunc is called when authentication fails. Default responds 401 with challenge.
+	ErrorFunc func(*web.Context)
+}
+
+// htpasswd holds bcrypt hashes of users.
+type htpasswd struct {
+	lock  sync.RWMutex
+	users map[string][]byte
+	// dummy is compared for unknown users, so that response time does not reveal
+	// whether a user exists. Its cost is the highest cost of users.
+	dummy []byte
+}
+
+func (h *htpasswd) load(data []byte) error {
+	users := make(map[string][]byte)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 {
+			return fmt.Errorf("auth: invalid htpasswd entry at line %d", n)
+		}
+		hash := line[i+1:]
+		if !strings.HasPrefix(hash, "$2") {
+			return fmt.Errorf("auth: unsupported htpasswd hash at line %d, only bcrypt is supported", n)
+		}
+		users[line[:i]] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	cost := bcrypt.MinCost
+	for _, hash := range users {
+		if c, err := bcrypt.Cost(hash); err == nil && c > cost {
+			cost = c
+		}
+	}
+	h.lock.RLock()
+	dummy := h.dummy
+	h.lock.RUnlock()
+	if c, err := bcrypt.Cost(dummy); err != nil || c != cost {
+		if dummy, err = bcrypt.GenerateFromPassword([]byte("dummy"), cost); err != nil {
+			return err
+		}
+	}
+
+	h.lock.Lock()
+	h.users = users
+	h.dummy = dummy
+	h.lock.Unlock()
+	return nil
+}
+
+func (h *htpasswd) validate(user, password string) (found, ok bool) {
+	h.lock.RLock()
+	hash, found := h.users[user]
+	dummy := h.dummy
+	h.lock.RUnlock()
+	if !found {
+		bcrypt.CompareHashAndPassword(dummy, []byte(password))
+		return false, false
+	}
+	return true, bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// ParseBasic returns credentials of HTTP Basic authorization header.
+func ParseBasic(header string) (user, password string, ok bool) {
+	const prefix = "Basic "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len(prefix):]))
+	if err != nil {
+		return "", "", false
+	}
+	i := bytes.IndexByte(data, ':')
+	if i < 0 {
+		return "", "", false
+	}
+	return string(data[:i]), string(data[i+1:]), true
+}
+
+// Basic returns a middleware handler that authenticates requests by HTTP Basic.
+// It does nothing if principal is already authenticated by previous handlers.
+func Basic(opt BasicOptions) web.Handler {
+	if len(opt.Realm) == 0 {
+		opt.Realm = "Restricted"
+	}
+	challenge := fmt.Sprintf("Basic realm=%q", opt.Realm)
+
+	var (
+		hp   *htpasswd
+		file *watchedFile
+	)
+	if len(opt.Htpasswd) > 0 {
+		hp = &htpasswd{}
+		var err error
+		if file, err = newWatchedFile(opt.Htpasswd, hp.load); err != nil {
+			panic("auth: fail to load htpasswd: " + err.Error())
+		}
+	}
+
+	validate := func(user, password string) bool {
+		if expected, ok := opt.Users[user]; ok {
+			return secureCompare(password, expected)
+		}
+		if hp != nil {
+			if found, ok := hp.validate(user, password); found {
+				return ok
+			}
+		}
+		return opt.Validate != nil && opt.Validate(user, password)
+	}
+
+	h := func(ctx *web.Context) {
+		if GetPrincipal(ctx) != nil {
+			return
+		}
+		header := ctx.Req.Header.Get("Authorization")
+		if len(header) == 0 && opt.Optional {
+			return
+		}
+
+		if file != nil {
+			if err := file.reload(false); err != nil {
+				ctx.Printf("auth: fail to reload htpasswd: %v", err)
+			}
+		}
+		user, password, ok := ParseBasic(header)
+		if !ok || !validate(user, password) {
+			unauthorized(ctx, challenge, opt.ErrorFunc)
+			return
+		}
+
+		p := &Principal{Name: user, Method: METHOD_BASIC}
+		if opt.Roles != nil {
+			p.Roles = opt.Roles(user)
+		}
+		ctx.Map(p)
+	}
+	return declare(h, opt.Optional)
+}
@@ -0,0 +1,482 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"landzero.net/x/net/web"
+)
+
+// Signing algorithms of JSON Web Token.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	ErrTokenMalformed   = errors.New("auth: token is malformed")
+	ErrTokenAlgorithm   = errors.New("auth: token algorithm is not allowed")
+	ErrTokenNoKey       = errors.New("auth: no key to verify token")
+	ErrTokenSignature   = errors.New("auth: token signature is invalid")
+	ErrTokenExpired     = errors.New("auth: token is expired")
+	ErrTokenNotValidYet = errors.New("auth: token is not valid yet")
+	ErrTokenIssuer      = errors.New("auth: token issuer is invalid")
+	ErrTokenAudience    = errors.New("auth: token audience is invalid")
+)
+
+// Claims are claims of JSON Web Token.
+type Claims map[string]interface{}
+
+// String returns claim as string.
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns claim as strings, a string claim is split by spaces, e.g. "scope".
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		vals := make([]string, 0, len(v))
+		for _, val := range v {
+			if s, ok := val.(string); ok {
+				vals = append(vals, s)
+			}
+		}
+		return vals
+	}
+	return nil
+}
+
+// Time returns claim of NumericDate, e.g. "exp".
+func (c Claims) Time(name string) (time.Time, bool) {
+	switch v := c[name].(type) {
+	case float64:
+		return unixTime(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return unixTime(f), err == nil
+	case int64:
+		return time.Unix(v, 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	}
+	return time.Time{}, false
+}
+
+// unixTime converts NumericDate to time, in nanoseconds it would overflow after year 2262.
+func unixTime(v float64) time.Time {
+	sec, frac := math.Modf(v)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// JWTOptions represents a struct for specifying configuration options for the JWT middleware.
+type JWTOptions struct {
+	// Secret is the key of HS256 for tokens without key ID.
+	Secret []byte
+	// Keys are verification keys by key ID: []byte for HS256, *rsa.PublicKey for RS256
+	// and ed25519.PublicKey for EdDSA. Tokens without key ID are verified by all of them.
+	Keys map[string]interface{}
+	// JWKS is path of JSON Web Key Set file, it's reloaded when modified so keys can be
+	// rotated without restart. RSA, Ed25519 and symmetric keys are supported.
+	JWKS string
+	// Algorithms are allowed algorithms. Default is HS256, RS256 and EdDSA.
+	Algorithms []string
+	// Issuer is the required "iss" claim if it's not empty.
+	Issuer string
+	// Audience is required to be in "aud" claim if it's not empty.
+	Audience string
+	// Leeway is allowed clock skew of "exp" and "nbf" claims.
+	Leeway time.Duration
+	// RolesClaim is the claim of roles. Default is "roles".
+	RolesClaim string
+	// Query parameter carrying token when Authorization header is absent, empty disables it.
+	Query string
+	// Realm of authentication. Default is "Restricted".
+	Realm string
+	// Optional allows requests without token to pass without principal.
+	Optional bool
+	// ErrorFunc is called when authentication fails. Default responds 401 with challenge.
+	ErrorFunc func(*web.Context)
+}
+
+// JWTVerifier verifies JSON Web Tokens.
+type JWTVerifier struct {
+	opt  JWTOptions
+	file *watchedFile
+
+	lock sync.RWMutex
+	jwks map[string]interface{}
+}
+
+// NewJWTVerifier creates and returns a verifier, JWKS file is loaded if it's set.
+func NewJWTVerifier(opt JWTOptions) (*JWTVerifier, error) {
+	if len(opt.Algorithms) == 0 {
+		opt.Algorithms = []string{HS256, RS256, EdDSA}
+	}
+	if len(opt.RolesClaim) == 0 {
+		opt.RolesClaim = "roles"
+	}
+	if len(opt.Realm) == 0 {
+		opt.Realm = "Restricted"
+	}
+
+	v := &JWTVerifier{opt: opt}
+	if len(opt.JWKS) > 0 {
+		var err error
+		if v.file, err = newWatchedFile(opt.JWKS, v.loadJWKS); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (v *JWTVerifier) loadJWKS(data []byte) error {
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	v.lock.Lock()
+	v.jwks = keys
+	v.lock.Unlock()
+	return nil
+}
+
+// Reload reloads JWKS file if it's modified.
+func (v *JWTVerifier) Reload() error {
+	if v.file == nil {
+		return nil
+	}
+	return v.file.reload(false)
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// keys returns candidate keys for algorithm and key ID.
+func (v *JWTVerifier) keys(alg, kid string) []interface{} {
+	var keys []interface{}
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	if len(kid) > 0 {
+		if key, ok := v.opt.Keys[kid]; ok {
+			keys = append(keys, key)
+		}
+		if key, ok := v.jwks[kid]; ok {
+			keys = append(keys, key)
+		}
+	} else {
+		if alg == HS256 && len(v.opt.Secret) > 0 {
+			keys = append(keys, v.opt.Secret)
+		}
+		for _, key := range v.opt.Keys {
+			keys = append(keys, key)
+		}
+		for _, key := range v.jwks {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Verify verifies signature and claims of token, and returns its claims.
+func (v *JWTVerifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	allowed := false
+	for _, alg := range v.opt.Algorithms {
+		if alg == header.Alg {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, ErrTokenAlgorithm
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	found, verified := false, false
+	for _, key := range v.keys(header.Alg, header.Kid) {
+		ok, match := verifySignature(header.Alg, key, signed, sig)
+		found = found || match
+		if ok {
+			verified = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrTokenNoKey
+	} else if !verified {
+		return nil, ErrTokenSignature
+	}
+
+	var claims Claims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	return claims, v.validate(claims)
+}
+
+func (v *JWTVerifier) validate(claims Claims) error {
+	now := time.Now()
+	if exp, ok := claims.Time("exp"); ok && !now.Before(exp.Add(v.opt.Leeway)) {
+		return ErrTokenExpired
+	}
+	if nbf, ok := claims.Time("nbf"); ok && now.Before(nbf.Add(-v.opt.Leeway)) {
+		return ErrTokenNotValidYet
+	}
+	if len(v.opt.Issuer) > 0 && claims.String("iss") != v.opt.Issuer {
+		return ErrTokenIssuer
+	}
+	if len(v.opt.Audience) > 0 {
+		matched := false
+		for _, aud := range claims.Strings("aud") {
+			if aud == v.opt.Audience {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return ErrTokenAudience
+		}
+	}
+	return nil
+}
+
+// verifySignature returns whether signature is valid, and whether key type matches algorithm.
+func verifySignature(alg string, key interface{}, signed, sig []byte) (ok, match bool) {
+	switch alg {
+	case HS256:
+		secret, match := key.([]byte)
+		if !match {
+			return false, false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return hmac.Equal(sig, mac.Sum(nil)), true
+	case RS256:
+		pub, match := key.(*rsa.PublicKey)
+		if !match {
+			return false, false
+		}
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil, true
+	case EdDSA:
+		pub, match := key.(ed25519.PublicKey)
+		if !match {
+			return false, false
+		}
+		return ed25519.Verify(pub, signed, sig), true
+	}
+	return false, false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func encodeSegment(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// SignJWT signs claims by key and returns the token, key is []byte for HS256,
+// *rsa.PrivateKey for RS256 and ed25519.PrivateKey for EdDSA. Key ID is optional.
+func SignJWT(claims Claims, alg, kid string, key interface{}) (string, error) {
+	header, err := encodeSegment(jwtHeader{Alg: alg, Kid: kid, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := encodeSegment(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + payload
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		if alg != HS256 {
+			return "", fmt.Errorf("auth: key type %T mismatches algorithm %s", key, alg)
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if alg != RS256 {
+			return "", fmt.Errorf("auth: key type %T mismatches algorithm %s", key, alg)
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	case ed25519.PrivateKey:
+		if alg != EdDSA {
+			return "", fmt.Errorf("auth: key type %T mismatches algorithm %s", key, alg)
+		}
+		sig = ed25519.Sign(k, []byte(signed))
+	default:
+		return "", fmt.Errorf("auth: unsupported key type %T", key)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses JSON Web Key Set and returns verification keys by key ID.
+// Keys which are not for signature or of unsupported types are ignored.
+func ParseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for i, k := range set.Keys {
+		if len(k.Use) > 0 && k.Use != "sig" {
+			continue
+		}
+		kid := k.Kid
+		if len(kid) == 0 {
+			kid = fmt.Sprintf("#%d", i)
+		}
+
+		switch {
+		case k.Kty == "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("auth: invalid RSA key '%s': %v", kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("auth: invalid RSA key '%s'", kid)
+			}
+			keys[kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case k.Kty == "OKP" && k.Crv == "Ed25519":
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("auth: invalid Ed25519 key '%s'", kid)
+			}
+			keys[kid] = ed25519.PublicKey(x)
+		case k.Kty == "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil {
+				return nil, fmt.Errorf("auth: invalid symmetric key '%s': %v", kid, err)
+			}
+			keys[kid] = secret
+		}
+	}
+	return keys, nil
+}
+
+// JWT returns a middleware handler that authenticates requests by JSON Web Token from
+// "Authorization: Bearer" header. It does nothing if principal is already authenticated
+// by previous handlers.
+func JWT(opt JWTOptions) web.Handler {
+	v, err := NewJWTVerifier(opt)
+	if err != nil {
+		panic("auth: " + err.Error())
+	}
+	opt = v.opt
+	challenge := fmt.Sprintf("Bearer realm=%q", opt.Realm)
+
+	h := func(ctx *web.Context) {
+		if GetPrincipal(ctx) != nil {
+			return
+		}
+		var token string
+		if header := ctx.Req.Header.Get("Authorization"); len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+			token = strings.TrimSpace(header[7:])
+		} else if len(opt.Query) > 0 {
+			token = ctx.Query(opt.Query)
+		}
+		if len(token) == 0 {
+			if !opt.Optional {
+				unauthorized(ctx, challenge, opt.ErrorFunc)
+			}
+			return
+		}
+
+		if err := v.Reload(); err != nil {
+			ctx.Printf("auth: fail to reload JWKS: %v", err)
+		}
+		claims, err := v.Verify(token)
+		if err != nil {
+			unauthorized(ctx, challenge+`, error="invalid_token"`, opt.ErrorFunc)
+			return
+		}
+		ctx.Map(&Principal{
+			Name:   claims.String("sub"),
+			Roles:  claims.Strings(opt.RolesClaim),
+			Method: METHOD_JWT,
+			Claims: claims,
+		})
+	}
+	return declare(h, opt.Optional)
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func writeJWKS(path string, keys ...map[string]string) {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	So(err, ShouldBeNil)
+	So(ioutil.WriteFile(path, data, 0600), ShouldBeNil)
+}
+
+func Test_JWTVerifier(t *testing.T) {
+	Convey("Verify tokens", t, func() {
+		secret := []byte("secret")
+		v, err := NewJWTVerifier(JWTOptions{Secret: secret, Issuer: "iss", Audience: "api", Leeway: time.Minute})
+		So(err, ShouldBeNil)
+		now := time.Now().Unix()
+
+		token, err := SignJWT(Claims{"sub": "alice", "iss": "iss", "aud": []string{"api", "web"}, "exp": now + 60}, HS256, "", secret)
+		So(err, ShouldBeNil)
+		claims, err := v.Verify(token)
+		So(err, ShouldBeNil)
+		So(claims.String("sub"), ShouldEqual, "alice")
+
+		check := func(claims Claims, expected error) {
+			token, err := SignJWT(claims, HS256, "", secret)
+			So(err, ShouldBeNil)
+			_, err = v.Verify(token)
+			So(err, ShouldEqual, expected)
+		}
+		check(Claims{"iss": "iss", "aud": "api", "exp": now - 30}, nil)
+		check(Claims{"iss": "iss", "aud": "api", "exp": now - 120}, ErrTokenExpired)
+		check(Claims{"iss": "iss", "aud": "api", "exp": 9999999999}, nil)
+		check(Claims{"iss": "iss", "aud": "api", "nbf": now + 120}, ErrTokenNotValidYet)
+		check(Claims{"iss": "other", "aud": "api"}, ErrTokenIssuer)
+		check(Claims{"iss": "iss", "aud": "web"}, ErrTokenAudience)
+
+		token, err = SignJWT(Claims{"iss": "iss", "aud": "api"}, HS256, "", []byte("wrong"))
+		So(err, ShouldBeNil)
+		_, err = v.Verify(token)
+		So(err, ShouldEqual, ErrTokenSignature)
+
+		_, err = v.Verify("a.b")
+		So(err, ShouldEqual, ErrTokenMalformed)
+		_, err = v.Verify(b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{}`)) + ".")
+		So(err, ShouldEqual, ErrTokenAlgorithm)
+
+		_, err = SignJWT(Claims{}, RS256, "", secret)
+		So(err, ShouldNotBeNil)
+
+		exp, ok := Claims{"exp": 9999999999.5}.Time("exp")
+		So(ok, ShouldBeTrue)
+		So(exp.Equal(time.Unix(9999999999, 5e8)), ShouldBeTrue)
+	})
+
+	Convey("Verify RS256 and EdDSA tokens with rotated JWKS", t, func() {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		So(err, ShouldBeNil)
+		edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+		So(err, ShouldBeNil)
+		_, newKey, err := ed25519.GenerateKey(rand.Reader)
+		So(err, ShouldBeNil)
+
+		dir, err := ioutil.TempDir("", "auth")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "jwks.json")
+		writeJWKS(path,
+			map[string]string{"kty": "RSA", "kid": "rsa1", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": "ed1", "x": b64(edPub)},
+			map[string]string{"kty": "oct", "kid": "enc", "use": "enc", "k": b64([]byte("x"))},
+		)
+
+		v, err := NewJWTVerifier(JWTOptions{JWKS: path})
+		So(err, ShouldBeNil)
+
+		token, err := SignJWT(Claims{"sub": "rsa"}, RS256, "rsa1", rsaKey)
+		So(err, ShouldBeNil)
+		claims, err := v.Verify(token)
+		So(err, ShouldBeNil)
+		So(claims.String("sub"), ShouldEqual, "rsa")
+
+		token, err = SignJWT(Claims{"sub": "ed"}, EdDSA, "ed1", edKey)
+		So(err, ShouldBeNil)
+		_, err = v.Verify(token)
+		So(err, ShouldBeNil)
+
+		// Algorithm confusion: key of kid is not a HMAC secret.
+		token, err = SignJWT(Claims{}, HS256, "ed1", []byte(edPub))
+		So(err, ShouldBeNil)
+		_, err = v.Verify(token)
+		So(err, ShouldEqual, ErrTokenNoKey)
+
+		// Rotate keys.
+		writeJWKS(path, map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": "ed2", "x": b64(newKey.Public().(ed25519.PublicKey))})
+		future := time.Now().Add(time.Minute)
+		So(os.Chtimes(path, future, future), ShouldBeNil)
+		time.Sleep(1100 * time.Millisecond)
+		So(v.Reload(), ShouldBeNil)
+
+		_, err = v.Verify(token)
+		So(err, ShouldEqual, ErrTokenNoKey)
+		token, err = SignJWT(Claims{"sub": "ed"}, EdDSA, "ed2", newKey)
+		So(err, ShouldBeNil)
+		_, err = v.Verify(token)
+		So(err, ShouldBeNil)
+	})
+}
+
+func Test_JWT(t *testing.T) {
+	Convey("Authenticate by JWT", t, func() {
+		secret := []byte("secret")
+		m := web.New()
+		m.Group("/api", func() {
+			m.Get("/me", func(p *Principal) string { return p.Name + ":" + p.Claims.String("email") })
+			m.Get("/admin", RequireRoles("admin"), func() string { return "admin" })
+		}, JWT(JWTOptions{Secret: secret, Query: "access_token", RolesClaim: "scope"}))
+
+		token, err := SignJWT(Claims{"sub": "alice", "email": "a@example.com", "scope": "read admin"}, HS256, "", secret)
+		So(err, ShouldBeNil)
+		bearer := http.Header{"Authorization": {"Bearer " + token}}
+
+		So(do(m, "/api/me", bearer).Body.String(), ShouldEqual, "alice:a@example.com")
+		So(do(m, "/api/me?access_token="+token, nil).Body.String(), ShouldEqual, "alice:a@example.com")
+		So(do(m, "/api/admin", bearer).Body.String(), ShouldEqual, "admin")
+
+		resp := do(m, "/api/me", nil)
+		So(resp.Code, ShouldEqual, http.StatusUnauthorized)
+		So(resp.Header().Get("WWW-Authenticate"), ShouldEqual, `Bearer realm="Restricted"`)
+
+		resp = do(m, "/api/me", http.Header{"Authorization": {"Bearer bad"}})
+		So(resp.Code, ShouldEqual, http.StatusUnauthorized)
+		So(resp.Header().Get("WWW-Authenticate"), ShouldContainSubstring, `error="invalid_token"`)
+
+		token, err = SignJWT(Claims{"sub": "bob", "scope": "read"}, HS256, "", secret)
+		So(err, ShouldBeNil)
+		So(do(m, "/api/admin", http.Header{"Authorization": {"Bearer " + token}}).Code, ShouldEqual, http.StatusForbidden)
+	})
+}
@@ -18,6 +18,7 @@ package binding
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -40,17 +41,19 @@ func Version() string {
 }
 
 func bind(ctx *web.Context, obj interface{}, ifacePtr ...interface{}) {
+	var errors Errors
+	ensureNotPointer(obj)
+	bindStruct := reflect.New(reflect.TypeOf(obj))
 	contentType := ctx.Req.Header.Get("Content-Type")
 	if ctx.Req.Method == "POST" || ctx.Req.Method == "PUT" || len(contentType) > 0 {
 		switch {
 		case strings.Contains(contentType, "form-urlencoded"):
-			ctx.Invoke(Form(obj, ifacePtr...))
+			errors = readForm(ctx, bindStruct, errors)
 		case strings.Contains(contentType, "multipart/form-data"):
-			ctx.Invoke(MultipartForm(obj, ifacePtr...))
+			errors = readMultipartForm(ctx, bindStruct, errors)
 		case strings.Contains(contentType, "json"):
-			ctx.Invoke(Json(obj, ifacePtr...))
+			errors = readJson(ctx, bindStruct, errors)
 		default:
-			var errors Errors
 			if contentType == "" {
 				errors.Add([]string{}, ERR_CONTENT_TYPE, "Empty Content-Type")
 			} else {
@@ -58,10 +61,13 @@ func bind(ctx *web.Context, obj interface{}, ifacePtr ...interface{}) {
 			}
 			ctx.Map(errors)
 			ctx.Map(obj) // Map a fake struct so handler won't panic.
+			return
 		}
 	} else {
-		ctx.Invoke(Form(obj, ifacePtr...))
+		errors = readForm(ctx, bindStruct, errors)
 	}
+	errors = readSources(ctx, bindStruct, errors)
+	validateAndMap(bindStruct, ctx, errors, ifacePtr...)
 }
 
 const (
@@ -81,7 +87,9 @@ const (
 func errorHandler(errs Errors, rw http.ResponseWriter) {
 	if len(errs) > 0 {
 		rw.Header().Set("Content-Type", _JSON_CONTENT_TYPE)
-		if errs.Has(ERR_DESERIALIZATION) {
+		if errs.Has(ERR_BODY_TOO_LARGE) {
+			rw.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else if errs.Has(ERR_DESERIALIZATION) {
 			rw.WriteHeader(http.StatusBadRequest)
 		} else if errs.Has(ERR_CONTENT_TYPE) {
 			rw.WriteHeader(http.StatusUnsupportedMediaType)
@@ -94,8 +102,55 @@ func errorHandler(errs Errors, rw http.ResponseWriter) {
 	}
 }
 
+// Sources of request data that binders read from.
+const (
+	SOURCE_BIND      = "bind" // Form, multipart form or JSON depends on Content-Type.
+	SOURCE_FORM      = "form"
+	SOURCE_MULTIPART = "multipart"
+	SOURCE_JSON      = "json"
+	SOURCE_QUERY     = "query"
+	SOURCE_HEADER    = "header"
+	SOURCE_PARAM     = "param"
+	SOURCE_COOKIE    = "cookie"
+)
+
+// Binder is the handler returned by binding middlewares. When it's called with
+// a nil context, it does nothing but reports the struct type it binds and the
+// source of data, so that tools like API documentation generators are able to
+// inspect handlers of routes.
+type Binder func(ctx *web.Context) (reflect.Type, string)
+
+// Invoke implements inject.FastInvoker.
+func (b Binder) Invoke(args []interface{}) ([]reflect.Value, error) {
+	b(args[0].(*web.Context))
+	return nil, nil
+}
+
+// Describe returns the struct type and the source of data of the binder.
+func (b Binder) Describe() (reflect.Type, string) {
+	return b(nil)
+}
+
+// ProvidedTypes implements web.Declarer, the struct type and Errors are mapped by the binder.
+func (b Binder) ProvidedTypes() []reflect.Type {
+	typ, _ := b(nil)
+	return []reflect.Type{typ, reflect.TypeOf(Errors{})}
+}
+
+func newBinder(obj interface{}, source string, handler func(*web.Context)) Binder {
+	typ := reflect.TypeOf(obj)
+	return func(ctx *web.Context) (reflect.Type, string) {
+		if ctx == nil {
+			return typ, source
+		}
+		handler(ctx)
+		return nil, ""
+	}
+}
+
 // Bind wraps up the functionality of the Form and Json middleware
-// according to the Content-Type and verb of the request.
+// according to the Content-Type and verb of the request, then fills
+// fields tagged with query, header, param and cookie from those sources.
 // A Content-Type is required for POST and PUT requests.
 // Bind invokes the ErrorHandler middleware to bail out if errors
 // occurred. If you want to perform your own error handling, use
@@ -103,23 +158,23 @@ func errorHandler(errs Errors, rw http.ResponseWriter) {
 // be added as a second argument in order to map the struct to
 // a specific interface.
 func Bind(obj interface{}, ifacePtr ...interface{}) web.Handler {
-	return func(ctx *web.Context) {
+	return newBinder(obj, SOURCE_BIND, func(ctx *web.Context) {
 		bind(ctx, obj, ifacePtr...)
 		if handler, ok := obj.(ErrorHandler); ok {
 			ctx.Invoke(handler.Error)
 		} else {
 			ctx.Invoke(errorHandler)
 		}
-	}
+	})
 }
 
 // BindIgnErr will do the exactly same thing as Bind but without any
 // error handling, which user has freedom to deal with them.
 // This allows user take advantages of validation.
 func BindIgnErr(obj interface{}, ifacePtr ...interface{}) web.Handler {
-	return func(ctx *web.Context) {
+	return newBinder(obj, SOURCE_BIND, func(ctx *web.Context) {
 		bind(ctx, obj, ifacePtr...)
-	}
+	})
 }
 
 // Form is middleware to deserialize form-urlencoded data from the request.
@@ -132,22 +187,37 @@ func BindIgnErr(obj interface{}, ifacePtr ...interface{}) web.Handler {
 // An interface pointer can be added as a second argument in order
 // to map the struct to a specific interface.
 func Form(formStruct interface{}, ifacePtr ...interface{}) web.Handler {
-	return func(ctx *web.Context) {
+	return newBinder(formStruct, SOURCE_FORM, func(ctx *web.Context) {
 		var errors Errors
-
 		ensureNotPointer(formStruct)
 		formStruct := reflect.New(reflect.TypeOf(formStruct))
-		parseErr := ctx.Req.ParseForm()
-
-		// Format validation of the request body or the URL would add considerable overhead,
-		// and ParseForm does not complain when URL encoding is off.
-		// Because an empty request body or url can also mean absence of all needed values,
-		// it is not in all cases a bad request, so let's return 422.
-		if parseErr != nil {
-			errors.Add([]string{}, ERR_DESERIALIZATION, parseErr.Error())
-		}
-		errors = mapForm(formStruct, ctx.Req.Form, nil, errors)
+		errors = readForm(ctx, formStruct, errors)
 		validateAndMap(formStruct, ctx, errors, ifacePtr...)
+	})
+}
+
+// readForm maps form-urlencoded body and query string into formStruct.
+func readForm(ctx *web.Context, formStruct reflect.Value, errors Errors) Errors {
+	parseErr := ctx.Req.ParseForm()
+
+	// Format validation of the request body or the URL would add considerable overhead,
+	// and ParseForm does not complain when URL encoding is off.
+	// Because an empty request body or url can also mean absence of all needed values,
+	// it is not in all cases a bad request, so let's return 422.
+	if parseErr != nil {
+		addDeserializationError(&errors, parseErr)
+	}
+	return mapForm(formStruct, ctx.Req.Form, nil, errors)
+}
+
+// addDeserializationError adds err of reading request body to errors, it's classified
+// as ERR_BODY_TOO_LARGE if body exceeds the limit of web.BodyLimit.
+func addDeserializationError(errors *Errors, err error) {
+	var me *http.MaxBytesError
+	if stderrors.As(err, &me) {
+		errors.Add([]string{}, ERR_BODY_TOO_LARGE, err.Error())
+	} else {
+		errors.Add([]string{}, ERR_DESERIALIZATION, err.Error())
 	}
 }
 
@@ -160,35 +230,42 @@ var MaxMemory = int64(1024 * 1024 * 10)
 // you can pass in an interface to make the interface available for injection
 // into other handlers later.
 func MultipartForm(formStruct interface{}, ifacePtr ...interface{}) web.Handler {
-	return func(ctx *web.Context) {
+	return newBinder(formStruct, SOURCE_MULTIPART, func(ctx *web.Context) {
 		var errors Errors
 		ensureNotPointer(formStruct)
 		formStruct := reflect.New(reflect.TypeOf(formStruct))
-		// This if check is necessary due to https://github.com/martini-contrib/csrf/issues/6
-		if ctx.Req.MultipartForm == nil {
-			// Workaround for multipart forms returning nil instead of an error
-			// when content is not multipart; see https://code.google.com/p/go/issues/detail?id=6334
-			if multipartReader, err := ctx.Req.MultipartReader(); err != nil {
-				errors.Add([]string{}, ERR_DESERIALIZATION, err.Error())
-			} else {
-				form, parseErr := multipartReader.ReadForm(MaxMemory)
-				if parseErr != nil {
-					errors.Add([]string{}, ERR_DESERIALIZATION, parseErr.Error())
-				}
+		errors = readMultipartForm(ctx, formStruct, errors)
+		validateAndMap(formStruct, ctx, errors, ifacePtr...)
+	})
+}
 
-				if ctx.Req.Form == nil {
-					ctx.Req.ParseForm()
-				}
-				for k, v := range form.Value {
-					ctx.Req.Form[k] = append(ctx.Req.Form[k], v...)
-				}
+// readMultipartForm maps multipart form and files into formStruct.
+func readMultipartForm(ctx *web.Context, formStruct reflect.Value, errors Errors) Errors {
+	// This if check is necessary due to https://github.com/martini-contrib/csrf/issues/6
+	if ctx.Req.MultipartForm == nil {
+		// Workaround for multipart forms returning nil instead of an error
+		// when content is not multipart; see https://code.google.com/p/go/issues/detail?id=6334
+		if multipartReader, err := ctx.Req.MultipartReader(); err != nil {
+			errors.Add([]string{}, ERR_DESERIALIZATION, err.Error())
+			ctx.Req.MultipartForm = &multipart.Form{}
+		} else {
+			form, parseErr := multipartReader.ReadForm(MaxMemory)
+			if parseErr != nil {
+				addDeserializationError(&errors, parseErr)
+				form = &multipart.Form{}
+			}
 
-				ctx.Req.MultipartForm = form
+			if ctx.Req.Form == nil {
+				ctx.Req.ParseForm()
+			}
+			for k, v := range form.Value {
+				ctx.Req.Form[k] = append(ctx.Req.Form[k], v...)
 			}
+
+			ctx.Req.MultipartForm = form
 		}
-		errors = mapForm(formStruct, ctx.Req.MultipartForm.Value, ctx.Req.MultipartForm.File, errors)
-		validateAndMap(formStruct, ctx, errors, ifacePtr...)
 	}
+	return mapForm(formStruct, ctx.Req.MultipartForm.Value, ctx.Req.MultipartForm.File, errors)
 }
 
 // Json is middleware to deserialize a JSON payload from the request
@@ -197,24 +274,30 @@ func MultipartForm(formStruct interface{}, ifacePtr ...interface{}) web.Handler
 // An interface pointer can be added as a second argument in order
 // to map the struct to a specific interface.
 func Json(jsonStruct interface{}, ifacePtr ...interface{}) web.Handler {
-	return func(ctx *web.Context) {
+	return newBinder(jsonStruct, SOURCE_JSON, func(ctx *web.Context) {
 		var errors Errors
 		ensureNotPointer(jsonStruct)
 		jsonStruct := reflect.New(reflect.TypeOf(jsonStruct))
-		if ctx.Req.Request.Body != nil {
-			defer ctx.Req.Request.Body.Close()
-			err := json.NewDecoder(ctx.Req.Request.Body).Decode(jsonStruct.Interface())
-			if err != nil && err != io.EOF {
-				errors.Add([]string{}, ERR_DESERIALIZATION, err.Error())
-			}
-		}
+		errors = readJson(ctx, jsonStruct, errors)
 		validateAndMap(jsonStruct, ctx, errors, ifacePtr...)
+	})
+}
+
+// readJson decodes JSON body into jsonStruct.
+func readJson(ctx *web.Context, jsonStruct reflect.Value, errors Errors) Errors {
+	if ctx.Req.Request.Body != nil {
+		defer ctx.Req.Request.Body.Close()
+		err := json.NewDecoder(ctx.Req.Request.Body).Decode(jsonStruct.Interface())
+		if err != nil && err != io.EOF {
+			addDeserializationError(&errors, err)
+		}
 	}
+	return errors
 }
 
 // RawValidate is same as Validate but does not require a HTTP context,
 // and can be used independently just for validation.
-// This function does not support Validator interface.
+// This function does not support Validator interface and rules added by AddContextRule.
 func RawValidate(obj interface{}) Errors {
 	var errs Errors
 	v := reflect.ValueOf(obj)
@@ -236,11 +319,13 @@ func RawValidate(obj interface{}) Errors {
 
 // Validate is middleware to enforce required fields. If the struct
 // passed in implements Validator, then the user-defined Validate method
-// is executed, and its errors are mapped to the context. This middleware
+// is executed, and its errors are mapped to the context. Rules added by
+// AddContextRule are checked concurrently after other rules. This middleware
 // performs no error handling: it merely detects errors and maps them.
 func Validate(obj interface{}) web.Handler {
 	return func(ctx *web.Context) {
 		var errs Errors
+		val := &validation{ctx: ctx}
 		v := reflect.ValueOf(obj)
 		k := v.Kind()
 		if k == reflect.Interface || k == reflect.Ptr {
@@ -250,18 +335,18 @@ func Validate(obj interface{}) web.Handler {
 		if k == reflect.Slice || k == reflect.Array {
 			for i := 0; i < v.Len(); i++ {
 				e := v.Index(i).Interface()
-				errs = validateStruct(errs, e)
+				errs = val.validateStruct(errs, e)
 				if validator, ok := e.(Validator); ok {
 					errs = validator.Validate(ctx, errs)
 				}
 			}
 		} else {
-			errs = validateStruct(errs, obj)
+			errs = val.validateStruct(errs, obj)
 			if validator, ok := obj.(Validator); ok {
 				errs = validator.Validate(ctx, errs)
 			}
 		}
-		ctx.Map(errs)
+		ctx.Map(val.wait(errs))
 	}
 }
 
@@ -361,8 +446,29 @@ func parseFormName(raw, actual string) string {
 	return nameMapper(raw)
 }
 
+// FormName returns the form key of given struct field, empty string means the field is ignored.
+// Fields bound from other sources are ignored unless they have a form tag.
+func FormName(field reflect.StructField) string {
+	tag := field.Tag.Get("form")
+	if tag == "-" || (len(tag) == 0 && hasSourceTag(field)) {
+		return ""
+	}
+	return parseFormName(field.Name, tag)
+}
+
 // Performs required field checking on a struct
 func validateStruct(errors Errors, obj interface{}) Errors {
+	return (&validation{}).validateStruct(errors, obj)
+}
+
+// validation holds context of validating a request, checks of context rules
+// are deferred until wait is called.
+type validation struct {
+	ctx     *web.Context
+	pending []contextCheck
+}
+
+func (v *validation) validateStruct(errors Errors, obj interface{}) Errors {
 	typ := reflect.TypeOf(obj)
 	val := reflect.ValueOf(obj)
 
@@ -387,14 +493,14 @@ func validateStruct(errors Errors, obj interface{}) Errors {
 		if field.Type.Kind() == reflect.Struct ||
 			(field.Type.Kind() == reflect.Ptr && !reflect.DeepEqual(zero, fieldValue) &&
 				field.Type.Elem().Kind() == reflect.Struct) {
-			errors = validateStruct(errors, fieldValue)
+			errors = v.validateStruct(errors, fieldValue)
 		}
-		errors = validateField(errors, zero, field, fieldVal, fieldValue)
+		errors = v.validateField(errors, val, zero, field, fieldVal, fieldValue)
 	}
 	return errors
 }
 
-func validateField(errors Errors, zero interface{}, field reflect.StructField, fieldVal reflect.Value, fieldValue interface{}) Errors {
+func (v *validation) validateField(errors Errors, parent reflect.Value, zero interface{}, field reflect.StructField, fieldVal reflect.Value, fieldValue interface{}) Errors {
 	if fieldVal.Kind() == reflect.Slice {
 		for i := 0; i < fieldVal.Len(); i++ {
 			sliceVal := fieldVal.Index(i)
@@ -407,7 +513,7 @@ func validateField(errors Errors, zero interface{}, field reflect.StructField, f
 			if sliceVal.Kind() == reflect.Struct ||
 				(sliceVal.Kind() == reflect.Ptr && !reflect.DeepEqual(zero, sliceValue) &&
 					sliceVal.Elem().Kind() == reflect.Struct) {
-				errors = validateStruct(errors, sliceValue)
+				errors = v.validateStruct(errors, sliceValue)
 			}
 			/* Apply validation rules to each item in a slice. ISSUE #3
 			else {
@@ -415,9 +521,15 @@ func validateField(errors Errors, zero interface{}, field reflect.StructField, f
 			}*/
 		}
 	}
+	return v.validateRules(errors, parent, field, field.Name, strings.Split(field.Tag.Get("binding"), ";"), zero, fieldVal, fieldValue)
+}
 
+// validateRules applies rules to value of a field, or an element of the field
+// after Dive, parent is the struct holding the field.
+func (v *validation) validateRules(errors Errors, parent reflect.Value, field reflect.StructField, name string, rules []string,
+	zero interface{}, fieldVal reflect.Value, fieldValue interface{}) Errors {
 VALIDATE_RULES:
-	for _, rule := range strings.Split(field.Tag.Get("binding"), ";") {
+	for i, rule := range rules {
 		if len(rule) == 0 {
 			continue
 		}
@@ -428,61 +540,64 @@ VALIDATE_RULES:
 				break VALIDATE_RULES
 			}
 		case rule == "Required":
-			v := reflect.ValueOf(fieldValue)
-			if v.Kind() == reflect.Slice {
-				if v.Len() == 0 {
-					errors.Add([]string{field.Name}, ERR_REQUIRED, "Required")
-					break VALIDATE_RULES
-				}
-
-				continue
+			if isEmpty(zero, fieldValue) {
+				errors.addRule(name, ERR_REQUIRED, rule)
+				break VALIDATE_RULES
 			}
-
-			if reflect.DeepEqual(zero, fieldValue) {
-				errors.Add([]string{field.Name}, ERR_REQUIRED, "Required")
+		case strings.HasPrefix(rule, "RequiredIf("), strings.HasPrefix(rule, "RequiredUnless("),
+			strings.HasPrefix(rule, "RequiredWith("):
+			if requiredBy(parent, rule) && isEmpty(zero, fieldValue) {
+				errors.addRule(name, ERR_REQUIRED, rule)
+				break VALIDATE_RULES
+			}
+		case rule == "Dive":
+			errors = v.dive(errors, parent, field, name, rules[i+1:], fieldVal)
+			break VALIDATE_RULES
+		case strings.HasPrefix(rule, "EqField("), strings.HasPrefix(rule, "NeField("),
+			strings.HasPrefix(rule, "GtField("), strings.HasPrefix(rule, "GteField("),
+			strings.HasPrefix(rule, "LtField("), strings.HasPrefix(rule, "LteField("):
+			if kind, ok := compareField(parent, rule, fieldVal); !ok {
+				errors.addRule(name, kind, rule)
 				break VALIDATE_RULES
 			}
 		case rule == "AlphaDash":
 			if AlphaDashPattern.MatchString(fmt.Sprintf("%v", fieldValue)) {
-				errors.Add([]string{field.Name}, ERR_ALPHA_DASH, "AlphaDash")
+				errors.addRule(name, ERR_ALPHA_DASH, rule)
 				break VALIDATE_RULES
 			}
 		case rule == "AlphaDashDot":
 			if AlphaDashDotPattern.MatchString(fmt.Sprintf("%v", fieldValue)) {
-				errors.Add([]string{field.Name}, ERR_ALPHA_DASH_DOT, "AlphaDashDot")
+				errors.addRule(name, ERR_ALPHA_DASH_DOT, rule)
 				break VALIDATE_RULES
 			}
 		case strings.HasPrefix(rule, "Size("):
 			size, _ := strconv.Atoi(rule[5 : len(rule)-1])
 			if str, ok := fieldValue.(string); ok && utf8.RuneCountInString(str) != size {
-				errors.Add([]string{field.Name}, ERR_SIZE, "Size")
+				errors.addRule(name, ERR_SIZE, rule)
 				break VALIDATE_RULES
 			}
-			v := reflect.ValueOf(fieldValue)
-			if v.Kind() == reflect.Slice && v.Len() != size {
-				errors.Add([]string{field.Name}, ERR_SIZE, "Size")
+			if v := reflect.ValueOf(fieldValue); v.Kind() == reflect.Slice && v.Len() != size {
+				errors.addRule(name, ERR_SIZE, rule)
 				break VALIDATE_RULES
 			}
 		case strings.HasPrefix(rule, "MinSize("):
 			min, _ := strconv.Atoi(rule[8 : len(rule)-1])
 			if str, ok := fieldValue.(string); ok && utf8.RuneCountInString(str) < min {
-				errors.Add([]string{field.Name}, ERR_MIN_SIZE, "MinSize")
+				errors.addRule(name, ERR_MIN_SIZE, rule)
 				break VALIDATE_RULES
 			}
-			v := reflect.ValueOf(fieldValue)
-			if v.Kind() == reflect.Slice && v.Len() < min {
-				errors.Add([]string{field.Name}, ERR_MIN_SIZE, "MinSize")
+			if v := reflect.ValueOf(fieldValue); v.Kind() == reflect.Slice && v.Len() < min {
+				errors.addRule(name, ERR_MIN_SIZE, rule)
 				break VALIDATE_RULES
 			}
 		case strings.HasPrefix(rule, "MaxSize("):
 			max, _ := strconv.Atoi(rule[8 : len(rule)-1])
 			if str, ok := fieldValue.(string); ok && utf8.RuneCountInString(str) > max {
-				errors.Add([]string{field.Name}, ERR_MAX_SIZE, "MaxSize")
+				errors.addRule(name, ERR_MAX_SIZE, rule)
 				break VALIDATE_RULES
 			}
-			v := reflect.ValueOf(fieldValue)
-			if v.Kind() == reflect.Slice && v.Len() > max {
-				errors.Add([]string{field.Name}, ERR_MAX_SIZE, "MaxSize")
+			if v := reflect.ValueOf(fieldValue); v.Kind() == reflect.Slice && v.Len() > max {
+				errors.addRule(name, ERR_MAX_SIZE, rule)
 				break VALIDATE_RULES
 			}
 		case strings.HasPrefix(rule, "Range("):
@@ -492,12 +607,12 @@ VALIDATE_RULES:
 			}
 			val := com.StrTo(fmt.Sprintf("%v", fieldValue)).MustInt()
 			if val < com.StrTo(nums[0]).MustInt() || val > com.StrTo(nums[1]).MustInt() {
-				errors.Add([]string{field.Name}, ERR_RANGE, "Range")
+				errors.addRule(name, ERR_RANGE, rule)
 				break VALIDATE_RULES
 			}
 		case rule == "Email":
 			if !EmailPattern.MatchString(fmt.Sprintf("%v", fieldValue)) {
-				errors.Add([]string{field.Name}, ERR_EMAIL, "Email")
+				errors.addRule(name, ERR_EMAIL, rule)
 				break VALIDATE_RULES
 			}
 		case rule == "Url":
@@ -505,35 +620,35 @@ VALIDATE_RULES:
 			if len(str) == 0 {
 				continue
 			} else if !isURL(str) {
-				errors.Add([]string{field.Name}, ERR_URL, "Url")
+				errors.addRule(name, ERR_URL, rule)
 				break VALIDATE_RULES
 			}
 		case strings.HasPrefix(rule, "In("):
 			if !in(fieldValue, rule[3:len(rule)-1]) {
-				errors.Add([]string{field.Name}, ERR_IN, "In")
+				errors.addRule(name, ERR_IN, rule)
 				break VALIDATE_RULES
 			}
 		case strings.HasPrefix(rule, "NotIn("):
 			if in(fieldValue, rule[6:len(rule)-1]) {
-				errors.Add([]string{field.Name}, ERR_NOT_INT, "NotIn")
+				errors.addRule(name, ERR_NOT_INT, rule)
 				break VALIDATE_RULES
 			}
 		case strings.HasPrefix(rule, "Include("):
 			if !strings.Contains(fmt.Sprintf("%v", fieldValue), rule[8:len(rule)-1]) {
-				errors.Add([]string{field.Name}, ERR_INCLUDE, "Include")
+				errors.addRule(name, ERR_INCLUDE, rule)
 				break VALIDATE_RULES
 			}
 		case strings.HasPrefix(rule, "Exclude("):
 			if strings.Contains(fmt.Sprintf("%v", fieldValue), rule[8:len(rule)-1]) {
-				errors.Add([]string{field.Name}, ERR_EXCLUDE, "Exclude")
+				errors.addRule(name, ERR_EXCLUDE, rule)
 				break VALIDATE_RULES
 			}
 		case strings.HasPrefix(rule, "Default("):
 			if reflect.DeepEqual(zero, fieldValue) {
 				if fieldVal.CanAddr() {
-					errors = setWithProperType(field.Type.Kind(), rule[8:len(rule)-1], fieldVal, field.Tag.Get("form"), errors)
+					errors = setWithProperType(fieldVal.Kind(), rule[8:len(rule)-1], fieldVal, field.Tag.Get("form"), errors)
 				} else {
-					errors.Add([]string{field.Name}, ERR_EXCLUDE, "Default")
+					errors.addRule(name, ERR_EXCLUDE, rule)
 					break VALIDATE_RULES
 				}
 			}
@@ -542,7 +657,7 @@ VALIDATE_RULES:
 			var isValid bool
 			for i := range ruleMapper {
 				if ruleMapper[i].IsMatch(rule) {
-					isValid, errors = ruleMapper[i].IsValid(errors, field.Name, fieldValue)
+					isValid, errors = ruleMapper[i].IsValid(errors, name, fieldValue)
 					if !isValid {
 						break VALIDATE_RULES
 					}
@@ -550,12 +665,17 @@ VALIDATE_RULES:
 			}
 			for i := range paramRuleMapper {
 				if paramRuleMapper[i].IsMatch(rule) {
-					isValid, errors = paramRuleMapper[i].IsValid(errors, rule, field.Name, fieldValue)
+					isValid, errors = paramRuleMapper[i].IsValid(errors, rule, name, fieldValue)
 					if !isValid {
 						break VALIDATE_RULES
 					}
 				}
 			}
+			for i := range contextRuleMapper {
+				if contextRuleMapper[i].IsMatch(rule) {
+					v.check(contextRuleMapper[i], rule, name, fieldValue)
+				}
+			}
 		}
 	}
 	return errors
@@ -588,6 +708,14 @@ func SetNameMapper(nm NameMapper) {
 // Takes values from the form data and puts them into a struct
 func mapForm(formStruct reflect.Value, form map[string][]string,
 	formfile map[string][]*multipart.FileHeader, errors Errors) Errors {
+	return mapFormPrefix(formStruct, "", form, formfile, errors)
+}
+
+// mapFormPrefix maps form keys with given prefix into a struct, fields of nested
+// structs are mapped from both the same keys and keys like "addr.city", elements
+// of slices of structs are mapped from keys like "items[0].name".
+func mapFormPrefix(formStruct reflect.Value, prefix string, form map[string][]string,
+	formfile map[string][]*multipart.FileHeader, errors Errors) Errors {
 
 	if formStruct.Kind() == reflect.Ptr {
 		formStruct = formStruct.Elem()
@@ -597,35 +725,52 @@ func mapForm(formStruct reflect.Value, form map[string][]string,
 	for i := 0; i < typ.NumField(); i++ {
 		typeField := typ.Field(i)
 		structField := formStruct.Field(i)
+		inputFieldName := FormName(typeField)
 
 		if typeField.Type.Kind() == reflect.Ptr && typeField.Anonymous {
 			structField.Set(reflect.New(typeField.Type.Elem()))
-			errors = mapForm(structField.Elem(), form, formfile, errors)
+			errors = mapFormPrefix(structField.Elem(), prefix, form, formfile, errors)
 			if reflect.DeepEqual(structField.Elem().Interface(), reflect.Zero(structField.Elem().Type()).Interface()) {
 				structField.Set(reflect.Zero(structField.Type()))
 			}
-		} else if typeField.Type.Kind() == reflect.Struct {
-			errors = mapForm(structField, form, formfile, errors)
+		} else if typeField.Type.Kind() == reflect.Struct && !isDecodable(typeField.Type) {
+			errors = mapFormPrefix(structField, prefix, form, formfile, errors)
+			if len(inputFieldName) > 0 && !typeField.Anonymous && hasFormPrefix(form, formfile, prefix+inputFieldName+".") {
+				errors = mapFormPrefix(structField, prefix+inputFieldName+".", form, formfile, errors)
+			}
+			continue
 		}
 
-		inputFieldName := parseFormName(typeField.Name, typeField.Tag.Get("form"))
 		if len(inputFieldName) == 0 || !structField.CanSet() {
 			continue
 		}
+		inputFieldName = prefix + inputFieldName
 
-		inputValue, exists := form[inputFieldName]
-		if exists {
-			numElems := len(inputValue)
-			if structField.Kind() == reflect.Slice && numElems > 0 {
-				sliceOf := structField.Type().Elem().Kind()
-				slice := reflect.MakeSlice(structField.Type(), numElems, numElems)
-				for i := 0; i < numElems; i++ {
-					errors = setWithProperType(sliceOf, inputValue[i], slice.Index(i), inputFieldName, errors)
+		if elem := structElem(typeField.Type); elem != nil {
+			if typeField.Type.Kind() == reflect.Ptr {
+				if hasFormPrefix(form, formfile, inputFieldName+".") {
+					structField.Set(reflect.New(elem))
+					errors = mapFormPrefix(structField, inputFieldName+".", form, formfile, errors)
 				}
-				formStruct.Field(i).Set(slice)
-			} else {
-				errors = setWithProperType(typeField.Type.Kind(), inputValue[0], structField, inputFieldName, errors)
+				continue
 			}
+			if n := formSliceLen(form, formfile, inputFieldName); n > 0 {
+				slice := reflect.MakeSlice(typeField.Type, n, n)
+				for i := 0; i < n; i++ {
+					item := slice.Index(i)
+					if item.Kind() == reflect.Ptr {
+						item.Set(reflect.New(elem))
+					}
+					errors = mapFormPrefix(item, inputFieldName+"["+strconv.Itoa(i)+"].", form, formfile, errors)
+				}
+				structField.Set(slice)
+			}
+			continue
+		}
+
+		inputValue, exists := form[inputFieldName]
+		if exists {
+			errors = setValues(structField, inputValue, inputFieldName, errors)
 			continue
 		}
 
@@ -724,10 +869,14 @@ func ensureNotPointer(obj interface{}) {
 
 // Performs validation and combines errors from validation
 // with errors from deserialization, then maps both the
-// resulting struct and the errors to the context.
+// resulting struct and the errors to the context. Messages
+// of errors are translated if the context has a Locale.
 func validateAndMap(obj reflect.Value, ctx *web.Context, errors Errors, ifacePtr ...interface{}) {
 	ctx.Invoke(Validate(obj.Interface()))
 	errors = append(errors, getErrors(ctx)...)
+	if ctx.Locale != nil {
+		errors = errors.Translate(ctx.Locale)
+	}
 	ctx.Map(errors)
 	ctx.Map(obj.Elem().Interface())
 	if len(ifacePtr) > 0 {
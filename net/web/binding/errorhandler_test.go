@@ -46,6 +46,20 @@ var errorTestCases = []errorTestCase{
 			body:        `[{"classification":"DeserializationError","message":"Some parser error here"}]`,
 		},
 	},
+	{
+		description: "Body too large error",
+		errors: Errors{
+			{
+				Classification: ERR_BODY_TOO_LARGE,
+				Message:        "http: request body too large",
+			},
+		},
+		expected: errorTestResult{
+			statusCode:  http.StatusRequestEntityTooLarge,
+			contentType: _JSON_CONTENT_TYPE,
+			body:        `[{"classification":"BodyTooLargeError","message":"http: request body too large"}]`,
+		},
+	},
 	{
 		description: "Content-Type error",
 		errors: Errors{
@@ -15,13 +15,21 @@
 
 package binding
 
+import (
+	"strings"
+
+	"landzero.net/x/net/web"
+)
+
 const (
 	// Type mismatch errors.
 	ERR_CONTENT_TYPE    = "ContentTypeError"
 	ERR_DESERIALIZATION = "DeserializationError"
+	ERR_BODY_TOO_LARGE  = "BodyTooLargeError"
 	ERR_INTERGER_TYPE   = "IntegerTypeError"
 	ERR_BOOLEAN_TYPE    = "BooleanTypeError"
 	ERR_FLOAT_TYPE      = "FloatTypeError"
+	ERR_TYPE            = "TypeError"
 
 	// Validation errors.
 	ERR_REQUIRED       = "RequiredError"
@@ -38,6 +46,13 @@ const (
 	ERR_INCLUDE        = "IncludeError"
 	ERR_EXCLUDE        = "ExcludeError"
 	ERR_DEFAULT        = "DefaultError"
+	ERR_PATTERN        = "PatternError"
+	ERR_EQ_FIELD       = "EqFieldError"
+	ERR_NE_FIELD       = "NeFieldError"
+	ERR_GT_FIELD       = "GtFieldError"
+	ERR_GTE_FIELD      = "GteFieldError"
+	ERR_LT_FIELD       = "LtFieldError"
+	ERR_LTE_FIELD      = "LteFieldError"
 )
 
 type (
@@ -66,6 +81,10 @@ type (
 		// an error in the 41st object. The message should help the
 		// end user find and fix the error with their request.
 		Message string `json:"message,omitempty"`
+
+		// param is the parameter of the failed rule, e.g. "20" of
+		// "MaxSize(20)", it's available in translations.
+		param string
 	}
 )
 
@@ -79,6 +98,42 @@ func (e *Errors) Add(fieldNames []string, classification, message string) {
 	})
 }
 
+// addRule adds an error of a validation rule of the field, the
+// message is name of the rule.
+func (e *Errors) addRule(fieldName, classification, rule string) {
+	message, param := rule, ""
+	if i := strings.Index(rule, "("); i > 0 && strings.HasSuffix(rule, ")") {
+		message, param = rule[:i], rule[i+1:len(rule)-1]
+	}
+	*e = append(*e, Error{
+		FieldNames:     []string{fieldName},
+		Classification: classification,
+		Message:        message,
+		param:          param,
+	})
+}
+
+// Translate returns a copy of errors with messages translated by locale.
+// Messages are looked up with keys like "binding.RequiredError", and named
+// arguments "field", "param" and "message" of the original message.
+// Errors without translations are kept unchanged.
+func (e Errors) Translate(l web.Locale) Errors {
+	errs := make(Errors, len(e))
+	for i, err := range e {
+		key := "binding." + err.Classification
+		msg := l.Tr(key, map[string]interface{}{
+			"field":   strings.Join(err.FieldNames, ", "),
+			"param":   err.param,
+			"message": err.Message,
+		})
+		if msg != key {
+			err.Message = msg
+		}
+		errs[i] = err
+	}
+	return errs
+}
+
 // Len returns the number of errors.
 func (e *Errors) Len() int {
 	return len(*e)
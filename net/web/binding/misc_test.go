@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -121,3 +122,35 @@ type Everything struct {
 	Fl64_2     float64 `form:"fl64_2"`
 	Str        string  `form:"str"`
 }
+
+func Test_Binder(t *testing.T) {
+	Convey("Describe binders", t, func() {
+		for source, handler := range map[string]web.Handler{
+			SOURCE_BIND:      Bind(Post{}),
+			SOURCE_FORM:      Form(Post{}),
+			SOURCE_MULTIPART: MultipartForm(Post{}),
+			SOURCE_JSON:      Json(Post{}),
+			SOURCE_QUERY:     Query(Post{}),
+			SOURCE_HEADER:    Header(Post{}),
+			SOURCE_PARAM:     Param(Post{}),
+			SOURCE_COOKIE:    Cookie(Post{}),
+		} {
+			b, ok := handler.(Binder)
+			So(ok, ShouldBeTrue)
+			typ, src := b.Describe()
+			So(typ, ShouldEqual, reflect.TypeOf(Post{}))
+			So(src, ShouldEqual, source)
+		}
+	})
+
+	Convey("Get form name of fields", t, func() {
+		typ := reflect.TypeOf(struct {
+			UserName string
+			Tagged   string `form:"tag"`
+			Ignored  string `form:"-"`
+		}{})
+		So(FormName(typ.Field(0)), ShouldEqual, "user_name")
+		So(FormName(typ.Field(1)), ShouldEqual, "tag")
+		So(FormName(typ.Field(2)), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,301 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package binding
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"landzero.net/x/net/web"
+)
+
+// ContextRule represents a validation rule which needs the request context,
+// e.g. checking uniqueness of a value in database. Rules of a request are
+// checked concurrently after other rules, and are skipped for fields which
+// already have errors.
+type ContextRule struct {
+	// IsMatch checks if rule matches.
+	IsMatch func(string) bool
+	// IsValid checks the value of field against the rule.
+	IsValid func(ctx *web.Context, rule string, value interface{}) bool
+	// Kind is the classification of errors, default is name of the rule
+	// followed by "Error", e.g. "UniqueError" for rule "Unique(users.name)".
+	Kind string
+}
+
+var contextRuleMapper []*ContextRule
+
+// AddContextRule adds new validation rule which needs the request context.
+func AddContextRule(r *ContextRule) {
+	contextRuleMapper = append(contextRuleMapper, r)
+}
+
+type contextCheck struct {
+	rule  *ContextRule
+	name  string
+	param string
+	value interface{}
+}
+
+// check defers checking of a context rule, it's ignored without context.
+func (v *validation) check(r *ContextRule, rule, name string, value interface{}) {
+	if v.ctx != nil {
+		v.pending = append(v.pending, contextCheck{rule: r, name: name, param: rule, value: value})
+	}
+}
+
+// wait runs deferred checks concurrently and adds errors in order of rules.
+func (v *validation) wait(errors Errors) Errors {
+	pending := v.pending
+	v.pending = nil
+	if len(pending) == 0 {
+		return errors
+	}
+
+	failed := make(map[string]bool)
+	for _, err := range errors {
+		for _, name := range err.FieldNames {
+			failed[name] = true
+		}
+	}
+
+	valid := make([]bool, len(pending))
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		recovered interface{}
+	)
+	for i, c := range pending {
+		if failed[c.name] {
+			valid[i] = true
+			continue
+		}
+		wg.Add(1)
+		go func(i int, c contextCheck) {
+			defer wg.Done()
+			defer func() {
+				if err := recover(); err != nil {
+					mu.Lock()
+					recovered = err
+					mu.Unlock()
+				}
+			}()
+			valid[i] = c.rule.IsValid(v.ctx, c.param, c.value)
+		}(i, c)
+	}
+	wg.Wait()
+	// Panics are raised again in the request goroutine to be recovered.
+	if recovered != nil {
+		panic(recovered)
+	}
+
+	for i, c := range pending {
+		if valid[i] {
+			continue
+		}
+		kind := c.rule.Kind
+		if len(kind) == 0 {
+			kind = c.param
+			if j := strings.Index(kind, "("); j > 0 {
+				kind = kind[:j]
+			}
+			kind += "Error"
+		}
+		errors.addRule(c.name, kind, c.param)
+	}
+	return errors
+}
+
+// dive applies rules to each element of a slice, an array or a map, elements
+// are named like "Tags[0]" and "Labels[key]".
+func (v *validation) dive(errors Errors, parent reflect.Value, field reflect.StructField, name string, rules []string, fieldVal reflect.Value) Errors {
+	for fieldVal.Kind() == reflect.Ptr || fieldVal.Kind() == reflect.Interface {
+		if fieldVal.IsNil() {
+			return errors
+		}
+		fieldVal = fieldVal.Elem()
+	}
+
+	validate := func(name string, elem reflect.Value) {
+		zero := reflect.Zero(elem.Type()).Interface()
+		errors = v.validateRules(errors, parent, field, name, rules, zero, elem, elem.Interface())
+	}
+	switch fieldVal.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fieldVal.Len(); i++ {
+			validate(name+"["+strconv.Itoa(i)+"]", fieldVal.Index(i))
+		}
+	case reflect.Map:
+		keys := fieldVal.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			validate(fmt.Sprintf("%s[%v]", name, key.Interface()), fieldVal.MapIndex(key))
+		}
+	}
+	return errors
+}
+
+// isEmpty returns true if value is zero or an empty slice.
+func isEmpty(zero, value interface{}) bool {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Slice {
+		return v.Len() == 0
+	}
+	return reflect.DeepEqual(zero, value)
+}
+
+// ruleArgs returns arguments of a rule like "Name(a,b)".
+func ruleArgs(rule string) []string {
+	i := strings.Index(rule, "(")
+	if i < 0 || !strings.HasSuffix(rule, ")") {
+		return nil
+	}
+	return strings.Split(rule[i+1:len(rule)-1], ",")
+}
+
+// fieldOf returns value of named field of parent, pointers are dereferenced.
+func fieldOf(parent reflect.Value, name string) (reflect.Value, bool) {
+	if parent.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	f := parent.FieldByName(strings.TrimSpace(name))
+	for f.IsValid() && f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return reflect.Value{}, false
+		}
+		f = f.Elem()
+	}
+	return f, f.IsValid() && f.CanInterface()
+}
+
+// requiredBy checks conditions of RequiredIf, RequiredUnless and RequiredWith.
+func requiredBy(parent reflect.Value, rule string) bool {
+	args := ruleArgs(rule)
+	if len(args) == 0 {
+		return false
+	}
+	other, ok := fieldOf(parent, args[0])
+	switch {
+	case strings.HasPrefix(rule, "RequiredWith("):
+		return ok && !isEmpty(reflect.Zero(other.Type()).Interface(), other.Interface())
+	case len(args) < 2:
+		return false
+	}
+
+	matched := false
+	if ok {
+		val := fmt.Sprintf("%v", other.Interface())
+		for _, arg := range args[1:] {
+			if val == arg {
+				matched = true
+				break
+			}
+		}
+	}
+	if strings.HasPrefix(rule, "RequiredIf(") {
+		return matched
+	}
+	return !matched
+}
+
+// compareField checks value against another field of parent with rules
+// like "GtField(Start)", it returns kind of error and false if check fails.
+// Fields which can not be compared never fail.
+func compareField(parent reflect.Value, rule string, value reflect.Value) (string, bool) {
+	args := ruleArgs(rule)
+	if len(args) != 1 {
+		return "", true
+	}
+	other, ok := fieldOf(parent, args[0])
+	for value.IsValid() && value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if !ok || !value.IsValid() {
+		return "", true
+	}
+
+	op := rule[:strings.Index(rule, "(")]
+	if op == "EqField" || op == "NeField" {
+		equal := reflect.DeepEqual(value.Interface(), other.Interface())
+		if c, ok := compareValues(value, other); ok {
+			equal = c == 0
+		}
+		if op == "EqField" {
+			return ERR_EQ_FIELD, equal
+		}
+		return ERR_NE_FIELD, !equal
+	}
+
+	c, ok := compareValues(value, other)
+	if !ok {
+		return "", true
+	}
+	switch op {
+	case "GtField":
+		return ERR_GT_FIELD, c > 0
+	case "GteField":
+		return ERR_GTE_FIELD, c >= 0
+	case "LtField":
+		return ERR_LT_FIELD, c < 0
+	case "LteField":
+		return ERR_LTE_FIELD, c <= 0
+	}
+	return "", true
+}
+
+// compareValues compares numbers, strings and times.
+func compareValues(a, b reflect.Value) (int, bool) {
+	cmp := func(less, greater bool) int {
+		switch {
+		case less:
+			return -1
+		case greater:
+			return 1
+		}
+		return 0
+	}
+
+	switch {
+	case a.Type() == timeType && b.Type() == timeType:
+		ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+		return cmp(ta.Before(tb), ta.After(tb)), true
+	case isNumberKind(a.Kind()) && isNumberKind(b.Kind()):
+		fa, fb := numberOf(a), numberOf(b)
+		return cmp(fa < fb, fa > fb), true
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return strings.Compare(a.String(), b.String()), true
+	}
+	return 0, false
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+func numberOf(v reflect.Value) float64 {
+	switch {
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		return float64(v.Int())
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uintptr:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
@@ -0,0 +1,191 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package binding
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+)
+
+type ruleAccount struct {
+	Type     string            `form:"type" binding:"In(person,company)"`
+	Company  string            `form:"company" binding:"RequiredIf(Type,company)"`
+	Nickname string            `form:"nickname" binding:"RequiredUnless(Type,company)"`
+	Phone    string            `form:"phone"`
+	Country  string            `form:"country" binding:"RequiredWith(Phone)"`
+	Password string            `form:"password"`
+	Confirm  string            `form:"confirm" binding:"EqField(Password)"`
+	Start    time.Time         `form:"start"`
+	End      time.Time         `form:"end" binding:"GtField(Start)"`
+	Min      int               `form:"min"`
+	Max      int               `form:"max" binding:"GteField(Min)"`
+	Tags     []string          `form:"tags" binding:"MaxSize(3);Dive;Required;AlphaDash"`
+	Labels   map[string]string `form:"labels" binding:"Dive;MaxSize(3)"`
+}
+
+type ruleUser struct {
+	Name  string `form:"name" binding:"Required;Unique(users.name)"`
+	Email string `form:"email" binding:"Unique(users.email)"`
+}
+
+type ruleLocale struct{}
+
+func (ruleLocale) Language() string {
+	return "en-US"
+}
+
+func (ruleLocale) Tr(key string, args ...interface{}) string {
+	named := args[0].(map[string]interface{})
+	switch key {
+	case "binding.RequiredError":
+		return named["field"].(string) + " is required"
+	case "binding.MaxSizeError":
+		return named["field"].(string) + " is longer than " + named["param"].(string)
+	}
+	return key
+}
+
+func ruleKinds(errs Errors) map[string]string {
+	kinds := make(map[string]string)
+	for _, err := range errs {
+		kinds[err.Fields()[0]] = err.Kind()
+	}
+	return kinds
+}
+
+func Test_Rules(t *testing.T) {
+	Convey("Cross-field, conditional and dive rules", t, func() {
+		start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+		valid := ruleAccount{
+			Type:     "company",
+			Company:  "ACME",
+			Password: "secret",
+			Confirm:  "secret",
+			Start:    start,
+			End:      start.Add(time.Hour),
+			Min:      1,
+			Max:      1,
+			Tags:     []string{"a", "b-c"},
+			Labels:   map[string]string{"x": "abc"},
+		}
+		So(RawValidate(valid), ShouldBeEmpty)
+
+		invalid := ruleAccount{
+			Type:     "person",
+			Company:  "ACME",
+			Phone:    "123",
+			Password: "secret",
+			Confirm:  "secreT",
+			Start:    start,
+			End:      start,
+			Min:      2,
+			Max:      1,
+			Tags:     []string{"a", "", "b c"},
+			Labels:   map[string]string{"x": "abc", "y": "abcd"},
+		}
+		So(ruleKinds(RawValidate(invalid)), ShouldResemble, map[string]string{
+			"Nickname":  ERR_REQUIRED,
+			"Country":   ERR_REQUIRED,
+			"Confirm":   ERR_EQ_FIELD,
+			"End":       ERR_GT_FIELD,
+			"Max":       ERR_GTE_FIELD,
+			"Tags[1]":   ERR_REQUIRED,
+			"Tags[2]":   ERR_ALPHA_DASH,
+			"Labels[y]": ERR_MAX_SIZE,
+		})
+
+		invalid = valid
+		invalid.Type = "company"
+		invalid.Company = ""
+		invalid.Tags = []string{"a", "b", "c", "d"}
+		So(ruleKinds(RawValidate(invalid)), ShouldResemble, map[string]string{
+			"Company": ERR_REQUIRED,
+			"Tags":    ERR_MAX_SIZE,
+		})
+	})
+
+	Convey("Context rules are checked concurrently", t, func() {
+		var (
+			calls int32
+			path  atomic.Value
+		)
+		AddContextRule(&ContextRule{
+			IsMatch: func(rule string) bool {
+				return strings.HasPrefix(rule, "Unique(")
+			},
+			IsValid: func(ctx *web.Context, rule string, value interface{}) bool {
+				atomic.AddInt32(&calls, 1)
+				path.Store(ctx.Req.URL.Path)
+				time.Sleep(10 * time.Millisecond)
+				return value != "taken" && value != "taken@example.com"
+			},
+		})
+		defer func() { contextRuleMapper = nil }()
+
+		So(RawValidate(ruleUser{Name: "taken"}), ShouldBeEmpty)
+		So(calls, ShouldEqual, 0)
+
+		m := web.New()
+		var errs Errors
+		m.Post("/users", Form(ruleUser{}), func(e Errors) { errs = e })
+
+		req, _ := http.NewRequest("POST", "/users", strings.NewReader("name=taken&email=taken@example.com"))
+		req.Header.Set("Content-Type", formContentType)
+		begin := time.Now()
+		m.ServeHTTP(httptest.NewRecorder(), req)
+		So(time.Since(begin), ShouldBeLessThan, 20*time.Millisecond)
+		So(calls, ShouldEqual, 2)
+		So(path.Load(), ShouldEqual, "/users")
+		So(len(errs), ShouldEqual, 2)
+		So(errs[0].Fields(), ShouldResemble, []string{"Name"})
+		So(errs[0].Kind(), ShouldEqual, "UniqueError")
+		So(errs[1].Fields(), ShouldResemble, []string{"Email"})
+
+		// Fields with errors are not checked.
+		req, _ = http.NewRequest("POST", "/users", strings.NewReader("email=free@example.com"))
+		req.Header.Set("Content-Type", formContentType)
+		m.ServeHTTP(httptest.NewRecorder(), req)
+		So(calls, ShouldEqual, 3)
+		So(ruleKinds(errs), ShouldResemble, map[string]string{"Name": ERR_REQUIRED})
+	})
+
+	Convey("Translate error messages", t, func() {
+		var errs Errors
+		errs.addRule("Name", ERR_REQUIRED, "Required")
+		errs.addRule("Name", ERR_MAX_SIZE, "MaxSize(20)")
+		errs.addRule("Name", ERR_ALPHA_DASH, "AlphaDash")
+
+		translated := errs.Translate(ruleLocale{})
+		So(translated[0].Message, ShouldEqual, "Name is required")
+		So(translated[1].Message, ShouldEqual, "Name is longer than 20")
+		So(translated[2].Message, ShouldEqual, "AlphaDash")
+		So(errs[0].Message, ShouldEqual, "Required")
+
+		m := web.New()
+		m.Use(func(ctx *web.Context) { ctx.Locale = ruleLocale{} })
+		m.Post("/", Form(ruleUser{}), func(e Errors) { errs = e })
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(""))
+		req.Header.Set("Content-Type", formContentType)
+		m.ServeHTTP(httptest.NewRecorder(), req)
+		So(errs[0].Message, ShouldEqual, "Name is required")
+	})
+}
@@ -0,0 +1,687 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package binding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// SchemaDialect is the JSON Schema dialect of generated documents.
+	SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+	// Patterns of AlphaDash and AlphaDashDot in JSON Schema.
+	AlphaDashSchemaPattern    = `^[\w-]*$`
+	AlphaDashDotSchemaPattern = `^[\w.-]*$`
+)
+
+// Schema represents a JSON Schema, it covers keywords which binding rules
+// can be translated to, and is also the subset used by OpenAPI 3.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+)
+
+// JSONSchema generates JSON Schema of given struct, properties are named
+// as encoding/json does and constrained by binding rules of fields.
+// Named structs other than the root are placed in "$defs".
+func JSONSchema(obj interface{}) *Schema {
+	typ := reflect.TypeOf(obj)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	g := &schemaGenerator{root: typ, defs: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+	var schema *Schema
+	if typ.Kind() == reflect.Struct {
+		schema = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		g.fields(schema, typ)
+	} else {
+		schema = g.schema(typ)
+	}
+	schema.Schema = SchemaDialect
+	schema.Title = typ.Name()
+	if len(g.defs) > 0 {
+		schema.Defs = g.defs
+	}
+	return schema
+}
+
+type schemaGenerator struct {
+	root  reflect.Type
+	defs  map[string]*Schema
+	names map[reflect.Type]string
+}
+
+func (g *schemaGenerator) schema(typ reflect.Type) *Schema {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case fileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(typ.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(typ.Elem())}
+	case reflect.Struct:
+		if typ == g.root {
+			return &Schema{Ref: "#"}
+		}
+		if len(typ.Name()) > 0 {
+			return &Schema{Ref: "#/$defs/" + g.define(typ)}
+		}
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		g.fields(schema, typ)
+		return schema
+	}
+	return &Schema{}
+}
+
+// define registers a named struct into definitions and returns its name.
+func (g *schemaGenerator) define(typ reflect.Type) string {
+	if name, ok := g.names[typ]; ok {
+		return name
+	}
+
+	name := typ.Name()
+	if _, ok := g.defs[name]; ok {
+		name = strings.Replace(typ.String(), ".", "_", -1)
+	}
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	// Register before walking fields for recursive types.
+	g.names[typ] = name
+	g.defs[name] = schema
+	g.fields(schema, typ)
+	return name
+}
+
+func (g *schemaGenerator) fields(schema *Schema, typ reflect.Type) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if len(field.PkgPath) > 0 && !field.Anonymous {
+			continue
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			if field.Anonymous && ft.Kind() == reflect.Struct {
+				g.fields(schema, ft)
+				continue
+			}
+			name = field.Name
+		}
+
+		prop := g.schema(field.Type)
+		if ApplyRules(prop, ft, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+}
+
+// ApplyRules translates binding rules of a field with given type into
+// constraints of schema, it returns true if the field is required.
+// Custom rules added by AddRule and AddParamRule are not translated.
+func ApplyRules(schema *Schema, typ reflect.Type, rules string) (required bool) {
+	// References can not have sibling keywords.
+	if len(schema.Ref) > 0 {
+		for _, rule := range strings.Split(rules, ";") {
+			if rule == "Required" {
+				return true
+			}
+		}
+		return false
+	}
+
+	isSlice := typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array
+	size := func(rule string) *int {
+		n, err := strconv.Atoi(rule[strings.Index(rule, "(")+1 : len(rule)-1])
+		if err != nil {
+			return nil
+		}
+		return &n
+	}
+
+	// Constraints are collected separately, OmitEmpty allows zero value
+	// to skip all of them.
+	c := &Schema{Type: schema.Type}
+	omitEmpty := false
+	list := strings.Split(rules, ";")
+RULES:
+	for i, rule := range list {
+		switch {
+		case rule == "Dive":
+			// Rules after Dive apply to elements.
+			elem := schema.Items
+			if elem == nil {
+				elem = schema.AdditionalProperties
+			}
+			if elem != nil && (isSlice || typ.Kind() == reflect.Map) {
+				et := typ.Elem()
+				for et.Kind() == reflect.Ptr {
+					et = et.Elem()
+				}
+				ApplyRules(elem, et, strings.Join(list[i+1:], ";"))
+			}
+			break RULES
+		case rule == "OmitEmpty":
+			omitEmpty = true
+		case rule == "Required":
+			required = true
+			// Zero values are treated as missing.
+			switch schema.Type {
+			case "string":
+				if c.MinLength == nil {
+					one := 1
+					c.MinLength = &one
+				}
+			case "array":
+				if c.MinItems == nil {
+					one := 1
+					c.MinItems = &one
+				}
+			case "integer", "number", "boolean":
+				c.notIn(zeroValue(schema.Type))
+			}
+		case rule == "AlphaDash":
+			c.addPattern(AlphaDashSchemaPattern)
+		case rule == "AlphaDashDot":
+			c.addPattern(AlphaDashDotSchemaPattern)
+		case rule == "Email":
+			c.Format = "email"
+		case rule == "Url":
+			c.Format = "uri"
+		case strings.HasPrefix(rule, "Size("):
+			if isSlice {
+				c.MinItems, c.MaxItems = size(rule), size(rule)
+			} else {
+				c.MinLength, c.MaxLength = size(rule), size(rule)
+			}
+		case strings.HasPrefix(rule, "MinSize("):
+			if isSlice {
+				c.MinItems = size(rule)
+			} else {
+				c.MinLength = size(rule)
+			}
+		case strings.HasPrefix(rule, "MaxSize("):
+			if isSlice {
+				c.MaxItems = size(rule)
+			} else {
+				c.MaxLength = size(rule)
+			}
+		case strings.HasPrefix(rule, "Range("):
+			nums := strings.Split(rule[6:len(rule)-1], ",")
+			if len(nums) != 2 {
+				continue
+			}
+			if min, err := strconv.ParseFloat(strings.TrimSpace(nums[0]), 64); err == nil {
+				c.Minimum = &min
+			}
+			if max, err := strconv.ParseFloat(strings.TrimSpace(nums[1]), 64); err == nil {
+				c.Maximum = &max
+			}
+		case strings.HasPrefix(rule, "In("):
+			for _, v := range strings.Split(rule[3:len(rule)-1], ",") {
+				c.Enum = append(c.Enum, typedValue(schema.Type, v))
+			}
+		case strings.HasPrefix(rule, "NotIn("):
+			for _, v := range strings.Split(rule[6:len(rule)-1], ",") {
+				c.notIn(typedValue(schema.Type, v))
+			}
+		case strings.HasPrefix(rule, "Include("):
+			c.addPattern(regexp.QuoteMeta(rule[8 : len(rule)-1]))
+		case strings.HasPrefix(rule, "Exclude("):
+			c.AllOf = append(c.AllOf, &Schema{Not: &Schema{Pattern: regexp.QuoteMeta(rule[8 : len(rule)-1])}})
+		case strings.HasPrefix(rule, "Default("):
+			schema.Default = typedValue(schema.Type, rule[8:len(rule)-1])
+		}
+	}
+
+	c.Type = ""
+	if reflect.DeepEqual(c, &Schema{}) {
+		return required
+	}
+	if omitEmpty && !required {
+		if zero := zeroValue(schema.Type); zero != nil {
+			schema.AnyOf = append(schema.AnyOf, &Schema{Enum: []interface{}{zero}}, c)
+			return required
+		}
+	}
+	schema.merge(c)
+	return required
+}
+
+// notIn adds values to the "not" enum of schema.
+func (s *Schema) notIn(v interface{}) {
+	if s.Not == nil {
+		s.Not = &Schema{}
+	}
+	s.Not.Enum = append(s.Not.Enum, v)
+}
+
+// addPattern sets pattern of schema, additional patterns go to "allOf".
+func (s *Schema) addPattern(pattern string) {
+	if len(s.Pattern) == 0 {
+		s.Pattern = pattern
+		return
+	}
+	s.AllOf = append(s.AllOf, &Schema{Pattern: pattern})
+}
+
+// merge copies constraints of c into s.
+func (s *Schema) merge(c *Schema) {
+	if len(c.Format) > 0 {
+		s.Format = c.Format
+	}
+	if len(c.Pattern) > 0 {
+		s.addPattern(c.Pattern)
+	}
+	if c.Minimum != nil {
+		s.Minimum = c.Minimum
+	}
+	if c.Maximum != nil {
+		s.Maximum = c.Maximum
+	}
+	if c.MinLength != nil {
+		s.MinLength = c.MinLength
+	}
+	if c.MaxLength != nil {
+		s.MaxLength = c.MaxLength
+	}
+	if c.MinItems != nil {
+		s.MinItems = c.MinItems
+	}
+	if c.MaxItems != nil {
+		s.MaxItems = c.MaxItems
+	}
+	if c.Not != nil {
+		s.Not = c.Not
+	}
+	s.Enum = append(s.Enum, c.Enum...)
+	s.AllOf = append(s.AllOf, c.AllOf...)
+}
+
+// typedValue converts a value in binding rules to the type of schema.
+func typedValue(typ, val string) interface{} {
+	switch typ {
+	case "integer":
+		if v, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return v
+		}
+	case "number":
+		if v, err := strconv.ParseFloat(val, 64); err == nil {
+			return v
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(val); err == nil {
+			return v
+		}
+	}
+	return val
+}
+
+// zeroValue returns JSON value of the zero value of schema type.
+func zeroValue(typ string) interface{} {
+	switch typ {
+	case "string":
+		return ""
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "array":
+		return []interface{}{}
+	}
+	return nil
+}
+
+// ValidateJSON decodes data and validates it against the schema.
+func (s *Schema) ValidateJSON(data []byte) (errors Errors) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		errors.Add([]string{}, ERR_DESERIALIZATION, err.Error())
+		return errors
+	}
+	return s.Validate(v)
+}
+
+// Validate validates a decoded JSON value against the schema. Errors are
+// classified like binding rules do, field names of errors are paths of
+// values joined by dots, e.g. "friends.0.name". Like encoding/json, null
+// is treated as a missing value.
+func (s *Schema) Validate(v interface{}) Errors {
+	return (&schemaValidator{root: s}).validate(nil, s, v, "")
+}
+
+type schemaValidator struct {
+	root *Schema
+}
+
+// resolve returns schema referenced by ref, only references to root and
+// its "$defs" are supported.
+func (sv *schemaValidator) resolve(ref string) *Schema {
+	if ref == "#" {
+		return sv.root
+	}
+	if strings.HasPrefix(ref, "#/$defs/") {
+		return sv.root.Defs[ref[8:]]
+	}
+	return nil
+}
+
+func (sv *schemaValidator) validate(errors Errors, s *Schema, v interface{}, path string) Errors {
+	if len(s.Ref) > 0 {
+		if s = sv.resolve(s.Ref); s == nil {
+			return errors
+		}
+	}
+	if v == nil {
+		return errors
+	}
+
+	fields := []string{}
+	if len(path) > 0 {
+		fields = []string{path}
+	}
+
+	// Like binding rules, only the first failure of a value is reported.
+	if len(s.Type) > 0 && !isType(s.Type, v) {
+		errors.Add(fields, typeErrorKind(s.Type), fmt.Sprintf("Value should be %s", s.Type))
+		return errors
+	}
+	if len(s.AnyOf) > 0 {
+		var errs Errors
+		for _, sub := range s.AnyOf {
+			if errs = sv.validate(nil, sub, v, path); len(errs) == 0 {
+				break
+			}
+		}
+		if len(errs) > 0 {
+			return append(errors, errs...)
+		}
+	}
+	for _, sub := range s.AllOf {
+		if errs := sv.validate(nil, sub, v, path); len(errs) > 0 {
+			return append(errors, errs...)
+		}
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		errors.Add(fields, ERR_IN, "In")
+		return errors
+	}
+	if s.Not != nil && len(sv.validate(nil, s.Not, v, path)) == 0 {
+		if len(s.Not.Pattern) > 0 {
+			errors.Add(fields, ERR_EXCLUDE, "Exclude")
+		} else {
+			errors.Add(fields, ERR_NOT_INT, "NotIn")
+		}
+		return errors
+	}
+
+	switch val := v.(type) {
+	case string:
+		if kind := sizeErrorKind(utf8.RuneCountInString(val), s.MinLength, s.MaxLength); len(kind) > 0 {
+			errors.Add(fields, kind, strings.TrimSuffix(kind, "Error"))
+			return errors
+		}
+		if len(s.Pattern) > 0 {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(val) {
+				kind := patternErrorKind(s.Pattern, re)
+				errors.Add(fields, kind, strings.TrimSuffix(kind, "Error"))
+				return errors
+			}
+		}
+		switch s.Format {
+		case "email":
+			if !EmailPattern.MatchString(val) {
+				errors.Add(fields, ERR_EMAIL, "Email")
+			}
+		case "uri":
+			if len(val) > 0 && !isURL(val) {
+				errors.Add(fields, ERR_URL, "Url")
+			}
+		}
+	case json.Number, float64:
+		n, _ := toFloat(val)
+		if (s.Minimum != nil && n < *s.Minimum) || (s.Maximum != nil && n > *s.Maximum) {
+			errors.Add(fields, ERR_RANGE, "Range")
+		}
+	case []interface{}:
+		if kind := sizeErrorKind(len(val), s.MinItems, s.MaxItems); len(kind) > 0 {
+			errors.Add(fields, kind, strings.TrimSuffix(kind, "Error"))
+			return errors
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				errors = sv.validate(errors, s.Items, item, joinPath(path, strconv.Itoa(i)))
+			}
+		}
+	case map[string]interface{}:
+		missing := make(map[string]bool)
+		for _, name := range s.Required {
+			prop, ok := val[name]
+			if !ok || prop == nil {
+				missing[name] = true
+				errors.Add([]string{joinPath(path, name)}, ERR_REQUIRED, "Required")
+				continue
+			}
+			// Constraints of Required reject zero values, report them as missing.
+			if sub := s.Properties[name]; sub != nil && isZero(prop) &&
+				len(sv.validate(nil, sub, prop, joinPath(path, name))) > 0 {
+				missing[name] = true
+				errors.Add([]string{joinPath(path, name)}, ERR_REQUIRED, "Required")
+			}
+		}
+		names := make([]string, 0, len(val))
+		for name := range val {
+			if !missing[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop := val[name]
+			if sub, ok := s.Properties[name]; ok {
+				errors = sv.validate(errors, sub, prop, joinPath(path, name))
+			} else if s.AdditionalProperties != nil {
+				errors = sv.validate(errors, s.AdditionalProperties, prop, joinPath(path, name))
+			}
+		}
+	}
+	return errors
+}
+
+func joinPath(path, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+	return path + "." + name
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func isType(typ string, v interface{}) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := toFloat(v)
+		return ok
+	case "integer":
+		f, ok := toFloat(v)
+		return ok && f == float64(int64(f))
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+func isZero(v interface{}) bool {
+	switch val := v.(type) {
+	case string:
+		return len(val) == 0
+	case bool:
+		return !val
+	case []interface{}:
+		return len(val) == 0
+	}
+	f, ok := toFloat(v)
+	return ok && f == 0
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if equalValue(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalValue compares JSON values, numbers are compared by value.
+func equalValue(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func typeErrorKind(typ string) string {
+	switch typ {
+	case "integer":
+		return ERR_INTERGER_TYPE
+	case "number":
+		return ERR_FLOAT_TYPE
+	case "boolean":
+		return ERR_BOOLEAN_TYPE
+	}
+	return ERR_TYPE
+}
+
+func sizeErrorKind(n int, min, max *int) string {
+	if min != nil && max != nil && *min == *max && n != *min {
+		return ERR_SIZE
+	}
+	if min != nil && n < *min {
+		return ERR_MIN_SIZE
+	}
+	if max != nil && n > *max {
+		return ERR_MAX_SIZE
+	}
+	return ""
+}
+
+func patternErrorKind(pattern string, re *regexp.Regexp) string {
+	switch pattern {
+	case AlphaDashSchemaPattern:
+		return ERR_ALPHA_DASH
+	case AlphaDashDotSchemaPattern:
+		return ERR_ALPHA_DASH_DOT
+	}
+	// Literal patterns come from Include.
+	if _, complete := re.LiteralPrefix(); complete {
+		return ERR_INCLUDE
+	}
+	return ERR_PATTERN
+}
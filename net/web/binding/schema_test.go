@@ -0,0 +1,155 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package binding
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type schemaAddress struct {
+	City string `json:"city" binding:"Required;MaxSize(10)"`
+}
+
+type schemaUser struct {
+	Name     string         `json:"name" binding:"Required;AlphaDash;MaxSize(20)"`
+	Email    string         `json:"email" binding:"OmitEmpty;Email"`
+	Homepage string         `json:"homepage" binding:"Url"`
+	Age      int            `json:"age" binding:"Range(1,150)"`
+	Role     string         `json:"role" binding:"In(admin,user);Default(user)"`
+	Code     string         `json:"code" binding:"Size(4);NotIn(root);Exclude(!)"`
+	Tags     []string       `json:"tags" binding:"MinSize(1)"`
+	Aliases  []string       `json:"aliases" binding:"MaxSize(2);Dive;AlphaDash"`
+	Address  *schemaAddress `json:"address"`
+	Friends  []*schemaUser  `json:"friends,omitempty"`
+	Secret   string         `json:"-"`
+}
+
+func Test_JSONSchema(t *testing.T) {
+	Convey("Generate JSON Schema from binding rules", t, func() {
+		s := JSONSchema(&schemaUser{})
+		So(s.Schema, ShouldEqual, SchemaDialect)
+		So(s.Type, ShouldEqual, "object")
+		So(s.Required, ShouldResemble, []string{"name"})
+		So(s.Properties["name"].Pattern, ShouldEqual, AlphaDashSchemaPattern)
+		So(*s.Properties["name"].MinLength, ShouldEqual, 1)
+		So(*s.Properties["name"].MaxLength, ShouldEqual, 20)
+		So(len(s.Properties["email"].AnyOf), ShouldEqual, 2)
+		So(s.Properties["email"].AnyOf[1].Format, ShouldEqual, "email")
+		So(s.Properties["homepage"].Format, ShouldEqual, "uri")
+		So(*s.Properties["age"].Minimum, ShouldEqual, 1)
+		So(*s.Properties["age"].Maximum, ShouldEqual, 150)
+		So(s.Properties["role"].Enum, ShouldResemble, []interface{}{"admin", "user"})
+		So(s.Properties["role"].Default, ShouldEqual, "user")
+		So(s.Properties["code"].Not.Enum, ShouldResemble, []interface{}{"root"})
+		So(*s.Properties["tags"].MinItems, ShouldEqual, 1)
+		So(*s.Properties["aliases"].MaxItems, ShouldEqual, 2)
+		So(s.Properties["aliases"].Items.Pattern, ShouldEqual, AlphaDashSchemaPattern)
+		So(s.Properties["address"].Ref, ShouldEqual, "#/$defs/schemaAddress")
+		So(s.Defs["schemaAddress"].Required, ShouldResemble, []string{"city"})
+		So(s.Properties["friends"].Items.Ref, ShouldEqual, "#")
+		So(s.Properties, ShouldNotContainKey, "Secret")
+
+		data, err := json.Marshal(s)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldContainSubstring, `"$defs"`)
+	})
+}
+
+func Test_Schema_Validate(t *testing.T) {
+	Convey("Validate JSON against generated schema", t, func() {
+		s := JSONSchema(schemaUser{})
+
+		Convey("Valid payload", func() {
+			errs := s.ValidateJSON([]byte(`{"name":"john-doe","age":30,"role":"admin","code":"abcd","tags":["a"],"address":{"city":"Paris"}}`))
+			So(errs, ShouldBeEmpty)
+			So(s.ValidateJSON([]byte(`{"name":"john","email":""}`)), ShouldBeEmpty)
+		})
+
+		Convey("Errors are mapped to binding errors", func() {
+			errs := s.ValidateJSON([]byte(`{
+				"name": "john doe",
+				"email": "john",
+				"homepage": "not a url",
+				"age": 200,
+				"role": "guest",
+				"code": "abc",
+				"tags": [],
+				"address": {"city": ""},
+				"friends": [{"name": ""}, {"name": 1}]
+			}`))
+			kinds := make(map[string]string)
+			for _, err := range errs {
+				So(len(err.Fields()), ShouldEqual, 1)
+				kinds[err.Fields()[0]] = err.Kind()
+			}
+			So(kinds, ShouldResemble, map[string]string{
+				"name":           ERR_ALPHA_DASH,
+				"email":          ERR_EMAIL,
+				"homepage":       ERR_URL,
+				"age":            ERR_RANGE,
+				"role":           ERR_IN,
+				"code":           ERR_SIZE,
+				"tags":           ERR_MIN_SIZE,
+				"address.city":   ERR_REQUIRED,
+				"friends.0.name": ERR_REQUIRED,
+				"friends.1.name": ERR_TYPE,
+			})
+		})
+
+		Convey("Missing required and excluded values", func() {
+			errs := s.ValidateJSON([]byte(`{"code":"root"}`))
+			So(len(errs), ShouldEqual, 2)
+			So(errs[0].Fields(), ShouldResemble, []string{"name"})
+			So(errs[0].Kind(), ShouldEqual, ERR_REQUIRED)
+			So(errs[1].Kind(), ShouldEqual, ERR_NOT_INT)
+
+			errs = s.ValidateJSON([]byte(`{"name":"a","code":"ab!c"}`))
+			So(len(errs), ShouldEqual, 1)
+			So(errs[0].Kind(), ShouldEqual, ERR_EXCLUDE)
+		})
+
+		Convey("Invalid JSON", func() {
+			errs := s.ValidateJSON([]byte(`{`))
+			So(errs.Has(ERR_DESERIALIZATION), ShouldBeTrue)
+		})
+	})
+
+	Convey("Validate against hand written schema", t, func() {
+		one := 1
+		s := &Schema{
+			Type:     "object",
+			Required: []string{"count"},
+			Properties: map[string]*Schema{
+				"count": {Type: "integer", Not: &Schema{Enum: []interface{}{0}}},
+				"slug":  {Type: "string", Pattern: `^[a-z]+$`, MinLength: &one},
+				"note":  {Type: "string", Pattern: "hello"},
+			},
+		}
+		So(s.ValidateJSON([]byte(`{"count":1,"slug":"abc"}`)), ShouldBeEmpty)
+
+		errs := s.ValidateJSON([]byte(`{"count":1.5,"slug":"ABC","note":"bye"}`))
+		So(len(errs), ShouldEqual, 3)
+		So(errs[0].Kind(), ShouldEqual, ERR_INTERGER_TYPE)
+		So(errs[1].Kind(), ShouldEqual, ERR_INCLUDE)
+		So(errs[2].Kind(), ShouldEqual, ERR_PATTERN)
+
+		errs = s.ValidateJSON([]byte(`{"count":0}`))
+		So(len(errs), ShouldEqual, 1)
+		So(errs[0].Kind(), ShouldEqual, ERR_REQUIRED)
+	})
+}
@@ -0,0 +1,268 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package binding
+
+import (
+	"encoding"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"landzero.net/x/net/web"
+)
+
+// Struct tags of fields bound from sources other than request body.
+var sourceTags = []string{SOURCE_QUERY, SOURCE_HEADER, SOURCE_PARAM, SOURCE_COOKIE}
+
+// Query is middleware to map fields tagged with query from the query string.
+func Query(obj interface{}, ifacePtr ...interface{}) web.Handler {
+	return sourceBinder(obj, SOURCE_QUERY, ifacePtr...)
+}
+
+// Header is middleware to map fields tagged with header from request headers.
+func Header(obj interface{}, ifacePtr ...interface{}) web.Handler {
+	return sourceBinder(obj, SOURCE_HEADER, ifacePtr...)
+}
+
+// Param is middleware to map fields tagged with param from route params.
+func Param(obj interface{}, ifacePtr ...interface{}) web.Handler {
+	return sourceBinder(obj, SOURCE_PARAM, ifacePtr...)
+}
+
+// Cookie is middleware to map fields tagged with cookie from request cookies.
+func Cookie(obj interface{}, ifacePtr ...interface{}) web.Handler {
+	return sourceBinder(obj, SOURCE_COOKIE, ifacePtr...)
+}
+
+func sourceBinder(obj interface{}, source string, ifacePtr ...interface{}) web.Handler {
+	return newBinder(obj, source, func(ctx *web.Context) {
+		var errors Errors
+		ensureNotPointer(obj)
+		sourceStruct := reflect.New(reflect.TypeOf(obj))
+		errors = mapSource(sourceStruct, source, sourceLookup(ctx, source), errors)
+		validateAndMap(sourceStruct, ctx, errors, ifacePtr...)
+	})
+}
+
+// readSources maps fields tagged with query, header, param and cookie.
+func readSources(ctx *web.Context, obj reflect.Value, errors Errors) Errors {
+	for _, source := range sourceTags {
+		errors = mapSource(obj, source, sourceLookup(ctx, source), errors)
+	}
+	return errors
+}
+
+// sourceLookup returns function to look up values by name from given source.
+func sourceLookup(ctx *web.Context, source string) func(string) []string {
+	switch source {
+	case SOURCE_QUERY:
+		query := ctx.Req.URL.Query()
+		return func(name string) []string {
+			return query[name]
+		}
+	case SOURCE_HEADER:
+		return func(name string) []string {
+			return ctx.Req.Header[textproto.CanonicalMIMEHeaderKey(name)]
+		}
+	case SOURCE_PARAM:
+		return func(name string) []string {
+			if val := ctx.Params(name); len(val) > 0 {
+				return []string{val}
+			}
+			return nil
+		}
+	case SOURCE_COOKIE:
+		return func(name string) []string {
+			var vals []string
+			for _, cookie := range ctx.Req.Cookies() {
+				if cookie.Name == name {
+					val, _ := url.QueryUnescape(cookie.Value)
+					vals = append(vals, val)
+				}
+			}
+			return vals
+		}
+	}
+	return func(string) []string { return nil }
+}
+
+// mapSource maps fields with given tag of a struct and its nested structs.
+func mapSource(obj reflect.Value, tag string, lookup func(string) []string, errors Errors) Errors {
+	if obj.Kind() == reflect.Ptr {
+		obj = obj.Elem()
+	}
+	if obj.Kind() != reflect.Struct {
+		return errors
+	}
+	typ := obj.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldVal := obj.Field(i)
+		if !fieldVal.CanSet() {
+			continue
+		}
+
+		name := field.Tag.Get(tag)
+		if len(name) == 0 || name == "-" {
+			switch {
+			case field.Type.Kind() == reflect.Struct && !isDecodable(field.Type):
+				errors = mapSource(fieldVal, tag, lookup, errors)
+			case field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct && !fieldVal.IsNil():
+				errors = mapSource(fieldVal, tag, lookup, errors)
+			}
+			continue
+		}
+
+		if vals := lookup(name); len(vals) > 0 {
+			errors = setValues(fieldVal, vals, name, errors)
+		}
+	}
+	return errors
+}
+
+func hasSourceTag(field reflect.StructField) bool {
+	for _, tag := range sourceTags {
+		if name := field.Tag.Get(tag); len(name) > 0 && name != "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// Decoder decodes a string value from request into value of a type.
+type Decoder func(string) (interface{}, error)
+
+var decoders = make(map[reflect.Type]Decoder)
+
+// AddDecoder registers decoder for the type of sample, values of the type in
+// forms, query strings, headers, params and cookies are decoded by it.
+// Types implementing encoding.TextUnmarshaler are decoded without registration.
+func AddDecoder(sample interface{}, decoder Decoder) {
+	decoders[reflect.TypeOf(sample)] = decoder
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isDecodable returns true if values of the type are decoded from a single string.
+func isDecodable(typ reflect.Type) bool {
+	if _, ok := decoders[typ]; ok {
+		return true
+	}
+	return reflect.PtrTo(typ).Implements(textUnmarshalerType)
+}
+
+// setValues sets values to a field, slices take all values.
+func setValues(field reflect.Value, vals []string, name string, errors Errors) Errors {
+	if field.Kind() == reflect.Slice && !isDecodable(field.Type()) {
+		slice := reflect.MakeSlice(field.Type(), len(vals), len(vals))
+		for i := range vals {
+			errors = setValue(slice.Index(i), vals[i], name, errors)
+		}
+		field.Set(slice)
+		return errors
+	}
+	return setValue(field, vals[0], name, errors)
+}
+
+// setValue sets a value to a field with registered decoders, encoding.TextUnmarshaler
+// or by kind of the field. Pointers are allocated.
+func setValue(field reflect.Value, val string, name string, errors Errors) Errors {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+
+	if decoder, ok := decoders[field.Type()]; ok {
+		v, err := decoder(val)
+		if err != nil {
+			errors.Add([]string{name}, ERR_TYPE, err.Error())
+		} else {
+			field.Set(reflect.ValueOf(v))
+		}
+		return errors
+	}
+	if field.CanAddr() {
+		if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := u.UnmarshalText([]byte(val)); err != nil {
+				errors.Add([]string{name}, ERR_TYPE, err.Error())
+			}
+			return errors
+		}
+	}
+	return setWithProperType(field.Kind(), val, field, name, errors)
+}
+
+// structElem returns the struct type of pointers and slices of structs which
+// are mapped from nested form keys, or nil for other types.
+func structElem(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	} else if typ.Kind() != reflect.Ptr {
+		return nil
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || isDecodable(typ) || typ == fileHeaderType {
+		return nil
+	}
+	return typ
+}
+
+func hasFormPrefix(form map[string][]string, formfile map[string][]*multipart.FileHeader, prefix string) bool {
+	for k := range form {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	for k := range formfile {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// formSliceLen returns length of slice from keys like "name[0].field", indexes
+// beyond the number of keys are ignored to bound the allocation.
+func formSliceLen(form map[string][]string, formfile map[string][]*multipart.FileHeader, name string) int {
+	n, limit := 0, len(form)+len(formfile)
+	count := func(k string) {
+		if !strings.HasPrefix(k, name+"[") {
+			return
+		}
+		k = k[len(name)+1:]
+		end := strings.Index(k, "].")
+		if end < 0 {
+			return
+		}
+		if i, err := strconv.Atoi(k[:end]); err == nil && i >= 0 && i < limit && i >= n {
+			n = i + 1
+		}
+	}
+	for k := range form {
+		count(k)
+	}
+	for k := range formfile {
+		count(k)
+	}
+	return n
+}
@@ -0,0 +1,184 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package binding
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+)
+
+type sourceLevel int
+
+func (l *sourceLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+type sourceColor struct {
+	R, G, B uint8
+}
+
+type sourceRequest struct {
+	ID      int64       `param:"id" json:"-"`
+	Token   string      `header:"X-Token" binding:"Required" json:"-"`
+	Session string      `cookie:"session" json:"-"`
+	Page    int         `query:"page" json:"-"`
+	Fields  []string    `query:"fields" json:"-"`
+	Level   sourceLevel `query:"level" json:"-"`
+	Since   *time.Time  `query:"since" json:"-"`
+	Color   sourceColor `query:"color" json:"-"`
+	Title   string      `json:"title" binding:"Required"`
+}
+
+type sourceItem struct {
+	Name  string `form:"name" binding:"Required"`
+	Count int    `form:"count"`
+}
+
+type sourceAddress struct {
+	City string `form:"city"`
+}
+
+type sourceOrder struct {
+	Token   string         `header:"X-Token"`
+	Note    string         `form:"note"`
+	Address sourceAddress  `form:"addr"`
+	Billing *sourceAddress `form:"billing"`
+	Items   []sourceItem   `form:"items"`
+	Extras  []*sourceItem  `form:"extras"`
+}
+
+func init() {
+	AddDecoder(sourceColor{}, func(s string) (interface{}, error) {
+		var c sourceColor
+		if len(s) != 7 || s[0] != '#' {
+			return nil, errors.New("invalid color")
+		}
+		_, err := fmt.Sscanf(s[1:], "%02x%02x%02x", &c.R, &c.G, &c.B)
+		return c, err
+	})
+}
+
+func Test_Sources(t *testing.T) {
+	Convey("Bind fills struct from all sources", t, func() {
+		m := web.New()
+		var actual sourceRequest
+		var errs Errors
+		m.Put("/items/:id", BindIgnErr(sourceRequest{}), func(r sourceRequest, e Errors) {
+			actual, errs = r, e
+		})
+
+		req, _ := http.NewRequest("PUT", "/items/42?page=3&fields=a&fields=b&level=high&since=2018-01-02T03:04:05Z&color=%23ff8000", strings.NewReader(`{"title":"hello"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Token", "secret")
+		req.AddCookie(&http.Cookie{Name: "session", Value: "s%201"})
+		m.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(errs, ShouldBeEmpty)
+		So(actual.ID, ShouldEqual, 42)
+		So(actual.Token, ShouldEqual, "secret")
+		So(actual.Session, ShouldEqual, "s 1")
+		So(actual.Page, ShouldEqual, 3)
+		So(actual.Fields, ShouldResemble, []string{"a", "b"})
+		So(actual.Level, ShouldEqual, 2)
+		So(actual.Since.Year(), ShouldEqual, 2018)
+		So(actual.Color, ShouldResemble, sourceColor{255, 128, 0})
+		So(actual.Title, ShouldEqual, "hello")
+
+		Convey("Decoding and validation errors", func() {
+			req, _ := http.NewRequest("PUT", "/items/x?level=medium&color=red", strings.NewReader(`{"title":"hello"}`))
+			req.Header.Set("Content-Type", "application/json")
+			m.ServeHTTP(httptest.NewRecorder(), req)
+
+			kinds := make(map[string]string)
+			for _, err := range errs {
+				kinds[err.Fields()[0]] = err.Kind()
+			}
+			So(kinds, ShouldResemble, map[string]string{
+				"id":    ERR_INTERGER_TYPE,
+				"level": ERR_TYPE,
+				"color": ERR_TYPE,
+				"Token": ERR_REQUIRED,
+			})
+		})
+	})
+
+	Convey("Bind single source", t, func() {
+		m := web.New()
+		var actual sourceRequest
+		m.Get("/header", Header(sourceRequest{}), func(r sourceRequest) { actual = r })
+		m.Get("/query", Query(sourceRequest{}), func(r sourceRequest) { actual = r })
+
+		req, _ := http.NewRequest("GET", "/header?page=2", nil)
+		req.Header.Set("X-Token", "secret")
+		m.ServeHTTP(httptest.NewRecorder(), req)
+		So(actual.Token, ShouldEqual, "secret")
+		So(actual.Page, ShouldEqual, 0)
+
+		req, _ = http.NewRequest("GET", "/query?page=2", nil)
+		req.Header.Set("X-Token", "secret")
+		m.ServeHTTP(httptest.NewRecorder(), req)
+		So(actual.Token, ShouldBeEmpty)
+		So(actual.Page, ShouldEqual, 2)
+	})
+
+	Convey("Nested form keys", t, func() {
+		m := web.New()
+		var actual sourceOrder
+		var errs Errors
+		m.Post("/orders", Form(sourceOrder{}), func(o sourceOrder, e Errors) {
+			actual, errs = o, e
+		})
+
+		body := "note=n&X-Token=t&token=t&addr.city=Paris&billing.city=Lyon" +
+			"&items[1].name=b&items[0].name=a&items[0].count=2" +
+			"&extras[0].name=x&extras[99999].name=y"
+		req, _ := http.NewRequest("POST", "/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", formContentType)
+		m.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(errs, ShouldBeEmpty)
+		So(actual.Token, ShouldBeEmpty)
+		So(actual.Note, ShouldEqual, "n")
+		So(actual.Address.City, ShouldEqual, "Paris")
+		So(actual.Billing.City, ShouldEqual, "Lyon")
+		So(actual.Items, ShouldResemble, []sourceItem{{"a", 2}, {"b", 0}})
+		So(len(actual.Extras), ShouldEqual, 1)
+		So(actual.Extras[0].Name, ShouldEqual, "x")
+
+		Convey("Elements are validated", func() {
+			req, _ := http.NewRequest("POST", "/orders", strings.NewReader("items[0].count=1"))
+			req.Header.Set("Content-Type", formContentType)
+			m.ServeHTTP(httptest.NewRecorder(), req)
+			So(actual.Billing, ShouldBeNil)
+			So(errs.Has(ERR_REQUIRED), ShouldBeTrue)
+		})
+	})
+}
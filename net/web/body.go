@@ -0,0 +1,158 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// limitedBody is request body limited by BodyLimit, body is the original one.
+type limitedBody struct {
+	io.ReadCloser
+	body   io.ReadCloser
+	resp   http.ResponseWriter
+	limit  int64
+	length int64
+}
+
+// Read fails without reading if Content-Length of request exceeds limit.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.length > b.limit {
+		b.resp.Header().Set("Connection", "close")
+		return 0, &http.MaxBytesError{Limit: b.limit}
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// BodyLimit returns a middleware which limits size of request body to limit bytes.
+// It can be used as handler of groups and routes, the innermost one takes effect,
+// e.g. a route is able to accept larger body than other routes of its group.
+//
+// Reading body fails with *http.MaxBytesError if its Content-Length or the bytes
+// read exceed limit, which is written as 413 problem when returned by handlers.
+func BodyLimit(limit int64) Handler {
+	return func(c *Context) {
+		if c.Req.Request.Body == nil || c.Req.Request.Body == http.NoBody {
+			return
+		}
+		body := c.Req.Request.Body
+		if lb, ok := body.(*limitedBody); ok {
+			body = lb.body
+		}
+		c.Req.Request.Body = &limitedBody{
+			ReadCloser: http.MaxBytesReader(c.Resp, body, limit),
+			body:       body,
+			resp:       c.Resp,
+			limit:      limit,
+			length:     c.Req.ContentLength,
+		}
+	}
+}
+
+// Part is a part of multipart request body read by Context.ReadParts. Reading it
+// counts size and computes SHA-256 checksum of content.
+type Part struct {
+	*multipart.Part
+	// ContentType is detected from content by http.DetectContentType, it's more
+	// reliable than Content-Type header of the part which is set by clients.
+	ContentType string
+
+	r    io.Reader
+	hash hash.Hash
+	size int64
+}
+
+func newPart(p *multipart.Part) (*Part, error) {
+	br := bufio.NewReaderSize(p, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	return &Part{
+		Part:        p,
+		ContentType: http.DetectContentType(head),
+		r:           br,
+		hash:        sha256.New(),
+	}, nil
+}
+
+func (p *Part) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.hash.Write(b[:n])
+	p.size += int64(n)
+	return n, err
+}
+
+// IsFile returns true if the part is a file of form.
+func (p *Part) IsFile() bool {
+	return len(p.FileName()) > 0
+}
+
+// Size returns number of bytes read.
+func (p *Part) Size() int64 {
+	return p.size
+}
+
+// Checksum returns hex encoded SHA-256 checksum of content read.
+func (p *Part) Checksum() string {
+	return hex.EncodeToString(p.hash.Sum(nil))
+}
+
+// ReadParts calls fn with parts of multipart request body one at a time, so that
+// files are processed as streams without buffering in memory or temporary files.
+// Unread content of a part is discarded after fn returns, and reading stops at
+// the first error returned by fn. The error is a 400 problem if body is not multipart.
+//
+// Example:
+//
+//	m.Post("/upload", web.BodyLimit(1<<30), func(ctx *web.Context) error {
+//		return ctx.ReadParts(func(p *web.Part) error {
+//			if !p.IsFile() {
+//				return nil
+//			}
+//			_, err := io.Copy(dst, p)
+//			log.Println(p.FileName(), p.ContentType, p.Size(), p.Checksum())
+//			return err
+//		})
+//	})
+func (c *Context) ReadParts(fn func(*Part) error) error {
+	mr, err := c.Req.MultipartReader()
+	if err != nil {
+		return NewProblem(http.StatusBadRequest, err.Error())
+	}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		part, err := newPart(p)
+		if err == nil {
+			err = fn(part)
+		}
+		p.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_BodyLimit(t *testing.T) {
+	Convey("Limit size of request body", t, func() {
+		m := New()
+		m.Group("/api", func() {
+			m.Post("/small", func(ctx *Context) (string, error) {
+				data, err := ctx.Req.Body().Bytes()
+				return string(data), err
+			})
+			m.Post("/large", BodyLimit(10), func(ctx *Context) (string, error) {
+				data, err := ctx.Req.Body().Bytes()
+				return string(data), err
+			})
+		}, BodyLimit(5))
+
+		post := func(url, body string, chunked bool) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", url, strings.NewReader(body))
+			So(err, ShouldBeNil)
+			if chunked {
+				req.ContentLength = -1
+			}
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		So(post("/api/small", "12345", false).Body.String(), ShouldEqual, "12345")
+		resp := post("/api/small", "123456", false)
+		So(resp.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+		So(resp.Header().Get("Content-Type"), ShouldEqual, _CONTENT_PROBLEM)
+
+		resp = post("/api/small", "123456", true)
+		So(resp.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+		So(resp.Header().Get("Content-Type"), ShouldEqual, _CONTENT_PROBLEM)
+
+		// The route limit overrides the group limit, with or without Content-Length.
+		So(post("/api/large", "1234567890", false).Body.String(), ShouldEqual, "1234567890")
+		So(post("/api/large", "1234567890", true).Body.String(), ShouldEqual, "1234567890")
+		So(post("/api/large", "12345678901", false).Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+		So(post("/api/large", "12345678901", true).Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+	})
+}
+
+func Test_Context_ReadParts(t *testing.T) {
+	Convey("Read multipart body part by part", t, func() {
+		png := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), bytes.Repeat([]byte{1}, 1024)...)
+		body := new(bytes.Buffer)
+		w := multipart.NewWriter(body)
+		w.WriteField("name", "avatar")
+		fw, _ := w.CreateFormFile("file", "avatar.txt")
+		fw.Write(png)
+		w.Close()
+
+		type result struct {
+			Name, FileName, ContentType, Checksum string
+			Size                                  int64
+		}
+		var results []result
+
+		m := New()
+		m.Post("/upload", func(ctx *Context) error {
+			return ctx.ReadParts(func(p *Part) error {
+				if p.IsFile() {
+					if _, err := ioutil.ReadAll(p); err != nil {
+						return err
+					}
+				}
+				results = append(results, result{p.FormName(), p.FileName(), p.ContentType, p.Checksum(), p.Size()})
+				return nil
+			})
+		})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("POST", "/upload", body)
+		So(err, ShouldBeNil)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		m.ServeHTTP(resp, req)
+		So(resp.Code, ShouldEqual, http.StatusOK)
+
+		sum := sha256.Sum256(png)
+		So(results, ShouldHaveLength, 2)
+		So(results[0].Name, ShouldEqual, "name")
+		So(results[0].ContentType, ShouldEqual, "text/plain; charset=utf-8")
+		So(results[0].Size, ShouldEqual, 0)
+		So(results[1], ShouldResemble, result{"file", "avatar.txt", "image/png", hex.EncodeToString(sum[:]), int64(len(png))})
+
+		Convey("Not multipart", func() {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", "/upload", strings.NewReader("a=b"))
+			So(err, ShouldBeNil)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			m.ServeHTTP(resp, req)
+			So(resp.Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
@@ -0,0 +1,474 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrEntryTooLarge is returned by BoundedCacher.Put if size of the value
+// exceeds the byte limit of a shard.
+var ErrEntryTooLarge = errors.New("cache: entry exceeds size limit")
+
+// Stats represents statistics of a cache.
+type Stats struct {
+	Hits   int64
+	Misses int64
+	// Evictions is number of entries evicted to make room for new ones.
+	Evictions int64
+	// Rejections is number of new entries not admitted by LFU policy.
+	Rejections int64
+	Entries    int64
+	Bytes      int64
+}
+
+// Stater is the interface that a cache adapter implements to report statistics.
+type Stater interface {
+	// Stats returns statistics of the cache.
+	Stats() Stats
+}
+
+// Sizer is the interface that a cached value implements to report its size
+// in bytes. Size of other values is estimated by their gob encoding.
+type Sizer interface {
+	Size() int64
+}
+
+// sizeOf returns estimated size of value in bytes.
+func sizeOf(val interface{}) int64 {
+	switch v := val.(type) {
+	case nil:
+		return 0
+	case Sizer:
+		return v.Size()
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int, uint, int64, uint64, float64:
+		return 8
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buf).Encode(val); err != nil {
+		// Values can not be encoded are counted as a pointer.
+		return 8
+	}
+	return int64(buf.Len())
+}
+
+// boundedItem represents an item of bounded cache.
+type boundedItem struct {
+	MemoryItem
+	key  string
+	size int64
+}
+
+// sketch is a count-min sketch of 4-bit counters which estimates access
+// frequency of keys, counters are halved periodically so old accesses fade.
+type sketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newSketch(capacity int) *sketch {
+	width := 16
+	for width < capacity {
+		width <<= 1
+	}
+	s := &sketch{mask: uint64(width - 1), resetAt: capacity * 10}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *sketch) index(h uint64, i int) uint64 {
+	return (h + uint64(i)*(h>>32|1)) & s.mask
+}
+
+func (s *sketch) add(h uint64) {
+	for i := range s.rows {
+		if idx := s.index(h, i); s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+	if s.additions++; s.additions >= s.resetAt {
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] >>= 1
+			}
+		}
+		s.additions /= 2
+	}
+}
+
+func (s *sketch) estimate(h uint64) uint8 {
+	min := uint8(15)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(h, i)]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// boundedShard is a partition of bounded cache with its own lock and bounds.
+type boundedShard struct {
+	lock  sync.Mutex
+	items map[string]*list.Element
+	// A list whose front is the most recently used.
+	list       *list.List
+	bytes      int64
+	maxEntries int
+	maxBytes   int64
+	sketch     *sketch
+}
+
+// full returns true if the shard can not hold extra entries and bytes.
+func (s *boundedShard) full(entries int, bytes int64) bool {
+	return (s.maxEntries > 0 && s.list.Len()+entries > s.maxEntries) ||
+		(s.maxBytes > 0 && s.bytes+bytes > s.maxBytes)
+}
+
+func (s *boundedShard) remove(e *list.Element) {
+	item := s.list.Remove(e).(*boundedItem)
+	delete(s.items, item.key)
+	s.bytes -= item.size
+}
+
+// BoundedCacher represents a memory cache adapter bounded by number of entries
+// and bytes, which evicts least recently used entries. With LFU policy, a new
+// entry is only admitted if it is used more frequently than the entry to evict,
+// like TinyLFU does.
+type BoundedCacher struct {
+	lock     sync.RWMutex
+	shards   []*boundedShard
+	interval int // GC interval.
+
+	hits, misses, evictions, rejections int64
+}
+
+// NewBoundedCacher creates and returns a new bounded cacher with default
+// configuration, which is replaced by StartAndGC.
+func NewBoundedCacher() *BoundedCacher {
+	c := &BoundedCacher{}
+	c.configure("")
+	return c
+}
+
+func (c *BoundedCacher) shard(key string) (*boundedShard, uint64) {
+	h := hashKey(key)
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.shards[h%uint64(len(c.shards))], h
+}
+
+// Put puts value into cache with key and expire time.
+// If expired is 0, it never expires but may be evicted.
+func (c *BoundedCacher) Put(key string, val interface{}, expire int64) error {
+	s, h := c.shard(key)
+	size := int64(len(key)) + sizeOf(val)
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return ErrEntryTooLarge
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.sketch != nil {
+		s.sketch.add(h)
+	}
+	item := &boundedItem{
+		MemoryItem: MemoryItem{val: val, created: time.Now().Unix(), expire: expire},
+		key:        key,
+		size:       size,
+	}
+
+	e, exists := s.items[key]
+	if exists {
+		s.bytes += size - e.Value.(*boundedItem).size
+		e.Value = item
+		s.list.MoveToFront(e)
+		// Replaced entry is at front, it's never evicted here.
+		for s.list.Len() > 1 && s.full(0, 0) {
+			s.remove(s.list.Back())
+			atomic.AddInt64(&c.evictions, 1)
+		}
+		return nil
+	}
+
+	if s.sketch != nil && s.full(1, size) {
+		victim := s.list.Back()
+		if victim != nil && s.sketch.estimate(h) <= s.sketch.estimate(hashKey(victim.Value.(*boundedItem).key)) {
+			atomic.AddInt64(&c.rejections, 1)
+			return nil
+		}
+	}
+	for s.list.Len() > 0 && s.full(1, size) {
+		s.remove(s.list.Back())
+		atomic.AddInt64(&c.evictions, 1)
+	}
+	s.items[key] = s.list.PushFront(item)
+	s.bytes += size
+	return nil
+}
+
+// get returns unexpired element of key, caller must hold the lock of shard.
+func (s *boundedShard) get(key string) *list.Element {
+	e, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	if e.Value.(*boundedItem).hasExpired() {
+		s.remove(e)
+		return nil
+	}
+	return e
+}
+
+// Get gets cached value by given key.
+func (c *BoundedCacher) Get(key string) interface{} {
+	s, h := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.sketch != nil {
+		s.sketch.add(h)
+	}
+	e := s.get(key)
+	if e == nil {
+		atomic.AddInt64(&c.misses, 1)
+		return nil
+	}
+	atomic.AddInt64(&c.hits, 1)
+	s.list.MoveToFront(e)
+	return e.Value.(*boundedItem).val
+}
+
+// Delete deletes cached value by given key.
+func (c *BoundedCacher) Delete(key string) error {
+	s, _ := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	return nil
+}
+
+func (c *BoundedCacher) update(key string, fn func(interface{}) (interface{}, error)) (err error) {
+	s, _ := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e := s.get(key)
+	if e == nil {
+		return errors.New("key not exist")
+	}
+	item := e.Value.(*boundedItem)
+	item.val, err = fn(item.val)
+	return err
+}
+
+// Incr increases cached int-type value by given key as a counter.
+func (c *BoundedCacher) Incr(key string) error {
+	return c.update(key, Incr)
+}
+
+// Decr decreases cached int-type value by given key as a counter.
+func (c *BoundedCacher) Decr(key string) error {
+	return c.update(key, Decr)
+}
+
+// IsExist returns true if cached value exists.
+func (c *BoundedCacher) IsExist(key string) bool {
+	s, _ := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.get(key) != nil
+}
+
+// Flush deletes all cached data.
+func (c *BoundedCacher) Flush() error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, s := range c.shards {
+		s.lock.Lock()
+		s.items = make(map[string]*list.Element)
+		s.list.Init()
+		s.bytes = 0
+		s.lock.Unlock()
+	}
+	return nil
+}
+
+// Stats returns statistics of the cache.
+func (c *BoundedCacher) Stats() Stats {
+	stats := Stats{
+		Hits:       atomic.LoadInt64(&c.hits),
+		Misses:     atomic.LoadInt64(&c.misses),
+		Evictions:  atomic.LoadInt64(&c.evictions),
+		Rejections: atomic.LoadInt64(&c.rejections),
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, s := range c.shards {
+		s.lock.Lock()
+		stats.Entries += int64(s.list.Len())
+		stats.Bytes += s.bytes
+		s.lock.Unlock()
+	}
+	return stats
+}
+
+func (c *BoundedCacher) startGC() {
+	c.lock.RLock()
+	interval, shards := c.interval, c.shards
+	c.lock.RUnlock()
+
+	if interval < 1 {
+		return
+	}
+
+	for _, s := range shards {
+		s.lock.Lock()
+		for key := range s.items {
+			s.get(key)
+		}
+		s.lock.Unlock()
+	}
+
+	time.AfterFunc(time.Duration(interval)*time.Second, func() { c.startGC() })
+}
+
+// configure replaces shards by configuration, existing data is dropped.
+func (c *BoundedCacher) configure(config string) error {
+	vals, err := url.ParseQuery(config)
+	if err != nil {
+		return err
+	}
+	intVal := func(name string, def int64) (int64, error) {
+		if len(vals.Get(name)) == 0 {
+			return def, nil
+		}
+		v, err := strconv.ParseInt(vals.Get(name), 10, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("cache: invalid %s '%s'", name, vals.Get(name))
+		}
+		return v, nil
+	}
+
+	maxBytes, err := intVal("max_bytes", 0)
+	if err != nil {
+		return err
+	}
+	maxEntries, err := intVal("max_entries", 10000)
+	if err != nil {
+		return err
+	}
+	if maxEntries == 0 && maxBytes == 0 {
+		return errors.New("cache: bounded cacher requires max_entries or max_bytes")
+	}
+	n, err := intVal("shards", 16)
+	if err != nil {
+		return err
+	} else if n == 0 {
+		n = 1
+	}
+	var lfu bool
+	switch policy := vals.Get("policy"); policy {
+	case "", "lru":
+	case "lfu":
+		lfu = true
+	default:
+		return fmt.Errorf("cache: unknown eviction policy '%s'", policy)
+	}
+
+	shards := make([]*boundedShard, n)
+	for i := range shards {
+		s := &boundedShard{
+			items: make(map[string]*list.Element),
+			list:  list.New(),
+			// Bounds are divided by shards, rounding up.
+			maxEntries: int((maxEntries + n - 1) / n),
+			maxBytes:   (maxBytes + n - 1) / n,
+		}
+		if lfu {
+			capacity := s.maxEntries
+			if capacity == 0 {
+				// Assume small entries if only bytes are bounded.
+				capacity = int(s.maxBytes / 64)
+			}
+			s.sketch = newSketch(capacity)
+		}
+		shards[i] = s
+	}
+
+	c.lock.Lock()
+	c.shards = shards
+	c.lock.Unlock()
+	return nil
+}
+
+// StartAndGC configures the cache and starts GC routine, existing data is dropped.
+// AdapterConfig: max_entries=10000&max_bytes=0&policy=lru&shards=16
+//
+// Default bound is 10000 entries, bounds of 0 are unlimited, policy is lru or lfu.
+func (c *BoundedCacher) StartAndGC(opt Options) error {
+	if err := c.configure(opt.AdapterConfig); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.interval = opt.Interval
+	c.lock.Unlock()
+
+	go c.startGC()
+	return nil
+}
+
+func init() {
+	Register("bounded", NewBoundedCacher())
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_BoundedCacher(t *testing.T) {
+	Convey("Test bounded cache adapter", t, func() {
+		testAdapter(Options{
+			Adapter:       "bounded",
+			AdapterConfig: "max_entries=100&shards=4",
+			Interval:      2,
+		})
+	})
+
+	Convey("Evict least recently used entries", t, func() {
+		c := NewBoundedCacher()
+		So(c.StartAndGC(Options{AdapterConfig: "max_entries=3&shards=1"}), ShouldBeNil)
+
+		for _, key := range []string{"a", "b", "c"} {
+			So(c.Put(key, key, 0), ShouldBeNil)
+		}
+		So(c.Get("a"), ShouldEqual, "a")
+		So(c.Put("d", "d", 0), ShouldBeNil)
+		So(c.IsExist("b"), ShouldBeFalse)
+		So(c.IsExist("a"), ShouldBeTrue)
+		So(c.Get("x"), ShouldBeNil)
+
+		stats := c.Stats()
+		So(stats.Hits, ShouldEqual, 1)
+		So(stats.Misses, ShouldEqual, 1)
+		So(stats.Evictions, ShouldEqual, 1)
+		So(stats.Entries, ShouldEqual, 3)
+		So(stats.Bytes, ShouldEqual, 6)
+
+		So(c.Flush(), ShouldBeNil)
+		So(c.Stats().Entries, ShouldEqual, 0)
+	})
+
+	Convey("Bound cache by bytes", t, func() {
+		c := NewBoundedCacher()
+		So(c.StartAndGC(Options{AdapterConfig: "max_entries=0&max_bytes=20&shards=1"}), ShouldBeNil)
+
+		So(c.Put("k", strings.Repeat("v", 20), 0), ShouldEqual, ErrEntryTooLarge)
+		So(c.Put("a", strings.Repeat("v", 9), 0), ShouldBeNil)
+		So(c.Put("b", strings.Repeat("v", 9), 0), ShouldBeNil)
+		So(c.Put("c", strings.Repeat("v", 4), 0), ShouldBeNil)
+		So(c.IsExist("a"), ShouldBeFalse)
+		So(c.Stats().Bytes, ShouldEqual, 15)
+
+		Convey("Replace entry with larger value", func() {
+			So(c.Put("c", strings.Repeat("v", 14), 0), ShouldBeNil)
+			So(c.IsExist("b"), ShouldBeFalse)
+			So(c.Stats().Bytes, ShouldEqual, 15)
+		})
+	})
+
+	Convey("Admit frequently used entries with LFU policy", t, func() {
+		c := NewBoundedCacher()
+		So(c.StartAndGC(Options{AdapterConfig: "max_entries=2&shards=1&policy=lfu"}), ShouldBeNil)
+
+		So(c.Put("hot", 1, 0), ShouldBeNil)
+		So(c.Put("warm", 2, 0), ShouldBeNil)
+		for i := 0; i < 5; i++ {
+			c.Get("hot")
+			c.Get("warm")
+		}
+
+		// Scanning keys once does not flush frequently used entries.
+		for i := 0; i < 10; i++ {
+			So(c.Put(fmt.Sprint("scan", i), i, 0), ShouldBeNil)
+		}
+		So(c.IsExist("hot"), ShouldBeTrue)
+		So(c.IsExist("warm"), ShouldBeTrue)
+		So(c.Stats().Rejections, ShouldEqual, 10)
+
+		// A key used often enough is admitted.
+		for i := 0; i < 10; i++ {
+			c.Get("new")
+		}
+		So(c.Put("new", 3, 0), ShouldBeNil)
+		So(c.Get("new"), ShouldEqual, 3)
+		So(c.Stats().Evictions, ShouldEqual, 1)
+	})
+
+	Convey("Access concurrently", t, func() {
+		c := NewBoundedCacher()
+		So(c.StartAndGC(Options{AdapterConfig: "max_entries=64"}), ShouldBeNil)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					key := fmt.Sprint(i, "-", j%100)
+					c.Put(key, j, 0)
+					c.Get(key)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		stats := c.Stats()
+		So(stats.Entries, ShouldBeLessThanOrEqualTo, 64)
+		So(stats.Hits+stats.Misses, ShouldEqual, 8000)
+	})
+
+	Convey("Reject invalid configuration", t, func() {
+		c := NewBoundedCacher()
+		So(c.StartAndGC(Options{AdapterConfig: "policy=fifo"}), ShouldNotBeNil)
+		So(c.StartAndGC(Options{AdapterConfig: "max_entries=-1"}), ShouldNotBeNil)
+		So(c.StartAndGC(Options{AdapterConfig: "max_entries=0"}), ShouldNotBeNil)
+	})
+}
@@ -48,6 +48,14 @@ type Cache interface {
 	StartAndGC(opt Options) error
 }
 
+// TagCache is the interface that an adapter implements to invalidate keys by tags.
+type TagCache interface {
+	// PutTagged puts value into cache with key, expire time and tags.
+	PutTagged(key string, val interface{}, expire int64, tags ...string) error
+	// InvalidateTags deletes cached values of keys with any of given tags.
+	InvalidateTags(tags ...string) error
+}
+
 // Options represents a struct for specifying configuration options for the cache middleware.
 type Options struct {
 	// Name of adapter. Default is "memory".
@@ -92,9 +100,9 @@ func Cacher(options ...Options) web.Handler {
 	if err != nil {
 		panic(err)
 	}
-	return func(ctx *web.Context) {
+	return web.Mapping(func(ctx *web.Context) {
 		ctx.Map(cache)
-	}
+	}, cache)
 }
 
 var adapters = make(map[string]Cache)
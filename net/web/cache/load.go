@@ -0,0 +1,248 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cache
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// ErrNotExist is returned by GetInto if key does not exist in cache.
+var ErrNotExist = errors.New("cache: key not exist")
+
+// Decode decodes a cached value into dst, which must be a non-nil pointer.
+// Values assignable or convertible to the pointed type are copied directly,
+// strings and bytes are parsed, i.e. values of redis are decoded from their
+// text or JSON representation.
+func Decode(val, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cache: decode into non-pointer %T", dst)
+	}
+	elem := rv.Elem()
+	v := reflect.ValueOf(val)
+	if !v.IsValid() {
+		elem.Set(reflect.Zero(elem.Type()))
+		return nil
+	}
+	if v.Type().AssignableTo(elem.Type()) {
+		elem.Set(v)
+		return nil
+	}
+
+	var data []byte
+	switch s := val.(type) {
+	case string:
+		data = []byte(s)
+	case []byte:
+		data = s
+	default:
+		if isNumber(v.Kind()) && isNumber(elem.Kind()) {
+			elem.Set(v.Convert(elem.Type()))
+			return nil
+		}
+		return fmt.Errorf("cache: can not decode %T into %T", val, dst)
+	}
+
+	if u, ok := dst.(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText(data)
+	}
+	var err error
+	switch elem.Kind() {
+	case reflect.String:
+		elem.SetString(string(data))
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(string(data))
+		elem.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(string(data), 10, elem.Type().Bits())
+		elem.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(string(data), 10, elem.Type().Bits())
+		elem.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(string(data), elem.Type().Bits())
+		elem.SetFloat(f)
+	default:
+		if elem.Kind() == reflect.Slice && elem.Type().Elem().Kind() == reflect.Uint8 {
+			elem.SetBytes(append([]byte(nil), data...))
+			return nil
+		}
+		err = json.Unmarshal(data, dst)
+	}
+	return err
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+// GetInto gets cached value by given key and decodes it into dst,
+// it returns ErrNotExist if key does not exist.
+func GetInto(c Cache, key string, dst interface{}) error {
+	val := c.Get(key)
+	if val == nil {
+		return ErrNotExist
+	}
+	return Decode(val, dst)
+}
+
+// LoadFunc loads value of a key missing in cache.
+type LoadFunc func() (interface{}, error)
+
+// LoadOptions represents a struct for specifying options of GetOrLoad.
+type LoadOptions struct {
+	// Seconds to serve a value after its TTL while it is reloaded in
+	// background. Default is 0, values are reloaded once expired.
+	Stale int64
+}
+
+// loadGroups deduplicates loads of keys per cache.
+var loadGroups = struct {
+	lock   sync.Mutex
+	groups map[Cache]*group
+}{groups: make(map[Cache]*group)}
+
+func loadGroup(c Cache) *group {
+	loadGroups.lock.Lock()
+	defer loadGroups.lock.Unlock()
+
+	// Caches can not be map keys share a group.
+	if !reflect.TypeOf(c).Comparable() {
+		c = nil
+	}
+	g, ok := loadGroups.groups[c]
+	if !ok {
+		g = new(group)
+		loadGroups.groups[c] = g
+	}
+	return g
+}
+
+// freshKey returns key of the marker which exists until value of key is stale.
+func freshKey(key string) string {
+	return key + "#fresh"
+}
+
+// GetOrLoad gets cached value by given key, or loads and puts it into cache
+// with ttl in seconds if missing. Concurrent loads of a key are performed
+// once. A nil value loaded is returned without being cached. Use Decode to
+// get a typed value, as adapters like redis return values in text.
+//
+// If load panics, the panic is propagated to the caller which runs it, and
+// callers waiting for it receive an error.
+//
+// With LoadOptions.Stale, a value is kept for extra seconds after ttl, during
+// which it is returned while reloaded in background.
+func GetOrLoad(c Cache, key string, ttl int64, load LoadFunc, options ...LoadOptions) (interface{}, error) {
+	var opt LoadOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if ttl == 0 {
+		// Values never expire, so they are never stale.
+		opt.Stale = 0
+	}
+
+	g := loadGroup(c)
+	reload := func() (interface{}, error) {
+		val, err := load()
+		if err != nil || val == nil {
+			return val, err
+		}
+		if opt.Stale == 0 {
+			return val, c.Put(key, val, ttl)
+		}
+		if err = c.Put(key, val, ttl+opt.Stale); err != nil {
+			return nil, err
+		}
+		return val, c.Put(freshKey(key), 1, ttl)
+	}
+
+	if val := c.Get(key); val != nil {
+		// Get checks expiration, which IsExist of some adapters does not.
+		if opt.Stale > 0 && c.Get(freshKey(key)) == nil {
+			go func() {
+				// Nobody is able to recover panics of background reloading.
+				defer func() { recover() }()
+				g.do(key, reload)
+			}()
+		}
+		return val, nil
+	}
+	return g.do(key, reload)
+}
+
+// MultiCache is the interface that an adapter implements to operate multiple
+// keys at once, e.g. in a round trip.
+type MultiCache interface {
+	// GetMulti gets cached values by given keys, missing keys are absent in result.
+	GetMulti(keys ...string) map[string]interface{}
+	// PutMulti puts values into cache with keys and expire time.
+	PutMulti(items map[string]interface{}, expire int64) error
+	// DeleteMulti deletes cached values by given keys.
+	DeleteMulti(keys ...string) error
+}
+
+// GetMulti gets cached values by given keys, missing keys are absent in result.
+func GetMulti(c Cache, keys ...string) map[string]interface{} {
+	if mc, ok := c.(MultiCache); ok {
+		return mc.GetMulti(keys...)
+	}
+	vals := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		if val := c.Get(key); val != nil {
+			vals[key] = val
+		}
+	}
+	return vals
+}
+
+// PutMulti puts values into cache with keys and expire time.
+func PutMulti(c Cache, items map[string]interface{}, expire int64) error {
+	if mc, ok := c.(MultiCache); ok {
+		return mc.PutMulti(items, expire)
+	}
+	for key, val := range items {
+		if err := c.Put(key, val, expire); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMulti deletes cached values by given keys, it returns the first
+// error after trying all keys.
+func DeleteMulti(c Cache, keys ...string) error {
+	if mc, ok := c.(MultiCache); ok {
+		return mc.DeleteMulti(keys...)
+	}
+	var err error
+	for _, key := range keys {
+		if e := c.Delete(key); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
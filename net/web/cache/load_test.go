@@ -0,0 +1,202 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type loadUser struct {
+	Name string
+	Age  int
+}
+
+func Test_Decode(t *testing.T) {
+	Convey("Decode cached values", t, func() {
+		var u loadUser
+		So(Decode(loadUser{"web", 1}, &u), ShouldBeNil)
+		So(u.Name, ShouldEqual, "web")
+		So(Decode(`{"Name":"redis","Age":2}`, &u), ShouldBeNil)
+		So(u.Age, ShouldEqual, 2)
+
+		var i64 int64
+		So(Decode(3, &i64), ShouldBeNil)
+		So(i64, ShouldEqual, 3)
+		So(Decode("4", &i64), ShouldBeNil)
+		So(i64, ShouldEqual, 4)
+		So(Decode("x", &i64), ShouldNotBeNil)
+
+		var b bool
+		So(Decode("true", &b), ShouldBeNil)
+		So(b, ShouldBeTrue)
+
+		var tm time.Time
+		So(Decode("2018-01-02T03:04:05Z", &tm), ShouldBeNil)
+		So(tm.Year(), ShouldEqual, 2018)
+
+		var s string
+		So(Decode([]byte("bytes"), &s), ShouldBeNil)
+		So(s, ShouldEqual, "bytes")
+		So(Decode(1.5, &s), ShouldNotBeNil)
+		So(Decode("v", s), ShouldNotBeNil)
+	})
+
+	Convey("Get typed values from adapters", t, func() {
+		dir, err := ioutil.TempDir("", "cache")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		file := NewFileCacher()
+		So(file.StartAndGC(Options{AdapterConfig: dir}), ShouldBeNil)
+		gob.Register(loadUser{})
+
+		for _, c := range []Cache{NewMemoryCacher(), NewBoundedCacher(), file} {
+			var u loadUser
+			So(GetInto(c, "user", &u), ShouldEqual, ErrNotExist)
+			So(c.Put("user", loadUser{"web", 1}, 0), ShouldBeNil)
+			So(GetInto(c, "user", &u), ShouldBeNil)
+			So(u, ShouldResemble, loadUser{"web", 1})
+		}
+	})
+}
+
+func Test_GetOrLoad(t *testing.T) {
+	Convey("Load missing values once", t, func() {
+		c := NewMemoryCacher()
+		var loads int32
+		release := make(chan struct{})
+		load := func() (interface{}, error) {
+			atomic.AddInt32(&loads, 1)
+			<-release
+			return "loaded", nil
+		}
+
+		var wg sync.WaitGroup
+		vals := make([]interface{}, 10)
+		for i := range vals {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				vals[i], _ = GetOrLoad(c, "key", 60, load)
+			}(i)
+		}
+		// Let all callers wait for the load in flight.
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		So(atomic.LoadInt32(&loads), ShouldEqual, 1)
+		for _, val := range vals {
+			So(val, ShouldEqual, "loaded")
+		}
+		So(c.Get("key"), ShouldEqual, "loaded")
+
+		val, err := GetOrLoad(c, "key", 60, load)
+		So(err, ShouldBeNil)
+		So(val, ShouldEqual, "loaded")
+		So(atomic.LoadInt32(&loads), ShouldEqual, 1)
+	})
+
+	Convey("Do not cache errors and nil values", t, func() {
+		c := NewMemoryCacher()
+		_, err := GetOrLoad(c, "key", 60, func() (interface{}, error) {
+			return nil, errors.New("failed")
+		})
+		So(err, ShouldNotBeNil)
+		val, err := GetOrLoad(c, "key", 60, func() (interface{}, error) {
+			return nil, nil
+		})
+		So(err, ShouldBeNil)
+		So(val, ShouldBeNil)
+		So(c.IsExist("key"), ShouldBeFalse)
+	})
+
+	Convey("Recover from panics of loader", t, func() {
+		c := NewMemoryCacher()
+		started, release := make(chan struct{}), make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() { panicked <- recover() }()
+			GetOrLoad(c, "key", 60, func() (interface{}, error) {
+				close(started)
+				<-release
+				panic("boom")
+			})
+		}()
+		<-started
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := GetOrLoad(c, "key", 60, func() (interface{}, error) {
+				return "never", nil
+			})
+			errs <- err
+		}()
+		// Let the caller wait for the load in flight.
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		So(<-panicked, ShouldEqual, "boom")
+		So(<-errs, ShouldNotBeNil)
+
+		val, err := GetOrLoad(c, "key", 60, func() (interface{}, error) {
+			return "loaded", nil
+		})
+		So(err, ShouldBeNil)
+		So(val, ShouldEqual, "loaded")
+	})
+
+	Convey("Serve stale value while reloading", t, func() {
+		c := NewMemoryCacher()
+		var loads int32
+		load := func() (interface{}, error) {
+			return atomic.AddInt32(&loads, 1), nil
+		}
+
+		val, err := GetOrLoad(c, "key", 1, load, LoadOptions{Stale: 10})
+		So(err, ShouldBeNil)
+		So(val, ShouldEqual, 1)
+
+		time.Sleep(2 * time.Second)
+		val, err = GetOrLoad(c, "key", 1, load, LoadOptions{Stale: 10})
+		So(err, ShouldBeNil)
+		So(val, ShouldEqual, 1)
+
+		for i := 0; i < 100 && atomic.LoadInt32(&loads) < 2; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(atomic.LoadInt32(&loads), ShouldEqual, 2)
+		So(c.Get("key"), ShouldEqual, 2)
+	})
+}
+
+func Test_MultiOperations(t *testing.T) {
+	Convey("Operate multiple keys", t, func() {
+		c := NewBoundedCacher()
+		So(PutMulti(c, map[string]interface{}{"a": 1, "b": 2}, 0), ShouldBeNil)
+		So(GetMulti(c, "a", "b", "c"), ShouldResemble, map[string]interface{}{"a": 1, "b": 2})
+		So(DeleteMulti(c, "a", "c"), ShouldBeNil)
+		So(GetMulti(c, "a", "b"), ShouldResemble, map[string]interface{}{"b": 2})
+	})
+}
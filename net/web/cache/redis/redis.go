@@ -16,7 +16,9 @@
 package cache
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"time"
 
 	"landzero.net/x/com"
@@ -33,28 +35,31 @@ type RedisCacher struct {
 	occupyMode bool
 }
 
-// Put puts value into cache with key and expire time.
-// If expired is 0, it lives forever.
-func (c *RedisCacher) Put(key string, val interface{}, expire int64) error {
-	key = c.prefix + key
-	if expire == 0 {
-		if err := c.c.Set(key, com.ToStr(val), 0).Err(); err != nil {
-			return err
-		}
-	} else {
-		dur, err := time.ParseDuration(com.ToStr(expire) + "s")
-		if err != nil {
-			return err
-		}
-		if err = c.c.Set(key, com.ToStr(val), dur).Err(); err != nil {
-			return err
+// encode returns text of value, strings, bytes, numbers and booleans, also of
+// named types, are kept as text and other values are encoded in JSON, so they
+// can be decoded by cache.Decode.
+func encode(val interface{}) (string, error) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes()), nil
 		}
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return com.ToStr(val), nil
 	}
+	data, err := json.Marshal(val)
+	return string(data), err
+}
 
-	if c.occupyMode {
-		return nil
-	}
-	return c.c.HSet(c.hsetName, key, "0").Err()
+// Put puts value into cache with key and expire time.
+// If expired is 0, it lives forever.
+func (c *RedisCacher) Put(key string, val interface{}, expire int64) error {
+	return c.PutMulti(map[string]interface{}{key: val}, expire)
 }
 
 // Get gets cached value by given key.
@@ -68,15 +73,69 @@ func (c *RedisCacher) Get(key string) interface{} {
 
 // Delete deletes cached value by given key.
 func (c *RedisCacher) Delete(key string) error {
-	key = c.prefix + key
-	if err := c.c.Del(key).Err(); err != nil {
+	return c.DeleteMulti(key)
+}
+
+// GetMulti gets cached values by given keys in a round trip.
+func (c *RedisCacher) GetMulti(keys ...string) map[string]interface{} {
+	vals := make(map[string]interface{}, len(keys))
+	if len(keys) == 0 {
+		return vals
+	}
+	pkeys := make([]string, len(keys))
+	for i, key := range keys {
+		pkeys[i] = c.prefix + key
+	}
+	results, err := c.c.MGet(pkeys...).Result()
+	if err != nil {
+		return vals
+	}
+	for i, val := range results {
+		if val != nil {
+			vals[keys[i]] = val
+		}
+	}
+	return vals
+}
+
+// PutMulti puts values into cache with keys and expire time in a pipeline.
+// If expired is 0, they live forever.
+func (c *RedisCacher) PutMulti(items map[string]interface{}, expire int64) error {
+	dur := time.Duration(expire) * time.Second
+	_, err := c.c.Pipelined(func(pipe redis.Pipeliner) error {
+		for key, val := range items {
+			text, err := encode(val)
+			if err != nil {
+				return err
+			}
+			key = c.prefix + key
+			pipe.Set(key, text, dur)
+			if !c.occupyMode {
+				pipe.HSet(c.hsetName, key, "0")
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// DeleteMulti deletes cached values by given keys.
+func (c *RedisCacher) DeleteMulti(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pkeys := make([]string, len(keys))
+	for i, key := range keys {
+		pkeys[i] = c.prefix + key
+	}
+	if err := c.c.Del(pkeys...).Err(); err != nil {
 		return err
 	}
 
 	if c.occupyMode {
 		return nil
 	}
-	return c.c.HDel(c.hsetName, key).Err()
+	return c.c.HDel(c.hsetName, pkeys...).Err()
 }
 
 // Incr increases cached int-type value by given key as a counter.
@@ -138,6 +197,94 @@ func (c *RedisCacher) StartAndGC(opts cache.Options) error {
 	return c.c.Ping().Err()
 }
 
+// tagKey returns key of set of keys with given tag.
+func (c *RedisCacher) tagKey(tag string) string {
+	return c.prefix + "tag:" + tag
+}
+
+// tagScript adds key to sets of tags and keeps each set alive at least as long as key.
+//
+// KEYS: sets of tags
+// ARGV: key, expire time in seconds, 0 means forever
+var tagScript = redis.NewScript(`
+local expire = tonumber(ARGV[2])
+for _, tag in ipairs(KEYS) do
+	local ttl = redis.call("TTL", tag)
+	redis.call("SADD", tag, ARGV[1])
+	if expire == 0 then
+		redis.call("PERSIST", tag)
+	elseif ttl == -2 or (ttl >= 0 and ttl < expire) then
+		redis.call("EXPIRE", tag, expire)
+	end
+end
+return 0
+`)
+
+// PutTagged puts value into cache with key, expire time and tags.
+// Sets of tags expire after the last key put with them, or never if expire is 0.
+func (c *RedisCacher) PutTagged(key string, val interface{}, expire int64, tags ...string) error {
+	if err := c.Put(key, val, expire); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	tagKeys := make([]string, len(tags))
+	for i, tag := range tags {
+		tagKeys[i] = c.tagKey(tag)
+	}
+	if err := tagScript.Run(c.c, tagKeys, key, expire).Err(); err != nil {
+		return err
+	}
+	if c.occupyMode {
+		return nil
+	}
+	_, err := c.c.Pipelined(func(pipe redis.Pipeliner) error {
+		for _, tagKey := range tagKeys {
+			pipe.HSet(c.hsetName, tagKey, "0")
+		}
+		return nil
+	})
+	return err
+}
+
+// invalidateTags deletes keys with any of given tags and returns them.
+func (c *RedisCacher) invalidateTags(tags ...string) ([]string, error) {
+	var keys, tagKeys []string
+	for _, tag := range tags {
+		members, err := c.c.SMembers(c.tagKey(tag)).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, members...)
+		tagKeys = append(tagKeys, c.tagKey(tag))
+	}
+	if err := c.DeleteMulti(keys...); err != nil {
+		return nil, err
+	}
+	if len(tagKeys) == 0 {
+		return keys, nil
+	}
+	if err := c.c.Del(tagKeys...).Err(); err != nil {
+		return nil, err
+	}
+	if c.occupyMode {
+		return keys, nil
+	}
+	return keys, c.c.HDel(c.hsetName, tagKeys...).Err()
+}
+
+// InvalidateTags deletes cached values of keys with any of given tags.
+func (c *RedisCacher) InvalidateTags(tags ...string) error {
+	_, err := c.invalidateTags(tags...)
+	return err
+}
+
+var (
+	_ cache.MultiCache = &RedisCacher{}
+	_ cache.TagCache   = &RedisCacher{}
+)
+
 func init() {
 	cache.Register("redis", &RedisCacher{})
 }
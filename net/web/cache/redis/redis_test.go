@@ -27,6 +27,10 @@ import (
 	"landzero.net/x/net/web/cache"
 )
 
+type Status string
+
+type Blob []byte
+
 func Test_RedisCacher(t *testing.T) {
 	Convey("Test redis cache adapter", t, func() {
 		opt := cache.Options{
@@ -66,6 +70,55 @@ func Test_RedisCacher(t *testing.T) {
 			m.ServeHTTP(resp, req)
 		})
 
+		Convey("Round trip values of named types", func() {
+			m := web.New()
+			m.Use(cache.Cacher(opt))
+
+			m.Get("/", func(c cache.Cache) {
+				So(c.Put("status", Status("active"), 0), ShouldBeNil)
+				So(c.Get("status"), ShouldEqual, "active")
+				var status Status
+				So(cache.GetInto(c, "status", &status), ShouldBeNil)
+				So(status, ShouldEqual, Status("active"))
+
+				So(c.Put("blob", Blob("data"), 0), ShouldBeNil)
+				var blob Blob
+				So(cache.GetInto(c, "blob", &blob), ShouldBeNil)
+				So(string(blob), ShouldEqual, "data")
+
+				So(c.Flush(), ShouldBeNil)
+			})
+
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/", nil)
+			So(err, ShouldBeNil)
+			m.ServeHTTP(resp, req)
+		})
+
+		Convey("Expire sets of tags with their keys", func() {
+			c := &RedisCacher{}
+			So(c.StartAndGC(opt), ShouldBeNil)
+			defer c.Flush()
+			ttl := func() time.Duration {
+				return c.c.TTL(c.tagKey("expire")).Val()
+			}
+
+			So(c.PutTagged("tagged:1", "1", 10, "expire"), ShouldBeNil)
+			So(ttl(), ShouldAlmostEqual, 10*time.Second, time.Second)
+			So(c.PutTagged("tagged:2", "2", 100, "expire"), ShouldBeNil)
+			So(ttl(), ShouldAlmostEqual, 100*time.Second, time.Second)
+			So(c.PutTagged("tagged:3", "3", 5, "expire"), ShouldBeNil)
+			So(ttl(), ShouldAlmostEqual, 100*time.Second, time.Second)
+
+			So(c.PutTagged("tagged:4", "4", 0, "expire"), ShouldBeNil)
+			So(ttl(), ShouldEqual, -time.Second)
+			So(c.PutTagged("tagged:5", "5", 5, "expire"), ShouldBeNil)
+			So(ttl(), ShouldEqual, -time.Second)
+
+			So(c.InvalidateTags("expire"), ShouldBeNil)
+			So(c.IsExist("tagged:4"), ShouldBeFalse)
+		})
+
 		Convey("Increase and decrease operations", func() {
 			m := web.New()
 			m.Use(cache.Cacher(opt))
@@ -0,0 +1,309 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"landzero.net/x/database/redis"
+	"landzero.net/x/net/web/cache"
+)
+
+// invalidation is a message broadcast to drop local entries of other instances.
+type invalidation struct {
+	// From is ID of the instance which sends the message.
+	From  string   `json:"from"`
+	Keys  []string `json:"keys,omitempty"`
+	Flush bool     `json:"flush,omitempty"`
+}
+
+// TieredCacher represents a two-tier cache adapter implementation, which keeps
+// recently read values of redis in a small in-process cache. Changes are
+// broadcast over redis pub/sub, so every instance drops its stale local
+// entries, and local entries expire in a short time in case a message is lost.
+type TieredCacher struct {
+	lock     sync.RWMutex
+	remote   *RedisCacher
+	local    *cache.BoundedCacher
+	localTTL int64
+	channel  string
+	id       string
+	pubsub   *redis.PubSub
+}
+
+// NewTieredCacher creates and returns a new tiered cacher.
+func NewTieredCacher() *TieredCacher {
+	return &TieredCacher{}
+}
+
+func (c *TieredCacher) tiers() (*RedisCacher, *cache.BoundedCacher, int64) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.remote, c.local, c.localTTL
+}
+
+// publish broadcasts invalidation to other instances.
+func (c *TieredCacher) publish(msg invalidation) error {
+	c.lock.RLock()
+	remote, channel := c.remote, c.channel
+	msg.From = c.id
+	c.lock.RUnlock()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return remote.c.Publish(channel, string(data)).Err()
+}
+
+// invalidate drops local entries of keys and broadcasts it.
+func (c *TieredCacher) invalidate(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, local, _ := c.tiers()
+	cache.DeleteMulti(local, keys...)
+	return c.publish(invalidation{Keys: keys})
+}
+
+// Put puts value into cache with key and expire time.
+func (c *TieredCacher) Put(key string, val interface{}, expire int64) error {
+	remote, _, _ := c.tiers()
+	if err := remote.Put(key, val, expire); err != nil {
+		return err
+	}
+	return c.invalidate(key)
+}
+
+// Get gets cached value by given key, from local cache if possible.
+func (c *TieredCacher) Get(key string) interface{} {
+	remote, local, localTTL := c.tiers()
+	if val := local.Get(key); val != nil {
+		return val
+	}
+	val := remote.Get(key)
+	if val != nil {
+		local.Put(key, val, localTTL)
+	}
+	return val
+}
+
+// Delete deletes cached value by given key.
+func (c *TieredCacher) Delete(key string) error {
+	remote, _, _ := c.tiers()
+	if err := remote.Delete(key); err != nil {
+		return err
+	}
+	return c.invalidate(key)
+}
+
+// Incr increases cached int-type value by given key as a counter.
+func (c *TieredCacher) Incr(key string) error {
+	remote, _, _ := c.tiers()
+	if err := remote.Incr(key); err != nil {
+		return err
+	}
+	return c.invalidate(key)
+}
+
+// Decr decreases cached int-type value by given key as a counter.
+func (c *TieredCacher) Decr(key string) error {
+	remote, _, _ := c.tiers()
+	if err := remote.Decr(key); err != nil {
+		return err
+	}
+	return c.invalidate(key)
+}
+
+// IsExist returns true if cached value exists.
+func (c *TieredCacher) IsExist(key string) bool {
+	remote, local, _ := c.tiers()
+	return local.IsExist(key) || remote.IsExist(key)
+}
+
+// Flush deletes all cached data.
+func (c *TieredCacher) Flush() error {
+	remote, local, _ := c.tiers()
+	if err := remote.Flush(); err != nil {
+		return err
+	}
+	local.Flush()
+	return c.publish(invalidation{Flush: true})
+}
+
+// GetMulti gets cached values by given keys, missing local entries are read
+// from redis in a round trip.
+func (c *TieredCacher) GetMulti(keys ...string) map[string]interface{} {
+	remote, local, localTTL := c.tiers()
+	vals := cache.GetMulti(local, keys...)
+	var missing []string
+	for _, key := range keys {
+		if _, ok := vals[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return vals
+	}
+	for key, val := range remote.GetMulti(missing...) {
+		vals[key] = val
+		local.Put(key, val, localTTL)
+	}
+	return vals
+}
+
+// PutMulti puts values into cache with keys and expire time.
+func (c *TieredCacher) PutMulti(items map[string]interface{}, expire int64) error {
+	remote, _, _ := c.tiers()
+	if err := remote.PutMulti(items, expire); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	return c.invalidate(keys...)
+}
+
+// DeleteMulti deletes cached values by given keys.
+func (c *TieredCacher) DeleteMulti(keys ...string) error {
+	remote, _, _ := c.tiers()
+	if err := remote.DeleteMulti(keys...); err != nil {
+		return err
+	}
+	return c.invalidate(keys...)
+}
+
+// PutTagged puts value into cache with key, expire time and tags.
+func (c *TieredCacher) PutTagged(key string, val interface{}, expire int64, tags ...string) error {
+	remote, _, _ := c.tiers()
+	if err := remote.PutTagged(key, val, expire, tags...); err != nil {
+		return err
+	}
+	return c.invalidate(key)
+}
+
+// InvalidateTags deletes cached values of keys with any of given tags on every instance.
+func (c *TieredCacher) InvalidateTags(tags ...string) error {
+	remote, _, _ := c.tiers()
+	keys, err := remote.invalidateTags(tags...)
+	if err != nil {
+		return err
+	}
+	return c.invalidate(keys...)
+}
+
+// receive drops local entries by invalidations from other instances until pubsub is closed.
+func (c *TieredCacher) receive(pubsub *redis.PubSub, local *cache.BoundedCacher, id string) {
+	for m := range pubsub.Channel() {
+		var msg invalidation
+		if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+			log.Printf("cache(tiered): invalid invalidation message: %v", err)
+			continue
+		}
+		if msg.From == id {
+			continue
+		}
+		if msg.Flush {
+			local.Flush()
+		} else {
+			cache.DeleteMulti(local, msg.Keys...)
+		}
+	}
+}
+
+// StartAndGC starts GC routine based on config string settings.
+// AdapterConfig: redis://localhost:3333/1?local_max_entries=1000&local_ttl=10&channel=webcache
+//
+// Local cache keeps at most local_max_entries entries, default is 1000, for
+// local_ttl seconds, default is 10. Invalidations are sent to channel,
+// default is "webcache:invalidate".
+func (c *TieredCacher) StartAndGC(opts cache.Options) error {
+	u, err := url.Parse(opts.AdapterConfig)
+	if err != nil {
+		return err
+	}
+	vals := u.Query()
+	u.RawQuery = ""
+
+	maxEntries, localTTL := "1000", int64(10)
+	if v := vals.Get("local_max_entries"); len(v) > 0 {
+		maxEntries = v
+	}
+	if v := vals.Get("local_ttl"); len(v) > 0 {
+		if localTTL, err = strconv.ParseInt(v, 10, 64); err != nil || localTTL <= 0 {
+			return fmt.Errorf("cache: invalid local_ttl '%s'", v)
+		}
+	}
+	channel := vals.Get("channel")
+	if len(channel) == 0 {
+		channel = "webcache:invalidate"
+	}
+
+	remote := &RedisCacher{}
+	if err = remote.StartAndGC(cache.Options{
+		AdapterConfig: u.String(),
+		OccupyMode:    opts.OccupyMode,
+	}); err != nil {
+		return err
+	}
+	local := cache.NewBoundedCacher()
+	if err = local.StartAndGC(cache.Options{
+		AdapterConfig: "max_entries=" + maxEntries,
+		Interval:      opts.Interval,
+	}); err != nil {
+		return err
+	}
+	id := make([]byte, 8)
+	if _, err = rand.Read(id); err != nil {
+		return err
+	}
+
+	// Subscription is confirmed before use, so no invalidation is missed.
+	pubsub := remote.c.Subscribe(channel)
+	if _, err = pubsub.Receive(); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	c.lock.Lock()
+	old := c.pubsub
+	c.remote, c.local, c.localTTL = remote, local, localTTL
+	c.channel, c.id, c.pubsub = channel, hex.EncodeToString(id), pubsub
+	c.lock.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	go c.receive(pubsub, local, hex.EncodeToString(id))
+	return nil
+}
+
+var (
+	_ cache.MultiCache = &TieredCacher{}
+	_ cache.TagCache   = &TieredCacher{}
+)
+
+func init() {
+	cache.Register("tiered", NewTieredCacher())
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"landzero.net/x/net/web/cache"
+)
+
+// eventually waits for invalidation to be received.
+func eventually(cond func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func Test_TieredCacher(t *testing.T) {
+	Convey("Test tiered cache adapter", t, func() {
+		opt := cache.Options{
+			AdapterConfig: "redis://localhost:6379/1?local_ttl=60&local_max_entries=100",
+		}
+		a, b := NewTieredCacher(), NewTieredCacher()
+		So(a.StartAndGC(opt), ShouldBeNil)
+		So(b.StartAndGC(opt), ShouldBeNil)
+		defer a.Flush()
+
+		Convey("Invalidate local entries of other instances", func() {
+			So(a.Put("tiered", "v1", 0), ShouldBeNil)
+			So(b.Get("tiered"), ShouldEqual, "v1")
+
+			So(a.Put("tiered", "v2", 0), ShouldBeNil)
+			So(a.Get("tiered"), ShouldEqual, "v2")
+			So(eventually(func() bool { return b.Get("tiered") == "v2" }), ShouldBeTrue)
+
+			So(b.Delete("tiered"), ShouldBeNil)
+			So(eventually(func() bool { return a.Get("tiered") == nil }), ShouldBeTrue)
+		})
+
+		Convey("Invalidate keys by tags", func() {
+			So(a.PutTagged("profile:42", "web", 0, "user:42"), ShouldBeNil)
+			So(a.PutTagged("posts:42", "[]", 0, "user:42", "posts"), ShouldBeNil)
+			So(a.Put("profile:43", "macaron", 0), ShouldBeNil)
+			So(cache.GetMulti(b, "profile:42", "posts:42", "profile:43"), ShouldHaveLength, 3)
+
+			So(a.InvalidateTags("user:42"), ShouldBeNil)
+			So(eventually(func() bool {
+				return len(cache.GetMulti(b, "profile:42", "posts:42", "profile:43")) == 1
+			}), ShouldBeTrue)
+			So(b.Get("profile:43"), ShouldEqual, "macaron")
+		})
+
+		Convey("Flush local entries of other instances", func() {
+			So(a.Put("tiered", "v1", 0), ShouldBeNil)
+			So(b.Get("tiered"), ShouldEqual, "v1")
+
+			So(a.Flush(), ShouldBeNil)
+			So(eventually(func() bool { return !b.IsExist("tiered") }), ShouldBeTrue)
+		})
+	})
+}
@@ -194,7 +194,7 @@ func NewCaptcha(opt Options) *Captcha {
 // An single variadic captcha.Options struct can be optionally provided to configure.
 // This should be register after cache.Cacher.
 func Captchaer(options ...Options) web.Handler {
-	return func(ctx *web.Context, cache cache.Cache) {
+	return web.Mapping(func(ctx *web.Context, cache cache.Cache) {
 		cpt := NewCaptcha(prepareOptions(options))
 		cpt.store = cache
 
@@ -232,5 +232,5 @@ func Captchaer(options ...Options) web.Handler {
 
 		ctx.Data["Captcha"] = cpt
 		ctx.Map(cpt)
-	}
+	}, &Captcha{})
 }
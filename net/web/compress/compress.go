@@ -0,0 +1,416 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package compress is a middleware that provides gzip, deflate and other registered response compression.
+package compress
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"landzero.net/x/net/web"
+)
+
+const _VERSION = "0.1.0"
+
+func Version() string {
+	return _VERSION
+}
+
+// Encoder creates a writer which compresses data into w, level is the compression
+// level of Options and -1 means default level of the encoder.
+// Returned writer should implement Flush() error to support streaming responses.
+type Encoder func(w io.Writer, level int) (io.WriteCloser, error)
+
+var (
+	encoderLock sync.RWMutex
+	encoders    = map[string]Encoder{
+		"gzip": func(w io.Writer, level int) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		"deflate": func(w io.Writer, level int) (io.WriteCloser, error) {
+			return flate.NewWriter(w, level)
+		},
+	}
+)
+
+// Register registers an encoder by content-coding name, gzip and deflate are
+// registered by default. Brotli is not in the standard library, register an
+// implementation as "br" to enable it, e.g.:
+//
+//	compress.Register("br", func(w io.Writer, level int) (io.WriteCloser, error) {
+//		if level < 0 {
+//			level = brotli.DefaultCompression
+//		}
+//		return brotli.NewWriterLevel(w, level), nil
+//	})
+func Register(name string, encoder Encoder) {
+	if encoder == nil {
+		panic("compress: cannot register encoder with nil value")
+	}
+	encoderLock.Lock()
+	defer encoderLock.Unlock()
+	encoders[strings.ToLower(name)] = encoder
+}
+
+func getEncoder(name string) Encoder {
+	encoderLock.RLock()
+	defer encoderLock.RUnlock()
+	return encoders[name]
+}
+
+// Options represents a struct for specifying configuration options for the compress middleware.
+type Options struct {
+	// Level of compression, 0 means default level of each encoder.
+	Level int
+	// MinSize is the minimum size of response body to be compressed. Default is 1024,
+	// negative value compresses all responses. Streaming responses which are flushed
+	// before reaching the size are always compressed.
+	MinSize int
+	// ContentTypes is the allowlist of compressible media types, a trailing "*"
+	// matches any subtype, e.g. "text/*". Default is DefaultContentTypes.
+	ContentTypes []string
+	// Encodings is the preference order of content-codings when client accepts
+	// them equally. Default is "br", "gzip", "deflate", unregistered ones are ignored.
+	Encodings []string
+}
+
+// DefaultContentTypes are compressible media types used by default.
+var DefaultContentTypes = []string{
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/xml",
+	"text/csv",
+	"text/javascript",
+	"application/javascript",
+	"application/json",
+	"application/problem+json",
+	"application/xml",
+	"application/x-yaml",
+	"application/wasm",
+	"image/svg+xml",
+	"image/x-icon",
+	"font/ttf",
+	"font/otf",
+}
+
+func prepareOptions(options []Options) Options {
+	var opt Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if opt.Level == 0 {
+		opt.Level = -1
+	}
+	if opt.MinSize == 0 {
+		opt.MinSize = 1024
+	}
+	if len(opt.ContentTypes) == 0 {
+		opt.ContentTypes = DefaultContentTypes
+	}
+	if len(opt.Encodings) == 0 {
+		opt.Encodings = []string{"br", "gzip", "deflate"}
+	}
+	return opt
+}
+
+// Compressor returns a middleware handler that compresses responses by the
+// content-coding negotiated with Accept-Encoding header. It should be used
+// before Renderer and any middleware writing responses, e.g. Static.
+func Compressor(options ...Options) web.Handler {
+	opt := prepareOptions(options)
+
+	return func(ctx *web.Context) {
+		w := &responseWriter{
+			ResponseWriter: ctx.Resp,
+			opt:            &opt,
+			head:           ctx.Req.Method == "HEAD",
+			encoding:       negotiate(ctx.Req.Header.Get("Accept-Encoding"), opt.Encodings),
+		}
+		setResponseWriter(ctx, w)
+
+		defer func() {
+			if err := recover(); err != nil {
+				// Let recovery middleware write error response without compression.
+				w.abort()
+				setResponseWriter(ctx, w.ResponseWriter)
+				panic(err)
+			}
+		}()
+
+		ctx.Next()
+		w.finish()
+	}
+}
+
+func setResponseWriter(ctx *web.Context, w web.ResponseWriter) {
+	ctx.Resp = w
+	ctx.MapTo(w, (*http.ResponseWriter)(nil))
+	if _, ok := ctx.Render.(*web.DummyRender); !ok && ctx.Render != nil {
+		ctx.Render.SetResponseWriter(w)
+	}
+}
+
+// negotiate returns the preferred content-coding by Accept-Encoding header,
+// empty string means identity.
+func negotiate(header string, prefers []string) string {
+	if len(header) == 0 {
+		return ""
+	}
+
+	accepts := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if len(name) == 0 {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepts[name] = q
+	}
+
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, name := range prefers {
+		if getEncoder(name) == nil {
+			continue
+		}
+		q, ok := accepts[name]
+		if !ok {
+			q, ok = accepts["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// isCompressible returns true if media type matches any of types.
+func isCompressible(contentType string, types []string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if len(mediaType) == 0 {
+		return false
+	}
+	for _, t := range types {
+		if strings.HasSuffix(t, "*") {
+			if strings.HasPrefix(mediaType, t[:len(t)-1]) {
+				return true
+			}
+		} else if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// addVary adds Accept-Encoding to Vary header if not present.
+func addVary(h http.Header) {
+	for _, v := range h["Vary"] {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, "Accept-Encoding") {
+				return
+			}
+		}
+	}
+	h.Add("Vary", "Accept-Encoding")
+}
+
+type flusher interface {
+	Flush() error
+}
+
+// responseWriter buffers response until the decision of compression can be made.
+type responseWriter struct {
+	web.ResponseWriter
+	opt      *Options
+	head     bool
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	w       io.WriteCloser
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status != 0 || w.decided {
+		return
+	}
+	// Informational responses are sent before the final one, e.g. 103 Early Hints.
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+
+	h := w.Header()
+	switch {
+	case w.head, status == http.StatusSwitchingProtocols, status == http.StatusNoContent,
+		status == http.StatusPartialContent, status == http.StatusNotModified,
+		len(h.Get("Content-Encoding")) > 0, len(h.Get("Content-Range")) > 0:
+		w.decide(false)
+	case len(h.Get("Content-Type")) > 0 && !isCompressible(h.Get("Content-Type"), w.opt.ContentTypes):
+		w.decide(false)
+	case len(h.Get("Content-Length")) > 0:
+		// Size is known, e.g. ServeContent.
+		size, _ := strconv.Atoi(h.Get("Content-Length"))
+		w.decide(size >= w.opt.MinSize)
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) >= w.opt.MinSize {
+			w.decide(true)
+		}
+		return len(p), nil
+	}
+	if w.w != nil {
+		return w.w.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide writes header with compression enabled if it's allowed.
+func (w *responseWriter) decide(compress bool) {
+	w.decided = true
+
+	h := w.Header()
+	if len(h.Get("Content-Type")) == 0 && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	eligible := !w.head && status >= 200 && status != http.StatusNoContent &&
+		status != http.StatusPartialContent && status != http.StatusNotModified &&
+		len(h.Get("Content-Encoding")) == 0 && len(h.Get("Content-Range")) == 0 &&
+		isCompressible(h.Get("Content-Type"), w.opt.ContentTypes)
+	if eligible {
+		// Response varies on Accept-Encoding even when it's not compressed this time.
+		addVary(h)
+		if compress && len(w.encoding) > 0 {
+			enc, err := getEncoder(w.encoding)(w.ResponseWriter, w.opt.Level)
+			if err == nil {
+				w.w = enc
+				h.Set("Content-Encoding", w.encoding)
+				h.Del("Content-Length")
+				// Ranges are not applicable to compressed representation.
+				h.Del("Accept-Ranges")
+				// Compressed representation is not byte-identical, but semantically equivalent.
+				if etag := h.Get("ETag"); len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+					h.Set("ETag", "W/"+etag)
+				}
+			}
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+	if len(w.buf) > 0 {
+		buf := w.buf
+		w.buf = nil
+		if w.w != nil {
+			w.w.Write(buf)
+		} else {
+			w.ResponseWriter.Write(buf)
+		}
+	}
+}
+
+// finish writes buffered response and closes the encoder.
+func (w *responseWriter) finish() {
+	if !w.decided && (w.status != 0 || len(w.buf) > 0) {
+		w.decide(len(w.buf) >= w.opt.MinSize)
+	}
+	if w.w != nil {
+		w.w.Close()
+		w.w = nil
+	}
+}
+
+// abort discards buffered response if nothing has been sent.
+func (w *responseWriter) abort() {
+	if !w.decided {
+		w.buf = nil
+		w.status = 0
+		return
+	}
+	w.finish()
+}
+
+func (w *responseWriter) Flush() {
+	// Streaming responses are compressed regardless of the size.
+	if !w.decided && (w.status != 0 || len(w.buf) > 0) {
+		w.decide(true)
+	}
+	if f, ok := w.w.(flusher); ok {
+		f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *responseWriter) Status() int {
+	if w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *responseWriter) Written() bool {
+	return w.status != 0 || w.ResponseWriter.Written()
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	// Connection is taken over, nothing to compress.
+	w.decided = true
+	return hijacker.Hijack()
+}
+
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
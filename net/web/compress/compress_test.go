@@ -0,0 +1,230 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+)
+
+var bigText = strings.Repeat("Hello, compression! ", 200)
+
+func do(m *web.Web, method, url string, header http.Header) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest(method, url, nil)
+	So(err, ShouldBeNil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	m.ServeHTTP(resp, req)
+	return resp
+}
+
+func gunzip(p []byte) string {
+	r, err := gzip.NewReader(bytes.NewReader(p))
+	So(err, ShouldBeNil)
+	data, err := ioutil.ReadAll(r)
+	So(err, ShouldBeNil)
+	return string(data)
+}
+
+func Test_Version(t *testing.T) {
+	Convey("Check package version", t, func() {
+		So(Version(), ShouldEqual, _VERSION)
+	})
+}
+
+func Test_negotiate(t *testing.T) {
+	Convey("Negotiate content-coding", t, func() {
+		prefers := []string{"br", "gzip", "deflate"}
+		So(negotiate("", prefers), ShouldBeEmpty)
+		So(negotiate("gzip, deflate", prefers), ShouldEqual, "gzip")
+		So(negotiate("deflate;q=1, gzip;q=0.5", prefers), ShouldEqual, "deflate")
+		So(negotiate("gzip;q=0, *", prefers), ShouldEqual, "deflate")
+		So(negotiate("identity", prefers), ShouldBeEmpty)
+		// "br" is not registered by default.
+		So(negotiate("br", prefers), ShouldBeEmpty)
+	})
+
+	Convey("Match content types", t, func() {
+		So(isCompressible("text/html; charset=UTF-8", DefaultContentTypes), ShouldBeTrue)
+		So(isCompressible("image/png", DefaultContentTypes), ShouldBeFalse)
+		So(isCompressible("text/x-custom", []string{"text/*"}), ShouldBeTrue)
+		So(isCompressible("", []string{"text/*"}), ShouldBeFalse)
+	})
+}
+
+func Test_Compressor(t *testing.T) {
+	Convey("Compress responses", t, func() {
+		m := web.New()
+		m.Use(Compressor())
+		m.Use(web.Renderer())
+		m.SetAutoHead(true)
+		m.Get("/big", func(ctx *web.Context) {
+			ctx.PlainText(http.StatusOK, []byte(bigText))
+		})
+		m.Get("/small", func(ctx *web.Context) {
+			ctx.PlainText(http.StatusOK, []byte("small"))
+		})
+		m.Get("/png", func(ctx *web.Context) {
+			ctx.Resp.Header().Set("Content-Type", "image/png")
+			ctx.Resp.Write([]byte(bigText))
+		})
+		m.Get("/sniff", func(ctx *web.Context) {
+			ctx.Resp.Write([]byte("<html><body>" + bigText + "</body></html>"))
+		})
+		m.Get("/content", func(ctx *web.Context) {
+			ctx.Resp.Header().Set("ETag", `"v1"`)
+			ctx.ServeContent("content.txt", strings.NewReader(bigText), time.Unix(0, 0))
+		})
+		m.Get("/stream", func(ctx *web.Context) {
+			ctx.Resp.Header().Set("Content-Type", "text/plain")
+			ctx.Resp.Write([]byte("chunk"))
+			ctx.Resp.Flush()
+			ctx.Resp.Write([]byte("chunk"))
+		})
+		gzipHeader := http.Header{"Accept-Encoding": {"gzip, deflate"}}
+
+		Convey("Large response is compressed", func() {
+			resp := do(m, "GET", "/big", gzipHeader)
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(resp.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+			So(resp.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+			So(resp.Header().Get("Content-Type"), ShouldStartWith, "text/plain")
+			So(resp.Body.Len(), ShouldBeLessThan, len(bigText))
+			So(gunzip(resp.Body.Bytes()), ShouldEqual, bigText)
+		})
+
+		Convey("Deflate is used when preferred", func() {
+			resp := do(m, "GET", "/big", http.Header{"Accept-Encoding": {"deflate"}})
+			So(resp.Header().Get("Content-Encoding"), ShouldEqual, "deflate")
+			data, err := ioutil.ReadAll(flate.NewReader(resp.Body))
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, bigText)
+		})
+
+		Convey("Client does not accept compression", func() {
+			resp := do(m, "GET", "/big", nil)
+			So(resp.Header().Get("Content-Encoding"), ShouldBeEmpty)
+			So(resp.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+			So(resp.Body.String(), ShouldEqual, bigText)
+		})
+
+		Convey("Small response is not compressed", func() {
+			resp := do(m, "GET", "/small", gzipHeader)
+			So(resp.Header().Get("Content-Encoding"), ShouldBeEmpty)
+			So(resp.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+			So(resp.Body.String(), ShouldEqual, "small")
+		})
+
+		Convey("Content type not in allowlist", func() {
+			resp := do(m, "GET", "/png", gzipHeader)
+			So(resp.Header().Get("Content-Encoding"), ShouldBeEmpty)
+			So(resp.Header().Get("Vary"), ShouldBeEmpty)
+			So(resp.Body.String(), ShouldEqual, bigText)
+		})
+
+		Convey("Content type is sniffed before compression", func() {
+			resp := do(m, "GET", "/sniff", gzipHeader)
+			So(resp.Header().Get("Content-Type"), ShouldStartWith, "text/html")
+			So(resp.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		})
+
+		Convey("ServeContent with ETag and ranges", func() {
+			resp := do(m, "GET", "/content", gzipHeader)
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(resp.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+			So(resp.Header().Get("Content-Length"), ShouldBeEmpty)
+			So(resp.Header().Get("Accept-Ranges"), ShouldBeEmpty)
+			So(resp.Header().Get("ETag"), ShouldEqual, `W/"v1"`)
+			So(gunzip(resp.Body.Bytes()), ShouldEqual, bigText)
+
+			resp = do(m, "GET", "/content", http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {`W/"v1"`}})
+			So(resp.Code, ShouldEqual, http.StatusNotModified)
+			So(resp.Header().Get("Content-Encoding"), ShouldBeEmpty)
+
+			resp = do(m, "GET", "/content", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-4"}})
+			So(resp.Code, ShouldEqual, http.StatusPartialContent)
+			So(resp.Header().Get("Content-Encoding"), ShouldBeEmpty)
+			So(resp.Body.String(), ShouldEqual, "Hello")
+		})
+
+		Convey("HEAD request is not compressed", func() {
+			resp := do(m, "HEAD", "/content", gzipHeader)
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(resp.Header().Get("Content-Encoding"), ShouldBeEmpty)
+		})
+
+		Convey("Flushed stream is compressed regardless of size", func() {
+			resp := do(m, "GET", "/stream", gzipHeader)
+			So(resp.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+			So(resp.Flushed, ShouldBeTrue)
+			So(gunzip(resp.Body.Bytes()), ShouldEqual, "chunkchunk")
+		})
+	})
+
+	Convey("Registered encoder and options", t, func() {
+		Register("x-test", func(w io.Writer, level int) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		})
+		defer func() {
+			encoderLock.Lock()
+			delete(encoders, "x-test")
+			encoderLock.Unlock()
+		}()
+
+		m := web.New()
+		m.Use(Compressor(Options{
+			MinSize:      -1,
+			ContentTypes: []string{"text/*"},
+			Encodings:    []string{"x-test", "gzip"},
+		}))
+		m.Get("/", func(ctx *web.Context) {
+			ctx.Resp.Header().Set("Content-Type", "text/x-custom")
+			ctx.Resp.Header().Set("Vary", "Origin")
+			ctx.Resp.Write([]byte("tiny"))
+		})
+
+		resp := do(m, "GET", "/", http.Header{"Accept-Encoding": {"gzip, x-test"}})
+		So(resp.Header().Get("Content-Encoding"), ShouldEqual, "x-test")
+		So(resp.Header()["Vary"], ShouldResemble, []string{"Origin", "Accept-Encoding"})
+		So(gunzip(resp.Body.Bytes()), ShouldEqual, "tiny")
+	})
+
+	Convey("Recover from panic without compression", t, func() {
+		m := web.New()
+		m.Use(web.Recovery())
+		m.Use(Compressor(Options{MinSize: -1}))
+		m.Get("/", func(ctx *web.Context) {
+			ctx.Resp.Header().Set("Content-Type", "text/plain")
+			panic("here")
+		})
+
+		resp := do(m, "GET", "/", http.Header{"Accept-Encoding": {"gzip"}})
+		So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+		So(resp.Header().Get("Content-Encoding"), ShouldBeEmpty)
+	})
+}
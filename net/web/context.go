@@ -89,9 +89,10 @@ type Context struct {
 	index    int
 
 	*Router
-	Req    Request
-	Resp   ResponseWriter
-	params Params
+	Req     Request
+	Resp    ResponseWriter
+	pattern string
+	params  Params
 	Render
 	Locale
 	Data   map[string]interface{}
@@ -271,6 +272,12 @@ func (c *Context) Params(name string) string {
 	return c.params[name]
 }
 
+// RoutePattern returns pattern of the matched route including patterns of groups,
+// e.g. "/users/:id", it's empty for requests not found.
+func (c *Context) RoutePattern() string {
+	return c.pattern
+}
+
 // SetParams sets value of param with given name.
 func (c *Context) SetParams(name, val string) {
 	if !strings.HasPrefix(name, ":") {
@@ -424,6 +431,45 @@ func (c *Context) setRawContentHeader() {
 	c.Resp.Header().Set("Pragma", "public")
 }
 
+// Push initiates HTTP/2 server push of assets, e.g. stylesheets and scripts referenced by
+// the page about to be rendered. It must be called before writing the response. When push
+// is not supported, "Link: <target>; rel=preload" headers are added instead so that clients
+// still fetch assets early, and http.ErrNotSupported is returned.
+func (c *Context) Push(targets ...string) error {
+	var err error
+	pusher, ok := c.Resp.(http.Pusher)
+	for _, target := range targets {
+		if ok {
+			if err = pusher.Push(target, nil); err == nil {
+				continue
+			} else if err != http.ErrNotSupported {
+				return err
+			}
+		}
+		err = http.ErrNotSupported
+		c.Resp.Header().Add("Link", "<"+target+">; rel=preload"+preloadAs(target))
+	}
+	return err
+}
+
+// preloadAs returns the "as" attribute of preload link by extension of target.
+func preloadAs(target string) string {
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		target = target[:i]
+	}
+	switch strings.ToLower(path.Ext(target)) {
+	case ".css":
+		return "; as=style"
+	case ".js", ".mjs":
+		return "; as=script"
+	case ".woff", ".woff2", ".ttf", ".otf":
+		return "; as=font; crossorigin"
+	case ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".ico":
+		return "; as=image"
+	}
+	return ""
+}
+
 // ServeContent serves given content to response.
 func (c *Context) ServeContent(name string, r io.ReadSeeker, params ...interface{}) {
 	modtime := time.Now()
@@ -358,3 +358,36 @@ func Test_Context_Redirect(t *testing.T) {
 		So(resp.HeaderMap["Location"][0], ShouldEqual, "/path/two")
 	})
 }
+
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (r *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	r.pushed = append(r.pushed, target)
+	return nil
+}
+
+func Test_Context_Push(t *testing.T) {
+	Convey("Push assets over HTTP/2", t, func() {
+		resp := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		ctx := &Context{Resp: NewResponseWriter(resp)}
+
+		So(ctx.Push("/css/app.css", "/js/app.js"), ShouldBeNil)
+		So(resp.pushed, ShouldResemble, []string{"/css/app.css", "/js/app.js"})
+		So(resp.Header().Get("Link"), ShouldBeEmpty)
+	})
+
+	Convey("Fallback to preload links", t, func() {
+		resp := httptest.NewRecorder()
+		ctx := &Context{Resp: NewResponseWriter(resp)}
+
+		So(ctx.Push("/css/app.css?v=1", "/js/app.js", "/data"), ShouldEqual, http.ErrNotSupported)
+		So(resp.Header()["Link"], ShouldResemble, []string{
+			"</css/app.css?v=1>; rel=preload; as=style",
+			"</js/app.js>; rel=preload; as=script",
+			"</data>; rel=preload",
+		})
+	})
+}
@@ -0,0 +1,213 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package cors is a middleware that handles Cross-Origin Resource Sharing.
+package cors
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"landzero.net/x/net/web"
+)
+
+const _VERSION = "0.1.0"
+
+func Version() string {
+	return _VERSION
+}
+
+// Options represents a struct for specifying configuration options for the cors middleware.
+type Options struct {
+	// AllowOrigins are allowed origins, e.g. "https://example.com". A wildcard subdomain
+	// like "https://*.example.com" matches any subdomain, "*" matches any origin.
+	// Default is "*" if AllowOriginFunc is nil.
+	AllowOrigins []string
+	// AllowOriginFunc allows origin when it returns true, it's checked after AllowOrigins.
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods are allowed methods. Default is GET, POST, PUT, PATCH, DELETE and HEAD.
+	AllowMethods []string
+	// AllowHeaders are allowed request headers, "*" allows any header.
+	// Default is Origin, Accept, Content-Type, Authorization, X-Requested-With and X-CSRFToken.
+	AllowHeaders []string
+	// ExposeHeaders are response headers exposed to scripts.
+	ExposeHeaders []string
+	// AllowCredentials allows cookies and authorization, origin is always echoed
+	// instead of "*" when it's true.
+	AllowCredentials bool
+	// MaxAge is how long the result of preflight request can be cached. Default is 10 minutes,
+	// negative value disables caching.
+	MaxAge time.Duration
+}
+
+func prepareOptions(options []Options) Options {
+	var opt Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if len(opt.AllowOrigins) == 0 && opt.AllowOriginFunc == nil {
+		opt.AllowOrigins = []string{"*"}
+	}
+	if len(opt.AllowMethods) == 0 {
+		opt.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+	}
+	if len(opt.AllowHeaders) == 0 {
+		opt.AllowHeaders = []string{"Origin", "Accept", "Content-Type", "Authorization", "X-Requested-With", "X-CSRFToken"}
+	}
+	if opt.MaxAge == 0 {
+		opt.MaxAge = 10 * time.Minute
+	}
+	return opt
+}
+
+// IsOriginAllowed returns true if origin is allowed by AllowOrigins or AllowOriginFunc.
+// It allows any origin with "*" or the default options, use IsOriginTrusted for csrf.
+func (opt Options) IsOriginAllowed(origin string) bool {
+	if len(origin) == 0 {
+		return false
+	}
+	if len(opt.AllowOrigins) == 0 && opt.AllowOriginFunc == nil {
+		return true
+	}
+	for _, o := range opt.AllowOrigins {
+		if matchOrigin(o, origin) {
+			return true
+		}
+	}
+	return opt.AllowOriginFunc != nil && opt.AllowOriginFunc(origin)
+}
+
+// IsOriginTrusted is like IsOriginAllowed but ignores "*", so only origins listed in
+// AllowOrigins or allowed by AllowOriginFunc are trusted. It can be used as
+// csrf.Options.AllowOrigin so that requests from these origins pass the Origin check of csrf.
+func (opt Options) IsOriginTrusted(origin string) bool {
+	if len(origin) == 0 {
+		return false
+	}
+	for _, o := range opt.AllowOrigins {
+		if o != "*" && matchOrigin(o, origin) {
+			return true
+		}
+	}
+	return opt.AllowOriginFunc != nil && opt.AllowOriginFunc(origin)
+}
+
+// matchOrigin matches origin against pattern which may have wildcard subdomain.
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" || strings.EqualFold(pattern, origin) {
+		return true
+	}
+	i := strings.Index(pattern, "://*.")
+	if i < 0 {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil || !strings.EqualFold(u.Scheme, pattern[:i]) {
+		return false
+	}
+	// Suffix includes the leading dot so that bare domain is not matched.
+	suffix := pattern[i+4:]
+	host := u.Host
+	return len(host) > len(suffix) && strings.EqualFold(host[len(host)-len(suffix):], suffix)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == "*" || strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS returns a middleware handler that adds CORS headers to responses of allowed
+// origins and answers preflight requests. It can be used globally or for a group,
+// OPTIONS routes of the group are registered automatically:
+//
+//	m.Group("/api", func() {
+//		m.Get("/users", listUsers)
+//		m.Post("/users", createUser)
+//	}, cors.CORS(cors.Options{AllowOrigins: []string{"https://*.example.com"}}))
+func CORS(options ...Options) web.Handler {
+	opt := prepareOptions(options)
+	allowAny := len(opt.AllowOrigins) == 1 && opt.AllowOrigins[0] == "*" && !opt.AllowCredentials
+	methods := strings.Join(opt.AllowMethods, ", ")
+	exposed := strings.Join(opt.ExposeHeaders, ", ")
+
+	return web.PreflightHandler(func(ctx *web.Context) {
+		h := ctx.Resp.Header()
+		if !allowAny {
+			h.Add("Vary", "Origin")
+		}
+
+		origin := ctx.Req.Header.Get("Origin")
+		if len(origin) == 0 {
+			return
+		}
+		preflight := ctx.Req.Method == "OPTIONS" && len(ctx.Req.Header.Get("Access-Control-Request-Method")) > 0
+
+		if !opt.IsOriginAllowed(origin) {
+			if preflight {
+				ctx.Resp.WriteHeader(http.StatusForbidden)
+			}
+			return
+		}
+
+		if allowAny {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if opt.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if len(exposed) > 0 {
+				h.Set("Access-Control-Expose-Headers", exposed)
+			}
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		if !contains(opt.AllowMethods, ctx.Req.Header.Get("Access-Control-Request-Method")) {
+			ctx.Resp.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var headers []string
+		for _, v := range strings.Split(ctx.Req.Header.Get("Access-Control-Request-Headers"), ",") {
+			if v = strings.TrimSpace(v); len(v) == 0 {
+				continue
+			}
+			if !contains(opt.AllowHeaders, v) {
+				ctx.Resp.WriteHeader(http.StatusForbidden)
+				return
+			}
+			headers = append(headers, v)
+		}
+
+		h.Set("Access-Control-Allow-Methods", methods)
+		if len(headers) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		}
+		if opt.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(opt.MaxAge/time.Second)))
+		}
+		ctx.Resp.WriteHeader(http.StatusNoContent)
+	})
+}
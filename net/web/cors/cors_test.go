@@ -0,0 +1,200 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+	"landzero.net/x/net/web/csrf"
+	"landzero.net/x/net/web/session"
+)
+
+func do(m *web.Web, method, url string, header http.Header) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest(method, url, nil)
+	So(err, ShouldBeNil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	m.ServeHTTP(resp, req)
+	return resp
+}
+
+func Test_Version(t *testing.T) {
+	Convey("Check package version", t, func() {
+		So(Version(), ShouldEqual, _VERSION)
+	})
+}
+
+func Test_IsOriginAllowed(t *testing.T) {
+	Convey("Match origins", t, func() {
+		opt := Options{
+			AllowOrigins:    []string{"https://example.com", "https://*.example.org"},
+			AllowOriginFunc: func(origin string) bool { return origin == "http://localhost:3000" },
+		}
+		So(opt.IsOriginAllowed("https://example.com"), ShouldBeTrue)
+		So(opt.IsOriginAllowed("https://EXAMPLE.com"), ShouldBeTrue)
+		So(opt.IsOriginAllowed("http://example.com"), ShouldBeFalse)
+		So(opt.IsOriginAllowed("https://api.example.org"), ShouldBeTrue)
+		So(opt.IsOriginAllowed("https://a.b.example.org:8443"), ShouldBeFalse)
+		So(opt.IsOriginAllowed("https://a.b.example.org"), ShouldBeTrue)
+		So(opt.IsOriginAllowed("https://example.org"), ShouldBeFalse)
+		So(opt.IsOriginAllowed("https://evilexample.org"), ShouldBeFalse)
+		So(opt.IsOriginAllowed("http://localhost:3000"), ShouldBeTrue)
+		So(opt.IsOriginAllowed(""), ShouldBeFalse)
+		So(Options{}.IsOriginAllowed("https://any.com"), ShouldBeTrue)
+	})
+
+	Convey("Wildcard is not trusted", t, func() {
+		opt := Options{AllowOrigins: []string{"*", "https://*.example.org"}}
+		So(opt.IsOriginAllowed("https://evil.com"), ShouldBeTrue)
+		So(opt.IsOriginTrusted("https://evil.com"), ShouldBeFalse)
+		So(opt.IsOriginTrusted("https://api.example.org"), ShouldBeTrue)
+		So(opt.IsOriginTrusted(""), ShouldBeFalse)
+		So(Options{}.IsOriginTrusted("https://any.com"), ShouldBeFalse)
+		So(prepareOptions(nil).IsOriginTrusted("https://any.com"), ShouldBeFalse)
+	})
+}
+
+func Test_CORS(t *testing.T) {
+	Convey("CORS for a group", t, func() {
+		m := web.New()
+		m.Group("/api", func() {
+			m.Get("/users", func() string { return "users" })
+			m.Post("/users", func() string { return "created" })
+		}, CORS(Options{
+			AllowOrigins:     []string{"https://*.example.com"},
+			AllowMethods:     []string{"GET", "POST"},
+			ExposeHeaders:    []string{"X-Total"},
+			AllowCredentials: true,
+			MaxAge:           time.Hour,
+		}))
+		m.Get("/other", func() string { return "other" })
+
+		Convey("Preflight request", func() {
+			resp := do(m, "OPTIONS", "/api/users", http.Header{
+				"Origin":                         {"https://app.example.com"},
+				"Access-Control-Request-Method":  {"POST"},
+				"Access-Control-Request-Headers": {"content-type, x-csrftoken"},
+			})
+			So(resp.Code, ShouldEqual, http.StatusNoContent)
+			So(resp.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "https://app.example.com")
+			So(resp.Header().Get("Access-Control-Allow-Credentials"), ShouldEqual, "true")
+			So(resp.Header().Get("Access-Control-Allow-Methods"), ShouldEqual, "GET, POST")
+			So(resp.Header().Get("Access-Control-Allow-Headers"), ShouldEqual, "content-type, x-csrftoken")
+			So(resp.Header().Get("Access-Control-Max-Age"), ShouldEqual, "3600")
+			So(resp.Header()["Vary"], ShouldContain, "Origin")
+		})
+
+		Convey("Preflight request is rejected", func() {
+			resp := do(m, "OPTIONS", "/api/users", http.Header{
+				"Origin":                        {"https://evil.com"},
+				"Access-Control-Request-Method": {"POST"},
+			})
+			So(resp.Code, ShouldEqual, http.StatusForbidden)
+			So(resp.Header().Get("Access-Control-Allow-Origin"), ShouldBeEmpty)
+
+			resp = do(m, "OPTIONS", "/api/users", http.Header{
+				"Origin":                        {"https://app.example.com"},
+				"Access-Control-Request-Method": {"DELETE"},
+			})
+			So(resp.Code, ShouldEqual, http.StatusForbidden)
+
+			resp = do(m, "OPTIONS", "/api/users", http.Header{
+				"Origin":                         {"https://app.example.com"},
+				"Access-Control-Request-Method":  {"GET"},
+				"Access-Control-Request-Headers": {"X-Unknown"},
+			})
+			So(resp.Code, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("Actual request", func() {
+			resp := do(m, "GET", "/api/users", http.Header{"Origin": {"https://app.example.com"}})
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(resp.Body.String(), ShouldEqual, "users")
+			So(resp.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "https://app.example.com")
+			So(resp.Header().Get("Access-Control-Expose-Headers"), ShouldEqual, "X-Total")
+
+			resp = do(m, "GET", "/api/users", http.Header{"Origin": {"https://evil.com"}})
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(resp.Header().Get("Access-Control-Allow-Origin"), ShouldBeEmpty)
+		})
+
+		Convey("OPTIONS without CORS and outside of group", func() {
+			resp := do(m, "OPTIONS", "/api/users", nil)
+			So(resp.Code, ShouldEqual, http.StatusNoContent)
+			So(resp.Header().Get("Allow"), ShouldEqual, "GET, OPTIONS, POST")
+
+			So(do(m, "OPTIONS", "/other", http.Header{
+				"Origin":                        {"https://app.example.com"},
+				"Access-Control-Request-Method": {"GET"},
+			}).Code, ShouldEqual, http.StatusNotFound)
+		})
+	})
+
+	Convey("Global CORS allows any origin", t, func() {
+		m := web.New()
+		m.Use(CORS())
+		m.Get("/", func() string { return "ok" })
+
+		resp := do(m, "OPTIONS", "/", http.Header{
+			"Origin":                        {"https://any.com"},
+			"Access-Control-Request-Method": {"PUT"},
+		})
+		So(resp.Code, ShouldEqual, http.StatusNoContent)
+		So(resp.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "*")
+		So(resp.Header().Get("Vary"), ShouldEqual, "Access-Control-Request-Method")
+
+		resp = do(m, "GET", "/", http.Header{"Origin": {"https://any.com"}})
+		So(resp.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "*")
+		So(resp.Header().Get("Access-Control-Allow-Credentials"), ShouldBeEmpty)
+	})
+
+	Convey("Cooperate with csrf Origin check", t, func() {
+		opt := Options{AllowOrigins: []string{"https://app.example.com"}, AllowCredentials: true}
+		m := web.New()
+		m.Use(session.Sessioner())
+		m.Use(csrf.Csrfer(csrf.Options{SetHeader: true, Origin: true, AllowOrigin: opt.IsOriginTrusted}))
+		m.Group("/api", func() {
+			m.Get("/token", func() {})
+		}, CORS(opt))
+
+		resp := do(m, "GET", "/api/token", http.Header{"Origin": {"https://app.example.com"}})
+		So(resp.Header().Get("X-CSRFToken"), ShouldNotBeEmpty)
+		So(resp.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "https://app.example.com")
+
+		resp = do(m, "GET", "/api/token", http.Header{"Origin": {"https://evil.com"}})
+		So(resp.Header().Get("X-CSRFToken"), ShouldBeEmpty)
+
+		Convey("Wildcard origins do not pass csrf Origin check", func() {
+			opt := Options{AllowOrigins: []string{"*"}}
+			m := web.New()
+			m.Use(session.Sessioner())
+			m.Use(csrf.Csrfer(csrf.Options{SetHeader: true, Origin: true, AllowOrigin: opt.IsOriginTrusted}))
+			m.Group("/api", func() {
+				m.Get("/token", func() {})
+			}, CORS(opt))
+
+			resp := do(m, "GET", "/api/token", http.Header{"Origin": {"https://evil.com"}})
+			So(resp.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "*")
+			So(resp.Header().Get("X-CSRFToken"), ShouldBeEmpty)
+		})
+	})
+}
@@ -17,6 +17,7 @@
 package csrf
 
 import (
+	"encoding/hex"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -41,8 +42,14 @@ type CSRF interface {
 	GetCookieHTTPOnly() bool
 	// Return the token.
 	GetToken() string
+	// Return the token of given action, e.g. a form or an API.
+	GetActionToken(action string) string
 	// Validate by token.
 	ValidToken(t string) bool
+	// Validate by token of given action.
+	ValidActionToken(t, action string) bool
+	// Validate Sec-Fetch-Site, Origin or Referer header of request.
+	ValidOrigin(req *http.Request) bool
 	// Error replies to the request with a custom function when ValidToken fails.
 	Error(w http.ResponseWriter)
 	// Create a form html
@@ -68,6 +75,8 @@ type csrf struct {
 	Secret string
 	// ErrorFunc is the custom function that replies to the request when ValidToken fails.
 	ErrorFunc func(w http.ResponseWriter)
+	// Options to verify origin of request.
+	opt *Options
 }
 
 // GetHeaderName returns the name of the HTTP header for csrf token.
@@ -101,9 +110,27 @@ func (c *csrf) GetToken() string {
 	return c.Token
 }
 
+// GetActionToken returns a token which is only valid for given action.
+func (c *csrf) GetActionToken(action string) string {
+	if len(c.ID) == 0 {
+		return ""
+	}
+	return GenerateToken(c.Secret, c.ID, action)
+}
+
 // ValidToken validates the passed token against the existing Secret and ID.
 func (c *csrf) ValidToken(t string) bool {
-	return ValidToken(t, c.Secret, c.ID, "POST")
+	return c.ValidActionToken(t, "POST")
+}
+
+// ValidActionToken validates the passed token of given action against the existing Secret and ID.
+func (c *csrf) ValidActionToken(t, action string) bool {
+	return len(c.ID) > 0 && ValidToken(t, c.Secret, c.ID, action)
+}
+
+// ValidOrigin returns true if request is not sent from an untrusted origin.
+func (c *csrf) ValidOrigin(req *http.Request) bool {
+	return validOrigin(req, c.opt)
 }
 
 // Error replies to the request when ValidToken fails.
@@ -138,8 +165,20 @@ type Options struct {
 	SetCookie bool
 	// Set the Secure flag to true on the cookie.
 	Secure bool
+	// SameSite attribute of the cookie. Default is http.SameSiteLaxMode.
+	SameSite http.SameSite
+	// Use a stateless double-submit cookie instead of session. A random ID is
+	// kept in cookie and tokens are bound to it, so session is not required.
+	DoubleSubmit bool
 	// Disallow Origin appear in request header.
 	Origin bool
+	// If true, Validate rejects requests from untrusted origins by
+	// Sec-Fetch-Site, Origin or Referer header, before checking token.
+	VerifyOrigin bool
+	// Origins trusted besides the origin of request, e.g. "https://example.com".
+	TrustedOrigins []string
+	// AllowOrigin exempts origins from the Origin check, e.g. cors.Options.IsOriginTrusted.
+	AllowOrigin func(origin string) bool
 	// The function called when Validate fails.
 	ErrorFunc func(w http.ResponseWriter)
 }
@@ -170,6 +209,9 @@ func prepareOptions(options []Options) Options {
 		opt.SessionKey = "uid"
 	}
 	opt.oldSeesionKey = "_old_" + opt.SessionKey
+	if opt.SameSite == 0 {
+		opt.SameSite = http.SameSiteLaxMode
+	}
 	if opt.ErrorFunc == nil {
 		opt.ErrorFunc = func(w http.ResponseWriter) {
 			http.Error(w, "Invalid csrf token.", http.StatusBadRequest)
@@ -179,24 +221,70 @@ func prepareOptions(options []Options) Options {
 	return opt
 }
 
+// setCookie sets the cookie of token with options.
+func setCookie(ctx *web.Context, opt *Options, value string, maxAge int) {
+	http.SetCookie(ctx.Resp, &http.Cookie{
+		Name:     opt.Cookie,
+		Value:    value,
+		Path:     opt.CookiePath,
+		MaxAge:   maxAge,
+		Expires:  time.Now().Add(time.Duration(maxAge) * time.Second),
+		Secure:   opt.Secure,
+		HttpOnly: opt.CookieHTTPOnly,
+		SameSite: opt.SameSite,
+	})
+}
+
+// generate maps CSRF to the request, it returns nil if token should not be generated.
+func generate(ctx *web.Context, opt *Options) *csrf {
+	x := &csrf{
+		Secret:         opt.Secret,
+		Header:         opt.Header,
+		Form:           opt.Form,
+		Cookie:         opt.Cookie,
+		CookiePath:     opt.CookiePath,
+		CookieHTTPOnly: opt.CookieHTTPOnly,
+		ErrorFunc:      opt.ErrorFunc,
+		opt:            opt,
+	}
+	ctx.Data["CSRF"] = x
+	ctx.MapTo(x, (*CSRF)(nil))
+
+	if origin := ctx.Req.Header.Get("Origin"); opt.Origin && len(origin) > 0 &&
+		(opt.AllowOrigin == nil || !opt.AllowOrigin(origin)) {
+		return nil
+	}
+	return x
+}
+
 // Generate maps CSRF to each request. If this request is a Get request, it will generate a new token.
 // Additionally, depending on options set, generated tokens will be sent via Header and/or Cookie.
 func Generate(options ...Options) web.Handler {
 	opt := prepareOptions(options)
-	return func(ctx *web.Context, sess session.Store) {
-		x := &csrf{
-			Secret:         opt.Secret,
-			Header:         opt.Header,
-			Form:           opt.Form,
-			Cookie:         opt.Cookie,
-			CookiePath:     opt.CookiePath,
-			CookieHTTPOnly: opt.CookieHTTPOnly,
-			ErrorFunc:      opt.ErrorFunc,
-		}
-		ctx.Data["CSRF"] = x
-		ctx.MapTo(x, (*CSRF)(nil))
+	if opt.DoubleSubmit {
+		return web.Mapping(func(ctx *web.Context) {
+			x := generate(ctx, &opt)
+			if x == nil {
+				return
+			}
+
+			// ID is a random value, a forged one is useless without Secret.
+			x.ID = ctx.GetCookie(opt.Cookie)
+			if len(x.ID) == 0 || len(x.ID) > 64 {
+				x.ID = hex.EncodeToString(com.RandomCreateBytes(16))
+				setCookie(ctx, &opt, x.ID, int(TIMEOUT/time.Second))
+			}
+			x.Token = GenerateToken(x.Secret, x.ID, "POST")
 
-		if opt.Origin && len(ctx.Req.Header.Get("Origin")) > 0 {
+			if opt.SetHeader {
+				ctx.Resp.Header().Add(opt.Header, x.Token)
+			}
+		}, (*CSRF)(nil))
+	}
+
+	return web.Mapping(func(ctx *web.Context, sess session.Store) {
+		x := generate(ctx, &opt)
+		if x == nil {
 			return
 		}
 
@@ -222,17 +310,16 @@ func Generate(options ...Options) web.Handler {
 		}
 
 		if needsNew {
-			// FIXME: actionId.
 			x.Token = GenerateToken(x.Secret, x.ID, "POST")
 			if opt.SetCookie {
-				ctx.SetCookie(opt.Cookie, x.Token, 0, opt.CookiePath, "", false, opt.CookieHTTPOnly, time.Now().AddDate(0, 0, 1))
+				setCookie(ctx, &opt, x.Token, int(TIMEOUT/time.Second))
 			}
 		}
 
 		if opt.SetHeader {
 			ctx.Resp.Header().Add(opt.Header, x.Token)
 		}
-	}
+	}, (*CSRF)(nil))
 }
 
 // Csrfer maps CSRF to each request. If this request is a Get request, it will generate a new token.
@@ -246,15 +333,32 @@ func Csrfer(options ...Options) web.Handler {
 // using ValidToken. If this validation fails, custom Error is sent in the reply.
 // If neither a header or form value is found, http.StatusBadRequest is sent.
 func Validate(ctx *web.Context, x CSRF) {
+	validate(ctx, x, "POST")
+}
+
+// ValidateAction is like Validate, but validates token of given action,
+// which is generated by CSRF.GetActionToken.
+func ValidateAction(action string) web.Handler {
+	return func(ctx *web.Context, x CSRF) {
+		validate(ctx, x, action)
+	}
+}
+
+func validate(ctx *web.Context, x CSRF, action string) {
+	if c, ok := x.(*csrf); ok && c.opt != nil && c.opt.VerifyOrigin && !x.ValidOrigin(ctx.Req.Request) {
+		http.Error(ctx.Resp, "Forbidden: cross-origin request", http.StatusForbidden)
+		return
+	}
+
 	if token := ctx.Req.Header.Get(x.GetHeaderName()); len(token) > 0 {
-		if !x.ValidToken(token) {
+		if !x.ValidActionToken(token, action) {
 			ctx.SetCookie(x.GetCookieName(), "", -1, x.GetCookiePath())
 			x.Error(ctx.Resp)
 		}
 		return
 	}
 	if token := ctx.Req.FormValue(x.GetFormName()); len(token) > 0 {
-		if !x.ValidToken(token) {
+		if !x.ValidActionToken(token, action) {
 			ctx.SetCookie(x.GetCookieName(), "", -1, x.GetCookiePath())
 			x.Error(ctx.Resp)
 		}
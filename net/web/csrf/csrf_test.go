@@ -20,6 +20,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -484,3 +485,89 @@ func Test_Invalid(t *testing.T) {
 		So(resp.Code, ShouldEqual, http.StatusBadRequest)
 	})
 }
+
+func Test_DoubleSubmit(t *testing.T) {
+	Convey("Validate double-submit cookie without session", t, func() {
+		m := web.New()
+		m.Use(Csrfer(Options{
+			DoubleSubmit: true,
+			SameSite:     http.SameSiteStrictMode,
+		}))
+
+		m.Get("/form", func(x CSRF) string {
+			return x.GetToken() + " " + x.GetActionToken("delete")
+		})
+		m.Post("/update", Validate, func() {})
+		m.Post("/delete", ValidateAction("delete"), func() {})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/form", nil)
+		So(err, ShouldBeNil)
+		m.ServeHTTP(resp, req)
+
+		cookie := resp.Header().Get("Set-Cookie")
+		So(cookie, ShouldContainSubstring, "_csrf=")
+		So(cookie, ShouldContainSubstring, "SameSite=Strict")
+		tokens := strings.Fields(resp.Body.String())
+		So(tokens, ShouldHaveLength, 2)
+
+		post := func(path, token, cookie string) int {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", path, nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("X-CSRFToken", token)
+			if len(cookie) > 0 {
+				req.Header.Set("Cookie", cookie)
+			}
+			m.ServeHTTP(resp, req)
+			return resp.Code
+		}
+
+		So(post("/update", tokens[0], cookie), ShouldEqual, http.StatusOK)
+		So(post("/delete", tokens[1], cookie), ShouldEqual, http.StatusOK)
+
+		Convey("Reject token of another action", func() {
+			So(post("/update", tokens[1], cookie), ShouldEqual, http.StatusBadRequest)
+			So(post("/delete", tokens[0], cookie), ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Reject token without its cookie", func() {
+			So(post("/update", tokens[0], ""), ShouldEqual, http.StatusBadRequest)
+			So(post("/update", tokens[0], "_csrf=forged"), ShouldEqual, http.StatusBadRequest)
+		})
+	})
+
+	Convey("Verify origin before token", t, func() {
+		m := web.New()
+		m.Use(Csrfer(Options{
+			DoubleSubmit:   true,
+			VerifyOrigin:   true,
+			TrustedOrigins: []string{"https://trusted.example.com"},
+		}))
+		m.Get("/form", func(x CSRF) string {
+			return x.GetToken()
+		})
+		m.Post("/update", Validate, func() {})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "http://example.com/form", nil)
+		So(err, ShouldBeNil)
+		m.ServeHTTP(resp, req)
+		cookie, token := resp.Header().Get("Set-Cookie"), resp.Body.String()
+
+		post := func(origin string) int {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", "http://example.com/update", nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("X-CSRFToken", token)
+			req.Header.Set("Cookie", cookie)
+			req.Header.Set("Origin", origin)
+			m.ServeHTTP(resp, req)
+			return resp.Code
+		}
+
+		So(post("http://example.com"), ShouldEqual, http.StatusOK)
+		So(post("https://trusted.example.com"), ShouldEqual, http.StatusOK)
+		So(post("https://evil.example.com"), ShouldEqual, http.StatusForbidden)
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package csrf
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"landzero.net/x/net/web"
+)
+
+// requestOrigin returns origin of the server which request is sent to.
+func requestOrigin(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil || strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + strings.ToLower(req.Host)
+}
+
+// refererOrigin returns origin of Referer header, or empty.
+func refererOrigin(req *http.Request) string {
+	u, err := url.Parse(req.Referer())
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// trustedOrigin returns true if origin is in TrustedOrigins or allowed by AllowOrigin.
+func trustedOrigin(origin string, opt *Options) bool {
+	for _, o := range opt.TrustedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return opt.AllowOrigin != nil && opt.AllowOrigin(origin)
+}
+
+// validOrigin returns true if request is not sent from an untrusted origin.
+// Sec-Fetch-Site is checked first, then Origin and Referer for browsers which
+// do not send fetch metadata. Requests without these headers, i.e. not sent
+// by browsers, are allowed.
+func validOrigin(req *http.Request, opt *Options) bool {
+	origin := strings.ToLower(req.Header.Get("Origin"))
+	if len(origin) == 0 {
+		origin = refererOrigin(req)
+	}
+
+	switch req.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "same-site", "cross-site":
+		return len(origin) > 0 && trustedOrigin(origin, opt)
+	}
+
+	if len(origin) == 0 {
+		return true
+	}
+	return origin == requestOrigin(req) || trustedOrigin(origin, opt)
+}
+
+// ValidateOrigin is a middleware which rejects requests from untrusted origins by
+// Sec-Fetch-Site, Origin or Referer header, without token. Only TrustedOrigins
+// and AllowOrigin of options are used. Safe methods are not checked.
+func ValidateOrigin(options ...Options) web.Handler {
+	opt := prepareOptions(options)
+	return func(ctx *web.Context) {
+		switch ctx.Req.Method {
+		case "GET", "HEAD", "OPTIONS", "TRACE":
+			return
+		}
+		if !validOrigin(ctx.Req.Request, &opt) {
+			http.Error(ctx.Resp, "Forbidden: cross-origin request", http.StatusForbidden)
+		}
+	}
+}
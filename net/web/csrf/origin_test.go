@@ -0,0 +1,77 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package csrf
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+)
+
+func Test_ValidateOrigin(t *testing.T) {
+	Convey("Validate origin of request", t, func() {
+		m := web.New()
+		m.Use(ValidateOrigin(Options{
+			TrustedOrigins: []string{"https://app.example.com/"},
+			AllowOrigin: func(origin string) bool {
+				return origin == "https://allowed.example.com"
+			},
+		}))
+		m.Any("/", func() {})
+
+		do := func(method string, header map[string]string) int {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(method, "https://example.com/", nil)
+			So(err, ShouldBeNil)
+			req.TLS = &tls.ConnectionState{}
+			for k, v := range header {
+				req.Header.Set(k, v)
+			}
+			m.ServeHTTP(resp, req)
+			return resp.Code
+		}
+
+		Convey("By fetch metadata", func() {
+			So(do("POST", map[string]string{"Sec-Fetch-Site": "same-origin"}), ShouldEqual, http.StatusOK)
+			So(do("POST", map[string]string{"Sec-Fetch-Site": "none"}), ShouldEqual, http.StatusOK)
+			So(do("POST", map[string]string{"Sec-Fetch-Site": "cross-site"}), ShouldEqual, http.StatusForbidden)
+			So(do("POST", map[string]string{
+				"Sec-Fetch-Site": "same-site",
+				"Origin":         "https://app.example.com",
+			}), ShouldEqual, http.StatusOK)
+			So(do("POST", map[string]string{
+				"Sec-Fetch-Site": "cross-site",
+				"Origin":         "https://evil.com",
+			}), ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("By Origin and Referer", func() {
+			So(do("POST", nil), ShouldEqual, http.StatusOK)
+			So(do("POST", map[string]string{"Origin": "http://example.com"}), ShouldEqual, http.StatusForbidden)
+			So(do("POST", map[string]string{"Origin": "https://allowed.example.com"}), ShouldEqual, http.StatusOK)
+			So(do("POST", map[string]string{"Origin": "null"}), ShouldEqual, http.StatusForbidden)
+			So(do("POST", map[string]string{"Referer": "https://example.com/form"}), ShouldEqual, http.StatusOK)
+			So(do("POST", map[string]string{"Referer": "https://evil.com/form"}), ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("Skip safe methods", func() {
+			So(do("GET", map[string]string{"Origin": "https://evil.com"}), ShouldEqual, http.StatusOK)
+		})
+	})
+}
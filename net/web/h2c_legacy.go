@@ -0,0 +1,28 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build !go1.24
+// +build !go1.24
+
+package web
+
+import (
+	"errors"
+	"net/http"
+)
+
+// enableH2C reports h2c is not available, the standard library supports it since Go 1.24.
+func enableH2C(srv *http.Server) error {
+	return errors.New("h2c requires Go 1.24 or later")
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build go1.24
+// +build go1.24
+
+package web
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Web_H2C(t *testing.T) {
+	Convey("Serve HTTP/2 over cleartext", t, func() {
+		m := New()
+		m.SetH2C(true)
+		m.Get("/", func(ctx *Context) string {
+			return ctx.Req.Proto
+		})
+
+		srv, err := m.Server("127.0.0.1:0")
+		So(err, ShouldBeNil)
+		l, err := net.Listen("tcp", srv.Addr)
+		So(err, ShouldBeNil)
+		go srv.Serve(l)
+		defer srv.Close()
+
+		tr := &http.Transport{Protocols: new(http.Protocols)}
+		tr.Protocols.SetUnencryptedHTTP2(true)
+		defer tr.CloseIdleConnections()
+
+		resp, err := (&http.Client{Transport: tr}).Get("http://" + l.Addr().String() + "/")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		So(err, ShouldBeNil)
+		So(resp.ProtoMajor, ShouldEqual, 2)
+		So(string(body), ShouldEqual, "HTTP/2.0")
+
+		Convey("HTTP/1 is still served", func() {
+			resp, err := http.Get("http://" + l.Addr().String() + "/")
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			So(resp.ProtoMajor, ShouldEqual, 1)
+		})
+	})
+}
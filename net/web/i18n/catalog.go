@@ -0,0 +1,296 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html/template"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"landzero.net/x/encoding/toml"
+	"landzero.net/x/encoding/yaml"
+)
+
+// Args are named arguments of message, e.g. "Hello, {name}!".
+// Value of "count" selects plural form.
+type Args map[string]interface{}
+
+// Catalog holds messages of languages.
+//
+// Nested keys are joined by dot, e.g. table [user] with key name is "user.name" and
+// section [user] of INI is the same. Plural forms are keys suffixed by plural
+// category, e.g. "apples.one" and "apples.other".
+type Catalog struct {
+	lock     sync.RWMutex
+	messages map[string]map[string]string
+	fallback string
+}
+
+// NewCatalog creates and returns a new catalog.
+func NewCatalog() *Catalog {
+	return &Catalog{messages: make(map[string]map[string]string)}
+}
+
+// DefaultCatalog is the catalog used by middleware and package level functions by default.
+var DefaultCatalog = NewCatalog()
+
+// Load parses data in format and merges messages into language, format is one of
+// "toml", "yaml", "yml" and "ini".
+func (c *Catalog) Load(lang, format string, data []byte) error {
+	msgs := make(map[string]string)
+	switch strings.ToLower(format) {
+	case "toml":
+		var v map[string]interface{}
+		if err := toml.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		flatten(msgs, "", v)
+	case "yaml", "yml":
+		var v map[string]interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		flatten(msgs, "", v)
+	case "ini":
+		if err := parseINI(msgs, data); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("i18n: unsupported format '%s'", format)
+	}
+
+	lang = normalizeLang(lang)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.messages[lang] == nil {
+		c.messages[lang] = msgs
+	} else {
+		for k, v := range msgs {
+			c.messages[lang][k] = v
+		}
+	}
+	return nil
+}
+
+// SetFallback sets the language used when message is missing in requested language.
+func (c *Catalog) SetFallback(lang string) {
+	c.lock.Lock()
+	c.fallback = normalizeLang(lang)
+	c.lock.Unlock()
+}
+
+// Has returns true if language is loaded.
+func (c *Catalog) Has(lang string) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	_, ok := c.messages[normalizeLang(lang)]
+	return ok
+}
+
+// Langs returns loaded languages in sorted order.
+func (c *Catalog) Langs() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	langs := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Tr translates key into language. Arguments of type Args or map[string]interface{}
+// replace named placeholders, other arguments format message by fmt.Sprintf if it
+// contains verbs. Plural form is selected by "count" of named arguments or the first
+// numeric argument. Key is returned when message is not found.
+func (c *Catalog) Tr(lang, key string, args ...interface{}) string {
+	var (
+		named      map[string]interface{}
+		positional []interface{}
+		count      interface{}
+	)
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case Args:
+			named = mergeArgs(named, v)
+		case map[string]interface{}:
+			named = mergeArgs(named, v)
+		default:
+			positional = append(positional, arg)
+			if count == nil && isNumber(arg) {
+				count = arg
+			}
+		}
+	}
+	if v, ok := named["count"]; ok {
+		count = v
+	}
+
+	msg, ok := c.lookup(normalizeLang(lang), key, count)
+	if !ok {
+		return key
+	}
+	if len(named) > 0 {
+		msg = replaceNamed(msg, named)
+	}
+	if len(positional) > 0 && strings.Contains(msg, "%") {
+		msg = fmt.Sprintf(msg, positional...)
+	}
+	return msg
+}
+
+// FuncMap returns template functions of catalog, "Tr" takes language as the first argument,
+// e.g. {{Tr .Lang "hello" .Name}}.
+func (c *Catalog) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"Tr": c.Tr,
+	}
+}
+
+func (c *Catalog) lookup(lang, key string, count interface{}) (string, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, l := range []string{lang, baseLang(lang), c.fallback} {
+		msgs, ok := c.messages[l]
+		if !ok {
+			continue
+		}
+		if count != nil {
+			if msg, ok := msgs[key+"."+PluralCategory(l, count)]; ok {
+				return msg, true
+			}
+		}
+		if msg, ok := msgs[key]; ok {
+			return msg, true
+		}
+		if msg, ok := msgs[key+"."+OTHER]; ok {
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+// Tr translates key into language by DefaultCatalog.
+func Tr(lang, key string, args ...interface{}) string {
+	return DefaultCatalog.Tr(lang, key, args...)
+}
+
+// FuncMap returns template functions of DefaultCatalog, add it to Funcs of web.RenderOptions.
+func FuncMap() template.FuncMap {
+	return DefaultCatalog.FuncMap()
+}
+
+func mergeArgs(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func isNumber(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// replaceNamed replaces placeholders like {name}, unknown ones are kept.
+func replaceNamed(msg string, named map[string]interface{}) string {
+	var buf bytes.Buffer
+	for {
+		start := strings.IndexByte(msg, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(msg[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		buf.WriteString(msg[:start])
+		if v, ok := named[strings.TrimSpace(msg[start+1:end])]; ok {
+			fmt.Fprint(&buf, v)
+		} else {
+			buf.WriteString(msg[start : end+1])
+		}
+		msg = msg[end+1:]
+	}
+	buf.WriteString(msg)
+	return buf.String()
+}
+
+func flatten(dst map[string]string, prefix string, v interface{}) {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Map {
+		if s, ok := v.(string); ok {
+			dst[prefix] = s
+		} else {
+			dst[prefix] = fmt.Sprint(v)
+		}
+		return
+	}
+	for _, k := range val.MapKeys() {
+		key := fmt.Sprint(k.Interface())
+		if len(prefix) > 0 {
+			key = prefix + "." + key
+		}
+		flatten(dst, key, val.MapIndex(k).Interface())
+	}
+}
+
+// parseINI parses INI data, keys in sections are prefixed by section name.
+func parseINI(dst map[string]string, data []byte) error {
+	var section string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case len(line) == 0, line[0] == ';', line[0] == '#':
+			continue
+		case line[0] == '[':
+			if line[len(line)-1] != ']' {
+				return fmt.Errorf("i18n: invalid section at line %d", n)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return fmt.Errorf("i18n: invalid key at line %d", n)
+		}
+		key, val := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '`') && val[len(val)-1] == val[0] {
+			val = val[1 : len(val)-1]
+		}
+		if len(section) > 0 {
+			key = section + "." + key
+		}
+		dst[key] = val
+	}
+	return scanner.Err()
+}
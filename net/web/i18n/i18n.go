@@ -0,0 +1,326 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package i18n is a middleware that provides internationalization and localization
+// by implementing web.Locale.
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"landzero.net/x/net/web"
+	"landzero.net/x/runtime/binfs"
+)
+
+const _VERSION = "0.1.0"
+
+func Version() string {
+	return _VERSION
+}
+
+// Options represents a struct for specifying configuration options for the i18n middleware.
+type Options struct {
+	// Directory of catalog files. Default is "locale".
+	// Files are named by language and format, e.g. "en-US.toml", "zh-CN.yaml" or "locale_fr-FR.ini".
+	Directory string
+	// BinFS loads catalog files from landzero.net/x/runtime/binfs instead of disk.
+	BinFS bool
+	// Files are catalog files keyed by file name, they are loaded after Directory.
+	Files map[string][]byte
+	// Langs are supported languages in order, default is all loaded languages in sorted order.
+	Langs []string
+	// Names are display names of Langs, default is same as Langs.
+	Names []string
+	// Default language. Default is the first of Langs.
+	Default string
+	// Parameter is the query parameter to change language. Default is "lang".
+	Parameter string
+	// Cookie saves language chosen by Parameter. Default is "lang".
+	Cookie string
+	// CookiePath of Cookie. Default is "/".
+	CookiePath string
+	// TmplName is the key of Locale in Context.Data. Default is "i18n".
+	TmplName string
+	// Catalog stores loaded messages. Default is DefaultCatalog.
+	Catalog *Catalog
+}
+
+func prepareOptions(options []Options) Options {
+	var opt Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if len(opt.Directory) == 0 {
+		opt.Directory = "locale"
+	}
+	if len(opt.Parameter) == 0 {
+		opt.Parameter = "lang"
+	}
+	if len(opt.Cookie) == 0 {
+		opt.Cookie = "lang"
+	}
+	if len(opt.CookiePath) == 0 {
+		opt.CookiePath = "/"
+	}
+	if len(opt.TmplName) == 0 {
+		opt.TmplName = "i18n"
+	}
+	if opt.Catalog == nil {
+		opt.Catalog = DefaultCatalog
+	}
+	return opt
+}
+
+// LangType represents a language in Context.Data["AllLangs"].
+type LangType struct {
+	Lang, Name string
+}
+
+// Locale implements web.Locale by catalog.
+type Locale struct {
+	catalog *Catalog
+	lang    string
+}
+
+// NewLocale returns locale of language in catalog.
+func NewLocale(c *Catalog, lang string) Locale {
+	return Locale{catalog: c, lang: lang}
+}
+
+// Language returns language of locale.
+func (l Locale) Language() string {
+	return l.lang
+}
+
+// Tr translates key, see Catalog.Tr for arguments.
+func (l Locale) Tr(key string, args ...interface{}) string {
+	return l.catalog.Tr(l.lang, key, args...)
+}
+
+// splitFileName returns language and format of a catalog file name.
+func splitFileName(name string) (lang, format string, ok bool) {
+	ext := path.Ext(name)
+	switch strings.ToLower(ext) {
+	case ".toml", ".yaml", ".yml", ".ini":
+	default:
+		return "", "", false
+	}
+	lang = strings.TrimPrefix(strings.TrimSuffix(name, ext), "locale_")
+	return lang, ext[1:], len(lang) > 0
+}
+
+func loadCatalog(opt Options) []string {
+	var loaded []string
+	load := func(name string, data []byte) {
+		lang, format, ok := splitFileName(name)
+		if !ok {
+			return
+		}
+		if err := opt.Catalog.Load(lang, format, data); err != nil {
+			panic("i18n: fail to load '" + name + "': " + err.Error())
+		}
+		loaded = append(loaded, normalizeLang(lang))
+	}
+
+	if opt.BinFS {
+		dir := binfs.Find(strings.Split(opt.Directory, "/")...)
+		if dir != nil {
+			for _, n := range dir.SortedChildren() {
+				if n.Chunk != nil {
+					load(n.Name, n.Chunk.Data)
+				}
+			}
+		}
+	} else if fis, err := ioutil.ReadDir(opt.Directory); err == nil {
+		for _, fi := range fis {
+			if fi.IsDir() {
+				continue
+			}
+			data, err := ioutil.ReadFile(filepath.Join(opt.Directory, fi.Name()))
+			if err != nil {
+				panic("i18n: " + err.Error())
+			}
+			load(fi.Name(), data)
+		}
+	} else if !os.IsNotExist(err) {
+		panic("i18n: " + err.Error())
+	}
+
+	names := make([]string, 0, len(opt.Files))
+	for name := range opt.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		load(name, opt.Files[name])
+	}
+	return loaded
+}
+
+// I18n returns a middleware handler that sets Context.Locale by language resolved from
+// query parameter, cookie and Accept-Language header in order, and falls back to the
+// default language. Locale is also mapped as web.Locale and set into Context.Data
+// with "Lang", "LangName", "AllLangs" and TmplName, so templates can translate by
+// {{.i18n.Tr "key"}}.
+func I18n(options ...Options) web.Handler {
+	opt := prepareOptions(options)
+	loaded := loadCatalog(opt)
+
+	if len(opt.Langs) == 0 {
+		opt.Langs = opt.Catalog.Langs()
+	}
+	if len(opt.Langs) == 0 {
+		panic("i18n: no catalog is loaded")
+	}
+	for _, lang := range opt.Langs {
+		if !opt.Catalog.Has(lang) {
+			panic("i18n: catalog of '" + lang + "' is not loaded, loaded: " + strings.Join(loaded, ", "))
+		}
+	}
+	if len(opt.Default) == 0 {
+		opt.Default = opt.Langs[0]
+	}
+	opt.Catalog.SetFallback(opt.Default)
+
+	allLangs := make([]LangType, len(opt.Langs))
+	for i, lang := range opt.Langs {
+		allLangs[i] = LangType{Lang: lang, Name: lang}
+		if i < len(opt.Names) {
+			allLangs[i].Name = opt.Names[i]
+		}
+	}
+
+	return web.Mapping(func(ctx *web.Context) {
+		lang := matchLang(ctx.Query(opt.Parameter), opt.Langs)
+		if len(lang) > 0 {
+			ctx.SetCookie(opt.Cookie, lang, 1<<31-1, opt.CookiePath)
+		} else {
+			lang = resolveLang(ctx, opt)
+		}
+
+		locale := NewLocale(opt.Catalog, lang)
+		ctx.Locale = locale
+		ctx.MapTo(locale, (*web.Locale)(nil))
+
+		ctx.Data["Lang"] = lang
+		for _, l := range allLangs {
+			if l.Lang == lang {
+				ctx.Data["LangName"] = l.Name
+				break
+			}
+		}
+		ctx.Data["AllLangs"] = allLangs
+		ctx.Data[opt.TmplName] = locale
+	}, (*web.Locale)(nil))
+}
+
+// resolveLang returns language by cookie and Accept-Language header, or the default language.
+func resolveLang(ctx *web.Context, opt Options) string {
+	if lang := matchLang(ctx.GetCookie(opt.Cookie), opt.Langs); len(lang) > 0 {
+		return lang
+	}
+	for _, l := range parseAcceptLanguage(ctx.Req.Header.Get("Accept-Language")) {
+		if lang := matchLang(l, opt.Langs); len(lang) > 0 {
+			return lang
+		}
+	}
+	return opt.Default
+}
+
+// normalizeLang returns language tag in canonical case, e.g. "zh_cn" becomes "zh-CN".
+func normalizeLang(lang string) string {
+	parts := strings.Split(strings.Replace(strings.TrimSpace(lang), "_", "-", -1), "-")
+	for i, p := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(p)
+		case len(p) == 2:
+			parts[i] = strings.ToUpper(p)
+		case len(p) == 4:
+			parts[i] = strings.ToUpper(p[:1]) + strings.ToLower(p[1:])
+		default:
+			parts[i] = strings.ToLower(p)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// baseLang returns the primary language subtag, e.g. "zh" of "zh-CN".
+func baseLang(lang string) string {
+	if i := strings.IndexByte(lang, '-'); i > 0 {
+		return lang[:i]
+	}
+	return lang
+}
+
+// matchLang returns the supported language matching lang exactly, or by base language.
+func matchLang(lang string, langs []string) string {
+	if len(lang) == 0 {
+		return ""
+	}
+	lang = normalizeLang(lang)
+	for _, l := range langs {
+		if normalizeLang(l) == lang {
+			return l
+		}
+	}
+	base := baseLang(lang)
+	for _, l := range langs {
+		if baseLang(normalizeLang(l)) == base {
+			return l
+		}
+	}
+	return ""
+}
+
+// parseAcceptLanguage returns languages of Accept-Language header ordered by quality.
+func parseAcceptLanguage(header string) []string {
+	type langQ struct {
+		lang string
+		q    float64
+	}
+	var langs []langQ
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		lang := strings.TrimSpace(params[0])
+		if len(lang) == 0 || lang == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, langQ{lang, q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	result := make([]string, len(langs))
+	for i, l := range langs {
+		result[i] = l.lang
+	}
+	return result
+}
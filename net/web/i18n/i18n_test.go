@@ -0,0 +1,192 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package i18n
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+	"landzero.net/x/runtime/binfs"
+)
+
+func Test_Version(t *testing.T) {
+	Convey("Check package version", t, func() {
+		So(Version(), ShouldEqual, _VERSION)
+	})
+}
+
+func Test_PluralCategory(t *testing.T) {
+	Convey("Select plural categories by CLDR rules", t, func() {
+		So(PluralCategory("en-US", 1), ShouldEqual, ONE)
+		So(PluralCategory("en", 0), ShouldEqual, OTHER)
+		So(PluralCategory("en", "1.0"), ShouldEqual, OTHER)
+		So(PluralCategory("fr", 0), ShouldEqual, ONE)
+		So(PluralCategory("zh-CN", 1), ShouldEqual, OTHER)
+		So(PluralCategory("ru", 21), ShouldEqual, ONE)
+		So(PluralCategory("ru", 3), ShouldEqual, FEW)
+		So(PluralCategory("ru", 12), ShouldEqual, MANY)
+		So(PluralCategory("ru", 1.5), ShouldEqual, OTHER)
+		So(PluralCategory("pl", 22), ShouldEqual, FEW)
+		So(PluralCategory("pl", 25), ShouldEqual, MANY)
+		So(PluralCategory("ar", 0), ShouldEqual, ZERO)
+		So(PluralCategory("ar", 102), ShouldEqual, OTHER)
+		So(PluralCategory("ar", 105), ShouldEqual, FEW)
+		So(PluralCategory("unknown", 1), ShouldEqual, OTHER)
+		So(PluralCategory("en", struct{}{}), ShouldEqual, OTHER)
+
+		o, err := NewOperands("-1.50")
+		So(err, ShouldBeNil)
+		So(o, ShouldResemble, Operands{N: 1.5, I: 1, V: 2, F: 50})
+	})
+}
+
+func Test_Catalog(t *testing.T) {
+	Convey("Load and translate messages", t, func() {
+		c := NewCatalog()
+		So(c.Load("en-US", "toml", []byte(`
+hello = "Hello, {name}!"
+visits = "You have visited %d times"
+[apples]
+one = "{count} apple"
+other = "{count} apples"
+`)), ShouldBeNil)
+		So(c.Load("ru_ru", "ini", []byte("[apples]\nfew = {count} яблока\nmany = \"{count} яблок\"\n")), ShouldBeNil)
+		So(c.Load("en", "json", nil), ShouldNotBeNil)
+		So(c.Load("en", "ini", []byte("[broken")), ShouldNotBeNil)
+		c.SetFallback("en-US")
+
+		So(c.Langs(), ShouldResemble, []string{"en-US", "ru-RU"})
+		So(c.Tr("en-US", "hello", Args{"name": "Joe"}), ShouldEqual, "Hello, Joe!")
+		So(c.Tr("en-US", "hello", map[string]interface{}{"other": 1}), ShouldEqual, "Hello, {name}!")
+		So(c.Tr("en-US", "visits", 3), ShouldEqual, "You have visited 3 times")
+		So(c.Tr("en-US", "apples", Args{"count": 1}), ShouldEqual, "1 apple")
+		So(c.Tr("en-US", "apples", Args{"count": 2}), ShouldEqual, "2 apples")
+		So(c.Tr("ru-RU", "apples", Args{"count": 3}), ShouldEqual, "3 яблока")
+		So(c.Tr("ru-RU", "apples", Args{"count": 5}), ShouldEqual, "5 яблок")
+		// Missing forms and messages fall back to plural rules and messages of fallback language.
+		So(c.Tr("ru-RU", "apples", Args{"count": 1}), ShouldEqual, "1 apple")
+		So(c.Tr("ru-RU", "hello", Args{"name": "Joe"}), ShouldEqual, "Hello, Joe!")
+		So(c.Tr("de", "missing"), ShouldEqual, "missing")
+
+		So(c.FuncMap()["Tr"], ShouldNotBeNil)
+	})
+}
+
+func Test_parseAcceptLanguage(t *testing.T) {
+	Convey("Parse and match languages", t, func() {
+		So(parseAcceptLanguage("fr;q=0.5, zh-CN, en;q=0.8, *;q=0.1, de;q=0"), ShouldResemble, []string{"zh-CN", "en", "fr"})
+		So(matchLang("zh_cn", []string{"en-US", "zh-CN"}), ShouldEqual, "zh-CN")
+		So(matchLang("en-GB", []string{"en-US", "zh-CN"}), ShouldEqual, "en-US")
+		So(matchLang("de", []string{"en-US", "zh-CN"}), ShouldBeEmpty)
+		So(normalizeLang("zh-hant-tw"), ShouldEqual, "zh-Hant-TW")
+	})
+}
+
+func Test_I18n(t *testing.T) {
+	Convey("Resolve language of requests", t, func() {
+		c := NewCatalog()
+		m := web.New()
+		m.Use(I18n(Options{
+			Directory: "../fixtures/locale",
+			Langs:     []string{"en-US", "zh-CN", "ru-RU"},
+			Names:     []string{"English", "简体中文", "Русский"},
+			Catalog:   c,
+		}))
+		m.Use(web.Renderer(web.RenderOptions{
+			Directory: "../fixtures/i18n_tmpl",
+			Funcs:     []template.FuncMap{c.FuncMap()},
+		}))
+		m.Get("/", func(ctx *web.Context, l web.Locale) {
+			So(l.Language(), ShouldEqual, ctx.Locale.Language())
+			ctx.Data["Args"] = Args{"name": "Joe"}
+			ctx.HTML(http.StatusOK, "index")
+		})
+		m.Get("/apples/:count:int", func(ctx *web.Context) string {
+			return ctx.Tr("apples", Args{"count": ctx.ParamsInt("count")})
+		})
+
+		do := func(url string, header http.Header) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", url, nil)
+			So(err, ShouldBeNil)
+			for k, v := range header {
+				req.Header[k] = v
+			}
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		Convey("Default language", func() {
+			resp := do("/", nil)
+			So(strings.TrimSpace(resp.Body.String()), ShouldEqual, "Hello, Joe! Name")
+			So(resp.Header().Get("Set-Cookie"), ShouldBeEmpty)
+		})
+
+		Convey("Accept-Language header", func() {
+			resp := do("/", http.Header{"Accept-Language": {"de;q=0.9, zh;q=0.8, en;q=0.5"}})
+			So(strings.TrimSpace(resp.Body.String()), ShouldEqual, "你好，Joe！ 名字")
+			So(do("/apples/2", http.Header{"Accept-Language": {"zh-TW"}}).Body.String(), ShouldEqual, "2 个苹果")
+		})
+
+		Convey("Query parameter saves cookie", func() {
+			resp := do("/apples/5?lang=ru-ru", http.Header{"Accept-Language": {"zh-CN"}})
+			So(resp.Body.String(), ShouldEqual, "5 яблок")
+			So(resp.Header().Get("Set-Cookie"), ShouldStartWith, "lang=ru-RU")
+
+			resp = do("/apples/1", http.Header{"Cookie": {"lang=ru-RU"}, "Accept-Language": {"zh-CN"}})
+			So(resp.Body.String(), ShouldEqual, "1 яблоко")
+		})
+	})
+
+	Convey("Load catalogs from binfs and files", t, func() {
+		binfs.Load(&binfs.Chunk{
+			Path: []string{"i18n_test", "locale", "de-DE.yaml"},
+			Date: time.Now(),
+			Data: []byte("hello: Hallo, {name}!\n"),
+		})
+		c := NewCatalog()
+		m := web.New()
+		m.Use(I18n(Options{
+			Directory: "i18n_test/locale",
+			BinFS:     true,
+			Files:     map[string][]byte{"fr-FR.toml": []byte(`hello = "Bonjour, {name} !"`)},
+			Catalog:   c,
+		}))
+		m.Get("/", func(ctx *web.Context) string {
+			So(ctx.Data["AllLangs"], ShouldResemble, []LangType{{"de-DE", "de-DE"}, {"fr-FR", "fr-FR"}})
+			return ctx.Tr("hello", Args{"name": "Joe"})
+		})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("Accept-Language", "fr")
+		m.ServeHTTP(resp, req)
+		So(resp.Body.String(), ShouldEqual, "Bonjour, Joe !")
+	})
+
+	Convey("Missing catalog", t, func() {
+		So(func() { I18n(Options{Directory: "not_exist", Catalog: NewCatalog()}) }, ShouldPanic)
+		So(func() {
+			I18n(Options{Directory: "../fixtures/locale", Langs: []string{"ja-JP"}, Catalog: NewCatalog()})
+		}, ShouldPanic)
+	})
+}
@@ -0,0 +1,241 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Plural categories defined by CLDR.
+const (
+	ZERO  = "zero"
+	ONE   = "one"
+	TWO   = "two"
+	FEW   = "few"
+	MANY  = "many"
+	OTHER = "other"
+)
+
+// Operands are plural operands of a number defined by CLDR.
+type Operands struct {
+	// N is the absolute value.
+	N float64
+	// I is the integer digits.
+	I int64
+	// V is the number of visible fraction digits, with trailing zeros.
+	V int64
+	// F is the visible fraction digits, with trailing zeros.
+	F int64
+}
+
+// NewOperands returns operands of a number, it accepts integers, floats and numeric strings.
+// Strings keep trailing zeros, e.g. "1.50" has 2 visible fraction digits.
+func NewOperands(num interface{}) (Operands, error) {
+	var s string
+	val := reflect.ValueOf(num)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(val.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = strconv.FormatUint(val.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		s = strconv.FormatFloat(val.Float(), 'f', -1, 64)
+	case reflect.String:
+		s = strings.TrimSpace(val.String())
+	default:
+		return Operands{}, fmt.Errorf("i18n: invalid plural number %v", num)
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Operands{}, fmt.Errorf("i18n: invalid plural number %q", s)
+	}
+	s = strings.TrimLeft(s, "+-")
+	o := Operands{N: math.Abs(n)}
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	o.I, _ = strconv.ParseInt(intPart, 10, 64)
+	if len(fracPart) > 0 {
+		o.V = int64(len(fracPart))
+		o.F, _ = strconv.ParseInt(fracPart, 10, 64)
+	}
+	return o, nil
+}
+
+// PluralRule returns plural category of operands.
+type PluralRule func(o Operands) string
+
+var (
+	pluralLock  sync.RWMutex
+	pluralRules = map[string]PluralRule{}
+)
+
+// RegisterPluralRule registers plural rule for languages, it overrides the built-in rule.
+// Languages are matched by base language when region is not registered, e.g. "pt-BR" uses "pt".
+func RegisterPluralRule(rule PluralRule, langs ...string) {
+	if rule == nil {
+		panic("i18n: cannot register plural rule with nil value")
+	}
+	pluralLock.Lock()
+	defer pluralLock.Unlock()
+	for _, lang := range langs {
+		pluralRules[normalizeLang(lang)] = rule
+	}
+}
+
+// PluralCategory returns plural category of number in language, it's OTHER for unknown
+// languages or invalid numbers.
+func PluralCategory(lang string, num interface{}) string {
+	o, err := NewOperands(num)
+	if err != nil {
+		return OTHER
+	}
+	lang = normalizeLang(lang)
+
+	pluralLock.RLock()
+	rule, ok := pluralRules[lang]
+	if !ok {
+		rule, ok = pluralRules[baseLang(lang)]
+	}
+	pluralLock.RUnlock()
+	if !ok {
+		return OTHER
+	}
+	return rule(o)
+}
+
+func inRange(v, min, max int64) bool {
+	return v >= min && v <= max
+}
+
+func init() {
+	RegisterPluralRule(func(o Operands) string {
+		return OTHER
+	}, "ja", "zh", "ko", "vi", "th", "id", "ms", "lo", "my", "km")
+
+	// English and most of Germanic languages.
+	RegisterPluralRule(func(o Operands) string {
+		if o.I == 1 && o.V == 0 {
+			return ONE
+		}
+		return OTHER
+	}, "en", "de", "nl", "sv", "da", "nb", "no", "fi", "et", "it", "ca", "gl")
+
+	RegisterPluralRule(func(o Operands) string {
+		if o.N == 1 {
+			return ONE
+		}
+		return OTHER
+	}, "es", "el", "hu", "tr", "bg")
+
+	RegisterPluralRule(func(o Operands) string {
+		if o.I == 0 || o.I == 1 {
+			return ONE
+		}
+		if o.V == 0 && o.I != 0 && o.I%1000000 == 0 {
+			return MANY
+		}
+		return OTHER
+	}, "fr")
+
+	RegisterPluralRule(func(o Operands) string {
+		if o.I == 0 || o.I == 1 {
+			return ONE
+		}
+		return OTHER
+	}, "pt")
+
+	RegisterPluralRule(func(o Operands) string {
+		if o.V != 0 {
+			return OTHER
+		}
+		i10, i100 := o.I%10, o.I%100
+		switch {
+		case i10 == 1 && i100 != 11:
+			return ONE
+		case inRange(i10, 2, 4) && !inRange(i100, 12, 14):
+			return FEW
+		default:
+			return MANY
+		}
+	}, "ru", "uk", "be")
+
+	RegisterPluralRule(func(o Operands) string {
+		if o.V != 0 {
+			return OTHER
+		}
+		i10, i100 := o.I%10, o.I%100
+		switch {
+		case o.I == 1:
+			return ONE
+		case inRange(i10, 2, 4) && !inRange(i100, 12, 14):
+			return FEW
+		default:
+			return MANY
+		}
+	}, "pl")
+
+	RegisterPluralRule(func(o Operands) string {
+		switch {
+		case o.V != 0:
+			return MANY
+		case o.I == 1:
+			return ONE
+		case inRange(o.I, 2, 4):
+			return FEW
+		}
+		return OTHER
+	}, "cs", "sk")
+
+	RegisterPluralRule(func(o Operands) string {
+		if o.V != 0 {
+			return OTHER
+		}
+		switch o.I {
+		case 1:
+			return ONE
+		case 2:
+			return TWO
+		}
+		return OTHER
+	}, "he")
+
+	RegisterPluralRule(func(o Operands) string {
+		if o.V != 0 {
+			return OTHER
+		}
+		n100 := o.I % 100
+		switch {
+		case o.I == 0:
+			return ZERO
+		case o.I == 1:
+			return ONE
+		case o.I == 2:
+			return TWO
+		case inRange(n100, 3, 10):
+			return FEW
+		case inRange(n100, 11, 99):
+			return MANY
+		}
+		return OTHER
+	}, "ar")
+}
@@ -19,6 +19,8 @@ package inject
 import (
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 )
 
 // Injector represents an interface for mapping and injecting dependencies into structs
@@ -27,6 +29,7 @@ type Injector interface {
 	Applicator
 	Invoker
 	TypeMapper
+	Provider
 	// SetParent sets the parent of the injector. If the injector cannot find a
 	// dependency in its Type map it will check its parent before returning an
 	// error.
@@ -36,7 +39,8 @@ type Injector interface {
 // Applicator represents an interface for mapping dependencies to a struct.
 type Applicator interface {
 	// Maps dependencies in the Type map to each field in the struct
-	// that is tagged with 'inject'. Returns an error if the injection
+	// that is tagged with 'inject', or the value of given name if the
+	// tag is 'inject:"name=xxx"'. Returns an error if the injection
 	// fails.
 	Apply(interface{}) error
 }
@@ -48,6 +52,10 @@ type Invoker interface {
 	// a slice of reflect.Value representing the returned values of the function.
 	// Returns an error if the injection fails.
 	Invoke(interface{}) ([]reflect.Value, error)
+	// Check returns an error if any argument of the function cannot be provided,
+	// including dependencies of providers, without calling anything. Values of
+	// known types are assumed to be mapped before invoking.
+	Check(f interface{}, known ...reflect.Type) error
 }
 
 // FastInvoker represents an interface in order to avoid the calling function via reflection.
@@ -93,11 +101,62 @@ type TypeMapper interface {
 	// Returns the Value that is mapped to the current type. Returns a zeroed Value if
 	// the Type has not been mapped.
 	GetVal(reflect.Type) reflect.Value
+	// Maps the interface{} value based on its immediate type and name, so that
+	// multiple values of a type can be mapped. Named values are retrieved by
+	// GetNamed or struct fields tagged with 'inject:"name=xxx"'.
+	MapNamed(string, interface{}) TypeMapper
+	// Returns the Value that is mapped to the type and name, or a zeroed Value.
+	GetNamed(string, reflect.Type) reflect.Value
+}
+
+// Lifetime determines how often a provider is called.
+type Lifetime int
+
+const (
+	// Singleton values are constructed once, dependencies are resolved by the injector
+	// where the provider is registered.
+	Singleton Lifetime = iota
+	// Scoped values are constructed once for each injector which resolves them, e.g. the
+	// injector of each request in web, dependencies are resolved by that injector.
+	Scoped
+	// Transient values are constructed every time they are resolved.
+	Transient
+)
+
+// Provider represents an interface for registering functions constructing values lazily.
+type Provider interface {
+	// Provide registers fn which constructs value of the type of its first return value,
+	// fn may return an error as the second value, its arguments are injected. It panics
+	// if fn is not such a function.
+	Provide(fn interface{}, lifetime Lifetime) TypeMapper
+	// ProvideNamed is like Provide but the value is retrieved by name, see MapNamed.
+	ProvideNamed(name string, fn interface{}, lifetime Lifetime) TypeMapper
+}
+
+type namedKey struct {
+	name string
+	typ  reflect.Type
+}
+
+type provider struct {
+	fn       reflect.Value
+	typ      reflect.Type
+	lifetime Lifetime
+	owner    *injector
+
+	lock  sync.Mutex
+	value reflect.Value
 }
 
 type injector struct {
-	values map[reflect.Type]reflect.Value
-	parent Injector
+	values    map[reflect.Type]reflect.Value
+	named     map[namedKey]reflect.Value
+	providers map[namedKey]*provider
+	parent    Injector
+
+	// scoped holds values of scoped providers resolved by this injector.
+	scopedLock sync.Mutex
+	scoped     map[*provider]reflect.Value
 }
 
 // InterfaceOf dereferences a pointer to an Interface type.
@@ -123,6 +182,11 @@ func New() Injector {
 	}
 }
 
+// notFoundError returns the error of value of type t not found.
+func notFoundError(t reflect.Type) error {
+	return fmt.Errorf("Value not found for type %v", t)
+}
+
 // Invoke attempts to call the interface{} provided as a function,
 // providing dependencies for function arguments based on Type.
 // Returns a slice of reflect.Value representing the returned values of the function.
@@ -144,11 +208,13 @@ func (inj *injector) fastInvoke(f FastInvoker, t reflect.Type, numIn int) ([]ref
 		in = make([]interface{}, numIn) // Panic if t is not kind of Func
 		var argType reflect.Type
 		var val reflect.Value
+		var err error
 		for i := 0; i < numIn; i++ {
 			argType = t.In(i)
-			val = inj.GetVal(argType)
-			if !val.IsValid() {
-				return nil, fmt.Errorf("Value not found for type %v", argType)
+			if val, err = inj.resolve(argType, "", inj, nil); err != nil {
+				return nil, err
+			} else if !val.IsValid() {
+				return nil, notFoundError(argType)
 			}
 
 			in[i] = val.Interface()
@@ -164,11 +230,13 @@ func (inj *injector) callInvoke(f interface{}, t reflect.Type, numIn int) ([]ref
 		in = make([]reflect.Value, numIn)
 		var argType reflect.Type
 		var val reflect.Value
+		var err error
 		for i := 0; i < numIn; i++ {
 			argType = t.In(i)
-			val = inj.GetVal(argType)
-			if !val.IsValid() {
-				return nil, fmt.Errorf("Value not found for type %v", argType)
+			if val, err = inj.resolve(argType, "", inj, nil); err != nil {
+				return nil, err
+			} else if !val.IsValid() {
+				return nil, notFoundError(argType)
 			}
 
 			in[i] = val
@@ -198,9 +266,18 @@ func (inj *injector) Apply(val interface{}) error {
 		structField := t.Field(i)
 		if f.CanSet() && (structField.Tag == "inject" || structField.Tag.Get("inject") != "") {
 			ft := f.Type()
-			v := inj.GetVal(ft)
-			if !v.IsValid() {
-				return fmt.Errorf("Value not found for type %v", ft)
+			name := ""
+			if tag := structField.Tag.Get("inject"); strings.HasPrefix(tag, "name=") {
+				name = tag[5:]
+			}
+			v, err := inj.resolve(ft, name, inj, nil)
+			if err != nil {
+				return err
+			} else if !v.IsValid() {
+				if len(name) > 0 {
+					return fmt.Errorf("Value not found for type %v named %q", ft, name)
+				}
+				return notFoundError(ft)
 			}
 
 			f.Set(v)
@@ -230,31 +307,222 @@ func (i *injector) Set(typ reflect.Type, val reflect.Value) TypeMapper {
 	return i
 }
 
+func (i *injector) MapNamed(name string, val interface{}) TypeMapper {
+	if i.named == nil {
+		i.named = make(map[namedKey]reflect.Value)
+	}
+	i.named[namedKey{name, reflect.TypeOf(val)}] = reflect.ValueOf(val)
+	return i
+}
+
+func (i *injector) Provide(fn interface{}, lifetime Lifetime) TypeMapper {
+	return i.ProvideNamed("", fn, lifetime)
+}
+
+func (i *injector) ProvideNamed(name string, fn interface{}, lifetime Lifetime) TypeMapper {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumOut() == 0 || t.NumOut() > 2 ||
+		(t.NumOut() == 2 && t.Out(1) != errorType) {
+		panic(fmt.Sprintf("inject: provider must be a function returning a value and an optional error, got %v", t))
+	}
+	if i.providers == nil {
+		i.providers = make(map[namedKey]*provider)
+	}
+	i.providers[namedKey{name, t.Out(0)}] = &provider{
+		fn:       reflect.ValueOf(fn),
+		typ:      t.Out(0),
+		lifetime: lifetime,
+		owner:    i,
+	}
+	return i
+}
+
 func (i *injector) GetVal(t reflect.Type) reflect.Value {
-	val := i.values[t]
+	val, _ := i.resolve(t, "", i, nil)
+	return val
+}
 
+func (i *injector) GetNamed(name string, t reflect.Type) reflect.Value {
+	val, _ := i.resolve(t, name, i, nil)
+	return val
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// resolve returns value of type t and name, origin is the injector where resolving
+// started and path is the providers being called. It returns a zeroed Value with nil
+// error if nothing is found.
+func (i *injector) resolve(t reflect.Type, name string, origin *injector, path []*provider) (reflect.Value, error) {
+	var val reflect.Value
+	if len(name) == 0 {
+		val = i.values[t]
+	} else {
+		val = i.named[namedKey{name, t}]
+	}
 	if val.IsValid() {
-		return val
+		return val, nil
+	}
+
+	if p := i.providers[namedKey{name, t}]; p != nil {
+		return p.get(origin, path)
 	}
 
 	// no concrete types found, try to find implementors
 	// if t is an interface
-	if t.Kind() == reflect.Interface {
+	if len(name) == 0 && t.Kind() == reflect.Interface {
 		for k, v := range i.values {
 			if k.Implements(t) {
-				val = v
-				break
+				return v, nil
 			}
 		}
 	}
 
 	// Still no type found, try to look it up on the parent
-	if !val.IsValid() && i.parent != nil {
-		val = i.parent.GetVal(t)
+	switch parent := i.parent.(type) {
+	case nil:
+	case *injector:
+		return parent.resolve(t, name, origin, path)
+	default:
+		if len(name) == 0 {
+			return parent.GetVal(t), nil
+		}
+		return parent.GetNamed(name, t), nil
 	}
+	return val, nil
+}
 
-	return val
+// get returns the value constructed by provider.
+func (p *provider) get(origin *injector, path []*provider) (reflect.Value, error) {
+	for _, q := range path {
+		if q == p {
+			return reflect.Value{}, fmt.Errorf("inject: circular dependency of provider of %v", p.typ)
+		}
+	}
+	path = append(path[:len(path):len(path)], p)
+
+	switch p.lifetime {
+	case Singleton:
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if !p.value.IsValid() {
+			val, err := p.call(p.owner, path)
+			if err != nil {
+				return val, err
+			}
+			p.value = val
+		}
+		return p.value, nil
+	case Scoped:
+		origin.scopedLock.Lock()
+		val, ok := origin.scoped[p]
+		origin.scopedLock.Unlock()
+		if ok {
+			return val, nil
+		}
+		val, err := p.call(origin, path)
+		if err != nil {
+			return val, err
+		}
+		origin.scopedLock.Lock()
+		if origin.scoped == nil {
+			origin.scoped = make(map[*provider]reflect.Value)
+		}
+		origin.scoped[p] = val
+		origin.scopedLock.Unlock()
+		return val, nil
+	}
+	return p.call(origin, path)
+}
+
+// call calls provider with arguments resolved by inj.
+func (p *provider) call(inj *injector, path []*provider) (reflect.Value, error) {
+	t := p.fn.Type()
+	in := make([]reflect.Value, t.NumIn())
+	for i := range in {
+		val, err := inj.resolve(t.In(i), "", inj, path)
+		if err != nil {
+			return reflect.Value{}, err
+		} else if !val.IsValid() {
+			return reflect.Value{}, fmt.Errorf("inject: provider of %v: %v", p.typ, notFoundError(t.In(i)))
+		}
+		in[i] = val
+	}
+
+	out := p.fn.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return reflect.Value{}, fmt.Errorf("inject: provider of %v: %v", p.typ, out[1].Interface())
+	}
+	return out[0], nil
+}
+
+func (inj *injector) Check(f interface{}, known ...reflect.Type) error {
+	t := reflect.TypeOf(f)
+	for i := 0; i < t.NumIn(); i++ {
+		if err := inj.check(t.In(i), inj, known, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check is like resolve but it only checks whether value of t can be resolved.
+func (i *injector) check(t reflect.Type, origin *injector, known []reflect.Type, path []*provider) error {
+	for _, k := range known {
+		if k == t || (t.Kind() == reflect.Interface && k.Implements(t)) {
+			return nil
+		}
+	}
+
+	for inj := i; inj != nil; {
+		if inj.values[t].IsValid() {
+			return nil
+		}
+		if p := inj.providers[namedKey{"", t}]; p != nil {
+			return p.check(origin, known, path)
+		}
+		if t.Kind() == reflect.Interface {
+			for k := range inj.values {
+				if k.Implements(t) {
+					return nil
+				}
+			}
+		}
 
+		switch parent := inj.parent.(type) {
+		case *injector:
+			inj = parent
+		case nil:
+			inj = nil
+		default:
+			if parent.GetVal(t).IsValid() {
+				return nil
+			}
+			inj = nil
+		}
+	}
+	return notFoundError(t)
+}
+
+// check checks dependencies of provider.
+func (p *provider) check(origin *injector, known []reflect.Type, path []*provider) error {
+	for _, q := range path {
+		if q == p {
+			return fmt.Errorf("inject: circular dependency of provider of %v", p.typ)
+		}
+	}
+	path = append(path[:len(path):len(path)], p)
+
+	// Singletons are constructed by owner, values of requests are not available.
+	if p.lifetime == Singleton {
+		origin, known = p.owner, nil
+	}
+	t := p.fn.Type()
+	for i := 0; i < t.NumIn(); i++ {
+		if err := origin.check(t.In(i), origin, known, path); err != nil {
+			return fmt.Errorf("inject: provider of %v: %v", p.typ, err)
+		}
+	}
+	return nil
 }
 
 func (i *injector) SetParent(parent Injector) {
@@ -196,6 +196,143 @@ func Test_FastInvoker(t *testing.T) {
 	})
 }
 
+type Config struct {
+	DSN string
+}
+
+type DB struct {
+	Config *Config
+	ID     int
+}
+
+type Request struct {
+	DB *DB
+}
+
+func Test_Injector_Provide(t *testing.T) {
+	Convey("Provide values lazily", t, func() {
+		parent := inject.New()
+		calls := make(map[string]int)
+		parent.Provide(func() (*Config, error) {
+			calls["config"]++
+			return &Config{DSN: "db"}, nil
+		}, inject.Singleton)
+		parent.Provide(func(c *Config) *DB {
+			calls["db"]++
+			return &DB{Config: c, ID: calls["db"]}
+		}, inject.Scoped)
+		parent.Provide(func(db *DB) *Request {
+			calls["request"]++
+			return &Request{db}
+		}, inject.Transient)
+		So(calls, ShouldBeEmpty)
+
+		child := inject.New()
+		child.SetParent(parent)
+		var db1, db2 *DB
+		_, err := child.Invoke(func(r1, r2 *Request, db *DB) {
+			So(r1, ShouldNotPointTo, r2)
+			So(r1.DB, ShouldEqual, db)
+			So(db.Config.DSN, ShouldEqual, "db")
+			db1 = db
+		})
+		So(err, ShouldBeNil)
+		So(calls, ShouldResemble, map[string]int{"config": 1, "db": 1, "request": 2})
+
+		Convey("Scoped value is constructed for each injector", func() {
+			other := inject.New()
+			other.SetParent(parent)
+			db2 = other.GetVal(reflect.TypeOf(db2)).Interface().(*DB)
+			So(db2, ShouldNotEqual, db1)
+			So(db2.Config, ShouldEqual, db1.Config)
+			So(child.GetVal(reflect.TypeOf(db2)).Interface(), ShouldEqual, db1)
+			So(calls["config"], ShouldEqual, 1)
+		})
+
+		Convey("Errors of providers are returned", func() {
+			inj := inject.New()
+			inj.Provide(func() (string, error) {
+				return "", fmt.Errorf("boom")
+			}, inject.Transient)
+			_, err := inj.Invoke(func(string) {})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "boom")
+			So(inj.GetVal(reflect.TypeOf("")).IsValid(), ShouldBeFalse)
+
+			inj.Provide(func(i int) *Config { return nil }, inject.Transient)
+			_, err = inj.Invoke(func(*Config) {})
+			So(err.Error(), ShouldContainSubstring, "Value not found for type int")
+		})
+
+		Convey("Circular dependencies are detected", func() {
+			inj := inject.New()
+			inj.Provide(func(*DB) *Config { return nil }, inject.Singleton)
+			inj.Provide(func(*Config) *DB { return nil }, inject.Singleton)
+			_, err := inj.Invoke(func(*DB) {})
+			So(err.Error(), ShouldContainSubstring, "circular dependency")
+			So(inj.Check(func(*DB) {}), ShouldNotBeNil)
+		})
+
+		Convey("Invalid provider panics", func() {
+			So(func() { parent.Provide(func() {}, inject.Singleton) }, ShouldPanic)
+			So(func() { parent.Provide(func() (int, int) { return 0, 0 }, inject.Singleton) }, ShouldPanic)
+			So(func() { parent.Provide("value", inject.Singleton) }, ShouldPanic)
+		})
+	})
+}
+
+func Test_Injector_Named(t *testing.T) {
+	Convey("Map and provide values by name", t, func() {
+		inj := inject.New()
+		inj.Map("default")
+		inj.MapNamed("primary", "primary dsn")
+		inj.ProvideNamed("replica", func() string { return "replica dsn" }, inject.Singleton)
+
+		typ := reflect.TypeOf("")
+		So(inj.GetVal(typ).String(), ShouldEqual, "default")
+		So(inj.GetNamed("primary", typ).String(), ShouldEqual, "primary dsn")
+		So(inj.GetNamed("replica", typ).String(), ShouldEqual, "replica dsn")
+		So(inj.GetNamed("none", typ).IsValid(), ShouldBeFalse)
+
+		child := inject.New()
+		child.SetParent(inj)
+		v := struct {
+			Default string `inject:"t"`
+			Primary string `inject:"name=primary"`
+			Replica string `inject:"name=replica"`
+		}{}
+		So(child.Apply(&v), ShouldBeNil)
+		So(v.Default, ShouldEqual, "default")
+		So(v.Primary, ShouldEqual, "primary dsn")
+		So(v.Replica, ShouldEqual, "replica dsn")
+
+		w := struct {
+			Missing string `inject:"name=missing"`
+		}{}
+		So(child.Apply(&w), ShouldNotBeNil)
+	})
+}
+
+func Test_Injector_Check(t *testing.T) {
+	Convey("Check arguments without invoking", t, func() {
+		parent := inject.New()
+		parent.Map(&Config{})
+		parent.MapTo("special", (*SpecialString)(nil))
+		parent.Provide(func(r *Request) *DB { return r.DB }, inject.Scoped)
+		parent.Provide(func(r *Request) int { return 0 }, inject.Singleton)
+		child := inject.New()
+		child.SetParent(parent)
+
+		So(child.Check(func(*Config, SpecialString) {}), ShouldBeNil)
+		So(child.Check(func(fmt.Stringer) {}), ShouldNotBeNil)
+		So(child.Check(func(fmt.Stringer) {}, reflect.TypeOf(&Greeter{})), ShouldBeNil)
+		So(child.Check(func(*DB) {}), ShouldNotBeNil)
+		So(child.Check(func(*DB) {}, reflect.TypeOf(&Request{})), ShouldBeNil)
+		// Singletons cannot depend on known values of requests.
+		So(child.Check(func(int) {}, reflect.TypeOf(&Request{})), ShouldNotBeNil)
+	})
+}
+
 //----------Benchmark InjectorInvoke-------------
 
 func f1InjectorInvoke(d1 string, d2 SpecialString) string {
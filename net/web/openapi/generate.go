@@ -0,0 +1,507 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package openapi
+
+import (
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"landzero.net/x/net/web"
+	"landzero.net/x/net/web/binding"
+)
+
+const (
+	_CONTENT_JSON      = "application/json"
+	_CONTENT_FORM      = "application/x-www-form-urlencoded"
+	_CONTENT_MULTIPART = "multipart/form-data"
+)
+
+// Generate generates an OpenAPI 3 document from given routes.
+func Generate(routes []web.RouteInfo, options ...Options) *Document {
+	opt := prepareOptions(options)
+
+	g := &generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+	doc := &Document{
+		OpenAPI: "3.0.0",
+		Info: Info{
+			Title:       opt.Title,
+			Description: opt.Description,
+			Version:     opt.Version,
+		},
+		Paths: make(map[string]PathItem),
+	}
+	for _, url := range opt.Servers {
+		doc.Servers = append(doc.Servers, Server{URL: url})
+	}
+
+	// HEAD routes added automatically along with GET routes are not documented.
+	gets := make(map[string]bool)
+	for _, route := range routes {
+		if route.Method == "GET" {
+			gets[route.Pattern] = true
+		}
+	}
+
+	for _, route := range routes {
+		if route.Method == "HEAD" && gets[route.Pattern] {
+			continue
+		}
+		if opt.Filter != nil && !opt.Filter(route) {
+			continue
+		}
+		d := routeDoc(route)
+		if d.Ignore {
+			continue
+		}
+
+		path, params := convertPattern(route.Pattern)
+		op := g.operation(route, d)
+		op.Parameters = append(params, op.Parameters...)
+
+		item := doc.Paths[path]
+		if item == nil {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	if len(g.schemas) > 0 {
+		doc.Components = &Components{Schemas: g.schemas}
+	}
+	return doc
+}
+
+// routeDoc returns the last Doc attached to the route.
+func routeDoc(route web.RouteInfo) Doc {
+	var d Doc
+	for _, v := range route.Meta {
+		switch v := v.(type) {
+		case Doc:
+			d = v
+		case *Doc:
+			d = *v
+		}
+	}
+	return d
+}
+
+var wildcardPattern = regexp.MustCompile(`^:[a-zA-Z0-9]+`)
+
+// convertPattern converts a route pattern to OpenAPI path template and path parameters.
+func convertPattern(pattern string) (string, []*Parameter) {
+	var params []*Parameter
+	segs := strings.Split(pattern, "/")
+
+	globs := strings.Count(pattern, "*") - strings.Count(pattern, "*.*")
+	globLevel := 0
+
+	for i, seg := range segs {
+		optional := strings.HasPrefix(seg, "?")
+		seg = strings.TrimPrefix(seg, "?")
+
+		switch seg {
+		case "*":
+			name := "*"
+			if globs > 1 {
+				name += strconv.Itoa(globLevel)
+			}
+			globLevel++
+			params = append(params, pathParam(name, optional, &Schema{Type: "string"}))
+			segs[i] = "{" + name + "}"
+			continue
+		case "*.*":
+			params = append(params,
+				pathParam("path", optional, &Schema{Type: "string"}),
+				pathParam("ext", optional, &Schema{Type: "string"}))
+			segs[i] = "{path}.{ext}"
+			continue
+		}
+
+		var buf []byte
+		for len(seg) > 0 {
+			loc := wildcardPattern.FindStringIndex(seg)
+			if loc == nil {
+				buf = append(buf, seg[0])
+				seg = seg[1:]
+				continue
+			}
+			name := seg[1:loc[1]]
+			seg = seg[loc[1]:]
+
+			schema := &Schema{Type: "string"}
+			switch {
+			case strings.HasPrefix(seg, ":int"):
+				schema.Type = "integer"
+				seg = seg[4:]
+			case strings.HasPrefix(seg, ":string"):
+				schema.Pattern = `^\w+$`
+				seg = seg[7:]
+			case strings.HasPrefix(seg, "("):
+				end := closingParen(seg)
+				schema.Pattern = "^" + seg[1:end] + "$"
+				seg = seg[end+1:]
+			}
+			params = append(params, pathParam(name, optional, schema))
+			buf = append(buf, "{"+name+"}"...)
+		}
+		segs[i] = string(buf)
+	}
+	return strings.Join(segs, "/"), params
+}
+
+// closingParen returns index of the parenthesis closing the one at the beginning of s.
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(s) - 1
+}
+
+func pathParam(name string, optional bool, schema *Schema) *Parameter {
+	p := &Parameter{
+		Name:     name,
+		In:       "path",
+		Required: true,
+		Schema:   schema,
+	}
+	// Path parameters must be required in OpenAPI, optional ones are noted in description.
+	if optional {
+		p.Description = "Optional, may be empty."
+	}
+	return p
+}
+
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func (g *generator) operation(route web.RouteInfo, d Doc) *Operation {
+	op := &Operation{
+		Tags:        d.Tags,
+		Summary:     d.Summary,
+		Description: d.Description,
+		OperationID: d.OperationID,
+		Deprecated:  d.Deprecated,
+		Responses:   make(map[string]*Response),
+	}
+	if len(op.OperationID) == 0 {
+		op.OperationID = route.Name
+	}
+
+	if d.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{_CONTENT_JSON: {Schema: g.schema(reflect.TypeOf(d.Request), "json")}},
+		}
+	} else {
+		for _, h := range route.Handlers {
+			if b, ok := h.(binding.Binder); ok {
+				typ, source := b.Describe()
+				g.request(op, route.Method, typ, source)
+			}
+		}
+	}
+
+	for _, r := range d.Replies {
+		resp := &Response{Description: r.Description}
+		if len(resp.Description) == 0 {
+			resp.Description = statusText(r.Status)
+		}
+		if r.Body != nil {
+			contentType := r.ContentType
+			if len(contentType) == 0 {
+				contentType = _CONTENT_JSON
+			}
+			resp.Content = map[string]*MediaType{contentType: {Schema: g.schema(reflect.TypeOf(r.Body), "json")}}
+		}
+		op.Responses[strconv.Itoa(r.Status)] = resp
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = &Response{Description: statusText(200)}
+	}
+	return op
+}
+
+// request documents request data bound by a binding handler.
+func (g *generator) request(op *Operation, method string, typ reflect.Type, source string) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+
+	switch source {
+	case binding.SOURCE_QUERY, binding.SOURCE_HEADER, binding.SOURCE_COOKIE:
+		g.parameters(op, typ, source)
+		return
+	case binding.SOURCE_PARAM:
+		// Path parameters are documented from the route pattern.
+		return
+	case binding.SOURCE_BIND:
+		g.parameters(op, typ, binding.SOURCE_QUERY, binding.SOURCE_HEADER, binding.SOURCE_COOKIE)
+	}
+
+	// Form and Bind read query string for requests without body.
+	if source == binding.SOURCE_FORM || source == binding.SOURCE_BIND {
+		switch method {
+		case "GET", "HEAD", "DELETE", "OPTIONS":
+			schema := g.schema(typ, "form")
+			for _, name := range sortedKeys(schema.Properties) {
+				op.Parameters = append(op.Parameters, &Parameter{
+					Name:     name,
+					In:       "query",
+					Required: contains(schema.Required, name),
+					Schema:   schema.Properties[name],
+				})
+			}
+			return
+		}
+	}
+
+	if op.RequestBody == nil {
+		op.RequestBody = &RequestBody{Required: true, Content: make(map[string]*MediaType)}
+	}
+	content := op.RequestBody.Content
+	switch source {
+	case binding.SOURCE_JSON:
+		content[_CONTENT_JSON] = &MediaType{Schema: g.schema(typ, "json")}
+	case binding.SOURCE_FORM:
+		content[_CONTENT_FORM] = &MediaType{Schema: g.schema(typ, "form")}
+	case binding.SOURCE_MULTIPART:
+		content[_CONTENT_MULTIPART] = &MediaType{Schema: g.schema(typ, "form")}
+	case binding.SOURCE_BIND:
+		content[_CONTENT_JSON] = &MediaType{Schema: g.schema(typ, "json")}
+		content[_CONTENT_FORM] = &MediaType{Schema: g.schema(typ, "form")}
+		content[_CONTENT_MULTIPART] = &MediaType{Schema: g.schema(typ, "form")}
+	}
+}
+
+// parameters documents fields tagged with given sources, which are also
+// names of parameter locations.
+func (g *generator) parameters(op *Operation, typ reflect.Type, sources ...string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if len(field.PkgPath) > 0 && !field.Anonymous {
+			continue
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		tagged := false
+		for _, source := range sources {
+			name := field.Tag.Get(source)
+			if len(name) == 0 || name == "-" {
+				continue
+			}
+			tagged = true
+			schema := g.schema(field.Type, "form")
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:     name,
+				In:       source,
+				Required: binding.ApplyRules(schema, ft, field.Tag.Get("binding")),
+				Schema:   schema,
+			})
+		}
+		if !tagged && ft.Kind() == reflect.Struct && ft != timeType {
+			g.parameters(op, ft, sources...)
+		}
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+)
+
+// schema returns schema of given type, tag is "json" or "form" which decides
+// names of properties. Named structs in JSON are placed in components, form
+// structs are always flattened like binding.Form does.
+func (g *generator) schema(typ reflect.Type, tag string) *Schema {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case fileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(typ.Elem(), tag)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(typ.Elem(), tag)}
+	case reflect.Struct:
+		if tag == "json" && len(typ.Name()) > 0 {
+			return &Schema{Ref: "#/components/schemas/" + g.component(typ)}
+		}
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		g.fields(schema, typ, tag)
+		return schema
+	}
+	return &Schema{}
+}
+
+// component registers a named struct into components and returns its name.
+func (g *generator) component(typ reflect.Type) string {
+	if name, ok := g.names[typ]; ok {
+		return name
+	}
+
+	name := typ.Name()
+	if _, ok := g.schemas[name]; ok {
+		name = strings.Replace(typ.String(), ".", "_", -1)
+	}
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	// Register before walking fields for recursive types.
+	g.names[typ] = name
+	g.schemas[name] = schema
+	g.fields(schema, typ, "json")
+	return name
+}
+
+// fields adds properties of struct fields to schema.
+func (g *generator) fields(schema *Schema, typ reflect.Type, tag string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if len(field.PkgPath) > 0 && !field.Anonymous {
+			continue
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		var name string
+		if tag == "json" {
+			name = strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if len(name) == 0 {
+				if field.Anonymous && ft.Kind() == reflect.Struct {
+					g.fields(schema, ft, tag)
+					continue
+				}
+				name = field.Name
+			}
+		} else {
+			// binding.Form maps fields of nested structs from the same form.
+			if ft.Kind() == reflect.Struct && ft != timeType && ft != fileHeaderType {
+				if field.Type.Kind() == reflect.Struct || field.Anonymous {
+					g.fields(schema, ft, tag)
+				}
+				continue
+			}
+			if name = binding.FormName(field); len(name) == 0 || !isFormValue(ft) {
+				continue
+			}
+		}
+
+		prop := g.schema(field.Type, tag)
+		if binding.ApplyRules(prop, ft, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+}
+
+func statusText(status int) string {
+	if text := http.StatusText(status); len(text) > 0 {
+		return text
+	}
+	return "Response"
+}
+
+func sortedKeys(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isFormValue returns true if values of the type can be mapped from form.
+func isFormValue(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+	}
+	switch typ.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Interface, reflect.Func, reflect.Chan:
+		return false
+	case reflect.Struct:
+		return typ == fileHeaderType
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package openapi generates OpenAPI 3 documents from registered routes and binding structs.
+package openapi
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sync"
+
+	"landzero.net/x/net/web"
+)
+
+const _VERSION = "0.1.0"
+
+func Version() string {
+	return _VERSION
+}
+
+// Doc documents a route, attach it with web.Route.Meta.
+//
+// Example:
+//
+//	m.Get("/users/:id:int", handler).Meta(openapi.Doc{
+//		Summary: "Get a user",
+//		Replies: []openapi.Reply{{Status: 200, Body: User{}}},
+//	})
+type Doc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	// OperationID defaults to name of the route.
+	OperationID string
+	Deprecated  bool
+	// Request is a struct describing the JSON request body, it overrides the
+	// struct discovered from binding handlers of the route.
+	Request interface{}
+	// Replies are possible responses, default is a 200 response without body.
+	Replies []Reply
+	// Ignore excludes the route from document.
+	Ignore bool
+}
+
+// Reply documents a response of a route.
+type Reply struct {
+	Status      int
+	Description string
+	// Body is a value of the response type, nil means no body.
+	Body interface{}
+	// ContentType of the body, default is "application/json".
+	ContentType string
+}
+
+// Options represents a struct for specifying configuration options for the openapi middleware.
+type Options struct {
+	// Title of the API. Default is "API".
+	Title string
+	// Version of the API. Default is "1.0.0".
+	Version     string
+	Description string
+	// Servers are base URLs of the API.
+	Servers []string
+	// SpecPath is the path serving the JSON document. Default is "/openapi.json".
+	SpecPath string
+	// DocsPath is the path serving the documentation page. Default is "/docs".
+	DocsPath string
+	// Filter excludes routes when it returns false.
+	Filter func(web.RouteInfo) bool
+}
+
+func prepareOptions(options []Options) Options {
+	var opt Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if len(opt.Title) == 0 {
+		opt.Title = "API"
+	}
+	if len(opt.Version) == 0 {
+		opt.Version = "1.0.0"
+	}
+	if len(opt.SpecPath) == 0 {
+		opt.SpecPath = "/openapi.json"
+	}
+	if len(opt.DocsPath) == 0 {
+		opt.DocsPath = "/docs"
+	}
+	return opt
+}
+
+// Handler returns a handler serving the document generated from routes of current router.
+// The document is generated on first request, so all routes are registered by then.
+func Handler(options ...Options) web.Handler {
+	opt := prepareOptions(options)
+
+	var (
+		once sync.Once
+		data []byte
+		err  error
+	)
+	return func(ctx *web.Context) {
+		once.Do(func() {
+			data, err = json.Marshal(Generate(ctx.Router.Routes(), opt))
+		})
+		if err != nil {
+			http.Error(ctx.Resp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx.Resp.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		ctx.Resp.WriteHeader(http.StatusOK)
+		ctx.Resp.Write(data)
+	}
+}
+
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>{{.Title}}</title>
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@3/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="https://unpkg.com/swagger-ui-dist@3/swagger-ui-bundle.js"></script>
+	<script>
+		window.ui = SwaggerUIBundle({url: {{.SpecPath}}, dom_id: "#swagger-ui"});
+	</script>
+</body>
+</html>
+`))
+
+// Docs returns a handler serving a documentation page which renders the document at SpecPath.
+func Docs(options ...Options) web.Handler {
+	opt := prepareOptions(options)
+
+	return func(ctx *web.Context) {
+		ctx.Resp.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		ctx.Resp.WriteHeader(http.StatusOK)
+		docsTemplate.Execute(ctx.Resp, opt)
+	}
+}
+
+// Serve registers the document and the documentation page on SpecPath and DocsPath of router,
+// both routes are excluded from the document.
+func Serve(r *web.Router, options ...Options) {
+	opt := prepareOptions(options)
+	r.Get(opt.SpecPath, Handler(opt)).Meta(Doc{Ignore: true})
+	r.Get(opt.DocsPath, Docs(opt)).Meta(Doc{Ignore: true})
+}
@@ -0,0 +1,195 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+	"landzero.net/x/net/web/binding"
+)
+
+type address struct {
+	City string `json:"city" form:"city" binding:"Required"`
+}
+
+type User struct {
+	Name    string   `json:"name" binding:"Required;AlphaDash;MaxSize(20)"`
+	Email   string   `json:"email" binding:"Email"`
+	Age     int      `json:"age" binding:"Range(1,150)"`
+	Role    string   `json:"role" binding:"In(admin,user);Default(user)"`
+	Tags    []string `json:"tags" binding:"MinSize(1)"`
+	Friends []*User  `json:"friends,omitempty"`
+	Secret  string   `json:"-"`
+	address
+}
+
+type query struct {
+	Page    int    `form:"page" binding:"Default(1)"`
+	Keyword string `binding:"Required"`
+	Ignored string `form:"-"`
+	Addr    address
+}
+
+type credential struct {
+	Token string `header:"Authorization" binding:"Required"`
+}
+
+func Test_Version(t *testing.T) {
+	Convey("Check package version", t, func() {
+		So(Version(), ShouldEqual, _VERSION)
+	})
+}
+
+func Test_convertPattern(t *testing.T) {
+	Convey("Convert route patterns", t, func() {
+		path, params := convertPattern("/users/:id")
+		So(path, ShouldEqual, "/users/{id}")
+		So(len(params), ShouldEqual, 1)
+		So(params[0].Name, ShouldEqual, "id")
+		So(params[0].In, ShouldEqual, "path")
+		So(params[0].Required, ShouldBeTrue)
+
+		path, params = convertPattern("/users/:id:int/posts/:slug([a-z]+(-[a-z]+)*)")
+		So(path, ShouldEqual, "/users/{id}/posts/{slug}")
+		So(params[0].Schema.Type, ShouldEqual, "integer")
+		So(params[1].Schema.Pattern, ShouldEqual, "^[a-z]+(-[a-z]+)*$")
+
+		path, params = convertPattern("/cms_:id_:page.html")
+		So(path, ShouldEqual, "/cms_{id}_{page}.html")
+		So(len(params), ShouldEqual, 2)
+
+		path, params = convertPattern("/files/*.*")
+		So(path, ShouldEqual, "/files/{path}.{ext}")
+		So(len(params), ShouldEqual, 2)
+
+		path, params = convertPattern("/static/*")
+		So(path, ShouldEqual, "/static/{*}")
+		So(params[0].Name, ShouldEqual, "*")
+
+		path, params = convertPattern("/?:name")
+		So(path, ShouldEqual, "/{name}")
+		So(params[0].Description, ShouldNotBeEmpty)
+	})
+}
+
+func Test_Generate(t *testing.T) {
+	Convey("Generate document from routes", t, func() {
+		m := web.New()
+		m.SetAutoHead(true)
+		m.Group("/api", func() {
+			m.Get("/users", binding.Form(query{}), func() {}).Name("listUsers")
+			m.Post("/users", binding.Json(User{}), func() {}).Meta(Doc{
+				Summary: "Create a user",
+				Tags:    []string{"user"},
+				Replies: []Reply{{Status: 201, Body: User{}}, {Status: 422, Description: "Invalid user"}},
+			})
+			m.Put("/users/:id:int", binding.Header(credential{}), binding.Bind(User{}), func() {})
+			m.Delete("/users/:id:int", func() {}).Meta(Doc{Ignore: true})
+		})
+
+		doc := Generate(m.Routes(), Options{Title: "Test"})
+		So(doc.OpenAPI, ShouldEqual, "3.0.0")
+		So(doc.Info.Title, ShouldEqual, "Test")
+		So(len(doc.Paths), ShouldEqual, 2)
+
+		Convey("Query parameters from form struct", func() {
+			op := doc.Paths["/api/users"]["get"]
+			So(op, ShouldNotBeNil)
+			So(doc.Paths["/api/users"]["head"], ShouldBeNil)
+			So(op.OperationID, ShouldEqual, "listUsers")
+			names := make(map[string]*Parameter)
+			for _, p := range op.Parameters {
+				So(p.In, ShouldEqual, "query")
+				names[p.Name] = p
+			}
+			So(len(names), ShouldEqual, 3)
+			So(names["page"].Schema.Default, ShouldEqual, 1)
+			So(names["keyword"].Required, ShouldBeTrue)
+			So(names["city"].Required, ShouldBeTrue)
+		})
+
+		Convey("JSON request and response bodies", func() {
+			op := doc.Paths["/api/users"]["post"]
+			So(op.Summary, ShouldEqual, "Create a user")
+			So(op.Tags, ShouldResemble, []string{"user"})
+			So(op.RequestBody.Content[_CONTENT_JSON].Schema.Ref, ShouldEqual, "#/components/schemas/User")
+			So(op.Responses["201"].Content[_CONTENT_JSON].Schema.Ref, ShouldEqual, "#/components/schemas/User")
+			So(op.Responses["201"].Description, ShouldEqual, "Created")
+			So(op.Responses["422"].Description, ShouldEqual, "Invalid user")
+
+			user := doc.Components.Schemas["User"]
+			So(user, ShouldNotBeNil)
+			So(user.Required, ShouldResemble, []string{"name", "city"})
+			So(user.Properties["name"].Pattern, ShouldNotBeEmpty)
+			So(*user.Properties["name"].MaxLength, ShouldEqual, 20)
+			So(user.Properties["email"].Format, ShouldEqual, "email")
+			So(*user.Properties["age"].Minimum, ShouldEqual, 1)
+			So(*user.Properties["age"].Maximum, ShouldEqual, 150)
+			So(user.Properties["role"].Enum, ShouldResemble, []interface{}{"admin", "user"})
+			So(user.Properties["role"].Default, ShouldEqual, "user")
+			So(*user.Properties["tags"].MinItems, ShouldEqual, 1)
+			So(user.Properties["friends"].Items.Ref, ShouldEqual, "#/components/schemas/User")
+			So(user.Properties["Secret"], ShouldBeNil)
+		})
+
+		Convey("Path parameters and body of Bind", func() {
+			op := doc.Paths["/api/users/{id}"]["put"]
+			So(op, ShouldNotBeNil)
+			So(op.Parameters[0].Name, ShouldEqual, "id")
+			So(op.Parameters[0].Schema.Type, ShouldEqual, "integer")
+			So(op.Parameters[1].Name, ShouldEqual, "Authorization")
+			So(op.Parameters[1].In, ShouldEqual, "header")
+			So(op.Parameters[1].Required, ShouldBeTrue)
+			So(op.RequestBody.Content, ShouldContainKey, _CONTENT_JSON)
+			So(op.RequestBody.Content, ShouldContainKey, _CONTENT_FORM)
+			So(op.RequestBody.Content, ShouldContainKey, _CONTENT_MULTIPART)
+			So(op.Responses["200"].Description, ShouldEqual, "OK")
+			So(doc.Paths["/api/users/{id}"]["delete"], ShouldBeNil)
+		})
+	})
+}
+
+func Test_Serve(t *testing.T) {
+	Convey("Serve document and docs page", t, func() {
+		m := web.New()
+		Serve(m.Router, Options{Title: "Served"})
+		m.Get("/ping", func() string { return "pong" })
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/openapi.json", nil)
+		So(err, ShouldBeNil)
+		m.ServeHTTP(resp, req)
+		So(resp.Code, ShouldEqual, http.StatusOK)
+		So(resp.Header().Get("Content-Type"), ShouldStartWith, "application/json")
+
+		var doc Document
+		So(json.Unmarshal(resp.Body.Bytes(), &doc), ShouldBeNil)
+		So(doc.Info.Title, ShouldEqual, "Served")
+		So(doc.Paths, ShouldContainKey, "/ping")
+		So(doc.Paths, ShouldNotContainKey, "/openapi.json")
+
+		resp = httptest.NewRecorder()
+		req, err = http.NewRequest("GET", "/docs", nil)
+		So(err, ShouldBeNil)
+		m.ServeHTTP(resp, req)
+		So(resp.Code, ShouldEqual, http.StatusOK)
+		So(resp.Body.String(), ShouldContainSubstring, `openapi.json`)
+	})
+}
@@ -0,0 +1,172 @@
+// Copyright 2014 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// StatusCoder is implemented by errors which carry HTTP status codes.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Problem is a problem details object of RFC 7807, it's also an error which
+// is written as "application/problem+json" response when returned by handlers.
+//
+// Example:
+//
+//	m.Get("/users/:id", func(ctx *web.Context) (*User, error) {
+//		if user == nil {
+//			return nil, web.NewProblem(http.StatusNotFound, "user does not exist")
+//		}
+//		return user, nil
+//	})
+type Problem struct {
+	// Type is a URI identifying the problem type, "about:blank" if omitted.
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions are additional members of the problem details object.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// NewProblem returns a problem of status with title of the status text.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) Error() string {
+	if len(p.Detail) == 0 {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	data, err := json.Marshal((*problem)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	if err = json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	return json.Marshal(members)
+}
+
+// ProblemOf converts err to a problem. Status code is derived from *Problem and
+// StatusCoder in the chain of err, *http.MaxBytesError is 413, os.ErrNotExist is 404,
+// os.ErrPermission is 403, context.DeadlineExceeded is 504, and others are 500.
+// Detail of the well-known errors is their status text, as their messages may contain
+// file paths or other internal information.
+func ProblemOf(err error) *Problem {
+	p, _ := problemOf(err)
+	return p
+}
+
+// problemOf returns the problem and whether err has a status code.
+func problemOf(err error) (*Problem, bool) {
+	var p *Problem
+	if errors.As(err, &p) {
+		cp := *p
+		if cp.Status == 0 {
+			cp.Status = http.StatusInternalServerError
+		}
+		if len(cp.Title) == 0 {
+			cp.Title = http.StatusText(cp.Status)
+		}
+		return &cp, true
+	}
+
+	var (
+		sc StatusCoder
+		me *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &sc):
+		return NewProblem(sc.StatusCode(), err.Error()), true
+	case errors.As(err, &me):
+		return statusProblem(http.StatusRequestEntityTooLarge), true
+	case errors.Is(err, os.ErrNotExist):
+		return statusProblem(http.StatusNotFound), true
+	case errors.Is(err, os.ErrPermission):
+		return statusProblem(http.StatusForbidden), true
+	case errors.Is(err, context.DeadlineExceeded):
+		return statusProblem(http.StatusGatewayTimeout), true
+	}
+	return NewProblem(http.StatusInternalServerError, err.Error()), false
+}
+
+// statusProblem returns a problem of status with the status text as detail.
+func statusProblem(status int) *Problem {
+	return NewProblem(status, http.StatusText(status))
+}
+
+// hasDetail returns true if err carries a detail meant for clients, i.e.
+// it's a *Problem or StatusCoder.
+func hasDetail(err error) bool {
+	var (
+		p  *Problem
+		sc StatusCoder
+	)
+	return errors.As(err, &p) || errors.As(err, &sc)
+}
+
+// isTypedError returns true if status code can be derived from err.
+func isTypedError(err error) bool {
+	_, typed := problemOf(err)
+	return typed
+}
+
+// Problem writes err as "application/problem+json" response, see ProblemOf for status codes.
+// In production, only details of *Problem and StatusCoder errors are written.
+func (c *Context) Problem(err error) {
+	p, _ := problemOf(err)
+	if c.env == PROD && !hasDetail(err) {
+		p.Detail = ""
+	}
+	if len(p.Instance) == 0 {
+		p.Instance = c.Req.URL.Path
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		http.Error(c.Resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.Resp.Header().Set(_CONTENT_TYPE, _CONTENT_PROBLEM)
+	c.Resp.WriteHeader(p.Status)
+	c.Resp.Write(data)
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	// tokens and updated are used by token bucket.
+	tokens  float64
+	updated time.Time
+	// window, prev and cur are used by sliding window.
+	window    int64
+	prev, cur int64
+
+	expire time.Time
+}
+
+// MemoryAdapter represents a memory adapter implementation, states are not shared across instances.
+type MemoryAdapter struct {
+	lock     sync.Mutex
+	entries  map[string]*memoryEntry
+	interval int // GC interval.
+	started  bool
+}
+
+// NewMemoryAdapter creates and returns a new memory adapter.
+func NewMemoryAdapter() *MemoryAdapter {
+	return &MemoryAdapter{entries: make(map[string]*memoryEntry)}
+}
+
+// Init starts GC routine, config is GC interval in seconds, default is 60.
+func (a *MemoryAdapter) Init(config string) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.started {
+		return nil
+	}
+	a.interval = 60
+	if len(config) > 0 {
+		interval, err := strconv.Atoi(config)
+		if err != nil {
+			return err
+		}
+		a.interval = interval
+	}
+	a.started = true
+	go a.startGC()
+	return nil
+}
+
+// Take takes a request from limit of given key at time now.
+func (a *MemoryAdapter) Take(key string, limit Limit, now time.Time) (Result, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	e, ok := a.entries[key]
+	if !ok || now.After(e.expire) {
+		e = &memoryEntry{tokens: float64(limit.Burst), updated: now}
+		a.entries[key] = e
+	}
+
+	if limit.Algorithm == SLIDING_WINDOW {
+		window := now.UnixNano() / int64(limit.Period)
+		switch {
+		case window == e.window+1:
+			e.window, e.prev, e.cur = window, e.cur, 0
+		case window != e.window:
+			e.window, e.prev, e.cur = window, 0, 0
+		}
+		elapsed := time.Duration(now.UnixNano() - window*int64(limit.Period))
+		count := float64(e.prev)*float64(limit.Period-elapsed)/float64(limit.Period) + float64(e.cur)
+		allowed := count < float64(limit.Rate)
+		if allowed {
+			e.cur++
+		}
+		e.expire = now.Add(2 * limit.Period)
+		return SlidingWindowResult(limit, allowed, e.prev, e.cur, elapsed), nil
+	}
+
+	perSecond := float64(limit.Rate) / limit.Period.Seconds()
+	if elapsed := now.Sub(e.updated).Seconds(); elapsed > 0 {
+		e.tokens += elapsed * perSecond
+		if e.tokens > float64(limit.Burst) {
+			e.tokens = float64(limit.Burst)
+		}
+		e.updated = now
+	}
+	allowed := e.tokens >= 1
+	if allowed {
+		e.tokens--
+	}
+	// Bucket is full again after this duration, state can be dropped.
+	e.expire = now.Add(secondsToDuration((float64(limit.Burst) - e.tokens) / perSecond))
+	return TokenBucketResult(limit, allowed, e.tokens), nil
+}
+
+func (a *MemoryAdapter) startGC() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.interval < 1 {
+		return
+	}
+
+	now := time.Now()
+	for key, e := range a.entries {
+		if now.After(e.expire) {
+			delete(a.entries, key)
+		}
+	}
+
+	time.AfterFunc(time.Duration(a.interval)*time.Second, func() { a.startGC() })
+}
+
+func init() {
+	Register("memory", NewMemoryAdapter())
+}
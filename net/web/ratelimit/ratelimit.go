@@ -0,0 +1,292 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package ratelimit is a middleware that limits request rate by token bucket or sliding window.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"landzero.net/x/net/web"
+	"landzero.net/x/net/web/session"
+)
+
+const _VERSION = "0.1.0"
+
+func Version() string {
+	return _VERSION
+}
+
+// Algorithms of rate limiting.
+const (
+	// TOKEN_BUCKET allows bursts up to Burst requests, tokens are refilled at Rate per Period.
+	TOKEN_BUCKET = "token_bucket"
+	// SLIDING_WINDOW allows Rate requests in any Period, it's approximated by weighting
+	// count of previous fixed window.
+	SLIDING_WINDOW = "sliding_window"
+)
+
+// Limit describes how many requests are allowed.
+type Limit struct {
+	Algorithm string
+	// Rate is number of requests allowed per Period.
+	Rate   int
+	Period time.Duration
+	// Burst is capacity of token bucket, it's ignored by sliding window.
+	Burst int
+}
+
+// Result is the outcome of taking a request from limit, it's mapped into the handler chain.
+type Result struct {
+	Allowed bool
+	// Limit is the maximum number of requests.
+	Limit int
+	// Remaining is number of requests left.
+	Remaining int
+	// Reset is the duration until the limit is fully restored or the window ends.
+	Reset time.Duration
+	// RetryAfter is the duration to wait before next request is allowed, zero if allowed.
+	RetryAfter time.Duration
+}
+
+// Adapter is the interface that stores and updates rate limit states.
+type Adapter interface {
+	// Init initializes adapter by config string, it may be called more than once.
+	Init(config string) error
+	// Take takes a request from limit of given key at time now.
+	Take(key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Options represents a struct for specifying configuration options for the ratelimit middleware.
+type Options struct {
+	// Name of adapter. Default is "memory".
+	Adapter string
+	// Adapter configuration, it's corresponding to adapter.
+	AdapterConfig string
+	// Name separates states of limiters, limiters with same name and key share
+	// the same limit. Default is unique per call of Limiter, it's numbered in order
+	// of calls, so that it's the same among instances of a program sharing adapter.
+	Name string
+	// Algorithm is TOKEN_BUCKET or SLIDING_WINDOW. Default is TOKEN_BUCKET.
+	Algorithm string
+	// Rate is number of requests allowed per Period. Default is 60.
+	Rate int
+	// Period of rate. Default is 1 minute.
+	Period time.Duration
+	// Burst is capacity of token bucket. Default is Rate.
+	Burst int
+	// KeyFunc returns the key identifying client. Default is ByIP.
+	KeyFunc func(*web.Context) string
+	// SkipHeaders disables X-RateLimit-* headers.
+	SkipHeaders bool
+	// DeniedFunc writes response when request is denied. Default responds 429 Too Many Requests.
+	DeniedFunc func(*web.Context, Result)
+	// ErrorFunc is called when adapter fails, request is allowed when it returns.
+	// Default logs the error.
+	ErrorFunc func(*web.Context, error)
+}
+
+// limiterSeq numbers limiters without names.
+var limiterSeq uint64
+
+func prepareOptions(options []Options) Options {
+	var opt Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if len(opt.Adapter) == 0 {
+		opt.Adapter = "memory"
+	}
+	if len(opt.Algorithm) == 0 {
+		opt.Algorithm = TOKEN_BUCKET
+	}
+	if opt.Rate <= 0 {
+		opt.Rate = 60
+	}
+	if opt.Period <= 0 {
+		opt.Period = time.Minute
+	}
+	if opt.Burst <= 0 {
+		opt.Burst = opt.Rate
+	}
+	if len(opt.Name) == 0 {
+		opt.Name = fmt.Sprintf("limiter%d:%s:%d:%s", atomic.AddUint64(&limiterSeq, 1), opt.Algorithm, opt.Rate, opt.Period)
+	}
+	if opt.KeyFunc == nil {
+		opt.KeyFunc = ByIP
+	}
+	if opt.DeniedFunc == nil {
+		opt.DeniedFunc = func(ctx *web.Context, _ Result) {
+			http.Error(ctx.Resp, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		}
+	}
+	if opt.ErrorFunc == nil {
+		opt.ErrorFunc = func(ctx *web.Context, err error) {
+			ctx.Printf("ratelimit: %v", err)
+		}
+	}
+	return opt
+}
+
+// ByIP returns the client IP address as key.
+func ByIP(ctx *web.Context) string {
+	return "ip:" + ctx.RemoteAddr()
+}
+
+// ByRoute returns a key function that limits each route separately, the key is
+// method and pattern of the matched route combined with key of keyFunc, e.g. ByIP.
+// It makes a global or group limiter apply to each route independently.
+func ByRoute(keyFunc func(*web.Context) string) func(*web.Context) string {
+	return func(ctx *web.Context) string {
+		return "route:" + ctx.Req.Method + " " + ctx.RoutePattern() + ":" + keyFunc(ctx)
+	}
+}
+
+// BySession returns a key function that uses value of given session key, e.g. user ID,
+// or session ID if name is empty. It falls back to ByIP when the value is absent.
+// Sessioner middleware must be used before the limiter.
+func BySession(name string) func(*web.Context) string {
+	return func(ctx *web.Context) string {
+		val := ctx.GetVal(reflect.TypeOf((*session.Store)(nil)).Elem())
+		if val.IsValid() && !val.IsNil() {
+			sess := val.Interface().(session.Store)
+			if len(name) == 0 {
+				return "sid:" + sess.ID()
+			}
+			if v := sess.Get(name); v != nil {
+				return fmt.Sprintf("%s:%v", name, v)
+			}
+		}
+		return ByIP(ctx)
+	}
+}
+
+// NewLimiter initializes and returns the adapter by given name.
+func NewLimiter(name, config string) (Adapter, error) {
+	adapter, ok := adapters[name]
+	if !ok {
+		return nil, fmt.Errorf("ratelimit: unknown adapter '%s'(forgot to import?)", name)
+	}
+	return adapter, adapter.Init(config)
+}
+
+// Limiter returns a middleware handler that limits request rate of clients. It sets
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers, and
+// Retry-After header when request is denied. Use it globally, for a group or a route.
+func Limiter(options ...Options) web.Handler {
+	opt := prepareOptions(options)
+	adapter, err := NewLimiter(opt.Adapter, opt.AdapterConfig)
+	if err != nil {
+		panic(err)
+	}
+	limit := Limit{
+		Algorithm: opt.Algorithm,
+		Rate:      opt.Rate,
+		Period:    opt.Period,
+		Burst:     opt.Burst,
+	}
+
+	return web.Mapping(func(ctx *web.Context) {
+		now := time.Now()
+		res, err := adapter.Take(opt.Name+":"+opt.KeyFunc(ctx), limit, now)
+		if err != nil {
+			opt.ErrorFunc(ctx, err)
+			return
+		}
+		ctx.Map(res)
+
+		h := ctx.Resp.Header()
+		if !opt.SkipHeaders {
+			h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(res.Reset).Unix(), 10))
+		}
+		if !res.Allowed {
+			h.Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+			opt.DeniedFunc(ctx, res)
+		}
+	}, Result{})
+}
+
+var adapters = make(map[string]Adapter)
+
+// Register registers a adapter.
+func Register(name string, adapter Adapter) {
+	if adapter == nil {
+		panic("ratelimit: cannot register adapter with nil value")
+	}
+	if _, dup := adapters[name]; dup {
+		panic(fmt.Errorf("ratelimit: cannot register adapter '%s' twice", name))
+	}
+	adapters[name] = adapter
+}
+
+// TokenBucketResult computes result of token bucket from tokens left after taking.
+// Adapters share it to compute results from their states.
+func TokenBucketResult(limit Limit, allowed bool, tokens float64) Result {
+	perSecond := float64(limit.Rate) / limit.Period.Seconds()
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsToDuration((float64(limit.Burst) - tokens) / perSecond),
+	}
+	if !allowed {
+		res.RetryAfter = secondsToDuration((1 - tokens) / perSecond)
+	}
+	return res
+}
+
+// SlidingWindowResult computes result of sliding window from counts of previous and
+// current fixed windows after taking, elapsed is the time passed in current window.
+func SlidingWindowResult(limit Limit, allowed bool, prev, cur int64, elapsed time.Duration) Result {
+	period := limit.Period.Seconds()
+	left := period - elapsed.Seconds()
+	rate := float64(limit.Rate)
+	count := float64(prev)*left/period + float64(cur)
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Rate,
+		Remaining: int(math.Max(0, math.Floor(rate-count))),
+		Reset:     secondsToDuration(left),
+	}
+	if !allowed {
+		// Find the time when count drops to allow one more request.
+		target := rate - 1
+		if prev > 0 && float64(cur) <= target {
+			res.RetryAfter = secondsToDuration(left - (target-float64(cur))*period/float64(prev))
+		} else {
+			wait := 0.0
+			if cur > 0 {
+				wait = period * (1 - target/float64(cur))
+			}
+			res.RetryAfter = secondsToDuration(left + math.Max(0, wait))
+		}
+	}
+	return res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s < 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,204 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+)
+
+func Test_Version(t *testing.T) {
+	Convey("Check package version", t, func() {
+		So(Version(), ShouldEqual, _VERSION)
+	})
+}
+
+func Test_MemoryAdapter(t *testing.T) {
+	Convey("Token bucket", t, func() {
+		a := NewMemoryAdapter()
+		limit := Limit{Algorithm: TOKEN_BUCKET, Rate: 1, Period: time.Second, Burst: 3}
+		now := time.Unix(1000, 0)
+
+		for i := 2; i >= 0; i-- {
+			res, err := a.Take("k", limit, now)
+			So(err, ShouldBeNil)
+			So(res.Allowed, ShouldBeTrue)
+			So(res.Remaining, ShouldEqual, i)
+			So(res.Limit, ShouldEqual, 3)
+		}
+
+		res, err := a.Take("k", limit, now)
+		So(err, ShouldBeNil)
+		So(res.Allowed, ShouldBeFalse)
+		So(res.RetryAfter, ShouldEqual, time.Second)
+		So(res.Reset, ShouldEqual, 3*time.Second)
+
+		// Other keys are not affected.
+		res, _ = a.Take("other", limit, now)
+		So(res.Allowed, ShouldBeTrue)
+
+		res, _ = a.Take("k", limit, now.Add(1500*time.Millisecond))
+		So(res.Allowed, ShouldBeTrue)
+		So(res.Remaining, ShouldEqual, 0)
+		res, _ = a.Take("k", limit, now.Add(1500*time.Millisecond))
+		So(res.Allowed, ShouldBeFalse)
+		So(res.RetryAfter, ShouldEqual, 500*time.Millisecond)
+
+		// Bucket is full again after a long time.
+		res, _ = a.Take("k", limit, now.Add(time.Minute))
+		So(res.Allowed, ShouldBeTrue)
+		So(res.Remaining, ShouldEqual, 2)
+	})
+
+	Convey("Sliding window", t, func() {
+		a := NewMemoryAdapter()
+		limit := Limit{Algorithm: SLIDING_WINDOW, Rate: 4, Period: 10 * time.Second}
+		start := time.Unix(1000, 0)
+
+		for i := 3; i >= 0; i-- {
+			res, err := a.Take("k", limit, start.Add(5*time.Second))
+			So(err, ShouldBeNil)
+			So(res.Allowed, ShouldBeTrue)
+			So(res.Remaining, ShouldEqual, i)
+		}
+		res, _ := a.Take("k", limit, start.Add(5*time.Second))
+		So(res.Allowed, ShouldBeFalse)
+		So(res.Reset, ShouldEqual, 5*time.Second)
+		// Current window is full, wait until weighted count drops in next window.
+		So(res.RetryAfter, ShouldEqual, 5*time.Second+2500*time.Millisecond)
+
+		// Half of previous window is counted.
+		res, _ = a.Take("k", limit, start.Add(15*time.Second))
+		So(res.Allowed, ShouldBeTrue)
+		So(res.Remaining, ShouldEqual, 1)
+		res, _ = a.Take("k", limit, start.Add(15*time.Second))
+		So(res.Allowed, ShouldBeTrue)
+		So(res.Remaining, ShouldEqual, 0)
+		res, _ = a.Take("k", limit, start.Add(15*time.Second))
+		So(res.Allowed, ShouldBeFalse)
+		So(res.RetryAfter, ShouldEqual, 2500*time.Millisecond)
+
+		// Previous window is dropped after two periods.
+		res, _ = a.Take("k", limit, start.Add(35*time.Second))
+		So(res.Allowed, ShouldBeTrue)
+		So(res.Remaining, ShouldEqual, 3)
+	})
+}
+
+func Test_Limiter(t *testing.T) {
+	Convey("Limit requests by IP", t, func() {
+		m := web.New()
+		m.Get("/", Limiter(Options{Name: "test-ip", Rate: 2, Period: time.Minute}), func() string { return "ok" })
+
+		do := func(ip string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/", nil)
+			So(err, ShouldBeNil)
+			req.RemoteAddr = ip + ":1234"
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := do("10.0.0.1")
+		So(resp.Code, ShouldEqual, http.StatusOK)
+		So(resp.Header().Get("X-RateLimit-Limit"), ShouldEqual, "2")
+		So(resp.Header().Get("X-RateLimit-Remaining"), ShouldEqual, "1")
+		So(resp.Header().Get("X-RateLimit-Reset"), ShouldNotBeEmpty)
+
+		So(do("10.0.0.1").Code, ShouldEqual, http.StatusOK)
+		resp = do("10.0.0.1")
+		So(resp.Code, ShouldEqual, http.StatusTooManyRequests)
+		So(resp.Header().Get("X-RateLimit-Remaining"), ShouldEqual, "0")
+		So(resp.Header().Get("Retry-After"), ShouldEqual, "30")
+
+		So(do("10.0.0.2").Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("Limit requests by custom key", t, func() {
+		var denied Result
+		m := web.New()
+		m.Use(web.Renderer())
+		m.Use(Limiter(Options{
+			Name:        "test-custom",
+			Algorithm:   SLIDING_WINDOW,
+			Rate:        1,
+			KeyFunc:     func(ctx *web.Context) string { return ctx.Req.Header.Get("X-API-Key") },
+			SkipHeaders: true,
+			DeniedFunc: func(ctx *web.Context, res Result) {
+				denied = res
+				ctx.PlainText(http.StatusServiceUnavailable, []byte("slow down"))
+			},
+		}))
+		m.Get("/", func(res Result) string {
+			So(res.Allowed, ShouldBeTrue)
+			return "ok"
+		})
+
+		do := func(key string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/", nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("X-API-Key", key)
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := do("a")
+		So(resp.Code, ShouldEqual, http.StatusOK)
+		So(resp.Header().Get("X-RateLimit-Limit"), ShouldBeEmpty)
+		So(do("b").Code, ShouldEqual, http.StatusOK)
+
+		resp = do("a")
+		So(resp.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(resp.Body.String(), ShouldEqual, "slow down")
+		So(resp.Header().Get("Retry-After"), ShouldNotBeEmpty)
+		So(denied.Allowed, ShouldBeFalse)
+	})
+
+	Convey("Limiters of routes do not share limits", t, func() {
+		m := web.New()
+		m.Get("/login", Limiter(Options{Rate: 1}), func() string { return "login" })
+		m.Group("/api", func() {
+			m.Get("/users", func() string { return "users" })
+			m.Get("/users/:id", func() string { return "user" })
+		}, Limiter(Options{Rate: 1, KeyFunc: ByRoute(ByIP)}))
+
+		do := func(path string) int {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", path, nil)
+			So(err, ShouldBeNil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			m.ServeHTTP(resp, req)
+			return resp.Code
+		}
+
+		So(do("/login"), ShouldEqual, http.StatusOK)
+		So(do("/login"), ShouldEqual, http.StatusTooManyRequests)
+		So(do("/api/users"), ShouldEqual, http.StatusOK)
+		So(do("/api/users"), ShouldEqual, http.StatusTooManyRequests)
+		// Routes are limited by pattern, not by path.
+		So(do("/api/users/1"), ShouldEqual, http.StatusOK)
+		So(do("/api/users/2"), ShouldEqual, http.StatusTooManyRequests)
+	})
+
+	Convey("Unknown adapter", t, func() {
+		So(func() { Limiter(Options{Adapter: "unknown"}) }, ShouldPanic)
+	})
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"landzero.net/x/database/redis"
+	"landzero.net/x/net/web/ratelimit"
+)
+
+// tokenBucketScript refills and takes a token atomically.
+//
+// KEYS[1]: state key
+// ARGV: refill rate per millisecond, burst, now in milliseconds
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate)
+	ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(ts))
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// slidingWindowScript counts and takes a request in current window atomically.
+//
+// KEYS[1]: counter of previous window, KEYS[2]: counter of current window
+// ARGV: rate, weight of previous window, period in milliseconds
+var slidingWindowScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local weight = tonumber(ARGV[2])
+local prev = tonumber(redis.call("GET", KEYS[1]) or "0")
+local cur = tonumber(redis.call("GET", KEYS[2]) or "0")
+local allowed = 0
+if prev * weight + cur < rate then
+	cur = redis.call("INCR", KEYS[2])
+	redis.call("PEXPIRE", KEYS[2], tonumber(ARGV[3]) * 2)
+	allowed = 1
+end
+return {allowed, prev, cur}
+`)
+
+// RedisAdapter represents a redis adapter implementation, states are shared by
+// all instances connecting to the same redis server.
+type RedisAdapter struct {
+	c      *redis.Client
+	prefix string
+}
+
+// Init initializes redis client by config, e.g. "redis://127.0.0.1:6379/0".
+func (a *RedisAdapter) Init(config string) error {
+	if a.c != nil {
+		return nil
+	}
+
+	opt, err := redis.ParseURL(config)
+	if err != nil {
+		return err
+	}
+
+	c := redis.NewClient(opt)
+	if err = c.Ping().Err(); err != nil {
+		return err
+	}
+	a.c = c
+	a.prefix = "ratelimit:"
+	return nil
+}
+
+// Take takes a request from limit of given key at time now.
+func (a *RedisAdapter) Take(key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	// Hash tag keeps keys of sliding window in the same slot of cluster.
+	key = a.prefix + "{" + key + "}"
+
+	if limit.Algorithm == ratelimit.SLIDING_WINDOW {
+		window := now.UnixNano() / int64(limit.Period)
+		elapsed := time.Duration(now.UnixNano() - window*int64(limit.Period))
+		weight := float64(limit.Period-elapsed) / float64(limit.Period)
+		keys := []string{
+			key + ":" + strconv.FormatInt(window-1, 10),
+			key + ":" + strconv.FormatInt(window, 10),
+		}
+		vals, err := a.run(slidingWindowScript, 3, keys,
+			limit.Rate, strconv.FormatFloat(weight, 'f', -1, 64), int64(limit.Period/time.Millisecond))
+		if err != nil {
+			return ratelimit.Result{}, err
+		}
+		return ratelimit.SlidingWindowResult(limit, toInt64(vals[0]) == 1, toInt64(vals[1]), toInt64(vals[2]), elapsed), nil
+	}
+
+	perMillisecond := float64(limit.Rate) / float64(limit.Period/time.Millisecond)
+	vals, err := a.run(tokenBucketScript, 2, []string{key},
+		strconv.FormatFloat(perMillisecond, 'f', -1, 64), limit.Burst, now.UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	tokens, err := strconv.ParseFloat(fmt.Sprint(vals[1]), 64)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	return ratelimit.TokenBucketResult(limit, toInt64(vals[0]) == 1, tokens), nil
+}
+
+// run runs script and checks it returns n values.
+func (a *RedisAdapter) run(script *redis.Script, n int, keys []string, args ...interface{}) ([]interface{}, error) {
+	val, err := script.Run(a.c, keys, args...).Result()
+	if err != nil {
+		return nil, err
+	}
+	vals, ok := val.([]interface{})
+	if !ok || len(vals) != n {
+		return nil, fmt.Errorf("ratelimit: unexpected script result %v", val)
+	}
+	return vals, nil
+}
+
+func toInt64(v interface{}) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case string:
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	}
+	return 0
+}
+
+func init() {
+	ratelimit.Register("redis", &RedisAdapter{})
+}
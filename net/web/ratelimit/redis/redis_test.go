@@ -0,0 +1,119 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+
+	"landzero.net/x/net/web/ratelimit"
+)
+
+const testConfig = "redis://localhost:6379/1"
+
+func Test_RedisAdapter(t *testing.T) {
+	// Keys are unique for each run, states of previous runs are not expired yet.
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	Convey("Token bucket", t, func() {
+		a := &RedisAdapter{}
+		So(a.Init(testConfig), ShouldBeNil)
+		limit := ratelimit.Limit{Algorithm: ratelimit.TOKEN_BUCKET, Rate: 1, Period: time.Second, Burst: 3}
+		now := time.Unix(1000, 0)
+		key := run + ":bucket"
+
+		for i := 2; i >= 0; i-- {
+			res, err := a.Take(key, limit, now)
+			So(err, ShouldBeNil)
+			So(res.Allowed, ShouldBeTrue)
+			So(res.Remaining, ShouldEqual, i)
+		}
+		res, err := a.Take(key, limit, now)
+		So(err, ShouldBeNil)
+		So(res.Allowed, ShouldBeFalse)
+		So(res.RetryAfter, ShouldEqual, time.Second)
+
+		res, _ = a.Take(key+":other", limit, now)
+		So(res.Allowed, ShouldBeTrue)
+
+		res, _ = a.Take(key, limit, now.Add(1500*time.Millisecond))
+		So(res.Allowed, ShouldBeTrue)
+		So(res.Remaining, ShouldEqual, 0)
+		res, _ = a.Take(key, limit, now.Add(1500*time.Millisecond))
+		So(res.Allowed, ShouldBeFalse)
+		So(res.RetryAfter, ShouldEqual, 500*time.Millisecond)
+	})
+
+	Convey("Sliding window", t, func() {
+		a := &RedisAdapter{}
+		So(a.Init(testConfig), ShouldBeNil)
+		limit := ratelimit.Limit{Algorithm: ratelimit.SLIDING_WINDOW, Rate: 4, Period: 10 * time.Second}
+		start := time.Unix(1000, 0)
+		key := run + ":window"
+
+		for i := 3; i >= 0; i-- {
+			res, err := a.Take(key, limit, start.Add(5*time.Second))
+			So(err, ShouldBeNil)
+			So(res.Allowed, ShouldBeTrue)
+			So(res.Remaining, ShouldEqual, i)
+		}
+		res, _ := a.Take(key, limit, start.Add(5*time.Second))
+		So(res.Allowed, ShouldBeFalse)
+		So(res.RetryAfter, ShouldEqual, 5*time.Second+2500*time.Millisecond)
+
+		// Half of previous window is counted.
+		res, _ = a.Take(key, limit, start.Add(15*time.Second))
+		So(res.Allowed, ShouldBeTrue)
+		So(res.Remaining, ShouldEqual, 1)
+		res, _ = a.Take(key, limit, start.Add(15*time.Second))
+		So(res.Allowed, ShouldBeTrue)
+		res, _ = a.Take(key, limit, start.Add(15*time.Second))
+		So(res.Allowed, ShouldBeFalse)
+
+		res, _ = a.Take(key, limit, start.Add(35*time.Second))
+		So(res.Allowed, ShouldBeTrue)
+		So(res.Remaining, ShouldEqual, 3)
+	})
+
+	Convey("Limit requests with redis adapter", t, func() {
+		m := web.New()
+		m.Get("/", ratelimit.Limiter(ratelimit.Options{
+			Adapter:       "redis",
+			AdapterConfig: testConfig,
+			Name:          run,
+			Rate:          1,
+		}), func() string { return "ok" })
+
+		do := func() *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/", nil)
+			So(err, ShouldBeNil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := do()
+		So(resp.Code, ShouldEqual, http.StatusOK)
+		So(resp.Header().Get("X-RateLimit-Remaining"), ShouldEqual, "0")
+		So(do().Code, ShouldEqual, http.StatusTooManyRequests)
+	})
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"hash/fnv"
 	"html/template"
 	"io"
 	"io/ioutil"
@@ -27,11 +28,15 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"landzero.net/x/com"
+	"landzero.net/x/encoding/msgpack"
+	"landzero.net/x/encoding/yaml"
 	"landzero.net/x/runtime/binfs"
 )
 
@@ -44,6 +49,9 @@ const (
 	_CONTENT_PLAIN   = "text/plain"
 	_CONTENT_XHTML   = "application/xhtml+xml"
 	_CONTENT_XML     = "text/xml"
+	_CONTENT_YAML    = "application/x-yaml"
+	_CONTENT_MSGPACK = "application/msgpack"
+	_CONTENT_PROBLEM = "application/problem+json"
 	_DEFAULT_CHARSET = "UTF-8"
 )
 
@@ -61,6 +69,10 @@ var (
 		"current": func() (string, error) {
 			return "", nil
 		},
+		"component": func(string, ...interface{}) (template.HTML, error) {
+			return "", fmt.Errorf("component called outside of rendering")
+		},
+		"dict": dict,
 	}
 )
 
@@ -92,7 +104,16 @@ type (
 		// Addtional directories to overwite templates.
 		AppendDirectories []string
 		// Layout template name. Will not render a layout if "". Default is to "".
+		// Name of template in LayoutDirectory can be used without the directory.
 		Layout string
+		// LayoutDirectory is directory of named layouts relative to Directory. Default is "layouts".
+		LayoutDirectory string
+		// ComponentDirectory is directory of components relative to Directory, which are
+		// rendered by {{component "name" data}} with their own data. Default is "components".
+		ComponentDirectory string
+		// WatchInterval is the minimum interval of polling template files for changes to
+		// recompile them in development. Default is 500 milliseconds.
+		WatchInterval time.Duration
 		// Extensions to parse template files from. Defaults are [".tmpl", ".html"].
 		Extensions []string
 		// Funcs is a slice of FuncMaps to apply to the template upon compilation. This is useful for helper functions. Default is [].
@@ -111,12 +132,27 @@ type (
 		PrefixXML []byte
 		// Allows changing of output to XHTML instead of HTML. Default is "text/html"
 		HTMLContentType string
+		// Formats are media types offered by Negotiate in order of preference, supported
+		// ones are "application/json", "text/xml", "application/x-yaml" and "application/msgpack".
+		// Default is all of them in that order.
+		Formats []string
 		// BinFS defines is landzero.net/x/runtime/binfs is using
 		BinFS bool
 		// TemplateFileSystem is the interface for supporting any implmentation of template file system.
 		TemplateFileSystem
 	}
 
+	// View is a value which is rendered by HTML template when client prefers HTML in
+	// content negotiation, otherwise Data is rendered in one of Formats.
+	View struct {
+		// Set is name of template set. Default is DEFAULT_TPL_SET_NAME.
+		Set  string
+		Name string
+		Data interface{}
+		// Layout overrides Options.Layout if it's not empty.
+		Layout string
+	}
+
 	// HTMLOptions is a struct for overriding some rendering Options for specific HTML call
 	HTMLOptions struct {
 		// Layout template name. Overrides Options.Layout.
@@ -139,6 +175,9 @@ type (
 		HTMLSetBytes(string, string, interface{}, ...HTMLOptions) ([]byte, error)
 		HTMLBytes(string, interface{}, ...HTMLOptions) ([]byte, error)
 		XML(int, interface{})
+		YAML(int, interface{})
+		MsgPack(int, interface{})
+		Negotiate(int, interface{})
 		Error(int, ...string)
 		Status(int)
 		SetTemplatePath(string, string)
@@ -337,6 +376,17 @@ func GetExt(s string) string {
 }
 
 func compile(opt RenderOptions) *template.Template {
+	t, _, err := compileTemplates(opt)
+	// Bomb out if parse fails. We don't want any silent server starts.
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// compileTemplates parses all template files, it also returns the files by template name,
+// including the one failed to parse when error is returned.
+func compileTemplates(opt RenderOptions) (*template.Template, map[string]TemplateFile, error) {
 	t := template.New(opt.Directory)
 	t.Delims(opt.Delims.Left, opt.Delims.Right)
 	// Parse an initial template in case we don't have any.
@@ -350,60 +400,270 @@ func compile(opt RenderOptions) *template.Template {
 		}
 	}
 
+	files := make(map[string]TemplateFile)
 	for _, f := range opt.TemplateFileSystem.ListFiles() {
+		files[f.Name()] = f
 		tmpl := t.New(f.Name())
 		for _, funcs := range opt.Funcs {
 			tmpl.Funcs(funcs)
 		}
-		// Bomb out if parse fails. We don't want any silent server starts.
-		template.Must(tmpl.Funcs(helperFuncs).Parse(string(f.Data())))
+		if _, err := tmpl.Funcs(helperFuncs).Parse(string(f.Data())); err != nil {
+			return nil, files, err
+		}
 	}
 
-	return t
+	return t, files, nil
+}
+
+// dict creates a map from pairs of keys and values, it is useful to pass data to
+// components, e.g. {{component "card" (dict "Title" .Title "Body" .Body)}}.
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict: odd number of arguments")
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
 }
 
 const (
 	DEFAULT_TPL_SET_NAME = "DEFAULT"
 )
 
+// templateEntry is a compiled template set with its source files.
+type templateEntry struct {
+	opt   RenderOptions
+	tpl   *template.Template
+	files map[string]TemplateFile
+
+	lock sync.Mutex
+	// views are clones of tpl for each page, so that definitions of the page override
+	// blocks of layouts. tpl itself is never executed, it's required by Clone.
+	views map[string]*template.Template
+	// checked, stamp and err are state of polling template files, errFiles are
+	// the files read by the failed compiling.
+	checked  time.Time
+	stamp    uint64
+	err      error
+	errFiles map[string]TemplateFile
+}
+
+// sourceFiles returns the files of the latest compiling, which are the files of
+// failed compiling if template files have been changed with errors.
+func (e *templateEntry) sourceFiles() map[string]TemplateFile {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.err != nil {
+		return e.errFiles
+	}
+	return e.files
+}
+
+// watchable returns true if template files are on disk which can be polled.
+func (e *templateEntry) watchable() bool {
+	return e.opt.TemplateFileSystem == nil && !e.opt.BinFS
+}
+
+// dirs returns template directories in order of precedence.
+func (e *templateEntry) dirs() []string {
+	dirs := make([]string, 0, len(e.opt.AppendDirectories)+1)
+	for i := len(e.opt.AppendDirectories) - 1; i >= 0; i-- {
+		dirs = append(dirs, e.opt.AppendDirectories[i])
+	}
+	return append(dirs, e.opt.Directory)
+}
+
+// fileStamp returns hash of names, sizes and modification times of template files.
+func (e *templateEntry) fileStamp() uint64 {
+	h := fnv.New64a()
+	for _, dir := range e.dirs() {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = real
+		}
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			ext := GetExt(info.Name())
+			for _, extension := range e.opt.Extensions {
+				if ext == extension {
+					fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+					break
+				}
+			}
+			return nil
+		})
+	}
+	return h.Sum64()
+}
+
+// filename returns path of template file for displaying.
+func (e *templateEntry) filename(name string) string {
+	f, ok := e.files[name]
+	if !ok {
+		return name
+	}
+	if e.watchable() {
+		for _, dir := range e.dirs() {
+			if p := filepath.Join(dir, name+f.Ext()); com.IsFile(p) {
+				return p
+			}
+		}
+	}
+	return path.Join(e.opt.Directory, name+f.Ext())
+}
+
+// layout returns template name of layout, named layout in LayoutDirectory takes precedence.
+func (e *templateEntry) layout(name string) string {
+	if named := path.Join(e.opt.LayoutDirectory, name); e.tpl.Lookup(named) != nil {
+		return named
+	}
+	return name
+}
+
+// view returns template for rendering page.
+func (e *templateEntry) view(page string) (*template.Template, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if v, ok := e.views[page]; ok {
+		return v, nil
+	}
+	v, err := e.tpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	// Parse page again, so its {{define}}s override {{block}}s of layouts, no matter
+	// which of templates defining the same name was parsed last.
+	if f, ok := e.files[page]; ok {
+		if _, err = v.New(page).Parse(string(f.Data())); err != nil {
+			return nil, err
+		}
+	}
+	dir := e.opt.ComponentDirectory
+	v.Funcs(template.FuncMap{
+		"component": func(name string, args ...interface{}) (template.HTML, error) {
+			var data interface{}
+			if len(args) == 1 {
+				data = args[0]
+			} else if len(args) > 1 {
+				m, err := dict(args...)
+				if err != nil {
+					return "", err
+				}
+				data = m
+			}
+			var buf bytes.Buffer
+			err := v.ExecuteTemplate(&buf, path.Join(dir, name), data)
+			return template.HTML(buf.String()), err
+		},
+	})
+	if e.views == nil {
+		e.views = make(map[string]*template.Template)
+	}
+	e.views[page] = v
+	return v, nil
+}
+
 // TemplateSet represents a template set of type *template.Template.
 type TemplateSet struct {
 	lock sync.RWMutex
-	sets map[string]*template.Template
-	dirs map[string]string
+	sets map[string]*templateEntry
 }
 
 // NewTemplateSet initializes a new empty template set.
 func NewTemplateSet() *TemplateSet {
 	return &TemplateSet{
-		sets: make(map[string]*template.Template),
-		dirs: make(map[string]string),
+		sets: make(map[string]*templateEntry),
 	}
 }
 
 func (ts *TemplateSet) Set(name string, opt *RenderOptions) *template.Template {
-	t := compile(*opt)
+	e := &templateEntry{opt: *opt, checked: time.Now()}
+	var err error
+	if e.tpl, e.files, err = compileTemplates(*opt); err != nil {
+		panic(err)
+	}
+	if e.watchable() {
+		e.stamp = e.fileStamp()
+	}
 
 	ts.lock.Lock()
 	defer ts.lock.Unlock()
 
-	ts.sets[name] = t
-	ts.dirs[name] = opt.Directory
-	return t
+	ts.sets[name] = e
+	return e.tpl
 }
 
-func (ts *TemplateSet) Get(name string) *template.Template {
+func (ts *TemplateSet) entry(name string) *templateEntry {
 	ts.lock.RLock()
 	defer ts.lock.RUnlock()
 
 	return ts.sets[name]
 }
 
+func (ts *TemplateSet) Get(name string) *template.Template {
+	if e := ts.entry(name); e != nil {
+		return e.tpl
+	}
+	return nil
+}
+
 func (ts *TemplateSet) GetDir(name string) string {
-	ts.lock.RLock()
-	defer ts.lock.RUnlock()
+	if e := ts.entry(name); e != nil {
+		return e.opt.Directory
+	}
+	return ""
+}
 
-	return ts.dirs[name]
+// refresh recompiles template set if any of template files changed since last polling,
+// it returns the error of compiling until files change again. Templates of custom
+// TemplateFileSystem are recompiled every time, and ones of binfs never change.
+func (ts *TemplateSet) refresh(name string) error {
+	e := ts.entry(name)
+	if e == nil || e.opt.BinFS {
+		return nil
+	}
+
+	var stamp uint64
+	if e.watchable() {
+		e.lock.Lock()
+		if time.Since(e.checked) < e.opt.WatchInterval {
+			e.lock.Unlock()
+			return e.err
+		}
+		e.checked = time.Now()
+		stamp = e.fileStamp()
+		changed := stamp != e.stamp
+		e.stamp = stamp
+		e.lock.Unlock()
+		if !changed {
+			return e.err
+		}
+	}
+
+	ne := &templateEntry{opt: e.opt, checked: time.Now(), stamp: stamp}
+	var err error
+	if ne.tpl, ne.files, err = compileTemplates(e.opt); err != nil {
+		e.lock.Lock()
+		e.err = err
+		e.errFiles = ne.files
+		e.lock.Unlock()
+		return err
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	ts.sets[name] = ne
+	return nil
 }
 
 func prepareRenderOptions(options []RenderOptions) RenderOptions {
@@ -419,9 +679,21 @@ func prepareRenderOptions(options []RenderOptions) RenderOptions {
 	if len(opt.Extensions) == 0 {
 		opt.Extensions = []string{".tmpl", ".html"}
 	}
+	if len(opt.LayoutDirectory) == 0 {
+		opt.LayoutDirectory = "layouts"
+	}
+	if len(opt.ComponentDirectory) == 0 {
+		opt.ComponentDirectory = "components"
+	}
+	if opt.WatchInterval == 0 {
+		opt.WatchInterval = 500 * time.Millisecond
+	}
 	if len(opt.HTMLContentType) == 0 {
 		opt.HTMLContentType = _CONTENT_HTML
 	}
+	if len(opt.Formats) == 0 {
+		opt.Formats = []string{_CONTENT_JSON, _CONTENT_XML, _CONTENT_YAML, _CONTENT_MSGPACK}
+	}
 
 	return opt
 }
@@ -459,9 +731,10 @@ func renderHandler(opt RenderOptions, tplSets []string) Handler {
 		ts.Set(tplName, &tmpOpt)
 	}
 
-	return func(ctx *Context) {
+	return Mapping(func(ctx *Context) {
 		r := &TplRender{
 			env:             ctx.env,
+			accept:          ctx.Req.Header.Get("Accept"),
 			ResponseWriter:  ctx.Resp,
 			TemplateSet:     ts,
 			Opt:             &opt,
@@ -476,7 +749,7 @@ func renderHandler(opt RenderOptions, tplSets []string) Handler {
 
 		ctx.Render = r
 		ctx.MapTo(r, (*Render)(nil))
-	}
+	}, (*Render)(nil))
 }
 
 // Renderer is a Middleware that maps a web.Render service into the Web handler chain.
@@ -484,7 +757,8 @@ func renderHandler(opt RenderOptions, tplSets []string) Handler {
 // HTML rendering. The default directory for templates is "templates" and the default
 // file extension is ".tmpl" and ".html".
 //
-// If MACARON_ENV is set to "" or "development" then templates will be recompiled on every request. For more performance, set the
+// If MACARON_ENV is set to "" or "development" then template files are polled for changes and
+// recompiled, and errors of templates are shown with their source. For more performance, set the
 // MACARON_ENV environment variable to "production".
 func Renderer(options ...RenderOptions) Handler {
 	return renderHandler(prepareRenderOptions(options), []string{})
@@ -495,7 +769,8 @@ func Renderers(options RenderOptions, tplSets ...string) Handler {
 }
 
 type TplRender struct {
-	env string
+	env    string
+	accept string
 	http.ResponseWriter
 	*TemplateSet
 	Opt             *RenderOptions
@@ -547,18 +822,22 @@ func (r *TplRender) JSONString(v interface{}) (string, error) {
 }
 
 func (r *TplRender) XML(status int, v interface{}) {
-	var result []byte
-	var err error
-	if r.Opt.IndentXML {
-		result, err = xml.MarshalIndent(v, "", "  ")
-	} else {
-		result, err = xml.Marshal(v)
-	}
+	result, err := r.marshalXML(v)
 	if err != nil {
 		http.Error(r, err.Error(), 500)
 		return
 	}
+	r.writeXML(status, result)
+}
+
+func (r *TplRender) marshalXML(v interface{}) ([]byte, error) {
+	if r.Opt.IndentXML {
+		return xml.MarshalIndent(v, "", "  ")
+	}
+	return xml.Marshal(v)
+}
 
+func (r *TplRender) writeXML(status int, result []byte) {
 	// XML rendered fine, write out the result
 	r.Header().Set(_CONTENT_TYPE, _CONTENT_XML+r.CompiledCharset)
 	r.WriteHeader(status)
@@ -568,6 +847,168 @@ func (r *TplRender) XML(status int, v interface{}) {
 	r.Write(result)
 }
 
+func (r *TplRender) YAML(status int, v interface{}) {
+	result, err := yaml.Marshal(v)
+	if err != nil {
+		http.Error(r, err.Error(), 500)
+		return
+	}
+
+	r.Header().Set(_CONTENT_TYPE, _CONTENT_YAML+r.CompiledCharset)
+	r.WriteHeader(status)
+	r.Write(result)
+}
+
+func (r *TplRender) MsgPack(status int, v interface{}) {
+	result, err := msgpack.Marshal(v)
+	if err != nil {
+		http.Error(r, err.Error(), 500)
+		return
+	}
+
+	r.Header().Set(_CONTENT_TYPE, _CONTENT_MSGPACK)
+	r.WriteHeader(status)
+	r.Write(result)
+}
+
+// Negotiate renders v in the format preferred by Accept header of request among
+// Options.Formats, or HTML template if v is a View and client prefers HTML. The first
+// format is used if there is no Accept header, and 406 is responded if none is acceptable.
+// Values which can not be marshalled as XML, e.g. maps, are rendered as JSON instead.
+func (r *TplRender) Negotiate(status int, v interface{}) {
+	offers := r.Opt.Formats
+	view, isView := v.(View)
+	if p, ok := v.(*View); ok && p != nil {
+		view, isView = *p, true
+	}
+	if isView {
+		offers = append([]string{r.Opt.HTMLContentType}, offers...)
+		v = view.Data
+	}
+
+	r.Header().Add("Vary", "Accept")
+	switch NegotiateContentType(r.accept, offers...) {
+	case r.Opt.HTMLContentType:
+		if len(view.Set) == 0 {
+			view.Set = DEFAULT_TPL_SET_NAME
+		}
+		if len(view.Layout) > 0 {
+			r.renderHTML(status, view.Set, view.Name, view.Data, HTMLOptions{Layout: view.Layout})
+		} else {
+			r.renderHTML(status, view.Set, view.Name, view.Data)
+		}
+	case _CONTENT_JSON:
+		r.JSON(status, v)
+	case _CONTENT_XML:
+		if result, err := r.marshalXML(v); err == nil {
+			r.writeXML(status, result)
+		} else {
+			r.JSON(status, v)
+		}
+	case _CONTENT_YAML:
+		r.YAML(status, v)
+	case _CONTENT_MSGPACK:
+		r.MsgPack(status, v)
+	default:
+		http.Error(r, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+	}
+}
+
+// mediaTypeAliases are alternative names of media types used in Accept header.
+var mediaTypeAliases = map[string]string{
+	"application/xml":         _CONTENT_XML,
+	"text/yaml":               _CONTENT_YAML,
+	"application/yaml":        _CONTENT_YAML,
+	"application/x-msgpack":   _CONTENT_MSGPACK,
+	"application/vnd.msgpack": _CONTENT_MSGPACK,
+}
+
+// NegotiateContentType returns the offer preferred by accept which is value of Accept
+// header, offers are in order of preference of server. It returns the first offer if
+// accept is empty, and empty string if none of offers is acceptable.
+//
+// Only media types named exactly in accept express preference, if the preferred offer
+// is matched by a wildcard or an alias, e.g. "application/xml" sent by browsers along
+// with "*/*", the first acceptable offer is returned.
+func NegotiateContentType(accept string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if len(strings.TrimSpace(accept)) == 0 {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		typ, sub string
+		q        float64
+		alias    bool
+	}
+	ranges := make([]mediaRange, 0, 4)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		alias, isAlias := mediaTypeAliases[mt]
+		if isAlias {
+			mt = alias
+		}
+		i := strings.Index(mt, "/")
+		if i == -1 {
+			continue
+		}
+		mr := mediaRange{typ: mt[:i], sub: mt[i+1:], q: 1, alias: isAlias}
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+
+	var (
+		best, first string
+		bestQ       float64
+		exact       bool
+	)
+	for _, offer := range offers {
+		i := strings.Index(offer, "/")
+		if i == -1 {
+			continue
+		}
+		typ, sub := offer[:i], offer[i+1:]
+		// The most specific range decides quality of the offer, exact names win aliases.
+		q, specificity := 0.0, -1
+		for _, mr := range ranges {
+			s := -1
+			switch {
+			case mr.typ == typ && mr.sub == sub && !mr.alias:
+				s = 3
+			case mr.typ == typ && mr.sub == sub:
+				s = 2
+			case mr.typ == typ && mr.sub == "*":
+				s = 1
+			case mr.typ == "*" && mr.sub == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = mr.q, s
+			}
+		}
+		if q > 0 && len(first) == 0 {
+			first = offer
+		}
+		if q > bestQ {
+			best, bestQ, exact = offer, q, specificity == 3
+		}
+	}
+	if !exact {
+		return first
+	}
+	return best
+}
+
 func (r *TplRender) data(status int, contentType string, v []byte) {
 	if r.Header().Get(_CONTENT_TYPE) == "" {
 		r.Header().Set(_CONTENT_TYPE, contentType)
@@ -604,21 +1045,26 @@ func (r *TplRender) addYield(t *template.Template, tplName string, data interfac
 }
 
 func (r *TplRender) renderBytes(setName, tplName string, data interface{}, htmlOpt ...HTMLOptions) (*bytes.Buffer, error) {
-	t := r.TemplateSet.Get(setName)
 	if r.env == DEV {
-		opt := *r.Opt
-		opt.Directory = r.TemplateSet.GetDir(setName)
-		t = r.TemplateSet.Set(setName, &opt)
+		if err := r.TemplateSet.refresh(setName); err != nil {
+			return nil, err
+		}
 	}
-	if t == nil {
+	e := r.TemplateSet.entry(setName)
+	if e == nil {
 		return nil, fmt.Errorf("html/template: template \"%s\" is undefined", tplName)
 	}
 
+	t, err := e.view(tplName)
+	if err != nil {
+		return nil, err
+	}
+
 	opt := r.prepareHTMLOptions(htmlOpt)
 
 	if len(opt.Layout) > 0 {
 		r.addYield(t, tplName, data)
-		tplName = opt.Layout
+		tplName = e.layout(opt.Layout)
 	}
 
 	out, err := r.execute(t, tplName, data)
@@ -634,7 +1080,9 @@ func (r *TplRender) renderHTML(status int, setName, tplName string, data interfa
 
 	out, err := r.renderBytes(setName, tplName, data, htmlOpt...)
 	if err != nil {
-		http.Error(r, err.Error(), http.StatusInternalServerError)
+		if r.env != DEV || !r.renderTemplateError(setName, err) {
+			http.Error(r, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -647,6 +1095,80 @@ func (r *TplRender) renderHTML(status int, setName, tplName string, data interfa
 	bufpool.Put(out)
 }
 
+// templateErrorPattern matches location in errors of parsing and executing templates,
+// e.g. `template: hello:3:5: executing "hello" at <.Name>: ...`.
+var templateErrorPattern = regexp.MustCompile(`template: ?([^:\s]+):(\d+):(?:(\d+):)?`)
+
+// templateErrorPage is the page of template errors in development.
+var templateErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Template Error</title>
+<style>
+body{font-family:sans-serif;margin:2em;color:#333}
+h1{color:#c00;font-size:1.5em}
+.error{background:#fee;border:1px solid #fcc;padding:1em;white-space:pre-wrap}
+.source{background:#f6f6f6;border:1px solid #ddd;font-family:monospace;padding:.5em 0}
+.line{white-space:pre;padding:0 1em}
+.line.current{background:#fdd}
+.no{color:#999;display:inline-block;width:4em}
+</style>
+</head>
+<body>
+<h1>Template Error</h1>
+<p><strong>{{.File}}</strong> line {{.Line}}{{if .Column}}, column {{.Column}}{{end}}</p>
+<div class="error">{{.Error}}</div>
+{{if .Lines}}<div class="source">{{range .Lines}}
+<div class="line{{if .Current}} current{{end}}"><span class="no">{{.No}}</span>{{.Text}}</div>{{end}}
+</div>{{end}}
+</body>
+</html>
+`))
+
+// renderTemplateError writes a page showing file and line of template error, it returns
+// false if location of error is unknown.
+func (r *TplRender) renderTemplateError(setName string, err error) bool {
+	e := r.TemplateSet.entry(setName)
+	// The innermost one is the cause of errors in layouts or components.
+	ms := templateErrorPattern.FindAllStringSubmatch(err.Error(), -1)
+	if e == nil || len(ms) == 0 {
+		return false
+	}
+	m := ms[len(ms)-1]
+
+	type line struct {
+		No      int
+		Text    string
+		Current bool
+	}
+	no, _ := strconv.Atoi(m[2])
+	var lines []line
+	if f, ok := e.sourceFiles()[m[1]]; ok {
+		src := strings.Split(string(f.Data()), "\n")
+		for i := no - 5; i <= no+5; i++ {
+			if i >= 1 && i <= len(src) {
+				lines = append(lines, line{i, src[i-1], i == no})
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := templateErrorPage.Execute(buf, map[string]interface{}{
+		"File":   e.filename(m[1]),
+		"Line":   no,
+		"Column": m[3],
+		"Error":  err.Error(),
+		"Lines":  lines,
+	}); err != nil {
+		return false
+	}
+	r.Header().Set(_CONTENT_TYPE, _CONTENT_HTML+r.CompiledCharset)
+	r.WriteHeader(http.StatusInternalServerError)
+	buf.WriteTo(r)
+	return true
+}
+
 func (r *TplRender) HTML(status int, name string, data interface{}, htmlOpt ...HTMLOptions) {
 	r.renderHTML(status, DEFAULT_TPL_SET_NAME, name, data, htmlOpt...)
 }
@@ -776,6 +1298,18 @@ func (r *DummyRender) XML(int, interface{}) {
 	renderNotRegistered()
 }
 
+func (r *DummyRender) YAML(int, interface{}) {
+	renderNotRegistered()
+}
+
+func (r *DummyRender) MsgPack(int, interface{}) {
+	renderNotRegistered()
+}
+
+func (r *DummyRender) Negotiate(int, interface{}) {
+	renderNotRegistered()
+}
+
 func (r *DummyRender) Error(int, ...string) {
 	renderNotRegistered()
 }
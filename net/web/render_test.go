@@ -18,8 +18,11 @@ package web
 import (
 	"encoding/xml"
 	"html/template"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -190,6 +193,95 @@ func Test_Render_XML(t *testing.T) {
 	})
 }
 
+// browserAccept is the Accept header sent by browsers for navigation.
+const browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+func Test_NegotiateContentType(t *testing.T) {
+	Convey("Negotiate content type", t, func() {
+		offers := []string{_CONTENT_JSON, _CONTENT_XML, _CONTENT_YAML, _CONTENT_MSGPACK}
+		So(NegotiateContentType("", offers...), ShouldEqual, _CONTENT_JSON)
+		So(NegotiateContentType("*/*", offers...), ShouldEqual, _CONTENT_JSON)
+		So(NegotiateContentType("application/xml", offers...), ShouldEqual, _CONTENT_XML)
+		So(NegotiateContentType("application/x-yaml, application/json;q=0.5", offers...), ShouldEqual, _CONTENT_YAML)
+		So(NegotiateContentType("text/yaml", offers...), ShouldEqual, _CONTENT_YAML)
+		So(NegotiateContentType("application/x-msgpack", offers...), ShouldEqual, _CONTENT_MSGPACK)
+		So(NegotiateContentType("application/*;q=0.5, application/json;q=0", offers...), ShouldEqual, _CONTENT_YAML)
+		So(NegotiateContentType("image/png", offers...), ShouldBeEmpty)
+		So(NegotiateContentType("text/html,*/*;q=0.8", _CONTENT_HTML, _CONTENT_JSON), ShouldEqual, _CONTENT_HTML)
+		So(NegotiateContentType("application/json", _CONTENT_HTML, _CONTENT_JSON), ShouldEqual, _CONTENT_JSON)
+
+		// Aliases and wildcards do not express preference.
+		So(NegotiateContentType("text/yaml, application/json;q=0.5", offers...), ShouldEqual, _CONTENT_JSON)
+		So(NegotiateContentType(browserAccept, offers...), ShouldEqual, _CONTENT_JSON)
+		So(NegotiateContentType(browserAccept, _CONTENT_HTML, _CONTENT_JSON), ShouldEqual, _CONTENT_HTML)
+	})
+}
+
+func Test_Render_Negotiate(t *testing.T) {
+	Convey("Render in negotiated format", t, func() {
+		m := Classic()
+		m.Use(Renderer(RenderOptions{Directory: "fixtures/basic"}))
+		m.Get("/greeting", func(ctx *Context) {
+			ctx.Render.Negotiate(http.StatusCreated, Greeting{"hello", "world"})
+		})
+		m.Get("/view", func(ctx *Context) {
+			ctx.Render.Negotiate(http.StatusOK, View{Name: "hello", Data: "jeremy"})
+		})
+		m.Get("/map", func() (int, interface{}) {
+			return http.StatusOK, map[string]int{"id": 1}
+		})
+
+		do := func(path, accept string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", path, nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("Accept", accept)
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := do("/greeting", "")
+		So(resp.Code, ShouldEqual, http.StatusCreated)
+		So(resp.Header().Get(_CONTENT_TYPE), ShouldEqual, _CONTENT_JSON+"; charset=UTF-8")
+		So(resp.Header().Get("Vary"), ShouldEqual, "Accept")
+		So(resp.Body.String(), ShouldEqual, `{"one":"hello","two":"world"}`)
+
+		resp = do("/greeting", "application/xml")
+		So(resp.Header().Get(_CONTENT_TYPE), ShouldEqual, _CONTENT_XML+"; charset=UTF-8")
+		So(resp.Body.String(), ShouldEqual, "<Greeting><One>hello</One><Two>world</Two></Greeting>")
+
+		resp = do("/greeting", "application/x-yaml")
+		So(resp.Header().Get(_CONTENT_TYPE), ShouldEqual, _CONTENT_YAML+"; charset=UTF-8")
+		So(resp.Body.String(), ShouldEqual, "one: hello\ntwo: world\n")
+
+		resp = do("/greeting", "application/msgpack")
+		So(resp.Header().Get(_CONTENT_TYPE), ShouldEqual, _CONTENT_MSGPACK)
+		So(resp.Body.String(), ShouldEqual, "\x82\xa3one\xa5hello\xa3two\xa5world")
+
+		resp = do("/greeting", "image/png")
+		So(resp.Code, ShouldEqual, http.StatusNotAcceptable)
+
+		resp = do("/view", "text/html,*/*;q=0.8")
+		So(resp.Header().Get(_CONTENT_TYPE), ShouldEqual, _CONTENT_HTML+"; charset=UTF-8")
+		So(resp.Body.String(), ShouldEqual, "<h1>Hello jeremy</h1>")
+
+		resp = do("/view", "application/json")
+		So(resp.Body.String(), ShouldEqual, `"jeremy"`)
+
+		resp = do("/map", browserAccept)
+		So(resp.Code, ShouldEqual, http.StatusOK)
+		So(resp.Header().Get(_CONTENT_TYPE), ShouldEqual, _CONTENT_JSON+"; charset=UTF-8")
+		So(resp.Body.String(), ShouldEqual, `{"id":1}`)
+
+		resp = do("/map", "application/xml")
+		So(resp.Code, ShouldEqual, http.StatusOK)
+		So(resp.Header().Get(_CONTENT_TYPE), ShouldEqual, _CONTENT_JSON+"; charset=UTF-8")
+
+		resp = do("/view", browserAccept)
+		So(resp.Body.String(), ShouldEqual, "<h1>Hello jeremy</h1>")
+	})
+}
+
 func Test_Render_HTML(t *testing.T) {
 	Convey("Render HTML", t, func() {
 		m := Classic()
@@ -528,21 +620,21 @@ func Test_Render_BinaryData(t *testing.T) {
 func Test_Render_Status(t *testing.T) {
 	Convey("Render with status 204", t, func() {
 		resp := httptest.NewRecorder()
-		r := TplRender{DEV, resp, NewTemplateSet(), &RenderOptions{}, "", time.Now()}
+		r := TplRender{DEV, "", resp, NewTemplateSet(), &RenderOptions{}, "", time.Now()}
 		r.Status(204)
 		So(resp.Code, ShouldEqual, http.StatusNoContent)
 	})
 
 	Convey("Render with status 404", t, func() {
 		resp := httptest.NewRecorder()
-		r := TplRender{DEV, resp, NewTemplateSet(), &RenderOptions{}, "", time.Now()}
+		r := TplRender{DEV, "", resp, NewTemplateSet(), &RenderOptions{}, "", time.Now()}
 		r.Error(404)
 		So(resp.Code, ShouldEqual, http.StatusNotFound)
 	})
 
 	Convey("Render with status 500", t, func() {
 		resp := httptest.NewRecorder()
-		r := TplRender{DEV, resp, NewTemplateSet(), &RenderOptions{}, "", time.Now()}
+		r := TplRender{DEV, "", resp, NewTemplateSet(), &RenderOptions{}, "", time.Now()}
 		r.Error(500)
 		So(resp.Code, ShouldEqual, http.StatusInternalServerError)
 	})
@@ -735,3 +827,130 @@ func Test_dummyRender(t *testing.T) {
 		performRequest("GET", "/hastemplateset")
 	})
 }
+
+func writeTemplates(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_Render_Views(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render_views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTemplates(t, dir, map[string]string{
+		"layouts/main.tmpl":    `<title>{{block "title" .}}Site{{end}}</title>{{yield}}`,
+		"components/card.tmpl": `<div>{{.Title}}: {{.Body}}</div>`,
+		"a.tmpl":               `{{define "title"}}A {{.}}{{end}}<p>{{component "card" "Title" "a" "Body" .}}</p>`,
+		"b.tmpl":               `{{define "title"}}B {{.}}{{end}}<p>{{component "card" (dict "Title" "b" "Body" .)}}</p>`,
+		"c.tmpl":               `<p>{{.}}</p>`,
+		"bad.tmpl":             "line 1\n{{.Missing.Field}}",
+	})
+
+	Convey("Render with named layouts, blocks and components", t, func() {
+		m := New()
+		m.Use(Renderer(RenderOptions{
+			Directory: dir,
+			Layout:    "main",
+		}))
+		m.Get("/:page", func(ctx *Context) {
+			ctx.HTML(200, ctx.Params("page"), "jeremy")
+		})
+		m.Get("/view/c", func(ctx *Context) View {
+			return View{Name: "c", Data: "view", Layout: "main"}
+		})
+		m.Get("/nolayout/b", func(ctx *Context) {
+			ctx.HTML(200, "b", "jeremy", HTMLOptions{})
+		})
+
+		get := func(url string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", url, nil)
+			So(err, ShouldBeNil)
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		So(get("/a").Body.String(), ShouldEqual, "<title>A jeremy</title><p><div>a: jeremy</div></p>")
+		So(get("/b").Body.String(), ShouldEqual, "<title>B jeremy</title><p><div>b: jeremy</div></p>")
+		So(get("/c").Body.String(), ShouldEqual, "<title>Site</title><p>jeremy</p>")
+		So(get("/a").Body.String(), ShouldEqual, "<title>A jeremy</title><p><div>a: jeremy</div></p>")
+		So(get("/view/c").Body.String(), ShouldEqual, "<title>Site</title><p>view</p>")
+		So(get("/nolayout/b").Body.String(), ShouldEqual, "<p><div>b: jeremy</div></p>")
+
+		Convey("Show template errors in development", func() {
+			resp := get("/bad")
+			So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+			So(resp.Header().Get(_CONTENT_TYPE), ShouldStartWith, _CONTENT_HTML)
+			So(resp.Body.String(), ShouldContainSubstring, filepath.Join(dir, "bad.tmpl"))
+			So(resp.Body.String(), ShouldContainSubstring, "line 2")
+			So(resp.Body.String(), ShouldContainSubstring, `<span class="no">2</span>{{.Missing.Field}}`)
+
+			m.SetEnv(PROD)
+			resp = get("/bad")
+			So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+			So(resp.Header().Get(_CONTENT_TYPE), ShouldStartWith, _CONTENT_PLAIN)
+			m.SetEnv(DEV)
+		})
+	})
+}
+
+func Test_Render_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render_reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTemplates(t, dir, map[string]string{"hello.tmpl": "Hello {{.}}"})
+
+	Convey("Recompile changed templates in development", t, func() {
+		m := New()
+		m.Use(Renderer(RenderOptions{
+			Directory:     dir,
+			WatchInterval: time.Nanosecond,
+		}))
+		m.Get("/", func(ctx *Context) {
+			ctx.HTML(200, "hello", "jeremy")
+		})
+
+		get := func() *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/", nil)
+			So(err, ShouldBeNil)
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		So(get().Body.String(), ShouldEqual, "Hello jeremy")
+
+		writeTemplates(t, dir, map[string]string{"hello.tmpl": "Hi, {{.}}!"})
+		So(get().Body.String(), ShouldEqual, "Hi, jeremy!")
+
+		writeTemplates(t, dir, map[string]string{"hello.tmpl": "Broken\n{{.}"})
+		resp := get()
+		So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+		So(resp.Body.String(), ShouldContainSubstring, "line 2")
+		// Source of the failed compiling is shown instead of the previous one.
+		So(resp.Body.String(), ShouldContainSubstring, "Broken")
+		So(resp.Body.String(), ShouldNotContainSubstring, "Hi, ")
+		So(get().Code, ShouldEqual, http.StatusInternalServerError)
+
+		writeTemplates(t, dir, map[string]string{"hello.tmpl": "Fixed {{.}}"})
+		So(get().Body.String(), ShouldEqual, "Fixed jeremy")
+
+		Convey("Do not recompile in production", func() {
+			m.SetEnv(PROD)
+			writeTemplates(t, dir, map[string]string{"hello.tmpl": "Changed {{.}}"})
+			So(get().Body.String(), ShouldEqual, "Fixed jeremy")
+			m.SetEnv(DEV)
+		})
+	})
+}
@@ -90,7 +90,20 @@ func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if !ok {
 		return nil, nil, fmt.Errorf("the ResponseWriter doesn't support the Hijacker interface")
 	}
-	return hijacker.Hijack()
+	conn, brw, err := hijacker.Hijack()
+	if err == nil && !rw.Written() {
+		// Connection is taken over (e.g. WebSocket), mark it written to stop the handler chain.
+		rw.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Push implements http.Pusher, it returns http.ErrNotSupported if the connection is not HTTP/2.
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := rw.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
 }
 
 func (rw *responseWriter) CloseNotify() <-chan bool {
@@ -128,6 +128,8 @@ func Test_ResponseWriter(t *testing.T) {
 		_, _, err := hijacker.Hijack()
 		So(err, ShouldBeNil)
 		So(hijackable.Hijacked, ShouldBeTrue)
+		So(rw.Written(), ShouldBeTrue)
+		So(rw.Status(), ShouldEqual, http.StatusSwitchingProtocols)
 	})
 
 	Convey("Response writer with bad Hijack", t, func() {
@@ -16,6 +16,7 @@
 package web
 
 import (
+	"errors"
 	"net/http"
 	"reflect"
 
@@ -26,8 +27,27 @@ import (
 // when a route handler returns something. The ReturnHandler is
 // responsible for writing to the ResponseWriter based on the values
 // that are passed into this function.
+//
+// The default one handles returned values as following:
+//
+//	error                 typed errors (see ProblemOf) are written as problem+json,
+//	                      others are passed to handler of Router.InternalServerError
+//	string, []byte        written as they are
+//	T                     rendered by Render.Negotiate with status 200, or passed to handler
+//	                      of Router.InternalServerError if no Renderer is mapped
+//	(int, T)              T is written or rendered with the status
+//	(T, error)/(int, T, error)
+//	                      non-nil error is handled as error above, otherwise as (T) or (int, T)
+//
+// Nil pointers, interfaces and errors write nothing.
 type ReturnHandler func(*Context, []reflect.Value)
 
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+	errRenderNotRegistered = errors.New("web: middleware render hasn't been registered")
+)
+
 func canDeref(val reflect.Value) bool {
 	return val.Kind() == reflect.Interface || val.Kind() == reflect.Ptr
 }
@@ -41,36 +61,86 @@ func isByteSlice(val reflect.Value) bool {
 	return val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8
 }
 
+// handleError writes typed errors as problem+json, and passes others to handler of
+// Router.InternalServerError.
+func (c *Context) handleError(err error) {
+	if isTypedError(err) {
+		c.Problem(err)
+	} else {
+		c.internalServerError(c, err)
+	}
+}
+
 func defaultReturnHandler() ReturnHandler {
 	return func(ctx *Context, vals []reflect.Value) {
 		rv := ctx.GetVal(inject.InterfaceOf((*http.ResponseWriter)(nil)))
 		resp := rv.Interface().(http.ResponseWriter)
-		var respVal reflect.Value
+
+		// Trailing error of multiple values decides the response if it's not nil.
+		if n := len(vals); n > 1 && (!vals[n-1].IsValid() || vals[n-1].Type().Implements(errorType)) {
+			if vals[n-1].IsValid() && !(canDeref(vals[n-1]) && vals[n-1].IsNil()) {
+				ctx.handleError(vals[n-1].Interface().(error))
+				return
+			}
+			vals = vals[:n-1]
+		}
+
+		var (
+			status  int
+			respVal reflect.Value
+		)
 		if len(vals) > 1 && vals[0].Kind() == reflect.Int {
-			resp.WriteHeader(int(vals[0].Int()))
+			status = int(vals[0].Int())
 			respVal = vals[1]
 		} else if len(vals) > 0 {
 			respVal = vals[0]
 
 			if isError(respVal) {
-				err := respVal.Interface().(error)
-				if err != nil {
-					ctx.internalServerError(ctx, err)
+				if err := respVal.Interface().(error); err != nil {
+					ctx.handleError(err)
 				}
 				return
-			} else if canDeref(respVal) {
-				if respVal.IsNil() {
-					return // Ignore nil error
-				}
 			}
 		}
-		if canDeref(respVal) {
+
+		for respVal.IsValid() && canDeref(respVal) {
+			if respVal.IsNil() {
+				respVal = reflect.Value{}
+				break
+			}
 			respVal = respVal.Elem()
 		}
-		if isByteSlice(respVal) {
+		if !respVal.IsValid() {
+			if status != 0 {
+				resp.WriteHeader(status)
+			}
+			return // Ignore nil value
+		}
+		if err, ok := respVal.Interface().(error); ok {
+			ctx.handleError(err)
+			return
+		}
+
+		switch {
+		case isByteSlice(respVal):
+			if status != 0 {
+				resp.WriteHeader(status)
+			}
 			resp.Write(respVal.Bytes())
-		} else {
+		case respVal.Kind() == reflect.String:
+			if status != 0 {
+				resp.WriteHeader(status)
+			}
 			resp.Write([]byte(respVal.String()))
+		default:
+			if _, ok := ctx.Render.(*DummyRender); ok {
+				ctx.internalServerError(ctx, errRenderNotRegistered)
+				return
+			}
+			if status == 0 {
+				status = http.StatusOK
+			}
+			ctx.Render.Negotiate(status, respVal.Interface())
 		}
 	}
 }
@@ -16,8 +16,10 @@ package web
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"testing"
 
@@ -122,4 +124,121 @@ func Test_Return_Handler(t *testing.T) {
 
 		So(resp.Body.String(), ShouldEqual, "hello world")
 	})
+
+	Convey("Return typed values and errors", t, func() {
+		m := New()
+		m.Use(Renderer())
+		m.Get("/value", func() Greeting {
+			return Greeting{"hello", "world"}
+		})
+		m.Get("/status", func() (int, interface{}) {
+			return http.StatusCreated, map[string]int{"id": 1}
+		})
+		m.Get("/ok", func() (*Greeting, error) {
+			return &Greeting{"a", "b"}, nil
+		})
+		m.Get("/problem", func() (*Greeting, error) {
+			p := NewProblem(http.StatusNotFound, "no greeting")
+			p.Extensions = map[string]interface{}{"id": 1}
+			return nil, fmt.Errorf("wrapped: %w", p)
+		})
+		m.Get("/coder", func() (int, string, error) {
+			return 0, "", statusError(http.StatusConflict)
+		})
+		m.Get("/notexist", func() (string, error) {
+			return "", os.ErrNotExist
+		})
+		m.Get("/untyped", func() (string, error) {
+			return "", errors.New("secret")
+		})
+		m.Get("/typed", func() error {
+			return NewProblem(http.StatusBadRequest, "")
+		})
+		m.Get("/nil", func() (int, *Greeting) {
+			return http.StatusNoContent, nil
+		})
+
+		do := func(path string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", path, nil)
+			So(err, ShouldBeNil)
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := do("/value")
+		So(resp.Code, ShouldEqual, http.StatusOK)
+		So(resp.Body.String(), ShouldEqual, `{"one":"hello","two":"world"}`)
+
+		resp = do("/status")
+		So(resp.Code, ShouldEqual, http.StatusCreated)
+		So(resp.Body.String(), ShouldEqual, `{"id":1}`)
+
+		So(do("/ok").Body.String(), ShouldEqual, `{"one":"a","two":"b"}`)
+
+		resp = do("/problem")
+		So(resp.Code, ShouldEqual, http.StatusNotFound)
+		So(resp.Header().Get("Content-Type"), ShouldEqual, "application/problem+json")
+		So(resp.Body.String(), ShouldEqual, `{"detail":"no greeting","id":1,"instance":"/problem","status":404,"title":"Not Found"}`)
+
+		resp = do("/coder")
+		So(resp.Code, ShouldEqual, http.StatusConflict)
+		So(resp.Body.String(), ShouldContainSubstring, `"detail":"status 409"`)
+
+		resp = do("/notexist")
+		So(resp.Code, ShouldEqual, http.StatusNotFound)
+		So(resp.Body.String(), ShouldContainSubstring, `"detail":"Not Found"`)
+		So(do("/typed").Code, ShouldEqual, http.StatusBadRequest)
+
+		resp = do("/untyped")
+		So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+		So(resp.Body.String(), ShouldEqual, "secret\n")
+
+		resp = do("/nil")
+		So(resp.Code, ShouldEqual, http.StatusNoContent)
+		So(resp.Body.Len(), ShouldEqual, 0)
+
+		Convey("Untyped errors are passed to InternalServerError handler", func() {
+			m.InternalServerError(func(rw http.ResponseWriter, err error) {
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write([]byte("oops"))
+			})
+			So(do("/untyped").Body.String(), ShouldEqual, "oops")
+		})
+
+		Convey("Hide details of errors without status codes in production", func() {
+			m.SetEnv(PROD)
+			defer m.SetEnv(DEV)
+			m.Get("/problem-untyped", func(ctx *Context) {
+				ctx.Problem(errors.New("secret"))
+			})
+			So(do("/problem-untyped").Body.String(), ShouldNotContainSubstring, "secret")
+			So(do("/notexist").Body.String(), ShouldNotContainSubstring, "detail")
+			So(do("/problem").Body.String(), ShouldContainSubstring, "no greeting")
+			So(do("/coder").Body.String(), ShouldContainSubstring, "status 409")
+		})
+	})
+
+	Convey("Return value without renderer", t, func() {
+		m := New()
+		m.Get("/", func() Greeting {
+			return Greeting{"hello", "world"}
+		})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		So(func() { m.ServeHTTP(resp, req) }, ShouldNotPanic)
+		So(resp.Code, ShouldEqual, http.StatusInternalServerError)
+	})
+}
+
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("status %d", int(e))
+}
+
+func (e statusError) StatusCode() int {
+	return int(e)
 }
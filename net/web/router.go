@@ -15,7 +15,13 @@
 package web
 
 import (
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -78,11 +84,20 @@ type Router struct {
 	routers  map[string]*Tree
 	*routeMap
 	namedRoutes map[string]*Leaf
+	routes      []*RouteInfo
 
 	groups              []group
+	preflights          map[string]*preflightRoute
+	conflicts           []RouteConflict
 	notFound            http.HandlerFunc
 	internalServerError func(*Context, error)
 
+	// parent is the router which a host router belongs to, host is the host pattern.
+	parent  *Router
+	host    string
+	hosts   []*hostRouter
+	curHost *hostRouter
+
 	// handlerWrapper is used to wrap arbitrary function from Handler to inject.FastInvoker.
 	handlerWrapper func(Handler) Handler
 }
@@ -92,6 +107,7 @@ func NewRouter() *Router {
 		routers:     make(map[string]*Tree),
 		routeMap:    NewRouteMap(),
 		namedRoutes: make(map[string]*Leaf),
+		preflights:  make(map[string]*preflightRoute),
 	}
 }
 
@@ -107,10 +123,24 @@ type Params map[string]string
 // Like http.HandlerFunc, but has a third parameter for the values of wildcards (variables).
 type Handle func(http.ResponseWriter, *http.Request, Params)
 
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	// Host is the host pattern of Router.Host, empty for routes of any host.
+	Host    string
+	Method  string
+	Pattern string
+	Name    string
+	// Handlers are route handlers including those of groups, global middlewares are not included.
+	Handlers []Handler
+	// Meta holds arbitrary values attached by Route.Meta, e.g. documentation of the route.
+	Meta []interface{}
+}
+
 // Route represents a wrapper of leaf route and upper level router.
 type Route struct {
 	router *Router
 	leaf   *Leaf
+	infos  []*RouteInfo
 }
 
 // Name sets name of route.
@@ -121,16 +151,57 @@ func (r *Route) Name(name string) {
 		panic("route with given name already exists: " + name)
 	}
 	r.router.namedRoutes[name] = r.leaf
+	for _, info := range r.infos {
+		info.Name = name
+	}
+}
+
+// Meta attaches values to the route, they can be retrieved by Router.Routes.
+func (r *Route) Meta(vals ...interface{}) *Route {
+	for _, info := range r.infos {
+		info.Meta = append(info.Meta, vals...)
+	}
+	return r
+}
+
+// getRouteInfos returns records of registered route with given method and pattern.
+func (r *Router) getRouteInfos(method, pattern string) []*RouteInfo {
+	infos := make([]*RouteInfo, 0, 1)
+	for _, info := range r.routes {
+		if info.Method == method && info.Pattern == pattern {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// Routes returns descriptions of all registered routes in order of registration,
+// routes of hosts are included.
+func (r *Router) Routes() []RouteInfo {
+	routes := make([]RouteInfo, len(r.routes))
+	for i, info := range r.routes {
+		routes[i] = *info
+	}
+	return routes
 }
 
 // handle adds new route to the router tree.
-func (r *Router) handle(method, pattern string, handle Handle) *Route {
+func (r *Router) handle(method, pattern string, handlers []Handler, handle Handle) *Route {
 	method = strings.ToUpper(method)
 
 	var leaf *Leaf
-	// Prevent duplicate routes.
-	if leaf = r.getLeaf(method, pattern); leaf != nil {
-		return &Route{r, leaf}
+	// Prevent duplicate routes, but explicit OPTIONS route overrides the automatic one.
+	if leaf = r.getLeaf(method, pattern); leaf != nil && (method != "OPTIONS" || r.preflights[pattern] == nil) {
+		infos := r.getRouteInfos(method, pattern)
+		// Registering again without handlers is allowed to attach names and meta.
+		if len(handlers) > 0 && len(infos) > 0 {
+			root := r.root()
+			root.conflicts = append(root.conflicts, RouteConflict{
+				Route: RouteInfo{Host: r.host, Method: method, Pattern: pattern, Handlers: handlers},
+				With:  *infos[0],
+			})
+		}
+		return &Route{r, leaf, infos}
 	}
 
 	// Validate HTTP methods.
@@ -139,17 +210,23 @@ func (r *Router) handle(method, pattern string, handle Handle) *Route {
 	}
 
 	// Generate methods need register.
-	methods := make(map[string]bool)
+	methods := []string{method}
 	if method == "*" {
+		methods = methods[:0]
 		for m := range _HTTP_METHODS {
-			methods[m] = true
+			methods = append(methods, m)
 		}
-	} else {
-		methods[method] = true
+		// Keep registration order stable for Routes.
+		sort.Strings(methods)
 	}
 
 	// Add to router tree.
-	for m := range methods {
+	infos := make([]*RouteInfo, 0, len(methods))
+	for _, m := range methods {
+		if p, ok := r.preflights[pattern]; ok && m == "OPTIONS" {
+			p.handle = handle
+			delete(r.preflights, pattern)
+		}
 		if t, ok := r.routers[m]; ok {
 			leaf = t.Add(pattern, handle)
 		} else {
@@ -158,34 +235,119 @@ func (r *Router) handle(method, pattern string, handle Handle) *Route {
 			r.routers[m] = t
 		}
 		r.add(m, pattern, leaf)
+
+		info := &RouteInfo{Host: r.host, Method: m, Pattern: pattern, Handlers: handlers}
+		infos = append(infos, info)
+		r.routes = append(r.routes, info)
+		if r.parent != nil {
+			r.parent.routes = append(r.parent.routes, info)
+		}
 	}
-	return &Route{r, leaf}
+	return &Route{r, leaf, infos}
 }
 
 // Handle registers a new request handle with the given pattern, method and handlers.
 func (r *Router) Handle(method string, pattern string, handlers []Handler) *Route {
+	var preflight []Handler
 	if len(r.groups) > 0 {
 		groupPattern := ""
 		h := make([]Handler, 0)
+		hasPreflight := false
 		for _, g := range r.groups {
 			groupPattern += g.pattern
 			h = append(h, g.handlers...)
+			for _, gh := range g.handlers {
+				if _, ok := gh.(PreflightHandler); ok {
+					hasPreflight = true
+				}
+			}
 		}
 
 		pattern = groupPattern + pattern
+		if hasPreflight && method != "OPTIONS" && method != "*" {
+			preflight = h[:len(h):len(h)]
+		}
 		h = append(h, handlers...)
 		handlers = h
 	}
 	handlers = validateAndWrapHandlers(handlers, r.handlerWrapper)
 
-	return r.handle(method, pattern, func(resp http.ResponseWriter, req *http.Request, params Params) {
+	// Routes in Router.Host are added to the router of the host.
+	target := r
+	if r.curHost != nil {
+		target = r.curHost.router
+	}
+	if preflight != nil {
+		target.addPreflight(pattern, preflight)
+	}
+	return target.handle(method, pattern, handlers, r.contextHandle(pattern, handlers))
+}
+
+// contextHandle returns a Handle which runs global middlewares and handlers.
+func (r *Router) contextHandle(pattern string, handlers []Handler) Handle {
+	return func(resp http.ResponseWriter, req *http.Request, params Params) {
 		c := r.m.createContext(resp, req)
+		c.pattern = pattern
 		c.params = params
 		c.handlers = make([]Handler, 0, len(r.m.handlers)+len(handlers))
 		c.handlers = append(c.handlers, r.m.handlers...)
 		c.handlers = append(c.handlers, handlers...)
 		c.run()
+	}
+}
+
+// PreflightHandler is a handler which answers OPTIONS requests, e.g. CORS middleware.
+// When it's one of handlers of Router.Group, OPTIONS requests to routes in the group
+// are handled by handlers of the group even if no OPTIONS route is registered.
+// The response is 204 with Allow header if none of them writes response.
+type PreflightHandler func(*Context)
+
+func (invoke PreflightHandler) Invoke(params []interface{}) ([]reflect.Value, error) {
+	invoke(params[0].(*Context))
+	return nil, nil
+}
+
+// preflightRoute is an automatic OPTIONS route, handle is replaced when OPTIONS route
+// of the same pattern is registered explicitly.
+type preflightRoute struct {
+	handle Handle
+}
+
+// addPreflight adds automatic OPTIONS route which runs handlers of groups.
+func (r *Router) addPreflight(pattern string, handlers []Handler) {
+	if r.getLeaf("OPTIONS", pattern) != nil {
+		return
+	}
+
+	handlers = validateAndWrapHandlers(handlers, r.handlerWrapper)
+	handlers = append(handlers, ContextInvoker(func(c *Context) {
+		c.Resp.Header().Set("Allow", strings.Join(r.allowedMethods(pattern), ", "))
+		c.Resp.WriteHeader(http.StatusNoContent)
+	}))
+	p := &preflightRoute{r.contextHandle(pattern, handlers)}
+	r.preflights[pattern] = p
+
+	t, ok := r.routers["OPTIONS"]
+	if !ok {
+		t = NewTree()
+		r.routers["OPTIONS"] = t
+	}
+	leaf := t.Add(pattern, func(resp http.ResponseWriter, req *http.Request, params Params) {
+		p.handle(resp, req, params)
 	})
+	r.add("OPTIONS", pattern, leaf)
+}
+
+// allowedMethods returns sorted methods registered with the pattern.
+func (r *Router) allowedMethods(pattern string) []string {
+	methods := make([]string, 0, len(_HTTP_METHODS))
+	for m := range _HTTP_METHODS {
+		if r.getLeaf(m, pattern) != nil {
+			methods = append(methods, m)
+		}
+	}
+	sort.Strings(methods)
+	return methods
 }
 
 func (r *Router) Group(pattern string, fn func(), h ...Handler) {
@@ -194,6 +356,163 @@ func (r *Router) Group(pattern string, fn func(), h ...Handler) {
 	r.groups = r.groups[:len(r.groups)-1]
 }
 
+// hostRouter is a router of requests whose host matches the pattern.
+type hostRouter struct {
+	pattern   string
+	reg       *regexp.Regexp
+	wildcards []string
+	router    *Router
+}
+
+// newHostRouter parses host pattern, labels separated by dots are matched literally
+// except ":name" which matches a label and "*" which matches one or more labels.
+func newHostRouter(parent *Router, pattern string) *hostRouter {
+	labels := strings.Split(strings.ToLower(pattern), ".")
+	wildcards := make([]string, 0, 1)
+	for i, label := range labels {
+		switch {
+		case label == "*":
+			labels[i] = `(.+)`
+			wildcards = append(wildcards, "*")
+		case len(label) > 1 && label[0] == ':':
+			labels[i] = `([^.]+)`
+			wildcards = append(wildcards, label)
+		default:
+			labels[i] = regexp.QuoteMeta(label)
+		}
+	}
+
+	router := NewRouter()
+	router.m = parent.m
+	router.namedRoutes = parent.namedRoutes
+	router.handlerWrapper = parent.handlerWrapper
+	router.parent = parent
+	router.host = pattern
+	return &hostRouter{
+		pattern:   pattern,
+		reg:       regexp.MustCompile("^" + strings.Join(labels, `\.`) + "$"),
+		wildcards: wildcards,
+		router:    router,
+	}
+}
+
+// match returns params of wildcards if host matches the pattern, port of host is ignored.
+func (h *hostRouter) match(host string) (Params, bool) {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	results := h.reg.FindStringSubmatch(strings.ToLower(host))
+	if results == nil {
+		return nil, false
+	}
+	params := make(Params, len(h.wildcards))
+	for i, wildcard := range h.wildcards {
+		params[wildcard] = results[i+1]
+	}
+	return params, true
+}
+
+// Host registers routes added in fn for requests whose host matches pattern, handlers
+// are used as handlers of a group. Labels of pattern are matched literally except ":name"
+// which matches a label and "*" which matches one or more labels, matched labels are
+// available as params, e.g. ctx.Params(":tenant"). Port of the request host is ignored.
+// Hosts are tried in order of first registration, requests fall back to routes added
+// outside Host when none of routes of matched hosts matches.
+//
+// Example:
+//
+//	m.Host(":tenant.example.com", func() {
+//		m.Get("/", func(ctx *web.Context) string {
+//			return "Hello " + ctx.Params(":tenant")
+//		})
+//	})
+func (r *Router) Host(pattern string, fn func(), h ...Handler) {
+	var host *hostRouter
+	for _, hr := range r.hosts {
+		if hr.pattern == pattern {
+			host = hr
+			break
+		}
+	}
+	if host == nil {
+		host = newHostRouter(r, pattern)
+		r.hosts = append(r.hosts, host)
+	}
+
+	prev := r.curHost
+	r.curHost = host
+	r.Group("", fn, h...)
+	r.curHost = prev
+}
+
+// Mount serves requests of prefix and paths under it by h with the prefix stripped
+// from URL path, e.g. an independent *Web. Global middlewares, handlers of groups and
+// given handlers run before h, be aware that both of them may write responses.
+//
+// Example:
+//
+//	admin := web.Classic()
+//	admin.Get("/", ...)
+//	m.Mount("/admin", admin, auth.RequireRoles("admin"))
+func (r *Router) Mount(prefix string, h http.Handler, handlers ...Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	handlers = append(handlers[:len(handlers):len(handlers)], ContextInvoker(func(c *Context) {
+		req := new(http.Request)
+		*req = *c.Req.Request
+		req.URL = new(url.URL)
+		*req.URL = *c.Req.URL
+		req.URL.Path = "/" + c.params["*"]
+		req.URL.RawPath = ""
+		h.ServeHTTP(c.Resp, req)
+	}))
+	r.Any(prefix, handlers...)
+	r.Any(prefix+"/*", handlers...)
+}
+
+// RouteConflict describes a route which can never be matched.
+type RouteConflict struct {
+	// Route is the route which can never be matched.
+	Route RouteInfo
+	// With is the route registered earlier which takes precedence.
+	With RouteInfo
+}
+
+func (c RouteConflict) String() string {
+	return fmt.Sprintf("%s %s%s conflicts with %s%s", c.Route.Method, c.Route.Host, c.Route.Pattern, c.With.Host, c.With.Pattern)
+}
+
+// Conflicts returns routes registered with handlers more than once and routes whose
+// patterns are same as earlier ones except names of wildcards or trailing slashes,
+// e.g. "/user/:id" and "/user/:name/". Check it after all routes are registered.
+func (r *Router) Conflicts() []RouteConflict {
+	r = r.root()
+	conflicts := append([]RouteConflict{}, r.conflicts...)
+	seen := make(map[string]*RouteInfo, len(r.routes))
+	for _, info := range r.routes {
+		key := info.Host + " " + info.Method + " " + normalizePattern(info.Pattern)
+		if with, ok := seen[key]; ok {
+			conflicts = append(conflicts, RouteConflict{*info, *with})
+			continue
+		}
+		seen[key] = info
+	}
+	return conflicts
+}
+
+// normalizePattern returns pattern with wildcards replaced by their regexps.
+func normalizePattern(pattern string) string {
+	pattern, _ = getWildcards(strings.Trim(pattern, "/"))
+	return pattern
+}
+
+// root returns the router which host routers belong to.
+func (r *Router) root() *Router {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
 // Get is a shortcut for r.Handle("GET", pattern, handlers)
 func (r *Router) Get(pattern string, h ...Handler) (leaf *Route) {
 	leaf = r.Handle("GET", pattern, h)
@@ -284,30 +603,54 @@ func (r *Router) InternalServerError(handlers ...Handler) {
 // SetHandlerWrapper sets handlerWrapper for the router.
 func (r *Router) SetHandlerWrapper(f func(Handler) Handler) {
 	r.handlerWrapper = f
+	for _, h := range r.hosts {
+		h.router.handlerWrapper = f
+	}
 }
 
 func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	if t, ok := r.routers[req.Method]; ok {
-		// Fast match for static routes
-		leaf := r.getLeaf(req.Method, req.URL.Path)
-		if leaf != nil {
-			leaf.handle(rw, req, nil)
-			return
-		}
-
-		h, p, ok := t.Match(req.URL.EscapedPath())
-		if ok {
-			if splat, ok := p["*0"]; ok {
-				p["*"] = splat // Easy name.
-			}
-			h(rw, req, p)
+	for _, h := range r.hosts {
+		if params, ok := h.match(req.Host); ok && h.router.serve(rw, req, params) {
 			return
 		}
 	}
+	if r.serve(rw, req, nil) {
+		return
+	}
 
 	r.notFound(rw, req)
 }
 
+// serve handles request by matched route with extra params, it returns false if no route matches.
+func (r *Router) serve(rw http.ResponseWriter, req *http.Request, params Params) bool {
+	t, ok := r.routers[req.Method]
+	if !ok {
+		return false
+	}
+
+	// Fast match for static routes
+	leaf := r.getLeaf(req.Method, req.URL.Path)
+	if leaf != nil {
+		leaf.handle(rw, req, params)
+		return true
+	}
+
+	h, p, ok := t.Match(req.URL.EscapedPath())
+	if !ok {
+		return false
+	}
+	if splat, ok := p["*0"]; ok {
+		p["*"] = splat // Easy name.
+	}
+	for k, v := range params {
+		if _, ok := p[k]; !ok {
+			p[k] = v
+		}
+	}
+	h(rw, req, p)
+	return true
+}
+
 // URLFor builds path part of URL by given pair values.
 func (r *Router) URLFor(name string, pairs ...string) string {
 	leaf, ok := r.namedRoutes[name]
@@ -235,6 +235,193 @@ func Test_Route_Name(t *testing.T) {
 	})
 }
 
+func Test_Router_Routes(t *testing.T) {
+	Convey("List registered routes", t, func() {
+		m := New()
+		m.Group("/api", func() {
+			m.Get("/user/:id", func() {}, func() {}).Meta("user doc").Name("user")
+		}, func() {})
+		m.Get("/user/:id", func() {})
+		m.Any("/any", func() {})
+
+		routes := m.Routes()
+		So(len(routes), ShouldEqual, 2+len(_HTTP_METHODS))
+		So(routes[0].Method, ShouldEqual, "GET")
+		So(routes[0].Pattern, ShouldEqual, "/api/user/:id")
+		So(routes[0].Name, ShouldEqual, "user")
+		So(len(routes[0].Handlers), ShouldEqual, 3)
+		So(routes[0].Meta, ShouldResemble, []interface{}{"user doc"})
+		So(routes[1].Pattern, ShouldEqual, "/user/:id")
+		So(routes[1].Name, ShouldBeEmpty)
+		So(routes[2].Method, ShouldEqual, "DELETE")
+		So(routes[2].Pattern, ShouldEqual, "/any")
+
+		Convey("Duplicated route shares the record", func() {
+			m.Get("/user/:id").Meta("more")
+			So(m.Routes()[1].Meta, ShouldResemble, []interface{}{"more"})
+		})
+	})
+}
+
+func Test_Router_Host(t *testing.T) {
+	Convey("Route requests by host", t, func() {
+		m := New()
+		m.Host(":tenant.example.com", func() {
+			m.Get("/", func(ctx *Context) string { return "tenant " + ctx.Params(":tenant") })
+			m.Get("/user/:id", func(ctx *Context) string {
+				return ctx.Params(":tenant") + " user " + ctx.Params(":id")
+			}).Name("tenant_user")
+		}, func(ctx *Context) { ctx.Resp.Header().Set("X-Host", "tenant") })
+		m.Host("*.static.example.com", func() {
+			m.Get("/", func(ctx *Context) string { return "static " + ctx.Params("*") })
+		})
+		m.Get("/", func() string { return "home" })
+		m.Get("/about", func() string { return "about" })
+
+		do := func(host, path string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", path, nil)
+			So(err, ShouldBeNil)
+			req.Host = host
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := do("acme.example.com:8080", "/")
+		So(resp.Body.String(), ShouldEqual, "tenant acme")
+		So(resp.Header().Get("X-Host"), ShouldEqual, "tenant")
+		So(do("ACME.example.com", "/user/1").Body.String(), ShouldEqual, "acme user 1")
+		So(do("a.b.static.example.com", "/").Body.String(), ShouldEqual, "static a.b")
+		So(do("example.com", "/").Body.String(), ShouldEqual, "home")
+		So(do("a.b.example.com", "/").Body.String(), ShouldEqual, "home")
+
+		Convey("Fall back to routes of any host", func() {
+			So(do("acme.example.com", "/about").Body.String(), ShouldEqual, "about")
+			So(do("acme.example.com", "/none").Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Routes of hosts are listed and named", func() {
+			routes := m.Routes()
+			So(len(routes), ShouldEqual, 5)
+			So(routes[0].Host, ShouldEqual, ":tenant.example.com")
+			So(routes[1].Name, ShouldEqual, "tenant_user")
+			So(routes[3].Host, ShouldBeEmpty)
+			So(m.URLFor("tenant_user", "id", "1"), ShouldEqual, "/user/1")
+		})
+	})
+}
+
+func Test_Router_Mount(t *testing.T) {
+	Convey("Mount applications under prefix", t, func() {
+		sub := New()
+		sub.Get("/", func(ctx *Context) string { return "sub index " + ctx.Req.URL.RawQuery })
+		sub.Get("/user/:id", func(ctx *Context) string { return "sub user " + ctx.Params(":id") })
+
+		m := New()
+		m.Group("/apps", func() {
+			m.Mount("/sub/", sub, func(ctx *Context) { ctx.Resp.Header().Set("X-Mounted", "1") })
+			m.Mount("/fn", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Write([]byte(req.Method + " " + req.URL.Path))
+			}))
+		})
+
+		do := func(method, path string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(method, path, nil)
+			So(err, ShouldBeNil)
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := do("GET", "/apps/sub?q=1")
+		So(resp.Body.String(), ShouldEqual, "sub index q=1")
+		So(resp.Header().Get("X-Mounted"), ShouldEqual, "1")
+		So(do("GET", "/apps/sub/").Body.String(), ShouldEqual, "sub index ")
+		So(do("GET", "/apps/sub/user/2").Body.String(), ShouldEqual, "sub user 2")
+		So(do("GET", "/apps/sub/none").Code, ShouldEqual, http.StatusNotFound)
+		So(do("DELETE", "/apps/fn/a/b").Body.String(), ShouldEqual, "DELETE /a/b")
+		So(do("GET", "/apps/other").Code, ShouldEqual, http.StatusNotFound)
+	})
+}
+
+func Test_Router_Conflicts(t *testing.T) {
+	Convey("Detect conflicting routes", t, func() {
+		m := New()
+		m.Get("/user/:id", func() {})
+		m.Get("/user/:name/", func() {})
+		m.Get("/user/:id:int", func() {})
+		m.Post("/user/:name", func() {})
+		m.Get("/user/:id", func() {})
+		m.Get("/user/:id").Meta("doc")
+		m.Host("api.example.com", func() {
+			m.Get("/user/:id", func() {})
+		})
+		So(len(m.Routes()), ShouldEqual, 5)
+
+		conflicts := m.Conflicts()
+		So(len(conflicts), ShouldEqual, 2)
+		So(conflicts[0].Route.Pattern, ShouldEqual, "/user/:id")
+		So(conflicts[0].With.Pattern, ShouldEqual, "/user/:id")
+		So(conflicts[1].Route.Pattern, ShouldEqual, "/user/:name/")
+		So(conflicts[1].With.Pattern, ShouldEqual, "/user/:id")
+		So(conflicts[1].String(), ShouldEqual, "GET /user/:name/ conflicts with /user/:id")
+	})
+}
+
+func Test_Router_Preflight(t *testing.T) {
+	Convey("Route OPTIONS requests to group with preflight handler", t, func() {
+		m := New()
+		m.Group("/api", func() {
+			m.Get("/user/:id", func() string { return "user" })
+			m.Post("/user/:id", func() string { return "updated" })
+			m.Get("/explicit", func() {})
+		}, PreflightHandler(func(ctx *Context) {
+			if ctx.Req.Method == "OPTIONS" && ctx.Req.Header.Get("X-Preflight") == "1" {
+				ctx.Resp.WriteHeader(http.StatusAccepted)
+			}
+		}))
+		m.Options("/api/explicit", func(ctx *Context) {
+			ctx.Resp.WriteHeader(http.StatusTeapot)
+		})
+		m.Group("/plain", func() {
+			m.Get("/", func() {})
+		}, func() {})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("OPTIONS", "/api/user/1", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("X-Preflight", "1")
+		m.ServeHTTP(resp, req)
+		So(resp.Code, ShouldEqual, http.StatusAccepted)
+
+		resp = httptest.NewRecorder()
+		req, err = http.NewRequest("OPTIONS", "/api/user/1", nil)
+		So(err, ShouldBeNil)
+		m.ServeHTTP(resp, req)
+		So(resp.Code, ShouldEqual, http.StatusNoContent)
+		So(resp.Header().Get("Allow"), ShouldEqual, "GET, OPTIONS, POST")
+
+		resp = httptest.NewRecorder()
+		req, err = http.NewRequest("OPTIONS", "/api/explicit", nil)
+		So(err, ShouldBeNil)
+		m.ServeHTTP(resp, req)
+		So(resp.Code, ShouldEqual, http.StatusTeapot)
+
+		resp = httptest.NewRecorder()
+		req, err = http.NewRequest("OPTIONS", "/plain/", nil)
+		So(err, ShouldBeNil)
+		m.ServeHTTP(resp, req)
+		So(resp.Code, ShouldEqual, http.StatusNotFound)
+
+		// Automatic routes are not listed.
+		for _, route := range m.Routes() {
+			if route.Method == "OPTIONS" {
+				So(route.Pattern, ShouldEqual, "/api/explicit")
+			}
+		}
+	})
+}
+
 func Test_Router_URLFor(t *testing.T) {
 	Convey("Build URL path", t, func() {
 		m := New()
@@ -0,0 +1,342 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"landzero.net/x/com"
+	"landzero.net/x/net/web"
+)
+
+// ErrCookieTooLarge is returned by CookieStore.Set if the encoded session
+// would exceed the size limit of cookie.
+var ErrCookieTooLarge = errors.New("session: cookie store exceeds size limit")
+
+// cookiePayload is the content of an encrypted session cookie.
+type cookiePayload struct {
+	ID      string
+	Expires int64
+	Data    []byte
+}
+
+// CookieStore represents a session store kept in an encrypted cookie.
+type CookieStore struct {
+	p       *CookieAdapter
+	sid     string
+	lock    sync.RWMutex
+	data    map[interface{}]interface{}
+	expires time.Time
+	changed bool
+}
+
+// NewCookieStore creates and returns a cookie session store.
+func NewCookieStore(p *CookieAdapter, sid string, kv map[interface{}]interface{}, expires time.Time) *CookieStore {
+	return &CookieStore{
+		p:       p,
+		sid:     sid,
+		data:    kv,
+		expires: expires,
+	}
+}
+
+// Set sets value to given key in session, it returns error wrapping
+// ErrCookieTooLarge and keeps session unchanged if the limit is exceeded.
+func (s *CookieStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	old, exists := s.data[key]
+	s.data[key] = val
+	if _, err := s.encode(); err != nil {
+		if exists {
+			s.data[key] = old
+		} else {
+			delete(s.data, key)
+		}
+		return err
+	}
+	s.changed = true
+	return nil
+}
+
+// Get gets value by given key in session.
+func (s *CookieStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.data[key]
+}
+
+// Delete deletes a key from session.
+func (s *CookieStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.data, key)
+	s.changed = true
+	return nil
+}
+
+// ID returns current session ID.
+func (s *CookieStore) ID() string {
+	return s.sid
+}
+
+// Release releases resource, data is saved into cookie before response is written.
+func (_ *CookieStore) Release() error {
+	return nil
+}
+
+// Flush deletes all session data.
+func (s *CookieStore) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data = make(map[interface{}]interface{})
+	s.changed = true
+	return nil
+}
+
+// Encode returns the encrypted cookie value of the session, expiry is
+// extended by max life time.
+func (s *CookieStore) Encode() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expires = time.Now().Add(s.p.maxLifetime)
+	return s.encode()
+}
+
+// encode must be called with lock held.
+func (s *CookieStore) encode() (string, error) {
+	data, err := EncodeGob(s.data)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.NewBuffer(nil)
+	if err = gob.NewEncoder(buf).Encode(cookiePayload{s.sid, s.expires.Unix(), data}); err != nil {
+		return "", err
+	}
+	ciphertext, err := com.AESGCMEncrypt(s.p.keys[0], buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	value := base64.RawURLEncoding.EncodeToString(ciphertext)
+	if len(value) > s.p.maxSize {
+		return "", fmt.Errorf("%w: %d bytes, limit is %d bytes", ErrCookieTooLarge, len(value), s.p.maxSize)
+	}
+	return value, nil
+}
+
+// expiring returns true if the session should be written into cookie,
+// i.e. it's changed or half of its life time has passed.
+func (s *CookieStore) expiring() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.changed || time.Until(s.expires) < s.p.maxLifetime/2
+}
+
+// CookieAdapter represents a session provider which keeps session data in
+// cookie, encrypted and authenticated with AES-GCM. It's configured like
+// "keys=hex1,hex2&max_size=4000", the first key encrypts cookies and all
+// keys decrypt them, so keys can be rotated by prepending new ones. Keys
+// are 16, 24 or 32 bytes in hex for AES-128, AES-192 or AES-256, max_size
+// is the limit of cookie value in bytes, default is 4000.
+//
+// Sessions can not be revoked on server side, Destory only clears cookie.
+type CookieAdapter struct {
+	keys        [][]byte
+	maxLifetime time.Duration
+	maxSize     int
+}
+
+// Init initializes cookie session provider.
+func (p *CookieAdapter) Init(maxLifetime int64, config string) error {
+	vals, err := url.ParseQuery(config)
+	if err != nil {
+		return err
+	}
+
+	p.keys = p.keys[:0]
+	for _, k := range strings.Split(vals.Get("keys"), ",") {
+		if k = strings.TrimSpace(k); len(k) == 0 {
+			continue
+		}
+		key, err := hex.DecodeString(k)
+		if err != nil {
+			return fmt.Errorf("session: invalid cookie key: %v", err)
+		}
+		if n := len(key); n != 16 && n != 24 && n != 32 {
+			return fmt.Errorf("session: invalid cookie key size %d", n)
+		}
+		p.keys = append(p.keys, key)
+	}
+	if len(p.keys) == 0 {
+		return errors.New("session: no key of cookie provider")
+	}
+
+	p.maxSize = 4000
+	if v := vals.Get("max_size"); len(v) > 0 {
+		if p.maxSize, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("session: invalid max_size: %v", err)
+		}
+	}
+	p.maxLifetime = time.Duration(maxLifetime) * time.Second
+	return nil
+}
+
+// decode decrypts and validates cookie value.
+func (p *CookieAdapter) decode(value string) (*cookiePayload, bool) {
+	ciphertext, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	for _, key := range p.keys {
+		plaintext, err := com.AESGCMDecrypt(key, ciphertext)
+		if err != nil {
+			continue
+		}
+		var payload cookiePayload
+		if err = gob.NewDecoder(bytes.NewReader(plaintext)).Decode(&payload); err != nil {
+			return nil, false
+		}
+		if time.Now().Unix() > payload.Expires {
+			return nil, false
+		}
+		return &payload, true
+	}
+	return nil, false
+}
+
+// Read returns raw session store by cookie value, a new session with random ID
+// is created if the value is invalid, as the value is chosen by client.
+func (p *CookieAdapter) Read(value string) (RawStore, error) {
+	if payload, ok := p.decode(value); ok {
+		kv, err := DecodeGob(payload.Data)
+		if err != nil {
+			return nil, err
+		}
+		if kv == nil {
+			kv = make(map[interface{}]interface{})
+		}
+		return NewCookieStore(p, payload.ID, kv, time.Unix(payload.Expires, 0)), nil
+	}
+
+	sid := hex.EncodeToString(generateRandomKey(16))
+	s := NewCookieStore(p, sid, make(map[interface{}]interface{}), time.Now().Add(p.maxLifetime))
+	s.changed = true
+	return s, nil
+}
+
+// Exist returns true if cookie value is a valid session.
+func (p *CookieAdapter) Exist(value string) bool {
+	_, ok := p.decode(value)
+	return ok
+}
+
+// Destory does nothing, sessions in cookie can not be deleted on server side.
+func (_ *CookieAdapter) Destory(_ string) error {
+	return nil
+}
+
+// Regenerate returns a session store with data of old cookie value and new ID.
+func (p *CookieAdapter) Regenerate(oldvalue, sid string) (RawStore, error) {
+	kv := make(map[interface{}]interface{})
+	if payload, ok := p.decode(oldvalue); ok {
+		var err error
+		if kv, err = DecodeGob(payload.Data); err != nil {
+			return nil, err
+		}
+	}
+	s := NewCookieStore(p, sid, kv, time.Now().Add(p.maxLifetime))
+	s.changed = true
+	return s, nil
+}
+
+// Count returns 0, sessions in cookie can not be counted.
+func (_ *CookieAdapter) Count() int {
+	return 0
+}
+
+// GC does nothing, expired cookies are rejected on reading.
+func (_ *CookieAdapter) GC() {}
+
+// cookieSession tracks the cookie store of a request, which is written into
+// cookie before response is written.
+type cookieSession struct {
+	store     *CookieStore
+	destroyed bool
+}
+
+// pending returns true if the store should be written into cookie.
+func (c *cookieSession) pending() bool {
+	return !c.destroyed && c.store.expiring()
+}
+
+var cookieSessionType = reflect.TypeOf(&cookieSession{})
+
+// trackCookieStore writes sess into cookie before response if it's a cookie
+// store, it returns false for other stores.
+func (m *Manager) trackCookieStore(ctx *web.Context, sess RawStore) bool {
+	cs, ok := sess.(*CookieStore)
+	if !ok {
+		return false
+	}
+	if cur := m.cookieSession(ctx); cur != nil {
+		cur.store, cur.destroyed = cs, false
+		return true
+	}
+
+	cur := &cookieSession{store: cs}
+	ctx.Map(cur)
+	ctx.Resp.Before(func(web.ResponseWriter) {
+		if !cur.pending() {
+			return
+		}
+		// Size is checked on setting, failing here means data can not be encoded.
+		value, err := cur.store.Encode()
+		if err != nil {
+			panic("session(cookie): " + err.Error())
+		}
+		http.SetCookie(ctx.Resp, m.cookie(value))
+	})
+	return true
+}
+
+// cookieSession returns cookie session of the request, or nil.
+func (m *Manager) cookieSession(ctx *web.Context) *cookieSession {
+	if val := ctx.GetVal(cookieSessionType); val.IsValid() && !val.IsNil() {
+		return val.Interface().(*cookieSession)
+	}
+	return nil
+}
+
+func init() {
+	Register("cookie", &CookieAdapter{})
+}
@@ -0,0 +1,168 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+)
+
+const (
+	testCookieKey1 = "000102030405060708090a0b0c0d0e0f"
+	testCookieKey2 = "101112131415161718191a1b1c1d1e1f"
+)
+
+// cookieServer serves a web with cookie session and returns session cookie of each request.
+func cookieServer(config string) (*web.Web, func(path, cookie string) (string, string)) {
+	m := web.New()
+	m.Use(Sessioner(Options{Adapter: "cookie", AdapterConfig: config, CookieName: "sess"}))
+	m.Get("/set", func(ctx *web.Context, sess Store) string {
+		if err := sess.Set(ctx.Query("k"), ctx.Query("v")); err != nil {
+			return err.Error()
+		}
+		return sess.ID()
+	})
+	m.Get("/get", func(ctx *web.Context, sess Store) string {
+		v, _ := sess.Get(ctx.Query("k")).(string)
+		return sess.ID() + ":" + v
+	})
+	m.Get("/regenerate", func(ctx *web.Context, sess Store) string {
+		sess.Set("k", "kept")
+		raw, _ := sess.RegenerateId(ctx)
+		return raw.ID()
+	})
+	m.Get("/destroy", func(ctx *web.Context, sess Store) {
+		sess.Set("k", "v")
+		sess.Destory(ctx)
+	})
+	m.Get("/flash", func(f *Flash) {
+		f.Success("done")
+	})
+
+	return m, func(path, cookie string) (string, string) {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if len(cookie) > 0 {
+			req.AddCookie(&http.Cookie{Name: "sess", Value: cookie})
+		}
+		m.ServeHTTP(resp, req)
+		for _, c := range (&http.Response{Header: resp.Header()}).Cookies() {
+			if c.Name == "sess" {
+				return resp.Body.String(), c.Value
+			}
+		}
+		return resp.Body.String(), ""
+	}
+}
+
+func Test_CookieAdapter(t *testing.T) {
+	Convey("Cookie session store", t, func() {
+		_, do := cookieServer("keys=" + testCookieKey1)
+
+		id, cookie := do("/set?k=name&v=web", "")
+		So(len(id), ShouldEqual, 32)
+		So(cookie, ShouldNotBeEmpty)
+		So(cookie, ShouldNotContainSubstring, "web")
+
+		body, renewed := do("/get?k=name", cookie)
+		So(body, ShouldEqual, id+":web")
+		So(renewed, ShouldBeEmpty)
+
+		Convey("Tampered cookie starts a new session", func() {
+			tampered := []byte(cookie)
+			tampered[len(tampered)/2] ^= 1
+			body, _ := do("/get?k=name", string(tampered))
+			So(body, ShouldNotStartWith, id)
+			So(body, ShouldEndWith, ":")
+		})
+
+		Convey("Invalid cookie value is never used as session ID", func() {
+			p := &CookieAdapter{}
+			So(p.Init(60, "keys="+testCookieKey1), ShouldBeNil)
+			sess, err := p.Read("chosen-by-client")
+			So(err, ShouldBeNil)
+			So(sess.ID(), ShouldNotEqual, "chosen-by-client")
+			So(len(sess.ID()), ShouldEqual, 32)
+
+			body, _ := do("/get?k=name", "chosen-by-client")
+			So(body, ShouldNotStartWith, "chosen-by-client")
+		})
+
+		Convey("Keys can be rotated", func() {
+			_, rotated := cookieServer("keys=" + testCookieKey2 + "," + testCookieKey1)
+			body, _ := rotated("/get?k=name", cookie)
+			So(body, ShouldEqual, id+":web")
+
+			_, removed := cookieServer("keys=" + testCookieKey2)
+			body, _ = removed("/get?k=name", cookie)
+			So(body, ShouldNotStartWith, id)
+		})
+
+		Convey("Size limit", func() {
+			_, small := cookieServer("keys=" + testCookieKey1 + "&max_size=200")
+			body, _ := small("/set?k=name&v="+strings.Repeat("x", 300), "")
+			So(body, ShouldStartWith, ErrCookieTooLarge.Error())
+		})
+
+		Convey("Expired cookie", func() {
+			p := &CookieAdapter{}
+			So(p.Init(-1, "keys="+testCookieKey1), ShouldBeNil)
+			value, err := NewCookieStore(p, "id", map[interface{}]interface{}{}, time.Now()).Encode()
+			So(err, ShouldBeNil)
+			So(p.Exist(value), ShouldBeFalse)
+		})
+
+		Convey("Regenerate ID keeps data", func() {
+			newID, newCookie := do("/regenerate", cookie)
+			So(newID, ShouldNotEqual, id)
+			body, _ := do("/get?k=k", newCookie)
+			So(body, ShouldEqual, newID+":kept")
+		})
+
+		Convey("Destroy clears cookie", func() {
+			m, _ := cookieServer("keys=" + testCookieKey1)
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/destroy", nil)
+			req.AddCookie(&http.Cookie{Name: "sess", Value: cookie})
+			m.ServeHTTP(resp, req)
+			cookies := (&http.Response{Header: resp.Header()}).Cookies()
+			So(len(cookies), ShouldEqual, 1)
+			So(cookies[0].MaxAge, ShouldBeLessThan, 0)
+		})
+
+		Convey("Flash works", func() {
+			m, _ := cookieServer("keys=" + testCookieKey1)
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/flash", nil)
+			m.ServeHTTP(resp, req)
+			So(resp.Header().Get("Set-Cookie"), ShouldNotBeEmpty)
+			So(strings.Join(resp.Header()["Set-Cookie"], ";"), ShouldContainSubstring, "web_flash=success%3Ddone")
+		})
+	})
+
+	Convey("Invalid configuration", t, func() {
+		p := &CookieAdapter{}
+		So(p.Init(60, ""), ShouldNotBeNil)
+		So(p.Init(60, "keys=abcd"), ShouldNotBeNil)
+		So(p.Init(60, "keys=zz"), ShouldNotBeNil)
+		So(p.Init(60, "keys="+testCookieKey1+"&max_size=x"), ShouldNotBeNil)
+	})
+}
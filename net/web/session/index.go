@@ -0,0 +1,155 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"landzero.net/x/net/web"
+)
+
+// ErrIndexUnsupported is returned by user session operations if the adapter
+// does not implement Indexer.
+var ErrIndexUnsupported = errors.New("session: adapter does not index sessions by user")
+
+// SessionInfo describes a session of user.
+type SessionInfo struct {
+	ID     string
+	UserID string
+	// Device is the User-Agent of the latest request.
+	Device string
+	// IP is the remote address of the latest request.
+	IP       string
+	Created  time.Time
+	LastSeen time.Time
+}
+
+// Indexer is the interface that an adapter implements to index sessions by
+// user. Indexed sessions are also removed from index by Destory, Regenerate
+// and GC of the adapter.
+type Indexer interface {
+	// Index saves info of session under its user, keeping Created of an
+	// existing entry of the same user. The session is removed from index if
+	// UserID is empty.
+	Index(info SessionInfo) error
+	// Sessions returns active sessions of user.
+	Sessions(uid string) ([]SessionInfo, error)
+}
+
+// sortSessions sorts sessions by last seen time, most recent first.
+func sortSessions(infos []SessionInfo) {
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].LastSeen.After(infos[j].LastSeen)
+	})
+}
+
+// indexer returns indexer of provider, or nil.
+func (m *Manager) indexer() Indexer {
+	idx, _ := m.provider.(Indexer)
+	return idx
+}
+
+// index saves info of current session into index if user key is configured
+// and adapter supports it, then revokes least recently seen sessions which
+// exceed the limit of user.
+func (m *Manager) index(ctx *web.Context, sess RawStore) error {
+	idx := m.indexer()
+	if idx == nil || len(m.opt.UserKey) == 0 {
+		return nil
+	}
+
+	var uid string
+	if v := sess.Get(m.opt.UserKey); v != nil {
+		uid = fmt.Sprint(v)
+	}
+	now := time.Now()
+	if err := idx.Index(SessionInfo{
+		ID:       sess.ID(),
+		UserID:   uid,
+		Device:   ctx.Req.UserAgent(),
+		IP:       ctx.RemoteAddr(),
+		Created:  now,
+		LastSeen: now,
+	}); err != nil {
+		return err
+	}
+	if len(uid) == 0 || m.opt.MaxSessionsPerUser <= 0 {
+		return nil
+	}
+
+	infos, err := idx.Sessions(uid)
+	if err != nil {
+		return err
+	}
+	sortSessions(infos)
+	// Current session is always kept.
+	kept := 1
+	for _, info := range infos {
+		if info.ID == sess.ID() {
+			continue
+		}
+		if kept < m.opt.MaxSessionsPerUser {
+			kept++
+			continue
+		}
+		if err = m.provider.Destory(info.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserSessions returns active sessions of user, most recently seen first.
+func (m *Manager) UserSessions(uid string) ([]SessionInfo, error) {
+	idx := m.indexer()
+	if idx == nil {
+		return nil, ErrIndexUnsupported
+	}
+	infos, err := idx.Sessions(uid)
+	if err != nil {
+		return nil, err
+	}
+	sortSessions(infos)
+	return infos, nil
+}
+
+// Revoke deletes a session by session ID, e.g. one listed by UserSessions.
+func (m *Manager) Revoke(sid string) error {
+	return m.provider.Destory(sid)
+}
+
+// RevokeUser deletes all sessions of user except given session IDs, e.g. to
+// log out everywhere but the current session.
+func (m *Manager) RevokeUser(uid string, except ...string) error {
+	infos, err := m.UserSessions(uid)
+	if err != nil {
+		return err
+	}
+SESSIONS:
+	for _, info := range infos {
+		for _, sid := range except {
+			if info.ID == sid {
+				continue SESSIONS
+			}
+		}
+		if err = m.provider.Destory(info.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
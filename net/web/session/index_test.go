@@ -0,0 +1,106 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+)
+
+func Test_UserIndex(t *testing.T) {
+	Convey("Index sessions by user", t, func() {
+		m := web.New()
+		m.Use(Sessioner(Options{
+			CookieName:         "indexed",
+			CookieLifeTime:     60,
+			SlidingExpiration:  true,
+			SameSite:           http.SameSiteStrictMode,
+			UserKey:            "uid",
+			MaxSessionsPerUser: 2,
+		}))
+		m.Get("/login", func(ctx *web.Context, sess Store) string {
+			sess.Set("uid", ctx.Query("uid"))
+			return sess.ID()
+		})
+		m.Get("/logout", func(sess Store) {
+			sess.Delete("uid")
+		})
+		m.Get("/sessions", func(ctx *web.Context, sess Store) string {
+			infos, err := sess.UserSessions(ctx.Query("uid"))
+			if err != nil {
+				return err.Error()
+			}
+			ids := make([]string, 0, len(infos))
+			for _, info := range infos {
+				ids = append(ids, info.ID+"@"+info.Device)
+			}
+			return strings.Join(ids, ",")
+		})
+		m.Get("/revoke", func(ctx *web.Context, sess Store) string {
+			if err := sess.RevokeUser(ctx.Query("uid"), sess.ID()); err != nil {
+				return err.Error()
+			}
+			return "ok"
+		})
+
+		do := func(path, sid, device string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			req.Header.Set("User-Agent", device)
+			if len(sid) > 0 {
+				req.AddCookie(&http.Cookie{Name: "indexed", Value: sid})
+			}
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := do("/login?uid=alice", "", "phone")
+		So(resp.Header().Get("Set-Cookie"), ShouldContainSubstring, "SameSite=Strict")
+		phone := resp.Body.String()
+		laptop := do("/login?uid=alice", "", "laptop").Body.String()
+		So(do("/sessions?uid=alice", "", "").Body.String(), ShouldEqual, laptop+"@laptop,"+phone+"@phone")
+
+		Convey("Refresh cookie on each request", func() {
+			resp := do("/sessions?uid=alice", phone, "phone")
+			So(resp.Header().Get("Set-Cookie"), ShouldContainSubstring, "indexed="+phone)
+			So(resp.Header().Get("Set-Cookie"), ShouldContainSubstring, "Max-Age=60")
+		})
+
+		Convey("Revoke least recently seen sessions over limit", func() {
+			do("/sessions", phone, "phone")
+			tablet := do("/login?uid=alice", "", "tablet").Body.String()
+			So(do("/sessions?uid=alice", "", "").Body.String(), ShouldEqual, tablet+"@tablet,"+phone+"@phone")
+		})
+
+		Convey("Revoke all other sessions", func() {
+			So(do("/revoke?uid=alice", phone, "phone").Body.String(), ShouldEqual, "ok")
+			So(do("/sessions?uid=alice", "", "").Body.String(), ShouldEqual, phone+"@phone")
+			// Revoked session starts as a new one.
+			So(do("/login?uid=alice", laptop, "laptop").Body.String(), ShouldNotEqual, laptop)
+		})
+
+		Convey("Remove session from index when user key is deleted", func() {
+			do("/logout", laptop, "laptop")
+			So(do("/sessions?uid=alice", "", "").Body.String(), ShouldEqual, phone+"@phone")
+		})
+
+		do("/revoke?uid=alice", "", "")
+	})
+}
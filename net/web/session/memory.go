@@ -91,6 +91,10 @@ type MemAdapter struct {
 	data        map[string]*list.Element
 	// A priority list whose lastAccess newer gets higer priority.
 	list *list.List
+	// Sessions of users, indexed by user ID and session ID.
+	users map[string]map[string]*SessionInfo
+	// User ID of indexed sessions.
+	owners map[string]string
 }
 
 // Init initializes memory session provider.
@@ -157,6 +161,7 @@ func (p *MemAdapter) Destory(sid string) error {
 
 	p.list.Remove(e)
 	delete(p.data, sid)
+	p.unindex(sid)
 	return nil
 }
 
@@ -171,14 +176,23 @@ func (p *MemAdapter) Regenerate(oldsid, sid string) (RawStore, error) {
 		return nil, err
 	}
 
+	// Index is moved to new ID, as Destory removes it.
+	p.lock.RLock()
+	info, indexed := p.users[p.owners[oldsid]][oldsid]
+	p.lock.RUnlock()
+
 	if err = p.Destory(oldsid); err != nil {
 		return nil, err
 	}
 
-	s.(*MemStore).sid = sid
-
 	p.lock.Lock()
 	defer p.lock.Unlock()
+
+	if indexed {
+		info.ID = sid
+		p.index(info)
+	}
+	s.(*MemStore).sid = sid
 	p.data[sid] = p.list.PushBack(s)
 	return s, nil
 }
@@ -203,6 +217,7 @@ func (p *MemAdapter) GC() {
 			p.lock.Lock()
 			p.list.Remove(e)
 			delete(p.data, e.Value.(*MemStore).sid)
+			p.unindex(e.Value.(*MemStore).sid)
 			p.lock.Unlock()
 			p.lock.RLock()
 		} else {
@@ -212,6 +227,61 @@ func (p *MemAdapter) GC() {
 	p.lock.RUnlock()
 }
 
+// index adds info into index, caller must hold the write lock.
+func (p *MemAdapter) index(info *SessionInfo) {
+	if p.users == nil {
+		p.users = make(map[string]map[string]*SessionInfo)
+		p.owners = make(map[string]string)
+	}
+	if p.users[info.UserID] == nil {
+		p.users[info.UserID] = make(map[string]*SessionInfo)
+	}
+	p.users[info.UserID][info.ID] = info
+	p.owners[info.ID] = info.UserID
+}
+
+// unindex removes session from index, caller must hold the write lock.
+func (p *MemAdapter) unindex(sid string) {
+	uid, ok := p.owners[sid]
+	if !ok {
+		return
+	}
+	delete(p.owners, sid)
+	if delete(p.users[uid], sid); len(p.users[uid]) == 0 {
+		delete(p.users, uid)
+	}
+}
+
+// Index saves info of session under its user.
+func (p *MemAdapter) Index(info SessionInfo) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.data[info.ID]; !ok {
+		return nil
+	}
+	if old, ok := p.users[p.owners[info.ID]][info.ID]; ok && old.UserID == info.UserID {
+		info.Created = old.Created
+	}
+	p.unindex(info.ID)
+	if len(info.UserID) > 0 {
+		p.index(&info)
+	}
+	return nil
+}
+
+// Sessions returns active sessions of user.
+func (p *MemAdapter) Sessions(uid string) ([]SessionInfo, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	infos := make([]SessionInfo, 0, len(p.users[uid]))
+	for _, info := range p.users[uid] {
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
 func init() {
 	Register("memory", &MemAdapter{list: list.New(), data: make(map[string]*list.Element)})
 }
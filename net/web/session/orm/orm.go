@@ -0,0 +1,286 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"landzero.net/x/database/orm"
+	"landzero.net/x/net/web/session"
+)
+
+// Session is a row of the sessions table. UserID is filled with value of the
+// user key in session, so sessions of a user can be queried.
+type Session struct {
+	ID      string `orm:"primary_key;size:128"`
+	UserID  string `orm:"size:128;index"`
+	Data    []byte
+	Expiry  int64 `orm:"index"`
+	Updated int64
+}
+
+// OrmStore represents an ORM session store implementation.
+type OrmStore struct {
+	p    *OrmAdapter
+	sid  string
+	lock sync.RWMutex
+	data map[interface{}]interface{}
+}
+
+// NewOrmStore creates and returns an ORM session store.
+func NewOrmStore(p *OrmAdapter, sid string, kv map[interface{}]interface{}) *OrmStore {
+	return &OrmStore{
+		p:    p,
+		sid:  sid,
+		data: kv,
+	}
+}
+
+// Set sets value to given key in session.
+func (s *OrmStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data[key] = val
+	return nil
+}
+
+// Get gets value by given key in session.
+func (s *OrmStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.data[key]
+}
+
+// Delete delete a key from session.
+func (s *OrmStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.data, key)
+	return nil
+}
+
+// ID returns current session ID.
+func (s *OrmStore) ID() string {
+	return s.sid
+}
+
+// Release releases resource and save data to provider.
+func (s *OrmStore) Release() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	data, err := session.EncodeGob(s.data)
+	if err != nil {
+		return err
+	}
+	var uid string
+	if v, ok := s.data[s.p.userKey]; ok && v != nil {
+		uid = fmt.Sprint(v)
+	}
+
+	now := time.Now().Unix()
+	return s.p.table().Where("id = ?", s.sid).UpdateColumns(map[string]interface{}{
+		"user_id": uid,
+		"data":    data,
+		"expiry":  now + s.p.maxlifetime,
+		"updated": now,
+	}).Error
+}
+
+// Flush deletes all session data.
+func (s *OrmStore) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data = make(map[interface{}]interface{})
+	return nil
+}
+
+// OrmAdapter represents a session provider backed by database/orm. As the
+// database can not be passed by configuration, register it before use:
+//
+//	session.Register("orm", ormsession.New(db))
+//	m.Use(session.Sessioner(session.Options{Adapter: "orm", AdapterConfig: "table=sessions&user_key=uid"}))
+//
+// The table, default is "sessions", is migrated on initialization. Value of
+// the session key user_key, if configured, is saved in the user_id column.
+type OrmAdapter struct {
+	db          *orm.DB
+	name        string
+	userKey     interface{}
+	maxlifetime int64
+}
+
+// New creates and returns an ORM session provider of db.
+func New(db *orm.DB) *OrmAdapter {
+	return &OrmAdapter{db: db, name: "sessions"}
+}
+
+// Init initializes ORM session provider and migrates the table.
+// configs: table=sessions&user_key=uid
+func (p *OrmAdapter) Init(maxlifetime int64, configs string) error {
+	if p.db == nil {
+		return errors.New("session: ORM provider is not created by New")
+	}
+	vals, err := url.ParseQuery(configs)
+	if err != nil {
+		return err
+	}
+	if name := vals.Get("table"); len(name) > 0 {
+		p.name = name
+	}
+	if key := vals.Get("user_key"); len(key) > 0 {
+		p.userKey = key
+	}
+	p.maxlifetime = maxlifetime
+	return p.table().AutoMigrate(&Session{}).Error
+}
+
+func (p *OrmAdapter) table() *orm.DB {
+	return p.db.Table(p.name)
+}
+
+// read returns the row of session, nil if it does not exist or has expired.
+func (p *OrmAdapter) read(db *orm.DB, sid string) (*Session, error) {
+	var row Session
+	err := db.Table(p.name).Where("id = ? AND expiry >= ?", sid, time.Now().Unix()).First(&row).Error
+	if err == orm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &row, err
+}
+
+// store returns session store of row.
+func (p *OrmAdapter) store(row *Session) (session.RawStore, error) {
+	kv := make(map[interface{}]interface{})
+	if len(row.Data) > 0 {
+		var err error
+		if kv, err = session.DecodeGob(row.Data); err != nil {
+			return nil, err
+		}
+	}
+	return NewOrmStore(p, row.ID, kv), nil
+}
+
+// Read returns raw session store by session ID.
+func (p *OrmAdapter) Read(sid string) (session.RawStore, error) {
+	row, err := p.read(p.db, sid)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		now := time.Now().Unix()
+		row = &Session{ID: sid, Expiry: now + p.maxlifetime, Updated: now}
+		// Expired row of the same ID is replaced.
+		if err = p.Destory(sid); err != nil {
+			return nil, err
+		}
+		if err = p.table().Create(row).Error; err != nil {
+			return nil, err
+		}
+	}
+	return p.store(row)
+}
+
+// Exist returns true if session with given ID exists.
+func (p *OrmAdapter) Exist(sid string) bool {
+	row, err := p.read(p.db, sid)
+	return err == nil && row != nil
+}
+
+// Destory deletes a session by session ID.
+func (p *OrmAdapter) Destory(sid string) error {
+	return p.table().Where("id = ?", sid).Delete(&Session{}).Error
+}
+
+// Regenerate regenerates a session store from old session ID to new one in a transaction.
+func (p *OrmAdapter) Regenerate(oldsid, sid string) (_ session.RawStore, err error) {
+	tx := p.db.Begin()
+	if err = tx.Error; err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var count int
+	if err = tx.Table(p.name).Where("id = ?", sid).Count(&count).Error; err != nil {
+		return nil, err
+	} else if count > 0 {
+		return nil, fmt.Errorf("new sid '%s' already exists", sid)
+	}
+
+	row, err := p.read(tx, oldsid)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if row == nil {
+		row = &Session{ID: sid, Expiry: now + p.maxlifetime, Updated: now}
+		err = tx.Table(p.name).Create(row).Error
+	} else {
+		row.ID = sid
+		err = tx.Table(p.name).Where("id = ?", oldsid).UpdateColumns(map[string]interface{}{
+			"id":      sid,
+			"updated": now,
+		}).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return p.store(row)
+}
+
+// Count counts and returns number of active sessions.
+func (p *OrmAdapter) Count() int {
+	var count int
+	p.table().Where("expiry >= ?", time.Now().Unix()).Count(&count)
+	return count
+}
+
+// GC deletes expired sessions.
+func (p *OrmAdapter) GC() {
+	p.table().Where("expiry < ?", time.Now().Unix()).Delete(&Session{})
+}
+
+// UserSessions returns active sessions of user, most recently updated first.
+func (p *OrmAdapter) UserSessions(uid string) ([]Session, error) {
+	var rows []Session
+	err := p.table().Where("user_id = ? AND expiry >= ?", uid, time.Now().Unix()).
+		Order("updated DESC").Find(&rows).Error
+	return rows, err
+}
+
+// DestoryUser deletes all sessions of user except given session IDs, e.g.
+// to log out everywhere but the current session.
+func (p *OrmAdapter) DestoryUser(uid string, except ...string) error {
+	db := p.table().Where("user_id = ?", uid)
+	if len(except) > 0 {
+		db = db.Where("id NOT IN (?)", except)
+	}
+	return db.Delete(&Session{}).Error
+}
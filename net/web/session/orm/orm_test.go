@@ -0,0 +1,108 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/database/orm"
+	_ "landzero.net/x/database/orm/dialects/sqlite3"
+)
+
+func Test_OrmAdapter(t *testing.T) {
+	Convey("ORM session adapter", t, func() {
+		dir, err := ioutil.TempDir("", "session")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		db, err := orm.Open("sqlite3", filepath.Join(dir, "session.db"))
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		p := New(db)
+		So(p.Init(3600, "table=web_sessions&user_key=uid"), ShouldBeNil)
+		So(db.HasTable("web_sessions"), ShouldBeTrue)
+
+		Convey("Read, release and read again", func() {
+			raw, err := p.Read("s1")
+			So(err, ShouldBeNil)
+			So(p.Exist("s1"), ShouldBeTrue)
+			So(raw.Set("uid", 7), ShouldBeNil)
+			So(raw.Set("name", "web"), ShouldBeNil)
+			So(raw.Release(), ShouldBeNil)
+
+			raw, err = p.Read("s1")
+			So(err, ShouldBeNil)
+			So(raw.Get("name"), ShouldEqual, "web")
+			So(p.Count(), ShouldEqual, 1)
+
+			rows, err := p.UserSessions("7")
+			So(err, ShouldBeNil)
+			So(len(rows), ShouldEqual, 1)
+			So(rows[0].ID, ShouldEqual, "s1")
+		})
+
+		Convey("Regenerate in transaction", func() {
+			raw, _ := p.Read("old")
+			raw.Set("name", "kept")
+			So(raw.Release(), ShouldBeNil)
+
+			raw, err := p.Regenerate("old", "new")
+			So(err, ShouldBeNil)
+			So(raw.ID(), ShouldEqual, "new")
+			So(raw.Get("name"), ShouldEqual, "kept")
+			So(p.Exist("old"), ShouldBeFalse)
+			So(p.Exist("new"), ShouldBeTrue)
+
+			_, err = p.Regenerate("none", "new")
+			So(err, ShouldNotBeNil)
+			So(p.Exist("none"), ShouldBeFalse)
+		})
+
+		Convey("Destory user sessions except current", func() {
+			for _, sid := range []string{"a", "b", "c"} {
+				raw, _ := p.Read(sid)
+				raw.Set("uid", "u1")
+				So(raw.Release(), ShouldBeNil)
+			}
+			So(p.DestoryUser("u1", "b"), ShouldBeNil)
+			So(p.Exist("a"), ShouldBeFalse)
+			So(p.Exist("b"), ShouldBeTrue)
+			So(p.Exist("c"), ShouldBeFalse)
+
+			So(p.Destory("b"), ShouldBeNil)
+			So(p.Count(), ShouldEqual, 0)
+		})
+
+		Convey("GC expired sessions", func() {
+			p.Read("live")
+			p.Read("dead")
+			db.Table("web_sessions").Where("id = ?", "dead").
+				UpdateColumn("expiry", time.Now().Unix()-1)
+			So(p.Exist("dead"), ShouldBeFalse)
+
+			p.GC()
+			var count int
+			db.Table("web_sessions").Count(&count)
+			So(count, ShouldEqual, 1)
+			So(p.Exist("live"), ShouldBeTrue)
+		})
+	})
+}
@@ -16,6 +16,7 @@
 package session
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -152,6 +153,9 @@ func (p *RedisAdapter) Exist(sid string) bool {
 
 // Destory deletes a session by session ID.
 func (p *RedisAdapter) Destory(sid string) error {
+	if err := p.unindex(sid); err != nil {
+		return err
+	}
 	return p.c.Del(p.prefix + sid).Err()
 }
 
@@ -172,6 +176,9 @@ func (p *RedisAdapter) Regenerate(oldsid, sid string) (_ session.RawStore, err e
 	if err = p.c.Rename(poldsid, psid).Err(); err != nil {
 		return nil, err
 	}
+	if err = p.reindex(oldsid, sid); err != nil {
+		return nil, err
+	}
 
 	var kv map[interface{}]interface{}
 	kvs, err := p.c.Get(psid).Result()
@@ -199,6 +206,166 @@ func (p *RedisAdapter) Count() int {
 // GC calls GC to clean expired sessions.
 func (_ *RedisAdapter) GC() {}
 
+// userKey returns key of hash which maps session ID to info of user.
+func (p *RedisAdapter) userKey(uid string) string {
+	return p.prefix + "user:" + uid
+}
+
+// ownerKey returns key of user ID of indexed session.
+func (p *RedisAdapter) ownerKey(sid string) string {
+	return p.prefix + "owner:" + sid
+}
+
+// owner returns user ID of indexed session, or empty.
+func (p *RedisAdapter) owner(sid string) (string, error) {
+	uid, err := p.c.Get(p.ownerKey(sid)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return uid, err
+}
+
+// info returns indexed info of session of user, or nil.
+func (p *RedisAdapter) info(uid, sid string) (*session.SessionInfo, error) {
+	data, err := p.c.HGet(p.userKey(uid), sid).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	info := new(session.SessionInfo)
+	return info, json.Unmarshal([]byte(data), info)
+}
+
+// indexEntry is info of session saved in index, Seen is LastSeen in Unix
+// seconds for indexScript to compare.
+type indexEntry struct {
+	session.SessionInfo
+	Seen int64 `json:"seen"`
+}
+
+// index saves info of session, the index expires along with the latest session.
+func (p *RedisAdapter) index(info *session.SessionInfo) error {
+	data, err := json.Marshal(indexEntry{*info, info.LastSeen.Unix()})
+	if err != nil {
+		return err
+	}
+	key := p.userKey(info.UserID)
+	if err = p.c.HSet(key, info.ID, string(data)).Err(); err != nil {
+		return err
+	}
+	if err = p.c.Expire(key, p.duration).Err(); err != nil {
+		return err
+	}
+	return p.c.Set(p.ownerKey(info.ID), info.UserID, p.duration).Err()
+}
+
+// unindex removes session from index.
+func (p *RedisAdapter) unindex(sid string) error {
+	uid, err := p.owner(sid)
+	if err != nil || len(uid) == 0 {
+		return err
+	}
+	if err = p.c.HDel(p.userKey(uid), sid).Err(); err != nil {
+		return err
+	}
+	return p.c.Del(p.ownerKey(sid)).Err()
+}
+
+// reindex moves index of session from old session ID to new one.
+func (p *RedisAdapter) reindex(oldsid, sid string) error {
+	uid, err := p.owner(oldsid)
+	if err != nil || len(uid) == 0 {
+		return err
+	}
+	info, err := p.info(uid, oldsid)
+	if err != nil {
+		return err
+	}
+	if err = p.unindex(oldsid); err != nil || info == nil {
+		return err
+	}
+	info.ID = sid
+	return p.index(info)
+}
+
+// indexInterval is how often LastSeen of an otherwise unchanged index entry is saved.
+const indexInterval = time.Minute
+
+// indexScript saves info of session under its user atomically, it moves the
+// session from index of its previous user and keeps Created of an existing
+// entry. Entries whose user, device and IP are unchanged are only rewritten
+// once LastSeen moves by indexInterval, otherwise their expiry is extended.
+//
+// KEYS[1]: session, KEYS[2]: owner of session
+// ARGV: session ID, entry in JSON, prefix of keys of users, expire time and interval in seconds
+var indexScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+local sid = ARGV[1]
+local info = cjson.decode(ARGV[2])
+local expire = tonumber(ARGV[4])
+local owner = redis.call("GET", KEYS[2])
+if owner and owner ~= info.UserID then
+	redis.call("HDEL", ARGV[3] .. owner, sid)
+	redis.call("DEL", KEYS[2])
+	owner = false
+end
+if info.UserID == "" then
+	return 0
+end
+local key = ARGV[3] .. info.UserID
+local old = owner and redis.call("HGET", key, sid)
+if old then
+	old = cjson.decode(old)
+	info.Created = old.Created
+	if old.Device == info.Device and old.IP == info.IP and info.seen - (old.seen or 0) < tonumber(ARGV[5]) then
+		redis.call("EXPIRE", key, expire)
+		redis.call("EXPIRE", KEYS[2], expire)
+		return 0
+	end
+end
+redis.call("HSET", key, sid, cjson.encode(info))
+redis.call("EXPIRE", key, expire)
+redis.call("SET", KEYS[2], info.UserID, "EX", expire)
+return 1
+`)
+
+// Index saves info of session under its user in a round trip.
+func (p *RedisAdapter) Index(info session.SessionInfo) error {
+	data, err := json.Marshal(indexEntry{info, info.LastSeen.Unix()})
+	if err != nil {
+		return err
+	}
+	return indexScript.Run(p.c, []string{p.prefix + info.ID, p.ownerKey(info.ID)},
+		info.ID, string(data), p.userKey(""), int64(p.duration/time.Second), int64(indexInterval/time.Second)).Err()
+}
+
+// Sessions returns active sessions of user, expired sessions are removed
+// from index.
+func (p *RedisAdapter) Sessions(uid string) ([]session.SessionInfo, error) {
+	all, err := p.c.HGetAll(p.userKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]session.SessionInfo, 0, len(all))
+	for sid, data := range all {
+		if !p.Exist(sid) {
+			if err = p.c.HDel(p.userKey(uid), sid).Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var info session.SessionInfo
+		if err = json.Unmarshal([]byte(data), &info); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
 func init() {
 	session.Register("redis", &RedisAdapter{})
 }
@@ -0,0 +1,104 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"landzero.net/x/net/web/session"
+)
+
+func Test_RedisIndex(t *testing.T) {
+	Convey("Index sessions by user with redis", t, func() {
+		p := &RedisAdapter{}
+		So(p.Init(60, "redis://localhost:6379/1"), ShouldBeNil)
+
+		run := time.Now().UnixNano()
+		uid := fmt.Sprintf("alice%d", run)
+		create := func(name string) string {
+			sid := fmt.Sprintf("%s%d", name, run)
+			sess, err := p.Read(sid)
+			So(err, ShouldBeNil)
+			So(sess.Release(), ShouldBeNil)
+			return sid
+		}
+		phone, laptop := create("phone"), create("laptop")
+		defer p.Destory(phone)
+		defer p.Destory(laptop)
+
+		created := time.Now().Add(-time.Hour).Round(time.Second)
+		So(p.Index(session.SessionInfo{ID: phone, UserID: uid, Device: "phone", Created: created, LastSeen: created}), ShouldBeNil)
+		So(p.Index(session.SessionInfo{ID: laptop, UserID: uid, Device: "laptop", Created: created, LastSeen: created}), ShouldBeNil)
+		// Sessions which do not exist are not indexed.
+		So(p.Index(session.SessionInfo{ID: "404", UserID: uid}), ShouldBeNil)
+
+		infos, err := p.Sessions(uid)
+		So(err, ShouldBeNil)
+		So(infos, ShouldHaveLength, 2)
+
+		info := func(sid string) *session.SessionInfo {
+			info, err := p.info(uid, sid)
+			So(err, ShouldBeNil)
+			return info
+		}
+
+		Convey("Keep created time and skip unchanged entries", func() {
+			So(p.Index(session.SessionInfo{ID: phone, UserID: uid, Device: "phone", Created: time.Now(), LastSeen: created.Add(time.Second)}), ShouldBeNil)
+			So(info(phone).LastSeen.Equal(created), ShouldBeTrue)
+
+			now := time.Now().Round(time.Second)
+			So(p.Index(session.SessionInfo{ID: phone, UserID: uid, Device: "phone", Created: now, LastSeen: now}), ShouldBeNil)
+			So(info(phone).LastSeen.Equal(now), ShouldBeTrue)
+			So(info(phone).Created.Equal(created), ShouldBeTrue)
+
+			So(p.Index(session.SessionInfo{ID: phone, UserID: uid, Device: "tablet", Created: now, LastSeen: now}), ShouldBeNil)
+			So(info(phone).Device, ShouldEqual, "tablet")
+			So(info(phone).Created.Equal(created), ShouldBeTrue)
+		})
+
+		Convey("Move sessions between users", func() {
+			bob := fmt.Sprintf("bob%d", run)
+			So(p.Index(session.SessionInfo{ID: phone, UserID: bob, Device: "phone", Created: time.Now(), LastSeen: time.Now()}), ShouldBeNil)
+			infos, err := p.Sessions(uid)
+			So(err, ShouldBeNil)
+			So(infos, ShouldHaveLength, 1)
+			So(infos[0].ID, ShouldEqual, laptop)
+			infos, err = p.Sessions(bob)
+			So(err, ShouldBeNil)
+			So(infos, ShouldHaveLength, 1)
+			So(infos[0].Created.Equal(created), ShouldBeFalse)
+
+			So(p.Index(session.SessionInfo{ID: phone}), ShouldBeNil)
+			infos, err = p.Sessions(bob)
+			So(err, ShouldBeNil)
+			So(infos, ShouldBeEmpty)
+			owner, err := p.owner(phone)
+			So(err, ShouldBeNil)
+			So(owner, ShouldBeEmpty)
+		})
+
+		Convey("Remove destroyed sessions from index", func() {
+			So(p.Destory(laptop), ShouldBeNil)
+			infos, err := p.Sessions(uid)
+			So(err, ShouldBeNil)
+			So(infos, ShouldHaveLength, 1)
+			So(infos[0].ID, ShouldEqual, phone)
+		})
+	})
+}
@@ -61,6 +61,12 @@ type Store interface {
 	Count() int
 	// GC calls GC to clean expired sessions.
 	GC()
+	// UserSessions returns active sessions of user, most recently seen first.
+	UserSessions(uid string) ([]SessionInfo, error)
+	// Revoke deletes a session by session ID.
+	Revoke(sid string) error
+	// RevokeUser deletes all sessions of user except given session IDs.
+	RevokeUser(uid string, except ...string) error
 }
 
 type store struct {
@@ -92,6 +98,17 @@ type Options struct {
 	Domain string
 	// Session ID length. Default is 16.
 	IDLength int
+	// SameSite attribute of session cookie. Default is http.SameSiteLaxMode.
+	SameSite http.SameSite
+	// Refresh session cookie on each request, so CookieLifeTime is counted
+	// from the latest request instead of the first one. Default is false.
+	SlidingExpiration bool
+	// Session key of user ID, sessions are indexed by its value if the
+	// adapter implements Indexer. Default is empty, i.e. no index.
+	UserKey string
+	// Max number of sessions of a user, least recently seen sessions are
+	// revoked when exceeded. It requires UserKey. Default is 0, no limit.
+	MaxSessionsPerUser int
 }
 
 func prepareOptions(options []Options) Options {
@@ -117,6 +134,9 @@ func prepareOptions(options []Options) Options {
 	if opt.IDLength == 0 {
 		opt.IDLength = 32
 	}
+	if opt.SameSite == 0 {
+		opt.SameSite = http.SameSiteLaxMode
+	}
 	return opt
 }
 
@@ -130,7 +150,7 @@ func Sessioner(options ...Options) web.Handler {
 	}
 	go manager.startGC()
 
-	return func(ctx *web.Context) {
+	return web.Mapping(func(ctx *web.Context) {
 		sess, err := manager.Start(ctx)
 		if err != nil {
 			panic("session(start): " + err.Error())
@@ -165,10 +185,20 @@ func Sessioner(options ...Options) web.Handler {
 
 		ctx.Next()
 
+		// Cookies are set before response is written, write it if handlers did not.
+		if !ctx.Resp.Written() {
+			if cur := manager.cookieSession(ctx); len(f.Values) > 0 || (cur != nil && cur.pending()) {
+				ctx.Resp.WriteHeader(http.StatusOK)
+			}
+		}
+
 		if err = sess.Release(); err != nil {
 			panic("session(release): " + err.Error())
 		}
-	}
+		if err = manager.index(ctx, sess); err != nil {
+			panic("session(index): " + err.Error())
+		}
+	}, &Flash{}, (*Store)(nil))
 }
 
 // Adapter is the interface that provides session manipulations.
@@ -235,7 +265,14 @@ func (m *Manager) sessionId() string {
 func (m *Manager) Start(ctx *web.Context) (RawStore, error) {
 	sid := ctx.GetCookie(m.opt.CookieName)
 	if len(sid) > 0 && m.provider.Exist(sid) {
-		return m.provider.Read(sid)
+		sess, err := m.provider.Read(sid)
+		if err != nil {
+			return nil, err
+		}
+		if !m.trackCookieStore(ctx, sess) && m.opt.SlidingExpiration && m.opt.CookieLifeTime > 0 {
+			http.SetCookie(ctx.Resp, m.cookie(sid))
+		}
+		return sess, nil
 	}
 
 	sid = m.sessionId()
@@ -243,21 +280,31 @@ func (m *Manager) Start(ctx *web.Context) (RawStore, error) {
 	if err != nil {
 		return nil, err
 	}
+	if m.trackCookieStore(ctx, sess) {
+		return sess, nil
+	}
+
+	cookie := m.cookie(sid)
+	http.SetCookie(ctx.Resp, cookie)
+	ctx.Req.AddCookie(cookie)
+	return sess, nil
+}
 
+// cookie returns the session cookie with given value.
+func (m *Manager) cookie(value string) *http.Cookie {
 	cookie := &http.Cookie{
 		Name:     m.opt.CookieName,
-		Value:    sid,
+		Value:    value,
 		Path:     m.opt.CookiePath,
 		HttpOnly: true,
 		Secure:   m.opt.Secure,
 		Domain:   m.opt.Domain,
+		SameSite: m.opt.SameSite,
 	}
 	if m.opt.CookieLifeTime >= 0 {
 		cookie.MaxAge = m.opt.CookieLifeTime
 	}
-	http.SetCookie(ctx.Resp, cookie)
-	ctx.Req.AddCookie(cookie)
-	return sess, nil
+	return cookie
 }
 
 // Read returns raw session store by session ID.
@@ -275,12 +322,16 @@ func (m *Manager) Destory(ctx *web.Context) error {
 	if err := m.provider.Destory(sid); err != nil {
 		return err
 	}
+	if cur := m.cookieSession(ctx); cur != nil {
+		cur.destroyed = true
+	}
 	cookie := &http.Cookie{
 		Name:     m.opt.CookieName,
 		Path:     m.opt.CookiePath,
 		HttpOnly: true,
 		Expires:  time.Now(),
 		MaxAge:   -1,
+		SameSite: m.opt.SameSite,
 	}
 	http.SetCookie(ctx.Resp, cookie)
 	return nil
@@ -289,22 +340,24 @@ func (m *Manager) Destory(ctx *web.Context) error {
 // RegenerateId regenerates a session store from old session ID to new one.
 func (m *Manager) RegenerateId(ctx *web.Context) (sess RawStore, err error) {
 	sid := m.sessionId()
+	// Cookie store of current request is renamed, so unsaved data is kept.
+	if cur := m.cookieSession(ctx); cur != nil && !cur.destroyed {
+		cur.store.lock.Lock()
+		cur.store.sid, cur.store.changed = sid, true
+		cur.store.lock.Unlock()
+		return cur.store, nil
+	}
+
 	oldsid := ctx.GetCookie(m.opt.CookieName)
 	sess, err = m.provider.Regenerate(oldsid, sid)
 	if err != nil {
 		return nil, err
 	}
-	ck := &http.Cookie{
-		Name:     m.opt.CookieName,
-		Value:    sid,
-		Path:     m.opt.CookiePath,
-		HttpOnly: true,
-		Secure:   m.opt.Secure,
-		Domain:   m.opt.Domain,
-	}
-	if m.opt.CookieLifeTime >= 0 {
-		ck.MaxAge = m.opt.CookieLifeTime
+	if m.trackCookieStore(ctx, sess) {
+		return sess, nil
 	}
+
+	ck := m.cookie(sid)
 	http.SetCookie(ctx.Resp, ck)
 	ctx.Req.AddCookie(ck)
 	return sess, nil
@@ -0,0 +1,230 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	_CONTENT_EVENT_STREAM = "text/event-stream"
+	_LAST_EVENT_ID        = "Last-Event-ID"
+)
+
+// ErrEventStreamClosed is returned when sending to a closed event stream.
+var ErrEventStreamClosed = errors.New("event stream closed")
+
+// Event represents a Server-Sent Event.
+type Event struct {
+	// ID sets the event ID, client sends it back as Last-Event-ID when reconnecting.
+	ID string
+	// Event is the event type, client dispatches it to listeners of this type.
+	Event string
+	// Data is the event payload. string and []byte are sent as is, others are encoded as JSON.
+	Data interface{}
+	// Retry tells client the reconnection time.
+	Retry time.Duration
+}
+
+// Encode encodes event into the text/event-stream format.
+func (e Event) Encode() ([]byte, error) {
+	var data string
+	switch v := e.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		p, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		data = string(p)
+	}
+
+	buf := make([]byte, 0, len(data)+32)
+	if len(e.ID) > 0 {
+		buf = append(buf, "id: "+sseEscape(e.ID)+"\n"...)
+	}
+	if len(e.Event) > 0 {
+		buf = append(buf, "event: "+sseEscape(e.Event)+"\n"...)
+	}
+	if e.Retry > 0 {
+		buf = append(buf, "retry: "+strconv.FormatInt(int64(e.Retry/time.Millisecond), 10)+"\n"...)
+	}
+	data = strings.Replace(data, "\r\n", "\n", -1)
+	for _, line := range strings.Split(data, "\n") {
+		buf = append(buf, "data: "+line+"\n"...)
+	}
+	return append(buf, '\n'), nil
+}
+
+// sseEscape removes line breaks that would break single line fields.
+func sseEscape(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// EventStreamOptions represents a struct for specifying configuration options for Context.EventStream.
+type EventStreamOptions struct {
+	// Heartbeat is the interval of sending comment lines to keep connection alive.
+	// Default is 15s, negative value disables heartbeat.
+	Heartbeat time.Duration
+	// Retry tells client the reconnection time, 0 leaves it to client.
+	Retry time.Duration
+}
+
+// EventStream is a writer of Server-Sent Events on top of current response.
+type EventStream struct {
+	resp        ResponseWriter
+	lastEventID string
+
+	lock   sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// EventStream starts a Server-Sent Events response, it sets headers and flushes them
+// immediately. Heartbeats are sent until client disconnects or the stream is closed.
+// The stream must be closed before the handler returns.
+func (c *Context) EventStream(options ...EventStreamOptions) *EventStream {
+	var opt EventStreamOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if opt.Heartbeat == 0 {
+		opt.Heartbeat = 15 * time.Second
+	}
+
+	s := &EventStream{
+		resp:        c.Resp,
+		lastEventID: c.Req.Header.Get(_LAST_EVENT_ID),
+		done:        make(chan struct{}),
+	}
+	// EventSource polyfills can not set headers when reconnecting.
+	if len(s.lastEventID) == 0 {
+		s.lastEventID = c.Req.URL.Query().Get("lastEventId")
+	}
+
+	h := c.Resp.Header()
+	h.Set(_CONTENT_TYPE, _CONTENT_EVENT_STREAM+"; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// Disable buffering of nginx.
+	h.Set("X-Accel-Buffering", "no")
+	c.Resp.WriteHeader(http.StatusOK)
+	if opt.Retry > 0 {
+		s.write([]byte("retry: " + strconv.FormatInt(int64(opt.Retry/time.Millisecond), 10) + "\n\n"))
+	} else {
+		s.Flush()
+	}
+
+	go func() {
+		select {
+		case <-c.Req.Context().Done():
+			s.Close()
+		case <-s.done:
+		}
+	}()
+	if opt.Heartbeat > 0 {
+		go s.heartbeat(opt.Heartbeat)
+	}
+	return s
+}
+
+func (s *EventStream) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if s.Comment("heartbeat") != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *EventStream) write(p []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return ErrEventStreamClosed
+	}
+	if _, err := s.resp.Write(p); err != nil {
+		return err
+	}
+	s.resp.Flush()
+	return nil
+}
+
+// LastEventID returns the ID of last event received by client before reconnecting.
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Send sends an event to client.
+func (s *EventStream) Send(e Event) error {
+	p, err := e.Encode()
+	if err != nil {
+		return err
+	}
+	return s.write(p)
+}
+
+// SendData sends an unnamed event with given data.
+func (s *EventStream) SendData(data interface{}) error {
+	return s.Send(Event{Data: data})
+}
+
+// Comment sends a comment line which is ignored by client.
+func (s *EventStream) Comment(text string) error {
+	return s.write([]byte(": " + sseEscape(text) + "\n\n"))
+}
+
+// Flush flushes buffered data to client.
+func (s *EventStream) Flush() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.closed {
+		s.resp.Flush()
+	}
+}
+
+// Done returns a channel which is closed when client disconnects or the stream is closed.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close stops the stream, following sends return ErrEventStreamClosed.
+func (s *EventStream) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sse
+
+import (
+	"landzero.net/x/net/web"
+)
+
+// MemoryAdapter represents an in-process adapter implementation,
+// published events are delivered to its own broker only.
+type MemoryAdapter struct {
+	deliver func(string, web.Event)
+}
+
+// Init initializes memory adapter.
+func (a *MemoryAdapter) Init(_ string, deliver func(string, web.Event)) error {
+	a.deliver = deliver
+	return nil
+}
+
+// Publish delivers event to the broker.
+func (a *MemoryAdapter) Publish(topic string, e web.Event) error {
+	a.deliver(topic, e)
+	return nil
+}
+
+func init() {
+	Register("memory", func() Adapter { return &MemoryAdapter{} })
+}
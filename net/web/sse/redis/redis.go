@@ -0,0 +1,92 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sse
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"landzero.net/x/database/redis"
+	"landzero.net/x/net/web"
+	"landzero.net/x/net/web/sse"
+)
+
+// redisEvent is the wire format of web.Event.
+type redisEvent struct {
+	ID    string        `json:"id"`
+	Event string        `json:"event,omitempty"`
+	Data  string        `json:"data"`
+	Retry time.Duration `json:"retry,omitempty"`
+}
+
+// RedisAdapter represents a redis pub/sub adapter implementation,
+// events are fanned out to brokers of all instances subscribed to the same redis.
+type RedisAdapter struct {
+	c      *redis.Client
+	prefix string
+}
+
+// Init initializes redis adapter and starts receiving events.
+// AdapterConfig: redis://:password@localhost:6379/0
+func (a *RedisAdapter) Init(config string, deliver func(string, web.Event)) error {
+	a.prefix = "sse:"
+
+	opt, err := redis.ParseURL(config)
+	if err != nil {
+		return err
+	}
+	a.c = redis.NewClient(opt)
+	if err = a.c.Ping().Err(); err != nil {
+		return err
+	}
+
+	pubsub := a.c.PSubscribe(a.prefix + "*")
+	// Wait for confirmation so events published after Init are not missed.
+	if _, err = pubsub.Receive(); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			var e redisEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				continue
+			}
+			deliver(strings.TrimPrefix(msg.Channel, a.prefix), web.Event{
+				ID:    e.ID,
+				Event: e.Event,
+				Data:  e.Data,
+				Retry: e.Retry,
+			})
+		}
+	}()
+	return nil
+}
+
+// Publish publishes event to redis channel of topic.
+func (a *RedisAdapter) Publish(topic string, e web.Event) error {
+	data, _ := e.Data.(string)
+	p, err := json.Marshal(redisEvent{e.ID, e.Event, data, e.Retry})
+	if err != nil {
+		return err
+	}
+	return a.c.Publish(a.prefix+topic, string(p)).Err()
+}
+
+func init() {
+	sse.Register("redis", func() sse.Adapter { return &RedisAdapter{} })
+}
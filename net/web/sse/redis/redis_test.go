@@ -0,0 +1,65 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sse
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+
+	"landzero.net/x/net/web/sse"
+)
+
+// receive returns the next event of subscriber, or an empty event on timeout.
+func receive(s *sse.Subscriber) web.Event {
+	select {
+	case e := <-s.C:
+		return e
+	case <-time.After(2 * time.Second):
+		return web.Event{}
+	}
+}
+
+func Test_RedisAdapter(t *testing.T) {
+	Convey("Fan out events among brokers by redis", t, func() {
+		opt := sse.Options{Adapter: "redis", AdapterConfig: "redis://localhost:6379/1"}
+		b1, err := sse.NewBroker(opt)
+		So(err, ShouldBeNil)
+		b2, err := sse.NewBroker(opt)
+		So(err, ShouldBeNil)
+
+		s1 := b1.Subscribe("chat")
+		defer s1.Close()
+		s2 := b2.Subscribe("chat")
+		defer s2.Close()
+
+		So(b1.Publish("chat", web.Event{ID: "1", Event: "msg", Data: "hello"}), ShouldBeNil)
+		So(receive(s1), ShouldResemble, web.Event{ID: "1", Event: "msg", Data: "hello"})
+		So(receive(s2), ShouldResemble, web.Event{ID: "1", Event: "msg", Data: "hello"})
+
+		// Each broker owns its adapter, the later one does not take over the earlier one.
+		So(b2.Publish("chat", web.Event{ID: "2", Data: map[string]int{"n": 2}}), ShouldBeNil)
+		So(receive(s1), ShouldResemble, web.Event{ID: "2", Data: `{"n":2}`})
+		So(receive(s2), ShouldResemble, web.Event{ID: "2", Data: `{"n":2}`})
+		So(b1.Since("chat", "1"), ShouldHaveLength, 1)
+	})
+
+	Convey("Invalid configuration", t, func() {
+		_, err := sse.NewBroker(sse.Options{Adapter: "redis", AdapterConfig: "mysql://localhost"})
+		So(err, ShouldNotBeNil)
+	})
+}
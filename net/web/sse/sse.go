@@ -0,0 +1,305 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package sse is a middleware that provides a topic broker of Server-Sent Events.
+package sse
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"landzero.net/x/com"
+	"landzero.net/x/net/web"
+)
+
+const _VERSION = "0.1.0"
+
+func Version() string {
+	return _VERSION
+}
+
+// Adapter is the interface that delivers events between broker instances.
+type Adapter interface {
+	// Init initializes adapter, events published by any instance must be passed to deliver.
+	Init(config string, deliver func(topic string, e web.Event)) error
+	// Publish broadcasts an event of topic to all instances, including current one.
+	Publish(topic string, e web.Event) error
+}
+
+// Options represents a struct for specifying configuration options for the sse middleware.
+type Options struct {
+	// Name of adapter. Default is "memory".
+	Adapter string
+	// Adapter configuration, it's corresponding to adapter.
+	AdapterConfig string
+	// Number of recent events kept per topic for Last-Event-ID replay. Default is 100,
+	// negative value disables replay.
+	History int
+	// Size of event buffer per subscriber, events are dropped for slow subscribers. Default is 16.
+	BufferSize int
+	// Heartbeat interval of event streams. Default is 15s.
+	Heartbeat time.Duration
+	// Retry tells client the reconnection time, 0 leaves it to client.
+	Retry time.Duration
+}
+
+func prepareOptions(options []Options) Options {
+	var opt Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if len(opt.Adapter) == 0 {
+		opt.Adapter = "memory"
+	}
+	if opt.History == 0 {
+		opt.History = 100
+	}
+	if opt.BufferSize == 0 {
+		opt.BufferSize = 16
+	}
+	return opt
+}
+
+type topic struct {
+	subscribers map[*Subscriber]bool
+	history     []web.Event
+}
+
+// Broker fans out published events to subscribers of topics.
+type Broker struct {
+	opt      Options
+	adapter  Adapter
+	instance string
+	seq      uint64
+
+	lock   sync.RWMutex
+	topics map[string]*topic
+}
+
+// NewBroker creates and returns a new broker by given options.
+func NewBroker(opt Options) (*Broker, error) {
+	opt = prepareOptions([]Options{opt})
+	newAdapter, ok := adapters[opt.Adapter]
+	if !ok {
+		return nil, fmt.Errorf("sse: unknown adapter '%s'(forgot to import?)", opt.Adapter)
+	}
+	adapter := newAdapter()
+	b := &Broker{
+		opt:      opt,
+		adapter:  adapter,
+		instance: hex.EncodeToString(com.RandomCreateBytes(4)),
+		topics:   make(map[string]*topic),
+	}
+	return b, adapter.Init(opt.AdapterConfig, b.deliver)
+}
+
+// Eventer is a middleware that maps a *sse.Broker service into the handler chain.
+// An single variadic sse.Options struct can be optionally provided to configure.
+func Eventer(options ...Options) web.Handler {
+	b, err := NewBroker(prepareOptions(options))
+	if err != nil {
+		panic(err)
+	}
+	return web.Mapping(func(ctx *web.Context) {
+		ctx.Map(b)
+	}, b)
+}
+
+// nextID generates an event ID unique among broker instances.
+func (b *Broker) nextID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + b.instance + "-" +
+		strconv.FormatUint(atomic.AddUint64(&b.seq, 1), 36)
+}
+
+// Publish publishes an event to all subscribers of topic, an ID is assigned if it's empty.
+func (b *Broker) Publish(topic string, e web.Event) error {
+	if len(e.ID) == 0 {
+		e.ID = b.nextID()
+	}
+	// Encode data before leaving current instance.
+	switch v := e.Data.(type) {
+	case nil, string:
+	case []byte:
+		e.Data = string(v)
+	default:
+		p, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		e.Data = string(p)
+	}
+	return b.adapter.Publish(topic, e)
+}
+
+func (b *Broker) deliver(name string, e web.Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	t := b.topics[name]
+	if t == nil {
+		if b.opt.History <= 0 {
+			// Nobody receives the event.
+			return
+		}
+		t = &topic{subscribers: make(map[*Subscriber]bool)}
+		b.topics[name] = t
+	}
+	if b.opt.History > 0 {
+		t.history = append(t.history, e)
+		if len(t.history) > b.opt.History {
+			t.history = t.history[len(t.history)-b.opt.History:]
+		}
+	}
+	for s := range t.subscribers {
+		select {
+		case s.ch <- e:
+		default:
+			// Drop event for slow subscriber.
+		}
+	}
+}
+
+// Since returns events of topic published after the event with given ID,
+// it returns nothing if the ID is no longer in history.
+func (b *Broker) Since(topic, lastEventID string) []web.Event {
+	if len(lastEventID) == 0 {
+		return nil
+	}
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	t := b.topics[topic]
+	if t == nil {
+		return nil
+	}
+	for i := len(t.history) - 1; i >= 0; i-- {
+		if t.history[i].ID == lastEventID {
+			return append([]web.Event{}, t.history[i+1:]...)
+		}
+	}
+	return nil
+}
+
+// Subscriber receives events of subscribed topics from channel C.
+type Subscriber struct {
+	C      <-chan web.Event
+	ch     chan web.Event
+	broker *Broker
+	topics []string
+}
+
+// Subscribe subscribes to given topics, the subscriber must be closed after use.
+func (b *Broker) Subscribe(topics ...string) *Subscriber {
+	ch := make(chan web.Event, b.opt.BufferSize)
+	s := &Subscriber{C: ch, ch: ch, broker: b, topics: topics}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, name := range topics {
+		t := b.topics[name]
+		if t == nil {
+			t = &topic{subscribers: make(map[*Subscriber]bool)}
+			b.topics[name] = t
+		}
+		t.subscribers[s] = true
+	}
+	return s
+}
+
+// Close unsubscribes from all topics. Topics without subscribers and history are
+// removed, so that topics requested by clients do not pile up.
+func (s *Subscriber) Close() {
+	s.broker.lock.Lock()
+	defer s.broker.lock.Unlock()
+
+	for _, name := range s.topics {
+		if t := s.broker.topics[name]; t != nil {
+			delete(t.subscribers, s)
+			if len(t.subscribers) == 0 && len(t.history) == 0 {
+				delete(s.broker.topics, name)
+			}
+		}
+	}
+}
+
+// Serve streams events of given topics to client until it disconnects.
+// Events missed since Last-Event-ID are replayed first.
+func (b *Broker) Serve(ctx *web.Context, topics ...string) {
+	sub := b.Subscribe(topics...)
+	defer sub.Close()
+
+	stream := ctx.EventStream(web.EventStreamOptions{
+		Heartbeat: b.opt.Heartbeat,
+		Retry:     b.opt.Retry,
+	})
+	defer stream.Close()
+
+	replayed := make(map[string]bool)
+	if lastEventID := stream.LastEventID(); len(lastEventID) > 0 {
+		for _, name := range topics {
+			for _, e := range b.Since(name, lastEventID) {
+				if err := stream.Send(e); err != nil {
+					return
+				}
+				replayed[e.ID] = true
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Done():
+			return
+		case e := <-sub.C:
+			if replayed[e.ID] {
+				continue
+			}
+			if err := stream.Send(e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Handler returns a route handler that streams events of given topics.
+// The topic is read from route parameter ":topic" when no topic is given.
+func (b *Broker) Handler(topics ...string) web.Handler {
+	return func(ctx *web.Context) {
+		if len(topics) > 0 {
+			b.Serve(ctx, topics...)
+		} else {
+			b.Serve(ctx, ctx.Params(":topic"))
+		}
+	}
+}
+
+var adapters = make(map[string]func() Adapter)
+
+// Register registers a adapter by function creating it, each broker owns a new adapter.
+func Register(name string, adapter func() Adapter) {
+	if adapter == nil {
+		panic("sse: cannot register adapter with nil value")
+	}
+	if _, dup := adapters[name]; dup {
+		panic(fmt.Errorf("sse: cannot register adapter '%s' twice", name))
+	}
+	adapters[name] = adapter
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package sse
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+)
+
+// readEvent reads lines of next event, comments are skipped.
+func readEvent(br *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return lines
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if len(line) == 0 {
+			if len(lines) > 0 {
+				return lines
+			}
+			continue
+		}
+		if line[0] != ':' && !strings.HasPrefix(line, "retry:") {
+			lines = append(lines, line)
+		}
+	}
+}
+
+func Test_Version(t *testing.T) {
+	Convey("Check package version", t, func() {
+		So(Version(), ShouldEqual, _VERSION)
+	})
+}
+
+func Test_Broker(t *testing.T) {
+	Convey("Register invalid adapter", t, func() {
+		_, err := NewBroker(Options{Adapter: "fake"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Subscribe and publish", t, func() {
+		b, err := NewBroker(Options{History: 2})
+		So(err, ShouldBeNil)
+
+		s := b.Subscribe("news")
+		So(b.Publish("news", web.Event{ID: "1", Data: "a"}), ShouldBeNil)
+		So(b.Publish("sports", web.Event{ID: "2", Data: "b"}), ShouldBeNil)
+		So(b.Publish("news", web.Event{Data: []int{1, 2}}), ShouldBeNil)
+
+		e := <-s.C
+		So(e.ID, ShouldEqual, "1")
+		e = <-s.C
+		So(len(e.ID), ShouldBeGreaterThan, 0)
+		So(e.Data, ShouldEqual, "[1,2]")
+
+		s.Close()
+		So(b.Publish("news", web.Event{ID: "3"}), ShouldBeNil)
+		So(len(s.C), ShouldEqual, 0)
+
+		// History is bounded.
+		So(b.Since("news", "1"), ShouldBeEmpty)
+		So(len(b.Since("news", e.ID)), ShouldEqual, 1)
+		So(b.Since("news", ""), ShouldBeEmpty)
+	})
+
+	Convey("Remove unused topics", t, func() {
+		b, err := NewBroker(Options{History: -1})
+		So(err, ShouldBeNil)
+		for i := 0; i < 10; i++ {
+			b.Subscribe(fmt.Sprintf("random-%d", i)).Close()
+		}
+		So(b.Publish("nobody", web.Event{Data: "a"}), ShouldBeNil)
+		So(b.topics, ShouldBeEmpty)
+
+		b, err = NewBroker(Options{})
+		So(err, ShouldBeNil)
+		s := b.Subscribe("news", "random")
+		So(b.Publish("news", web.Event{Data: "a"}), ShouldBeNil)
+		s.Close()
+		// Topics with history are kept for replay.
+		So(b.topics, ShouldHaveLength, 1)
+		So(b.topics["news"], ShouldNotBeNil)
+	})
+
+	Convey("Serve event stream", t, func() {
+		m := web.New()
+		m.Use(Eventer(Options{Heartbeat: -1}))
+		m.Get("/events/:topic", func(ctx *web.Context, b *Broker) {
+			b.Serve(ctx, ctx.Params(":topic"))
+		})
+		m.Post("/publish/:topic", func(ctx *web.Context, b *Broker) {
+			b.Publish(ctx.Params(":topic"), web.Event{ID: ctx.Query("id"), Event: "msg", Data: ctx.Query("data")})
+		})
+		srv := httptest.NewServer(m)
+		defer srv.Close()
+
+		publish := func(id, data string) {
+			resp, err := http.Post(srv.URL+"/publish/chat?id="+id+"&data="+data, "", nil)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+		}
+
+		resp, err := http.Get(srv.URL + "/events/chat")
+		So(err, ShouldBeNil)
+		So(resp.Header.Get("Content-Type"), ShouldStartWith, "text/event-stream")
+		br := bufio.NewReader(resp.Body)
+
+		publish("1", "hello")
+		publish("2", "world")
+		So(readEvent(br), ShouldResemble, []string{"id: 1", "event: msg", "data: hello"})
+		So(readEvent(br), ShouldResemble, []string{"id: 2", "event: msg", "data: world"})
+		resp.Body.Close()
+
+		// Reconnect with Last-Event-ID.
+		publish("3", "missed")
+		req, err := http.NewRequest("GET", srv.URL+"/events/chat", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("Last-Event-ID", "1")
+		resp, err = http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		br = bufio.NewReader(resp.Body)
+
+		So(readEvent(br), ShouldResemble, []string{"id: 2", "event: msg", "data: world"})
+		So(readEvent(br), ShouldResemble, []string{"id: 3", "event: msg", "data: missed"})
+
+		time.Sleep(10 * time.Millisecond)
+		publish("4", "live")
+		So(readEvent(br), ShouldResemble, []string{"id: 4", "event: msg", "data: live"})
+	})
+}
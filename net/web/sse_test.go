@@ -0,0 +1,83 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Event_Encode(t *testing.T) {
+	Convey("Encode events", t, func() {
+		p, err := Event{ID: "1", Event: "update", Data: "line1\nline2"}.Encode()
+		So(err, ShouldBeNil)
+		So(string(p), ShouldEqual, "id: 1\nevent: update\ndata: line1\ndata: line2\n\n")
+
+		p, err = Event{Data: map[string]int{"count": 1}, Retry: 3 * time.Second}.Encode()
+		So(err, ShouldBeNil)
+		So(string(p), ShouldEqual, "retry: 3000\ndata: {\"count\":1}\n\n")
+
+		p, err = Event{ID: "a\nb"}.Encode()
+		So(err, ShouldBeNil)
+		So(string(p), ShouldEqual, "id: ab\ndata: \n\n")
+	})
+}
+
+func Test_Context_EventStream(t *testing.T) {
+	Convey("Write event stream", t, func() {
+		m := New()
+		m.Get("/events", func(ctx *Context) {
+			s := ctx.EventStream(EventStreamOptions{Heartbeat: -1, Retry: time.Second})
+			defer s.Close()
+
+			So(s.LastEventID(), ShouldEqual, "42")
+			So(s.Send(Event{ID: "43", Data: "hello"}), ShouldBeNil)
+			So(s.Comment("ping"), ShouldBeNil)
+			s.Close()
+			So(s.SendData("world"), ShouldEqual, ErrEventStreamClosed)
+		})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/events", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("Last-Event-ID", "42")
+		m.ServeHTTP(resp, req)
+
+		So(resp.Code, ShouldEqual, http.StatusOK)
+		So(resp.Flushed, ShouldBeTrue)
+		So(resp.Header().Get("Content-Type"), ShouldEqual, "text/event-stream; charset=utf-8")
+		So(resp.Header().Get("Cache-Control"), ShouldEqual, "no-cache")
+		So(resp.Body.String(), ShouldEqual, "retry: 1000\n\nid: 43\ndata: hello\n\n: ping\n\n")
+	})
+
+	Convey("Send heartbeats", t, func() {
+		m := New()
+		m.Get("/events", func(ctx *Context) {
+			s := ctx.EventStream(EventStreamOptions{Heartbeat: 10 * time.Millisecond})
+			time.Sleep(35 * time.Millisecond)
+			s.Close()
+		})
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/events?lastEventId=1", nil)
+		So(err, ShouldBeNil)
+		m.ServeHTTP(resp, req)
+		So(resp.Body.String(), ShouldStartWith, ": heartbeat\n\n: heartbeat\n\n")
+	})
+}
@@ -17,10 +17,18 @@ package web
 
 import (
 	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -41,6 +49,17 @@ type StaticOptions struct {
 	// ETag defines if we should add an ETag header
 	// https://developers.google.com/web/fundamentals/performance/optimizing-content-efficiency/http-caching#validating-cached-responses-with-etags
 	ETag bool
+	// CacheControl defines values of Cache-Control header by file extension, e.g. ".js",
+	// the value of empty extension "" is used for files of other extensions.
+	CacheControl map[string]string
+	// Listing enables listing of directories without IndexFile.
+	Listing bool
+	// Precompressed enables serving of sibling ".br" and ".gz" files, e.g. "app.js.br" for
+	// "app.js", to clients accepting the encoding.
+	Precompressed bool
+	// Fallback defines file to serve for GET and HEAD requests accepting text/html which
+	// match no file and have no file extension, e.g. "/index.html" of a single-page app.
+	Fallback string
 	// BinFS defines if use landzero.net/x/runtime/binfs
 	BinFS bool
 	// FileSystem is the interface for supporting any implmentation of file system.
@@ -108,6 +127,9 @@ func prepareStaticOption(dir string, opt StaticOptions) StaticOptions {
 		// Remove any trailing '/'
 		opt.Prefix = strings.TrimRight(opt.Prefix, "/")
 	}
+	if len(opt.Fallback) > 0 && opt.Fallback[0] != '/' {
+		opt.Fallback = "/" + opt.Fallback
+	}
 	if opt.FileSystem == nil {
 		if opt.BinFS {
 			comps := strings.Split(dir, "/")
@@ -146,7 +168,7 @@ func staticHandler(ctx *Context, log *log.Logger, opt StaticOptions) bool {
 
 	f, err := opt.FileSystem.Open(file)
 	if err != nil {
-		return false
+		return serveFallback(ctx, log, opt, file)
 	}
 	defer f.Close()
 
@@ -163,35 +185,182 @@ func staticHandler(ctx *Context, log *log.Logger, opt StaticOptions) bool {
 			return true
 		}
 
-		file = path.Join(file, opt.IndexFile)
-		f, err = opt.FileSystem.Open(file)
+		index := path.Join(file, opt.IndexFile)
+		indexFile, err := opt.FileSystem.Open(index)
 		if err != nil {
-			return false // Discard error.
+			if opt.Listing {
+				serveListing(ctx, log, opt, file, f)
+				return true
+			}
+			return serveFallback(ctx, log, opt, file)
 		}
-		defer f.Close()
+		defer indexFile.Close()
 
-		fi, err = f.Stat()
+		fi, err = indexFile.Stat()
 		if err != nil || fi.IsDir() {
 			return true
 		}
+		file, f = index, indexFile
 	}
 
+	serveFile(ctx, log, opt, file, f, fi)
+	return true
+}
+
+// serveFallback serves opt.Fallback for file not found, it returns false if the request
+// is not qualified or the fallback file does not exist.
+func serveFallback(ctx *Context, log *log.Logger, opt StaticOptions, file string) bool {
+	if len(opt.Fallback) == 0 || len(path.Ext(file)) > 0 {
+		return false
+	}
+	accept := ctx.Req.Header.Get("Accept")
+	if len(accept) == 0 || len(NegotiateContentType(accept, _CONTENT_HTML)) == 0 {
+		return false
+	}
+
+	f, err := opt.FileSystem.Open(opt.Fallback)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		return false
+	}
+	serveFile(ctx, log, opt, opt.Fallback, f, fi)
+	return true
+}
+
+// precompressedEncodings are content-codings of precompressed files in order of preference.
+var precompressedEncodings = []struct {
+	encoding, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// openPrecompressed opens precompressed sibling of file accepted by the request.
+func openPrecompressed(ctx *Context, opt StaticOptions, file string) (http.File, os.FileInfo, string) {
+	accept := ctx.Req.Header.Get("Accept-Encoding")
+	for _, pe := range precompressedEncodings {
+		if !acceptsEncoding(accept, pe.encoding) {
+			continue
+		}
+		f, err := opt.FileSystem.Open(file + pe.ext)
+		if err != nil {
+			continue
+		}
+		fi, err := f.Stat()
+		if err != nil || fi.IsDir() {
+			f.Close()
+			continue
+		}
+		return f, fi, pe.encoding
+	}
+	return nil, nil, ""
+}
+
+// acceptsEncoding returns true if encoding is acceptable by Accept-Encoding header.
+func acceptsEncoding(header, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name != encoding && name != "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if name == encoding {
+			// Explicit coding takes precedence over wildcard.
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// serveFile writes content of file with caching headers, conditional and range requests
+// are handled by http.ServeContent.
+func serveFile(ctx *Context, log *log.Logger, opt StaticOptions, file string, f http.File, fi os.FileInfo) {
 	if !opt.SkipLogging {
 		log.Println("[Static] Serving " + file)
 	}
 
+	h := ctx.Resp.Header()
 	// Add an Expires header to the static content
 	if opt.Expires != nil {
-		ctx.Resp.Header().Set("Expires", opt.Expires())
+		h.Set("Expires", opt.Expires())
+	}
+	if cc, ok := opt.CacheControl[strings.ToLower(path.Ext(file))]; ok {
+		h.Set("Cache-Control", cc)
+	} else if cc, ok = opt.CacheControl[""]; ok {
+		h.Set("Cache-Control", cc)
+	}
+
+	var content io.ReadSeeker = f
+	if opt.Precompressed {
+		h.Add("Vary", "Accept-Encoding")
+		// Content type can not be sniffed from compressed content.
+		if ctype := mime.TypeByExtension(path.Ext(file)); len(ctype) > 0 {
+			if cf, cfi, encoding := openPrecompressed(ctx, opt, file); cf != nil {
+				defer cf.Close()
+				h.Set("Content-Type", ctype)
+				h.Set("Content-Encoding", encoding)
+				content, fi = cf, cfi
+			}
+		}
 	}
 
 	if opt.ETag {
-		tag := GenerateETag(string(fi.Size()), fi.Name(), fi.ModTime().UTC().Format(http.TimeFormat))
-		ctx.Resp.Header().Set("ETag", tag)
+		tag := GenerateETag(strconv.FormatInt(fi.Size(), 10), fi.Name(), fi.ModTime().UTC().Format(http.TimeFormat))
+		h.Set("ETag", `"`+tag+`"`)
 	}
 
-	http.ServeContent(ctx.Resp, ctx.Req.Request, file, fi.ModTime(), f)
-	return true
+	http.ServeContent(ctx.Resp, ctx.Req.Request, file, fi.ModTime(), content)
+}
+
+// serveListing writes HTML listing of directory.
+func serveListing(ctx *Context, log *log.Logger, opt StaticOptions, dir string, f http.File) {
+	fis, err := f.Readdir(-1)
+	if err != nil {
+		http.Error(ctx.Resp, "Error reading directory", http.StatusInternalServerError)
+		return
+	}
+	if !opt.SkipLogging {
+		log.Println("[Static] Listing " + dir)
+	}
+
+	names := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		// binfs reports full path as name.
+		name := path.Base(fi.Name())
+		if fi.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ctx.Resp.Header().Set("Content-Type", _CONTENT_HTML+"; charset=utf-8")
+	ctx.Resp.WriteHeader(http.StatusOK)
+	if ctx.Req.Method == "HEAD" {
+		return
+	}
+	fmt.Fprintf(ctx.Resp, "<pre>\n")
+	for _, name := range names {
+		link := url.URL{Path: name}
+		fmt.Fprintf(ctx.Resp, "<a href=\"%s\">%s</a>\n", link.String(), html.EscapeString(name))
+	}
+	fmt.Fprintf(ctx.Resp, "</pre>\n")
 }
 
 // GenerateETag generates an ETag based on size, filename and file modification time
@@ -23,10 +23,14 @@ import (
 	"net/http/httptest"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/runtime/binfs"
 )
 
 var currentRoot, _ = os.Getwd()
@@ -203,9 +207,14 @@ func Test_Static_Options(t *testing.T) {
 		req, err := http.NewRequest("GET", "http://localhost:4000/web.go", nil)
 		So(err, ShouldBeNil)
 		m.ServeHTTP(resp, req)
-		tag := GenerateETag(string(resp.Body.Len()), "web.go", resp.Header().Get("last-modified"))
+		tag := GenerateETag(strconv.Itoa(resp.Body.Len()), "web.go", resp.Header().Get("last-modified"))
 
-		So(resp.Header().Get("ETag"), ShouldEqual, tag)
+		So(resp.Header().Get("ETag"), ShouldEqual, `"`+tag+`"`)
+
+		resp = httptest.NewRecorder()
+		req.Header.Set("If-None-Match", `"`+tag+`"`)
+		m.ServeHTTP(resp, req)
+		So(resp.Code, ShouldEqual, http.StatusNotModified)
 	})
 }
 
@@ -264,3 +273,164 @@ func Test_Statics(t *testing.T) {
 		})
 	})
 }
+
+func Test_Static_Modes(t *testing.T) {
+	date := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	files := map[string]string{
+		"index.html":         "<p>app</p>",
+		"assets/app.js":      "console.log('hello, world')",
+		"assets/app.js.gz":   "gzipped",
+		"assets/app.js.br":   "brotli",
+		"assets/style.css":   "body{}",
+		"docs/a & b.txt":     "a and b",
+		"docs/sub/readme.md": "readme",
+	}
+
+	dir, err := ioutil.TempDir("", "static_modes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := &binfs.Node{}
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, date, date); err != nil {
+			t.Fatal(err)
+		}
+		root.Load(&binfs.Chunk{Path: strings.Split(name, "/"), Date: date, Data: []byte(content)})
+	}
+
+	backends := []struct {
+		name string
+		fs   http.FileSystem
+	}{
+		{"disk", http.Dir(dir)},
+		{"binfs", root.FileSystem()},
+	}
+	for _, backend := range backends {
+		opt := StaticOptions{
+			SkipLogging:   true,
+			ETag:          true,
+			Listing:       true,
+			Precompressed: true,
+			Fallback:      "index.html",
+			CacheControl: map[string]string{
+				".js": "public, max-age=31536000, immutable",
+				"":    "no-cache",
+			},
+			FileSystem: backend.fs,
+		}
+		serve := func(method, url string, header ...string) *httptest.ResponseRecorder {
+			m := New()
+			m.Use(Static("", opt))
+			m.Get("/api/ping", func() string { return "pong" })
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest(method, url, nil)
+			So(err, ShouldBeNil)
+			for i := 0; i+1 < len(header); i += 2 {
+				req.Header.Set(header[i], header[i+1])
+			}
+			m.ServeHTTP(resp, req)
+			return resp
+		}
+
+		Convey("Serve static files from "+backend.name, t, func() {
+			Convey("Conditional requests", func() {
+				resp := serve("GET", "/assets/style.css")
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldEqual, "body{}")
+				So(resp.Header().Get("Cache-Control"), ShouldEqual, "no-cache")
+				So(resp.Header().Get("Last-Modified"), ShouldEqual, date.Format(http.TimeFormat))
+				etag := resp.Header().Get("ETag")
+				So(etag, ShouldStartWith, `"`)
+
+				resp = serve("GET", "/assets/style.css", "If-None-Match", etag)
+				So(resp.Code, ShouldEqual, http.StatusNotModified)
+				So(resp.Body.Len(), ShouldEqual, 0)
+
+				resp = serve("GET", "/assets/style.css", "If-Modified-Since", date.Format(http.TimeFormat))
+				So(resp.Code, ShouldEqual, http.StatusNotModified)
+
+				resp = serve("GET", "/assets/style.css", "If-Modified-Since", date.Add(-time.Hour).Format(http.TimeFormat))
+				So(resp.Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("Range requests", func() {
+				resp := serve("GET", "/docs/sub/readme.md", "Range", "bytes=2-4")
+				So(resp.Code, ShouldEqual, http.StatusPartialContent)
+				So(resp.Body.String(), ShouldEqual, "adm")
+				So(resp.Header().Get("Content-Range"), ShouldEqual, "bytes 2-4/6")
+
+				etag := resp.Header().Get("ETag")
+				resp = serve("GET", "/docs/sub/readme.md", "Range", "bytes=2-4", "If-Range", etag)
+				So(resp.Code, ShouldEqual, http.StatusPartialContent)
+				resp = serve("GET", "/docs/sub/readme.md", "Range", "bytes=2-4", "If-Range", `"stale"`)
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldEqual, "readme")
+
+				resp = serve("GET", "/docs/sub/readme.md", "Range", "bytes=10-")
+				So(resp.Code, ShouldEqual, http.StatusRequestedRangeNotSatisfiable)
+			})
+
+			Convey("Precompressed files", func() {
+				resp := serve("GET", "/assets/app.js", "Accept-Encoding", "gzip, br")
+				So(resp.Body.String(), ShouldEqual, "brotli")
+				So(resp.Header().Get("Content-Encoding"), ShouldEqual, "br")
+				So(resp.Header().Get("Content-Type"), ShouldContainSubstring, "javascript")
+				So(resp.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+				So(resp.Header().Get("Cache-Control"), ShouldEqual, "public, max-age=31536000, immutable")
+				brTag := resp.Header().Get("ETag")
+
+				resp = serve("GET", "/assets/app.js", "Accept-Encoding", "gzip, br;q=0")
+				So(resp.Body.String(), ShouldEqual, "gzipped")
+				So(resp.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+				So(resp.Header().Get("ETag"), ShouldNotEqual, brTag)
+
+				resp = serve("GET", "/assets/app.js")
+				So(resp.Body.String(), ShouldEqual, files["assets/app.js"])
+				So(resp.Header().Get("Content-Encoding"), ShouldBeEmpty)
+			})
+
+			Convey("Directory listing", func() {
+				resp := serve("GET", "/docs")
+				So(resp.Code, ShouldEqual, http.StatusFound)
+
+				resp = serve("GET", "/docs/")
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+				So(resp.Body.String(), ShouldEqual, "<pre>\n"+
+					"<a href=\"a%20&%20b.txt\">a &amp; b.txt</a>\n"+
+					"<a href=\"sub/\">sub/</a>\n"+
+					"</pre>\n")
+
+				resp = serve("GET", "/")
+				So(resp.Body.String(), ShouldEqual, files["index.html"])
+			})
+
+			Convey("Single-page app fallback", func() {
+				resp := serve("GET", "/users/1", "Accept", "text/html,*/*;q=0.8")
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldEqual, files["index.html"])
+				So(resp.Header().Get("Cache-Control"), ShouldEqual, "no-cache")
+
+				resp = serve("GET", "/assets/missing.js", "Accept", "text/html")
+				So(resp.Code, ShouldEqual, http.StatusNotFound)
+
+				resp = serve("GET", "/users/1", "Accept", "application/json")
+				So(resp.Code, ShouldEqual, http.StatusNotFound)
+
+				resp = serve("POST", "/users/1", "Accept", "text/html")
+				So(resp.Code, ShouldEqual, http.StatusNotFound)
+
+				resp = serve("GET", "/api/ping", "Accept", "application/json")
+				So(resp.Body.String(), ShouldEqual, "pong")
+			})
+		})
+	}
+}
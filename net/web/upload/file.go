@@ -0,0 +1,194 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStorage stores uploads in a directory, content of upload is file "{id}.bin"
+// and its information is file "{id}.info".
+type FileStorage struct {
+	lock  sync.Mutex
+	locks map[string]*fileLock
+	dir   string
+}
+
+// fileLock serializes writes of an upload, it is removed once nobody holds it.
+type fileLock struct {
+	sync.Mutex
+	refs int
+}
+
+// acquire locks upload id and returns function to unlock it.
+func (s *FileStorage) acquire(id string) func() {
+	s.lock.Lock()
+	if s.locks == nil {
+		s.locks = make(map[string]*fileLock)
+	}
+	l, ok := s.locks[id]
+	if !ok {
+		l = &fileLock{}
+		s.locks[id] = l
+	}
+	l.refs++
+	s.lock.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.lock.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, id)
+		}
+		s.lock.Unlock()
+	}
+}
+
+// Init initializes the storage, config is the directory, default is "data/uploads".
+func (s *FileStorage) Init(config string) error {
+	if len(config) == 0 {
+		config = "data/uploads"
+	}
+	s.dir = config
+	return os.MkdirAll(s.dir, os.ModePerm)
+}
+
+// path returns path of file of upload, ErrNotFound if id is invalid.
+func (s *FileStorage) path(id, ext string) (string, error) {
+	if len(id) == 0 {
+		return "", ErrNotFound
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '_') {
+			return "", ErrNotFound
+		}
+	}
+	return filepath.Join(s.dir, id+ext), nil
+}
+
+func (s *FileStorage) Create(info Info) error {
+	infoPath, err := s.path(info.ID, ".info")
+	if err != nil {
+		return err
+	}
+	binPath, _ := s.path(info.ID, ".bin")
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(binPath, nil, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(infoPath, data, 0600)
+}
+
+func (s *FileStorage) Info(id string) (Info, error) {
+	var info Info
+	infoPath, err := s.path(id, ".info")
+	if err != nil {
+		return info, err
+	}
+	binPath, _ := s.path(id, ".bin")
+
+	data, err := ioutil.ReadFile(infoPath)
+	if os.IsNotExist(err) {
+		return info, ErrNotFound
+	} else if err != nil {
+		return info, err
+	}
+	if err = json.Unmarshal(data, &info); err != nil {
+		return info, err
+	}
+	fi, err := os.Stat(binPath)
+	if os.IsNotExist(err) {
+		return info, ErrNotFound
+	} else if err != nil {
+		return info, err
+	}
+	info.Offset = fi.Size()
+	return info, nil
+}
+
+func (s *FileStorage) Write(id string, offset int64, r io.Reader) (int64, error) {
+	binPath, err := s.path(id, ".bin")
+	if err != nil {
+		return 0, err
+	}
+
+	// Chunks of an upload are written one at a time, other uploads are not blocked.
+	defer s.acquire(id)()
+
+	f, err := os.OpenFile(binPath, os.O_WRONLY, 0600)
+	if os.IsNotExist(err) {
+		return 0, ErrNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// Another chunk may have been written since offset was read.
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	} else if fi.Size() != offset {
+		return 0, ErrOffsetMismatch
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if err != nil {
+		// Discard partial chunk.
+		f.Truncate(offset)
+		return 0, err
+	}
+	return n, f.Truncate(offset + n)
+}
+
+func (s *FileStorage) Open(id string) (io.ReadCloser, error) {
+	binPath, err := s.path(id, ".bin")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(binPath)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *FileStorage) Delete(id string) error {
+	infoPath, err := s.path(id, ".info")
+	if err != nil {
+		return err
+	}
+	binPath, _ := s.path(id, ".bin")
+	if err = os.Remove(infoPath); os.IsNotExist(err) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return os.Remove(binPath)
+}
+
+func init() {
+	Register("file", &FileStorage{})
+}
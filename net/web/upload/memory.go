@@ -0,0 +1,112 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// memoryUpload is an upload in memory.
+type memoryUpload struct {
+	info Info
+	data []byte
+}
+
+// MemoryStorage stores uploads in memory.
+type MemoryStorage struct {
+	lock    sync.RWMutex
+	uploads map[string]*memoryUpload
+}
+
+// NewMemoryStorage creates and returns a new memory storage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{uploads: make(map[string]*memoryUpload)}
+}
+
+// Init does nothing, config is ignored.
+func (s *MemoryStorage) Init(config string) error {
+	return nil
+}
+
+func (s *MemoryStorage) Create(info Info) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	info.Offset = 0
+	s.uploads[info.ID] = &memoryUpload{info: info}
+	return nil
+}
+
+func (s *MemoryStorage) Info(id string) (Info, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	info := u.info
+	info.Offset = int64(len(u.data))
+	return info, nil
+}
+
+func (s *MemoryStorage) Write(id string, offset int64, r io.Reader) (int64, error) {
+	// Read outside of lock, content is discarded if reading fails.
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		return 0, ErrNotFound
+	} else if int64(len(u.data)) != offset {
+		return 0, ErrOffsetMismatch
+	}
+	u.data = append(u.data, data...)
+	return int64(len(data)), nil
+}
+
+func (s *MemoryStorage) Open(id string) (io.ReadCloser, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(u.data)), nil
+}
+
+func (s *MemoryStorage) Delete(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.uploads[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.uploads, id)
+	return nil
+}
+
+func init() {
+	Register("memory", NewMemoryStorage())
+}
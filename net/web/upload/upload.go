@@ -0,0 +1,477 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package upload is a handler of resumable uploads of tus protocol 1.0.0 with pluggable storages.
+//
+// Example:
+//
+//	uploads := upload.New(upload.Options{
+//		Adapter:       "file",
+//		AdapterConfig: "data/uploads",
+//		MaxSize:       1 << 30,
+//		Completed: func(req *http.Request, info upload.Info) {
+//			log.Println("uploaded", info.ID, info.Metadata["filename"])
+//		},
+//	})
+//	m.Mount("/files", uploads, auth.Basic(...))
+package upload
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const _VERSION = "0.1.0"
+
+func Version() string {
+	return _VERSION
+}
+
+const (
+	// TusVersion is the version of tus protocol implemented.
+	TusVersion = "1.0.0"
+	// TusExtensions are extensions of tus protocol supported.
+	TusExtensions = "creation,creation-with-upload,termination,checksum"
+
+	_CONTENT_OFFSET = "application/offset+octet-stream"
+	// StatusChecksumMismatch is the status of PATCH requests whose checksum mismatches.
+	StatusChecksumMismatch = 460
+)
+
+var (
+	// ErrNotFound is returned by storages if upload does not exist.
+	ErrNotFound = errors.New("upload: not found")
+	// ErrChecksumMismatch is returned by reader of chunk whose checksum mismatches.
+	ErrChecksumMismatch = errors.New("upload: checksum mismatch")
+	// ErrOffsetMismatch is returned by storages if offset is not the size of upload.
+	ErrOffsetMismatch = errors.New("upload: offset mismatch")
+)
+
+// Info describes an upload.
+type Info struct {
+	ID string `json:"id"`
+	// Size is the total size of upload.
+	Size int64 `json:"size"`
+	// Offset is number of bytes received.
+	Offset    int64             `json:"-"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Completed returns true if all bytes of upload are received.
+func (info Info) Completed() bool {
+	return info.Offset >= info.Size
+}
+
+// Storage is the interface that stores uploads.
+type Storage interface {
+	// Init initializes the storage by configuration string.
+	Init(config string) error
+	// Create creates an empty upload.
+	Create(info Info) error
+	// Info returns information of upload, ErrNotFound if it does not exist.
+	Info(id string) (Info, error)
+	// Write appends content read from r to upload at offset, and returns number of
+	// bytes written. If reading r fails, the upload must be left unchanged.
+	// ErrOffsetMismatch is returned if offset is not the current size of upload.
+	Write(id string, offset int64, r io.Reader) (int64, error)
+	// Open opens content of upload.
+	Open(id string) (io.ReadCloser, error)
+	// Delete deletes upload.
+	Delete(id string) error
+}
+
+// Options represents a struct for specifying configuration options for the upload handler.
+type Options struct {
+	// Name of adapter. Default is "memory".
+	Adapter string
+	// Adapter configuration, it's corresponding to adapter.
+	AdapterConfig string
+	// MaxSize is the maximum size of uploads in bytes, 0 means unlimited.
+	MaxSize int64
+	// Completed is called after the last chunk of upload is received.
+	Completed func(*http.Request, Info)
+}
+
+func prepareOptions(options []Options) Options {
+	var opt Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if len(opt.Adapter) == 0 {
+		opt.Adapter = "memory"
+	}
+	return opt
+}
+
+// NewStorage initializes and returns the storage registered by given adapter name.
+func NewStorage(name, config string) (Storage, error) {
+	adapter, ok := adapters[name]
+	if !ok {
+		return nil, fmt.Errorf("upload: unknown adapter '%s'(forgot to import?)", name)
+	}
+	return adapter, adapter.Init(config)
+}
+
+// Server handles requests of tus protocol, it should be mounted at the URL of upload
+// collection, e.g. by web.Router.Mount, so that paths of uploads are "/{id}".
+type Server struct {
+	opt     Options
+	storage Storage
+}
+
+// New creates a server of uploads. It panics if the storage fails to initialize.
+func New(options ...Options) *Server {
+	opt := prepareOptions(options)
+	storage, err := NewStorage(opt.Adapter, opt.AdapterConfig)
+	if err != nil {
+		panic(err)
+	}
+	return &Server{opt, storage}
+}
+
+// Storage returns the storage of uploads, e.g. to open completed uploads.
+func (s *Server) Storage() Storage {
+	return s.storage
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h := w.Header()
+	h.Set("Tus-Resumable", TusVersion)
+
+	method := req.Method
+	if override := req.Header.Get("X-HTTP-Method-Override"); method == "POST" && len(override) > 0 {
+		method = strings.ToUpper(override)
+	}
+
+	if method == "OPTIONS" {
+		h.Set("Tus-Version", TusVersion)
+		h.Set("Tus-Extension", TusExtensions)
+		h.Set("Tus-Checksum-Algorithm", "md5,sha1,sha256")
+		if s.opt.MaxSize > 0 {
+			h.Set("Tus-Max-Size", strconv.FormatInt(s.opt.MaxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if req.Header.Get("Tus-Resumable") != TusVersion {
+		h.Set("Tus-Version", TusVersion)
+		http.Error(w, "unsupported version of tus protocol", http.StatusPreconditionFailed)
+		return
+	}
+
+	id := strings.Trim(req.URL.Path, "/")
+	switch {
+	case method == "POST" && len(id) == 0:
+		s.create(w, req)
+	case method == "HEAD" && len(id) > 0:
+		s.head(w, id)
+	case method == "PATCH" && len(id) > 0:
+		s.patch(w, req, id)
+	case method == "DELETE" && len(id) > 0:
+		s.delete(w, id)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) create(w http.ResponseWriter, req *http.Request) {
+	size, err := strconv.ParseInt(req.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		http.Error(w, "invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if s.opt.MaxSize > 0 && size > s.opt.MaxSize {
+		http.Error(w, "upload exceeds maximum size", http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := parseMetadata(req.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info := Info{
+		ID:        newID(),
+		Size:      size,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	}
+	if err = s.storage.Create(info); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := req.URL.Path
+	// Path of request is rewritten if the server is mounted.
+	if u, err := url.ParseRequestURI(req.RequestURI); err == nil {
+		base = u.Path
+	}
+	w.Header().Set("Location", strings.TrimSuffix(base, "/")+"/"+info.ID)
+
+	// Extension creation-with-upload.
+	if req.Header.Get("Content-Type") == _CONTENT_OFFSET {
+		if info, err = s.write(req, info); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	} else if size == 0 {
+		s.complete(req, info)
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) head(w http.ResponseWriter, id string) {
+	info, err := s.storage.Info(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	h.Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	h.Set("Upload-Length", strconv.FormatInt(info.Size, 10))
+	if len(info.Metadata) > 0 {
+		h.Set("Upload-Metadata", formatMetadata(info.Metadata))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) patch(w http.ResponseWriter, req *http.Request, id string) {
+	if req.Header.Get("Content-Type") != _CONTENT_OFFSET {
+		http.Error(w, "Content-Type must be "+_CONTENT_OFFSET, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(req.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+	info, err := s.storage.Info(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if offset != info.Offset {
+		http.Error(w, "Upload-Offset mismatches", http.StatusConflict)
+		return
+	}
+
+	if info, err = s.write(req, info); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) delete(w http.ResponseWriter, id string) {
+	if err := s.storage.Delete(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tooLargeError is returned if chunk exceeds the size of upload.
+type tooLargeError struct{}
+
+func (tooLargeError) Error() string {
+	return "chunk exceeds size of upload"
+}
+
+// badRequestError is returned if request headers are invalid.
+type badRequestError string
+
+func (e badRequestError) Error() string {
+	return string(e)
+}
+
+// write writes body of req as a chunk of upload and returns the updated info.
+func (s *Server) write(req *http.Request, info Info) (Info, error) {
+	remaining := info.Size - info.Offset
+	if req.ContentLength > remaining {
+		return info, tooLargeError{}
+	}
+	// Read one more byte to detect chunks exceeding the size.
+	var r io.Reader = &limitedReader{io.LimitReader(req.Body, remaining+1), remaining}
+	if header := req.Header.Get("Upload-Checksum"); len(header) > 0 {
+		cr, err := newChecksumReader(r, header)
+		if err != nil {
+			return info, err
+		}
+		r = cr
+	}
+
+	n, err := s.storage.Write(info.ID, info.Offset, r)
+	if err != nil {
+		return info, err
+	}
+	info.Offset += n
+	if info.Completed() {
+		s.complete(req, info)
+	}
+	return info, nil
+}
+
+func (s *Server) complete(req *http.Request, info Info) {
+	if s.opt.Completed != nil {
+		s.opt.Completed(req, info)
+	}
+}
+
+// limitedReader fails if more than n bytes are read.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	if lr.n -= int64(n); lr.n < 0 {
+		return n, tooLargeError{}
+	}
+	return n, err
+}
+
+// checksumReader verifies checksum of content at EOF.
+type checksumReader struct {
+	r    io.Reader
+	hash hash.Hash
+	sum  []byte
+}
+
+func newChecksumReader(r io.Reader, header string) (*checksumReader, error) {
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return nil, badRequestError("invalid Upload-Checksum")
+	}
+	sum, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, badRequestError("invalid Upload-Checksum")
+	}
+
+	var h hash.Hash
+	switch fields[0] {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return nil, badRequestError("unsupported checksum algorithm " + fields[0])
+	}
+	return &checksumReader{r, h, sum}, nil
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.hash.Write(p[:n])
+	if err == io.EOF && string(cr.hash.Sum(nil)) != string(cr.sum) {
+		return n, ErrChecksumMismatch
+	}
+	return n, err
+}
+
+// writeError writes err with status derived from it.
+func writeError(w http.ResponseWriter, err error) {
+	var (
+		tl tooLargeError
+		br badRequestError
+		me *http.MaxBytesError
+	)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrChecksumMismatch):
+		http.Error(w, err.Error(), StatusChecksumMismatch)
+	case errors.Is(err, ErrOffsetMismatch):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.As(err, &tl), errors.As(err, &me):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.As(err, &br):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseMetadata parses Upload-Metadata header, which is comma separated pairs of key
+// and base64 encoded value.
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 0:
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of metadata %s", fields[0])
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, errors.New("invalid Upload-Metadata")
+		}
+	}
+	return metadata, nil
+}
+
+func formatMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for k, v := range metadata {
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+var adapters = make(map[string]Storage)
+
+// Register registers a adapter.
+func Register(name string, adapter Storage) {
+	if adapter == nil {
+		panic("upload: cannot register adapter with nil value")
+	}
+	if _, dup := adapters[name]; dup {
+		panic(fmt.Errorf("upload: cannot register adapter '%s' twice", name))
+	}
+	adapters[name] = adapter
+}
@@ -0,0 +1,197 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package upload
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+)
+
+func Test_Version(t *testing.T) {
+	Convey("Get version", t, func() {
+		So(Version(), ShouldEqual, _VERSION)
+	})
+}
+
+func Test_Upload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, opt := range []Options{
+		{Adapter: "memory", MaxSize: 20},
+		{Adapter: "file", AdapterConfig: dir, MaxSize: 20},
+	} {
+		Convey("Resumable uploads with "+opt.Adapter+" adapter", t, func() {
+			var completed []Info
+			opt.Completed = func(req *http.Request, info Info) {
+				completed = append(completed, info)
+			}
+			srv := New(opt)
+			m := web.New()
+			m.Mount("/files", srv)
+
+			do := func(method, url, body string, header ...string) *httptest.ResponseRecorder {
+				resp := httptest.NewRecorder()
+				req := httptest.NewRequest(method, url, strings.NewReader(body))
+				req.Header.Set("Tus-Resumable", TusVersion)
+				for i := 0; i+1 < len(header); i += 2 {
+					req.Header.Set(header[i], header[i+1])
+				}
+				m.ServeHTTP(resp, req)
+				So(resp.Header().Get("Tus-Resumable"), ShouldEqual, TusVersion)
+				return resp
+			}
+
+			resp := do("OPTIONS", "/files", "")
+			So(resp.Code, ShouldEqual, http.StatusNoContent)
+			So(resp.Header().Get("Tus-Extension"), ShouldEqual, TusExtensions)
+			So(resp.Header().Get("Tus-Max-Size"), ShouldEqual, "20")
+
+			resp = do("POST", "/files", "", "Tus-Resumable", "0.2.2", "Upload-Length", "10")
+			So(resp.Code, ShouldEqual, http.StatusPreconditionFailed)
+			resp = do("POST", "/files", "", "Upload-Length", "21")
+			So(resp.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			resp = do("POST", "/files", "", "Upload-Length", "x")
+			So(resp.Code, ShouldEqual, http.StatusBadRequest)
+
+			resp = do("POST", "/files/", "", "Upload-Length", "10",
+				"Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("a.txt"))+",private")
+			So(resp.Code, ShouldEqual, http.StatusCreated)
+			location := resp.Header().Get("Location")
+			So(location, ShouldStartWith, "/files/")
+			id := strings.TrimPrefix(location, "/files/")
+
+			resp = do("HEAD", location, "")
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(resp.Header().Get("Upload-Offset"), ShouldEqual, "0")
+			So(resp.Header().Get("Upload-Length"), ShouldEqual, "10")
+			So(resp.Header().Get("Cache-Control"), ShouldEqual, "no-store")
+			metadata, err := parseMetadata(resp.Header().Get("Upload-Metadata"))
+			So(err, ShouldBeNil)
+			So(metadata, ShouldResemble, map[string]string{"filename": "a.txt", "private": ""})
+
+			resp = do("PATCH", location, "hello", "Content-Type", _CONTENT_OFFSET, "Upload-Offset", "0")
+			So(resp.Code, ShouldEqual, http.StatusNoContent)
+			So(resp.Header().Get("Upload-Offset"), ShouldEqual, "5")
+
+			// Wrong offset.
+			resp = do("PATCH", location, "world", "Content-Type", _CONTENT_OFFSET, "Upload-Offset", "0")
+			So(resp.Code, ShouldEqual, http.StatusConflict)
+			// Wrong content type.
+			resp = do("PATCH", location, "world", "Upload-Offset", "5")
+			So(resp.Code, ShouldEqual, http.StatusUnsupportedMediaType)
+			// Chunk exceeds the size.
+			resp = do("PATCH", location, "world!", "Content-Type", _CONTENT_OFFSET, "Upload-Offset", "5")
+			So(resp.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+			// Checksum mismatches, the chunk is discarded.
+			resp = do("PATCH", location, "world", "Content-Type", _CONTENT_OFFSET, "Upload-Offset", "5",
+				"Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString([]byte("01234567890123456789")))
+			So(resp.Code, ShouldEqual, StatusChecksumMismatch)
+			resp = do("PATCH", location, "world", "Content-Type", _CONTENT_OFFSET, "Upload-Offset", "5",
+				"Upload-Checksum", "crc32 AAAA")
+			So(resp.Code, ShouldEqual, http.StatusBadRequest)
+			So(do("HEAD", location, "").Header().Get("Upload-Offset"), ShouldEqual, "5")
+			So(completed, ShouldBeEmpty)
+
+			sum := sha1.Sum([]byte("world"))
+			resp = do("PATCH", location, "world", "Content-Type", _CONTENT_OFFSET, "Upload-Offset", "5",
+				"Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString(sum[:]))
+			So(resp.Code, ShouldEqual, http.StatusNoContent)
+			So(resp.Header().Get("Upload-Offset"), ShouldEqual, "10")
+			So(completed, ShouldHaveLength, 1)
+			So(completed[0].ID, ShouldEqual, id)
+			So(completed[0].Metadata["filename"], ShouldEqual, "a.txt")
+
+			f, err := srv.Storage().Open(id)
+			So(err, ShouldBeNil)
+			data, err := ioutil.ReadAll(f)
+			f.Close()
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "helloworld")
+
+			resp = do("DELETE", location, "")
+			So(resp.Code, ShouldEqual, http.StatusNoContent)
+			So(do("HEAD", location, "").Code, ShouldEqual, http.StatusNotFound)
+			So(do("DELETE", location, "").Code, ShouldEqual, http.StatusNotFound)
+
+			Convey("Create with upload", func() {
+				resp := do("POST", "/files", "abc", "Upload-Length", "3", "Content-Type", _CONTENT_OFFSET)
+				So(resp.Code, ShouldEqual, http.StatusCreated)
+				So(resp.Header().Get("Upload-Offset"), ShouldEqual, "3")
+				So(completed, ShouldHaveLength, 2)
+			})
+
+			Convey("Override method", func() {
+				resp := do("POST", location, "", "X-HTTP-Method-Override", "HEAD")
+				So(resp.Code, ShouldEqual, http.StatusNotFound)
+				So(do("GET", location, "").Code, ShouldEqual, http.StatusMethodNotAllowed)
+			})
+		})
+	}
+
+	Convey("Reject invalid IDs of files", t, func() {
+		s := &FileStorage{}
+		So(s.Init(dir), ShouldBeNil)
+		_, err := s.Info("../upload")
+		So(err, ShouldEqual, ErrNotFound)
+	})
+
+	Convey("Write chunks of files concurrently", t, func() {
+		s := &FileStorage{}
+		So(s.Init(dir), ShouldBeNil)
+		So(s.Create(Info{ID: "stalled", Size: 10}), ShouldBeNil)
+		So(s.Create(Info{ID: "other", Size: 10}), ShouldBeNil)
+
+		// A chunk of a slow client does not block other uploads.
+		pr, pw := io.Pipe()
+		done := make(chan error)
+		go func() {
+			_, err := s.Write("stalled", 0, pr)
+			done <- err
+		}()
+		pw.Write([]byte("h"))
+		n, err := s.Write("other", 0, strings.NewReader("hello"))
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 5)
+
+		// Offset is checked again under lock of upload.
+		_, err = s.Write("other", 0, strings.NewReader("world"))
+		So(err, ShouldEqual, ErrOffsetMismatch)
+		info, err := s.Info("other")
+		So(err, ShouldBeNil)
+		So(info.Offset, ShouldEqual, 5)
+
+		pw.Write([]byte("i"))
+		pw.Close()
+		So(<-done, ShouldBeNil)
+		info, err = s.Info("stalled")
+		So(err, ShouldBeNil)
+		So(info.Offset, ShouldEqual, 2)
+		So(s.locks, ShouldBeEmpty)
+	})
+}
@@ -18,11 +18,13 @@
 package web
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"reflect"
+	"runtime"
 	"strings"
 
 	"landzero.net/x/com"
@@ -68,6 +70,61 @@ func (invoke internalServerErrorInvoker) Invoke(params []interface{}) ([]reflect
 	return nil, nil
 }
 
+// Declarer is implemented by handlers which map values into injector of requests,
+// ProvidedTypes returns types of the values, so that Web.Validate knows values
+// available to following handlers.
+type Declarer interface {
+	ProvidedTypes() []reflect.Type
+}
+
+// MappingHandler is a handler which maps values into injector of requests. When it's
+// called with a nil context, it does nothing but returns types of the values.
+type MappingHandler func(ctx *Context) []reflect.Type
+
+func (h MappingHandler) Invoke(params []interface{}) ([]reflect.Value, error) {
+	h(params[0].(*Context))
+	return nil, nil
+}
+
+// ProvidedTypes implements Declarer.
+func (h MappingHandler) ProvidedTypes() []reflect.Type {
+	return h(nil)
+}
+
+// Mapping returns a MappingHandler of h which maps values of given types, types are
+// specified like arguments of Map and MapTo, i.e. values or pointers to interfaces.
+// Arguments of h other than *Context are injected but not checked by Validate.
+//
+// Example:
+//
+//	return web.Mapping(func(ctx *web.Context) {
+//		ctx.MapTo(store, (*Store)(nil))
+//	}, (*Store)(nil))
+func Mapping(h Handler, vals ...interface{}) MappingHandler {
+	validateAndWrapHandler(h)
+	fn, isContextFunc := h.(func(*Context))
+
+	types := make([]reflect.Type, len(vals))
+	for i, val := range vals {
+		t := reflect.TypeOf(val)
+		if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Interface {
+			t = t.Elem()
+		}
+		types[i] = t
+	}
+	return func(ctx *Context) []reflect.Type {
+		if ctx == nil {
+			return types
+		}
+		if isContextFunc {
+			fn(ctx)
+		} else if _, err := ctx.Invoke(h); err != nil {
+			panic(err)
+		}
+		return nil
+	}
+}
+
 // validateAndWrapHandler makes sure a handler is a callable function, it panics if not.
 // When the handler is also potential to be any built-in inject.FastInvoker,
 // it wraps the handler automatically to have some performance gain.
@@ -137,6 +194,7 @@ type Web struct {
 
 	hasURLPrefix bool
 	urlPrefix    string // For suburl support.
+	h2c          bool   // Serve cleartext HTTP/2.
 	*Router
 
 	logger *log.Logger
@@ -205,6 +263,43 @@ func (m *Web) Before(handler BeforeHandler) {
 	m.befores = append(m.befores, handler)
 }
 
+// Validate checks that arguments of all handlers of routes can be injected, so that
+// "Value not found" errors are found on startup rather than on requests. Values
+// mapped or provided by m, values of requests (*Context, http.ResponseWriter and
+// *http.Request) and values declared by preceding handlers (see Declarer) are
+// considered available. Call it after all middlewares and routes are registered.
+func (m *Web) Validate() error {
+	builtins := []reflect.Type{
+		reflect.TypeOf((*Context)(nil)),
+		inject.InterfaceOf((*http.ResponseWriter)(nil)),
+		reflect.TypeOf((*http.Request)(nil)),
+	}
+
+	var errs []string
+	for _, route := range m.Routes() {
+		known := builtins[:len(builtins):len(builtins)]
+		handlers := append(m.handlers[:len(m.handlers):len(m.handlers)], route.Handlers...)
+		for i, h := range handlers {
+			if err := m.Check(h, known...); err != nil {
+				kind, n := "handler", i-len(m.handlers)
+				if n < 0 {
+					kind, n = "middleware", i
+				}
+				name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+				errs = append(errs, fmt.Sprintf("%s %s%s: %s #%d %s: %v",
+					route.Method, route.Host, route.Pattern, kind, n, name, err))
+			}
+			if d, ok := h.(Declarer); ok {
+				known = append(known, d.ProvidedTypes()...)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("web: unsatisfied handler arguments:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
 // Use adds a middleware Handler to the stack,
 // and panics if the handler is not a callable func.
 // Middleware Handlers are invoked in the order that they are added.
@@ -228,7 +323,7 @@ func (m *Web) createContext(rw http.ResponseWriter, req *http.Request) *Context
 		crid:     extractCrid(req),
 		logger:   m.logger,
 	}
-	c.SetParent(m)
+	c.SetParent(m.Injector)
 	c.Map(c)
 	c.MapTo(c.Resp, (*http.ResponseWriter)(nil))
 	c.Map(req)
@@ -284,7 +379,29 @@ func (m *Web) Run(args ...interface{}) {
 	addr := host + ":" + com.ToStr(port)
 	logger := m.GetVal(reflect.TypeOf(m.logger)).Interface().(*log.Logger)
 	logger.Printf("listening on %s (%s)\n", addr, m.Env())
-	logger.Fatalln(http.ListenAndServe(addr, m))
+	srv, err := m.Server(addr)
+	if err != nil {
+		logger.Fatalln(err)
+	}
+	logger.Fatalln(srv.ListenAndServe())
+}
+
+// SetH2C sets the value who determines whether servers created by Run and Server
+// accept HTTP/2 without TLS (h2c) along with HTTP/1.
+func (m *Web) SetH2C(v bool) {
+	m.h2c = v
+}
+
+// Server returns a http.Server serving the Web on given address,
+// it accepts h2c connections if enabled by SetH2C.
+func (m *Web) Server(addr string) (*http.Server, error) {
+	srv := &http.Server{Addr: addr, Handler: m}
+	if m.h2c {
+		if err := enableH2C(srv); err != nil {
+			return nil, err
+		}
+	}
+	return srv, nil
 }
 
 // SetURLPrefix sets URL prefix of router layer, so that it support suburl.
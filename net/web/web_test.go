@@ -23,6 +23,7 @@ import (
 	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web/inject"
 )
 
 func Test_New(t *testing.T) {
@@ -204,3 +205,36 @@ func Test_SetENV(t *testing.T) {
 		}
 	})
 }
+
+func Test_Web_Validate(t *testing.T) {
+	Convey("Validate arguments of handlers", t, func() {
+		type service struct{}
+		type user struct{}
+
+		m := New()
+		m.Use(Renderer())
+		m.Use(Mapping(func(ctx *Context) {
+			ctx.Map(&user{})
+		}, &user{}))
+		m.Provide(func(ctx *Context) *service { return &service{} }, inject.Scoped)
+		m.Get("/", func(ctx *Context, r Render, u *user, s *service) {
+			r.PlainText(http.StatusOK, []byte("ok"))
+		})
+		m.Post("/", func(http.ResponseWriter, *http.Request) {})
+		So(m.Validate(), ShouldBeNil)
+
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		m.ServeHTTP(resp, req)
+		So(resp.Body.String(), ShouldEqual, "ok")
+
+		m.Group("/api", func() {
+			m.Get("/missing", func() {}, func(int) {})
+		})
+		err = m.Validate()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "GET /api/missing: handler #1")
+		So(err.Error(), ShouldContainSubstring, "Value not found for type int")
+	})
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const defaultCompressionLevel = flate.BestSpeed
+
+// deflateTail is appended to a compressed message before inflating, it contains the
+// stripped empty block (RFC 7692 section 7.2.2) and a final empty block to end the stream.
+const deflateTail = "\x00\x00\xff\xff\x01\x00\x00\xff\xff"
+
+// negotiateDeflate returns true if client offers permessage-deflate with parameters
+// the server can accept. Context takeover is always disabled on both sides.
+func negotiateDeflate(h http.Header) bool {
+	for _, v := range h["Sec-Websocket-Extensions"] {
+	offers:
+		for _, offer := range strings.Split(v, ",") {
+			params := strings.Split(offer, ";")
+			if strings.TrimSpace(params[0]) != "permessage-deflate" {
+				continue
+			}
+			for _, param := range params[1:] {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				switch kv[0] {
+				case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+				case "server_max_window_bits":
+					// compress/flate always uses a 32K window.
+					if len(kv) != 2 {
+						continue offers
+					}
+					if bits, err := strconv.Atoi(strings.Trim(kv[1], `"`)); err != nil || bits != 15 {
+						continue offers
+					}
+				default:
+					continue offers
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// decompress inflates a message payload, limit 0 means no limit.
+func decompress(p []byte, limit int64) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(p), strings.NewReader(deflateTail)))
+	defer r.Close()
+
+	if limit <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrReadLimit
+	}
+	return data, nil
+}
+
+// truncWriter holds back the last 4 bytes written, which is the
+// 0x00 0x00 0xff 0xff tail produced by flushing the flate writer.
+type truncWriter struct {
+	w    io.Writer
+	tail []byte
+}
+
+func (w *truncWriter) Write(p []byte) (int, error) {
+	buf := append(w.tail, p...)
+	if len(buf) <= 4 {
+		w.tail = buf
+		return len(p), nil
+	}
+	n := len(buf) - 4
+	w.tail = append([]byte{}, buf[n:]...)
+	if _, err := w.w.Write(buf[:n]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// flateWriter compresses a message into the underlying messageWriter.
+type flateWriter struct {
+	mw *messageWriter
+	tw *truncWriter
+	fw *flate.Writer
+}
+
+func newFlateWriter(mw *messageWriter, level int) io.WriteCloser {
+	tw := &truncWriter{w: mw}
+	fw, err := flate.NewWriter(tw, level)
+	if err != nil {
+		fw, _ = flate.NewWriter(tw, defaultCompressionLevel)
+	}
+	return &flateWriter{mw, tw, fw}
+}
+
+func (w *flateWriter) Write(p []byte) (int, error) {
+	return w.fw.Write(p)
+}
+
+func (w *flateWriter) Close() error {
+	err := w.fw.Flush()
+	if err == nil && !bytes.Equal(w.tw.tail, []byte{0, 0, 0xff, 0xff}) {
+		err = io.ErrShortWrite
+	}
+	if cerr := w.mw.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
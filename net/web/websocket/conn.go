@@ -0,0 +1,656 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types defined by RFC 6455 section 11.8.
+const (
+	// TextMessage denotes a UTF-8 encoded text message.
+	TextMessage = 1
+	// BinaryMessage denotes a binary data message.
+	BinaryMessage = 2
+	// CloseMessage denotes a close control message, payload is built by FormatCloseMessage.
+	CloseMessage = 8
+	// PingMessage denotes a ping control message.
+	PingMessage = 9
+	// PongMessage denotes a pong control message.
+	PongMessage = 10
+
+	continuationFrame = 0
+)
+
+// Close codes defined by RFC 6455 section 11.7.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+	CloseTLSHandshake            = 1015
+)
+
+const (
+	finalBit = 1 << 7
+	rsv1Bit  = 1 << 6
+	rsv2Bit  = 1 << 5
+	rsv3Bit  = 1 << 4
+	maskBit  = 1 << 7
+
+	maxControlPayloadSize = 125
+)
+
+var (
+	// ErrCloseSent is returned when writing to a connection that has sent a close message.
+	ErrCloseSent = errors.New("websocket: close sent")
+	// ErrReadLimit is returned when a message exceeds MaxMessageSize.
+	ErrReadLimit = errors.New("websocket: read limit exceeded")
+)
+
+// CloseError represents a close message received from peer.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return "websocket: close " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// IsCloseError returns true if err is a *CloseError with one of given codes.
+// It returns true for any close code when no code is given.
+func IsCloseError(err error, codes ...int) bool {
+	e, ok := err.(*CloseError)
+	if !ok {
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatCloseMessage formats close code and text as payload of close message.
+// CloseNoStatusReceived results in an empty payload.
+func FormatCloseMessage(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	buf := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], text)
+	return buf
+}
+
+// isValidReceivedCloseCode returns true if the code is allowed in a close frame.
+func isValidReceivedCloseCode(code int) bool {
+	switch code {
+	case CloseNoStatusReceived, CloseAbnormalClosure, CloseTLSHandshake:
+		return false
+	}
+	return (code >= 1000 && code <= 1011) || (code >= 3000 && code <= 4999)
+}
+
+// Conn represents a WebSocket connection.
+//
+// Reading methods must not be called concurrently, writing methods
+// are safe to be called from multiple goroutines.
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	req         *http.Request
+	subprotocol string
+
+	// Write states, protected by wmu.
+	wmu              sync.Mutex
+	closeSent        bool
+	writeBufferSize  int
+	compress         bool
+	writeCompress    bool
+	compressionLevel int
+
+	// Read states.
+	readLimit    int64
+	readErr      error
+	pingHandler  func(string) error
+	pongHandler  func(string) error
+	closeHandler func(int, string) error
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newConn(netConn net.Conn, br *bufio.Reader, req *http.Request, subprotocol string, compress bool, opt Options) *Conn {
+	if br == nil {
+		br = bufio.NewReaderSize(netConn, opt.ReadBufferSize)
+	}
+	c := &Conn{
+		conn:             netConn,
+		br:               br,
+		req:              req,
+		subprotocol:      subprotocol,
+		writeBufferSize:  opt.WriteBufferSize,
+		compress:         compress,
+		writeCompress:    compress,
+		compressionLevel: opt.CompressionLevel,
+		readLimit:        opt.MaxMessageSize,
+		done:             make(chan struct{}),
+	}
+	c.SetPingHandler(nil)
+	c.SetPongHandler(nil)
+	c.SetCloseHandler(nil)
+	return c
+}
+
+// Request returns the handshake request.
+func (c *Conn) Request() *http.Request {
+	return c.req
+}
+
+// Subprotocol returns the negotiated subprotocol, empty if none.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Compressed returns true if permessage-deflate has been negotiated.
+func (c *Conn) Compressed() bool {
+	return c.compress
+}
+
+// RemoteAddr returns the remote network address of underlying connection.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// LocalAddr returns the local network address of underlying connection.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// SetReadDeadline sets the read deadline of underlying connection.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of underlying connection.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit sets the max size of a message read from peer, 0 or negative means no limit.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// EnableWriteCompression enables or disables compression of following messages,
+// it has no effect if permessage-deflate was not negotiated.
+func (c *Conn) EnableWriteCompression(enable bool) {
+	c.wmu.Lock()
+	c.writeCompress = enable && c.compress
+	c.wmu.Unlock()
+}
+
+// SetPingHandler sets handler for ping messages, default handler replies a pong.
+func (c *Conn) SetPingHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(appData string) error {
+			err := c.WriteControl(PongMessage, []byte(appData), time.Now().Add(time.Second))
+			if err == ErrCloseSent {
+				return nil
+			}
+			return err
+		}
+	}
+	c.pingHandler = h
+}
+
+// SetPongHandler sets handler for pong messages, default handler does nothing.
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(string) error { return nil }
+	}
+	c.pongHandler = h
+}
+
+// SetCloseHandler sets handler for close messages, default handler echoes the close code.
+// ReadMessage returns a *CloseError after the handler is called.
+func (c *Conn) SetCloseHandler(h func(code int, text string) error) {
+	if h == nil {
+		h = func(code int, text string) error {
+			c.WriteControl(CloseMessage, FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+			return nil
+		}
+	}
+	c.closeHandler = h
+}
+
+// Close closes the underlying connection without sending close message.
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// keepalive sends ping messages periodically and drops connection without pong.
+func (c *Conn) keepalive(interval time.Duration) {
+	c.conn.SetReadDeadline(time.Now().Add(2 * interval))
+	pongHandler := c.pongHandler
+	c.pongHandler = func(appData string) error {
+		c.conn.SetReadDeadline(time.Now().Add(2 * interval))
+		return pongHandler(appData)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				if err := c.WriteControl(PingMessage, nil, time.Now().Add(interval)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+//  __      __        .__  __
+// /  \    /  \_______|__|/  |_  ____
+// \   \/\/   /\_  __ \  \   __\/ __ \
+//  \        /  |  | \/  ||  | \  ___/
+//   \__/\  /   |__|  |__||__|  \___  >
+//        \/                        \/
+
+// writeFrame writes a single frame, caller must hold wmu.
+func (c *Conn) writeFrame(final, rsv1 bool, opcode int, payload []byte) error {
+	if c.closeSent {
+		return ErrCloseSent
+	}
+
+	header := make([]byte, 0, 10)
+	b0 := byte(opcode)
+	if final {
+		b0 |= finalBit
+	}
+	if rsv1 {
+		b0 |= rsv1Bit
+	}
+	header = append(header, b0)
+
+	// Frames sent from server are never masked.
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 65535:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+	return nil
+}
+
+// WriteControl writes a control message with given deadline.
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType != CloseMessage && messageType != PingMessage && messageType != PongMessage {
+		return fmt.Errorf("websocket: invalid control message type %d", messageType)
+	}
+	if len(data) > maxControlPayloadSize {
+		return errors.New("websocket: control message payload too big")
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	c.conn.SetWriteDeadline(deadline)
+	defer c.conn.SetWriteDeadline(time.Time{})
+	return c.writeFrame(true, false, messageType, data)
+}
+
+// WriteMessage writes a complete text or binary message, or a control message.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType == CloseMessage || messageType == PingMessage || messageType == PongMessage {
+		return c.WriteControl(messageType, data, time.Time{})
+	}
+
+	w, err := c.NextWriter(messageType)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// WriteJSON writes JSON encoding of v as a text message.
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// NextWriter returns a writer for the next text or binary message.
+// Data is sent as fragments of WriteBufferSize, the message is finished by closing the writer.
+// Other writes are blocked until the writer is closed.
+func (c *Conn) NextWriter(messageType int) (io.WriteCloser, error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return nil, fmt.Errorf("websocket: invalid data message type %d", messageType)
+	}
+
+	c.wmu.Lock()
+	if c.closeSent {
+		c.wmu.Unlock()
+		return nil, ErrCloseSent
+	}
+
+	w := &messageWriter{c: c, opcode: messageType, compressed: c.writeCompress}
+	if w.compressed {
+		return newFlateWriter(w, c.compressionLevel), nil
+	}
+	return w, nil
+}
+
+// messageWriter splits a message into frames, it holds wmu until closed.
+type messageWriter struct {
+	c          *Conn
+	opcode     int
+	compressed bool
+	buf        []byte
+	err        error
+	closed     bool
+}
+
+func (w *messageWriter) flushFrame(final bool, payload []byte) error {
+	// RSV1 is only set on the first frame of a compressed message.
+	err := w.c.writeFrame(final, w.compressed && w.opcode != continuationFrame, w.opcode, payload)
+	w.opcode = continuationFrame
+	return err
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("websocket: write to closed writer")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buf = append(w.buf, p...)
+	for len(w.buf) > w.c.writeBufferSize {
+		if w.err = w.flushFrame(false, w.buf[:w.c.writeBufferSize]); w.err != nil {
+			return 0, w.err
+		}
+		w.buf = w.buf[w.c.writeBufferSize:]
+	}
+	return len(p), nil
+}
+
+func (w *messageWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.c.wmu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.flushFrame(true, w.buf)
+}
+
+// __________                   .___
+// \______   \ ____ _____     __| _/
+//  |       _// __ \\__  \   / __ |
+//  |    |   \  ___/ / __ \_/ /_/ |
+//  |____|_  /\___  >____  /\____ |
+//         \/     \/     \/      \/
+
+type frameHeader struct {
+	final  bool
+	rsv1   bool
+	opcode int
+	length int64
+	mask   [4]byte
+}
+
+func (c *Conn) handleProtocolError(message string) error {
+	c.WriteControl(CloseMessage, FormatCloseMessage(CloseProtocolError, message), time.Now().Add(time.Second))
+	return errors.New("websocket: " + message)
+}
+
+func (c *Conn) readFrameHeader() (h frameHeader, err error) {
+	var p [8]byte
+	if _, err = io.ReadFull(c.br, p[:2]); err != nil {
+		return h, err
+	}
+
+	h.final = p[0]&finalBit != 0
+	h.rsv1 = p[0]&rsv1Bit != 0
+	h.opcode = int(p[0] & 0xf)
+	masked := p[1]&maskBit != 0
+	h.length = int64(p[1] & 0x7f)
+
+	if p[0]&(rsv2Bit|rsv3Bit) != 0 {
+		return h, c.handleProtocolError("unexpected reserved bits")
+	}
+	switch h.opcode {
+	case CloseMessage, PingMessage, PongMessage:
+		if h.length > maxControlPayloadSize {
+			return h, c.handleProtocolError("control frame length > 125")
+		}
+		if !h.final {
+			return h, c.handleProtocolError("control frame not final")
+		}
+		if h.rsv1 {
+			return h, c.handleProtocolError("control frame with RSV1 set")
+		}
+	case TextMessage, BinaryMessage:
+		if h.rsv1 && !c.compress {
+			return h, c.handleProtocolError("RSV1 set without compression negotiated")
+		}
+	case continuationFrame:
+		if h.rsv1 {
+			return h, c.handleProtocolError("continuation frame with RSV1 set")
+		}
+	default:
+		return h, c.handleProtocolError("unknown opcode " + strconv.Itoa(h.opcode))
+	}
+	// Frames sent from client must be masked.
+	if !masked {
+		return h, c.handleProtocolError("client frame not masked")
+	}
+
+	switch h.length {
+	case 126:
+		if _, err = io.ReadFull(c.br, p[:2]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(p[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.br, p[:8]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint64(p[:8]))
+		if h.length < 0 {
+			return h, c.handleProtocolError("invalid payload length")
+		}
+	}
+
+	_, err = io.ReadFull(c.br, h.mask[:])
+	return h, err
+}
+
+func (c *Conn) readPayload(h frameHeader) ([]byte, error) {
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return nil, err
+	}
+	for i := range payload {
+		payload[i] ^= h.mask[i%4]
+	}
+	return payload, nil
+}
+
+// handleControl processes control frame and returns non-nil error on close.
+func (c *Conn) handleControl(h frameHeader, payload []byte) error {
+	switch h.opcode {
+	case PingMessage:
+		return c.pingHandler(string(payload))
+	case PongMessage:
+		return c.pongHandler(string(payload))
+	}
+
+	code, text := CloseNoStatusReceived, ""
+	if len(payload) == 1 {
+		return c.handleProtocolError("invalid close payload")
+	} else if len(payload) >= 2 {
+		code = int(binary.BigEndian.Uint16(payload))
+		if !isValidReceivedCloseCode(code) {
+			return c.handleProtocolError("invalid close code " + strconv.Itoa(code))
+		}
+		text = string(payload[2:])
+		if !utf8.ValidString(text) {
+			return c.handleProtocolError("invalid utf8 payload in close frame")
+		}
+	}
+	if err := c.closeHandler(code, text); err != nil {
+		return err
+	}
+	return &CloseError{Code: code, Text: text}
+}
+
+// ReadMessage reads the next text or binary message, fragments are reassembled and
+// decompressed. Control messages received in between are dispatched to their handlers.
+// Once an error is returned, all subsequent calls return the same error.
+func (c *Conn) ReadMessage() (messageType int, p []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	messageType, p, err = c.readMessage()
+	if err != nil {
+		c.readErr = err
+	}
+	return messageType, p, err
+}
+
+func (c *Conn) readMessage() (int, []byte, error) {
+	var (
+		messageType int
+		compressed  bool
+		message     []byte
+	)
+	for {
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+		// Check the declared length before allocating its payload.
+		if c.readLimit > 0 && int64(len(message))+h.length > c.readLimit {
+			c.WriteControl(CloseMessage, FormatCloseMessage(CloseMessageTooBig, ""), time.Now().Add(time.Second))
+			return 0, nil, ErrReadLimit
+		}
+		payload, err := c.readPayload(h)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch h.opcode {
+		case CloseMessage, PingMessage, PongMessage:
+			if err = c.handleControl(h, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.handleProtocolError("continuation frame without start")
+			}
+		default:
+			if messageType != 0 {
+				return 0, nil, c.handleProtocolError("data frame before previous message finished")
+			}
+			messageType = h.opcode
+			compressed = h.rsv1
+		}
+
+		message = append(message, payload...)
+		if !h.final {
+			continue
+		}
+
+		if compressed {
+			if message, err = decompress(message, c.readLimit); err != nil {
+				if err == ErrReadLimit {
+					c.WriteControl(CloseMessage, FormatCloseMessage(CloseMessageTooBig, ""), time.Now().Add(time.Second))
+					return 0, nil, err
+				}
+				return 0, nil, c.handleProtocolError("invalid compressed payload")
+			}
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			c.WriteControl(CloseMessage, FormatCloseMessage(CloseInvalidFramePayloadData, ""), time.Now().Add(time.Second))
+			return 0, nil, errors.New("websocket: invalid utf8 payload in text message")
+		}
+		return messageType, message, nil
+	}
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v.
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, p, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(p, v)
+}
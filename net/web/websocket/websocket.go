@@ -0,0 +1,280 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package websocket is a middleware that upgrades requests to RFC 6455 WebSocket connections.
+//
+// Upgrade is used as a route handler, the following handlers receive the *websocket.Conn
+// through dependency injection:
+//
+//	m.Get("/ws", websocket.Upgrade(), func(conn *websocket.Conn) {
+//		for {
+//			typ, p, err := conn.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			conn.WriteMessage(typ, p)
+//		}
+//	})
+//
+// Middlewares registered before the upgrade (session, csrf, etc.) run against the handshake
+// request as usual, cookies they set are sent with the handshake response.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"landzero.net/x/net/web"
+)
+
+const _VERSION = "0.1.0"
+
+func Version() string {
+	return _VERSION
+}
+
+// keyGUID is the magic string defined by RFC 6455 section 1.3.
+const keyGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Options represents a struct for specifying configuration options for the websocket middleware.
+type Options struct {
+	// Subprotocols lists server supported protocols in order of preference.
+	Subprotocols []string
+	// CheckOrigin returns true if the request Origin header is acceptable.
+	// Default only allows requests without Origin or with Origin matches Host.
+	CheckOrigin func(r *http.Request) bool
+	// EnableCompression negotiates permessage-deflate extension with client.
+	EnableCompression bool
+	// CompressionLevel used for flate writer. Default is flate.BestSpeed.
+	CompressionLevel int
+	// HandshakeTimeout limits time for writing handshake response. Default is 10s.
+	HandshakeTimeout time.Duration
+	// ReadBufferSize is the size of frame reader buffer. Default is 4096.
+	ReadBufferSize int
+	// WriteBufferSize is the max payload size of a single frame written by NextWriter,
+	// bigger messages are fragmented. Default is 4096.
+	WriteBufferSize int
+	// MaxMessageSize limits size of a message read from peer, frames declaring a bigger
+	// length are rejected before reading their payload. Default is 32MB, negative means no limit.
+	MaxMessageSize int64
+	// PingInterval is the interval of sending ping to peer, 0 disables keepalive.
+	// Connection is considered dead if no pong received in twice the interval.
+	PingInterval time.Duration
+	// ErrorFunc replies to the request when handshake fails.
+	ErrorFunc func(w http.ResponseWriter, status int, reason string)
+}
+
+// defaultMaxMessageSize is the default limit of a message read from peer.
+const defaultMaxMessageSize = 32 << 20
+
+func prepareOptions(options []Options) Options {
+	var opt Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+
+	// Defaults.
+	if opt.CheckOrigin == nil {
+		opt.CheckOrigin = checkSameOrigin
+	}
+	if opt.CompressionLevel == 0 {
+		opt.CompressionLevel = defaultCompressionLevel
+	}
+	if opt.HandshakeTimeout == 0 {
+		opt.HandshakeTimeout = 10 * time.Second
+	}
+	if opt.ReadBufferSize == 0 {
+		opt.ReadBufferSize = 4096
+	}
+	if opt.WriteBufferSize == 0 {
+		opt.WriteBufferSize = 4096
+	}
+	if opt.MaxMessageSize == 0 {
+		opt.MaxMessageSize = defaultMaxMessageSize
+	}
+	if opt.ErrorFunc == nil {
+		opt.ErrorFunc = func(w http.ResponseWriter, status int, reason string) {
+			http.Error(w, reason, status)
+		}
+	}
+	return opt
+}
+
+// checkSameOrigin returns true if Origin header is absent or its host equals to request host.
+func checkSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// headerContainsToken returns true if comma-separated header values contain given token.
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsWebSocketUpgrade returns true if the request asks for a WebSocket upgrade.
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// ComputeAcceptKey computes value of Sec-WebSocket-Accept header for given Sec-WebSocket-Key.
+func ComputeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + keyGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func selectSubprotocol(r *http.Request, supported []string) string {
+	for _, s := range supported {
+		if headerContainsToken(r.Header, "Sec-WebSocket-Protocol", s) {
+			return s
+		}
+	}
+	return ""
+}
+
+// hopHeaders are not copied from web.Context to handshake response.
+var hopHeaders = map[string]bool{
+	"Upgrade":                  true,
+	"Connection":               true,
+	"Content-Length":           true,
+	"Content-Type":             true,
+	"Sec-Websocket-Accept":     true,
+	"Sec-Websocket-Protocol":   true,
+	"Sec-Websocket-Extensions": true,
+}
+
+// upgrade performs the server side handshake and returns the WebSocket connection.
+// Failures are replied to client with ErrorFunc and returned as nil connection.
+func upgrade(ctx *web.Context, opt Options) *Conn {
+	r := ctx.Req.Request
+	if r.Method != "GET" {
+		opt.ErrorFunc(ctx.Resp, http.StatusMethodNotAllowed, "websocket: method not GET")
+		return nil
+	}
+	if !IsWebSocketUpgrade(r) {
+		ctx.Resp.Header().Set("Upgrade", "websocket")
+		opt.ErrorFunc(ctx.Resp, http.StatusUpgradeRequired, "websocket: not a websocket handshake")
+		return nil
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		ctx.Resp.Header().Set("Sec-WebSocket-Version", "13")
+		opt.ErrorFunc(ctx.Resp, http.StatusUpgradeRequired, "websocket: unsupported version")
+		return nil
+	}
+	key := strings.TrimSpace(r.Header.Get("Sec-Websocket-Key"))
+	if k, err := base64.StdEncoding.DecodeString(key); err != nil || len(k) != 16 {
+		opt.ErrorFunc(ctx.Resp, http.StatusBadRequest, "websocket: invalid Sec-WebSocket-Key")
+		return nil
+	}
+	if !opt.CheckOrigin(r) {
+		opt.ErrorFunc(ctx.Resp, http.StatusForbidden, "websocket: origin not allowed")
+		return nil
+	}
+
+	hijacker, ok := ctx.Resp.(http.Hijacker)
+	if !ok {
+		opt.ErrorFunc(ctx.Resp, http.StatusInternalServerError, "websocket: response does not implement http.Hijacker")
+		return nil
+	}
+
+	subprotocol := selectSubprotocol(r, opt.Subprotocols)
+	compress := opt.EnableCompression && negotiateDeflate(r.Header)
+
+	// Collect headers set by previous middlewares before hijacking, e.g. session cookie.
+	extra := make(http.Header)
+	for k, v := range ctx.Resp.Header() {
+		if !hopHeaders[k] {
+			extra[k] = v
+		}
+	}
+
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		opt.ErrorFunc(ctx.Resp, http.StatusInternalServerError, "websocket: "+err.Error())
+		return nil
+	}
+
+	buf := make([]byte, 0, 256)
+	buf = append(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: "...)
+	buf = append(buf, ComputeAcceptKey(key)...)
+	buf = append(buf, "\r\n"...)
+	if len(subprotocol) > 0 {
+		buf = append(buf, "Sec-WebSocket-Protocol: "+subprotocol+"\r\n"...)
+	}
+	if compress {
+		buf = append(buf, "Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n"...)
+	}
+	for k, vs := range extra {
+		for _, v := range vs {
+			buf = append(buf, k+": "+strings.NewReplacer("\r", "", "\n", "").Replace(v)+"\r\n"...)
+		}
+	}
+	buf = append(buf, "\r\n"...)
+
+	netConn.SetWriteDeadline(time.Now().Add(opt.HandshakeTimeout))
+	if _, err = netConn.Write(buf); err != nil {
+		netConn.Close()
+		return nil
+	}
+	netConn.SetWriteDeadline(time.Time{})
+
+	var br *bufio.Reader
+	if brw != nil && brw.Reader.Buffered() > 0 {
+		// Client may send frames right after handshake request.
+		br = brw.Reader
+	}
+	return newConn(netConn, br, r, subprotocol, compress, opt)
+}
+
+// Upgrade returns a handler that upgrades the request to a WebSocket connection,
+// maps *websocket.Conn to the following handlers and closes it when they return.
+func Upgrade(options ...Options) web.Handler {
+	opt := prepareOptions(options)
+	return web.Mapping(func(ctx *web.Context) {
+		conn := upgrade(ctx, opt)
+		if conn == nil {
+			return
+		}
+		defer conn.Close()
+
+		if opt.PingInterval > 0 {
+			conn.keepalive(opt.PingInterval)
+		}
+
+		ctx.Map(conn)
+		ctx.Next()
+
+		conn.WriteControl(CloseMessage, FormatCloseMessage(CloseNormalClosure, ""), time.Now().Add(time.Second))
+	}, &Conn{})
+}
@@ -0,0 +1,333 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+	"landzero.net/x/net/web/session"
+)
+
+// testClient is a minimal client side implementation for testing.
+type testClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dial(url string, header http.Header) (*testClient, error) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		return nil, err
+	}
+	req, _ := http.NewRequest("GET", url+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err = req.Write(conn); err != nil {
+		return nil, err
+	}
+	c := &testClient{conn: conn, br: bufio.NewReader(conn)}
+	c.resp, err = http.ReadResponse(c.br, req)
+	return c, err
+}
+
+func (c *testClient) writeFrame(final, rsv1 bool, opcode int, payload []byte) {
+	b0 := byte(opcode)
+	if final {
+		b0 |= finalBit
+	}
+	if rsv1 {
+		b0 |= rsv1Bit
+	}
+	buf := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 65535:
+		buf = append(buf, maskBit|126, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(buf[2:], uint64(n))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	buf = append(buf, mask[:]...)
+	for i := range payload {
+		buf = append(buf, payload[i]^mask[i%4])
+	}
+	c.conn.Write(buf)
+}
+
+func (c *testClient) readFrame() (final, rsv1 bool, opcode int, payload []byte) {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var p [8]byte
+	io.ReadFull(c.br, p[:2])
+	final = p[0]&finalBit != 0
+	rsv1 = p[0]&rsv1Bit != 0
+	opcode = int(p[0] & 0xf)
+	n := int(p[1] & 0x7f)
+	switch n {
+	case 126:
+		io.ReadFull(c.br, p[:2])
+		n = int(binary.BigEndian.Uint16(p[:2]))
+	case 127:
+		io.ReadFull(c.br, p[:8])
+		n = int(binary.BigEndian.Uint64(p[:8]))
+	}
+	payload = make([]byte, n)
+	io.ReadFull(c.br, payload)
+	return final, rsv1, opcode, payload
+}
+
+func echoServer(opt Options) *httptest.Server {
+	m := web.New()
+	m.Use(session.Sessioner())
+	m.Get("/ws", Upgrade(opt), func(conn *Conn) {
+		for {
+			typ, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(typ, p)
+		}
+	})
+	return httptest.NewServer(m)
+}
+
+func Test_Version(t *testing.T) {
+	Convey("Check package version", t, func() {
+		So(Version(), ShouldEqual, _VERSION)
+	})
+}
+
+func Test_Handshake(t *testing.T) {
+	Convey("Compute accept key", t, func() {
+		So(ComputeAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="), ShouldEqual, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+	})
+
+	Convey("Upgrade request", t, func() {
+		srv := echoServer(Options{Subprotocols: []string{"chat", "superchat"}})
+		defer srv.Close()
+
+		c, err := dial(srv.URL, http.Header{"Sec-Websocket-Protocol": {"superchat, chat"}})
+		So(err, ShouldBeNil)
+		defer c.conn.Close()
+
+		So(c.resp.StatusCode, ShouldEqual, http.StatusSwitchingProtocols)
+		So(c.resp.Header.Get("Sec-WebSocket-Accept"), ShouldEqual, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+		So(c.resp.Header.Get("Sec-WebSocket-Protocol"), ShouldEqual, "chat")
+		// Session cookie is sent with handshake response.
+		So(c.resp.Header.Get("Set-Cookie"), ShouldContainSubstring, "session=")
+	})
+
+	Convey("Reject invalid requests", t, func() {
+		m := web.New()
+		m.Get("/ws", Upgrade(), func(conn *Conn) {})
+
+		Convey("Not an upgrade request", func() {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/ws", nil)
+			So(err, ShouldBeNil)
+			m.ServeHTTP(resp, req)
+			So(resp.Code, ShouldEqual, http.StatusUpgradeRequired)
+		})
+
+		Convey("Unsupported version", func() {
+			resp := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/ws", nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("Connection", "keep-alive, Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Version", "8")
+			m.ServeHTTP(resp, req)
+			So(resp.Code, ShouldEqual, http.StatusUpgradeRequired)
+			So(resp.Header().Get("Sec-WebSocket-Version"), ShouldEqual, "13")
+		})
+
+		Convey("Cross origin", func() {
+			srv := httptest.NewServer(m)
+			defer srv.Close()
+
+			c, err := dial(srv.URL, http.Header{"Origin": {"http://evil.com"}})
+			So(err, ShouldBeNil)
+			defer c.conn.Close()
+			So(c.resp.StatusCode, ShouldEqual, http.StatusForbidden)
+		})
+	})
+}
+
+func Test_Conn(t *testing.T) {
+	Convey("Echo messages", t, func() {
+		srv := echoServer(Options{WriteBufferSize: 16})
+		defer srv.Close()
+
+		c, err := dial(srv.URL, nil)
+		So(err, ShouldBeNil)
+		defer c.conn.Close()
+
+		Convey("Single frame", func() {
+			c.writeFrame(true, false, TextMessage, []byte("hello"))
+			final, _, opcode, payload := c.readFrame()
+			So(final, ShouldBeTrue)
+			So(opcode, ShouldEqual, TextMessage)
+			So(string(payload), ShouldEqual, "hello")
+		})
+
+		Convey("Fragmented frames with ping in between", func() {
+			c.writeFrame(false, false, BinaryMessage, []byte("hello "))
+			c.writeFrame(true, false, PingMessage, []byte("ping"))
+			c.writeFrame(true, false, continuationFrame, []byte("world, this is a long message"))
+
+			_, _, opcode, payload := c.readFrame()
+			So(opcode, ShouldEqual, PongMessage)
+			So(string(payload), ShouldEqual, "ping")
+
+			// Echoed message is fragmented by WriteBufferSize.
+			var message []byte
+			final, _, opcode, payload := c.readFrame()
+			So(final, ShouldBeFalse)
+			So(opcode, ShouldEqual, BinaryMessage)
+			message = append(message, payload...)
+			for !final {
+				final, _, opcode, payload = c.readFrame()
+				So(opcode, ShouldEqual, continuationFrame)
+				message = append(message, payload...)
+			}
+			So(string(message), ShouldEqual, "hello world, this is a long message")
+		})
+
+		Convey("Close handshake", func() {
+			c.writeFrame(true, false, CloseMessage, FormatCloseMessage(CloseGoingAway, "bye"))
+			_, _, opcode, payload := c.readFrame()
+			So(opcode, ShouldEqual, CloseMessage)
+			So(binary.BigEndian.Uint16(payload), ShouldEqual, CloseGoingAway)
+		})
+
+		Convey("Protocol error on unmasked frame", func() {
+			c.conn.Write([]byte{finalBit | TextMessage, 2, 'h', 'i'})
+			_, _, opcode, payload := c.readFrame()
+			So(opcode, ShouldEqual, CloseMessage)
+			So(binary.BigEndian.Uint16(payload), ShouldEqual, CloseProtocolError)
+		})
+
+		Convey("Invalid UTF-8 text", func() {
+			c.writeFrame(true, false, TextMessage, []byte{0xff, 0xfe})
+			_, _, opcode, payload := c.readFrame()
+			So(opcode, ShouldEqual, CloseMessage)
+			So(binary.BigEndian.Uint16(payload), ShouldEqual, CloseInvalidFramePayloadData)
+		})
+	})
+
+	Convey("Message size limit", t, func() {
+		srv := echoServer(Options{MaxMessageSize: 4})
+		defer srv.Close()
+
+		c, err := dial(srv.URL, nil)
+		So(err, ShouldBeNil)
+		defer c.conn.Close()
+
+		c.writeFrame(true, false, TextMessage, []byte("too long"))
+		_, _, opcode, payload := c.readFrame()
+		So(opcode, ShouldEqual, CloseMessage)
+		So(binary.BigEndian.Uint16(payload), ShouldEqual, CloseMessageTooBig)
+	})
+
+	Convey("Oversized frame is rejected by default before its payload arrives", t, func() {
+		srv := echoServer(Options{})
+		defer srv.Close()
+
+		c, err := dial(srv.URL, nil)
+		So(err, ShouldBeNil)
+		defer c.conn.Close()
+
+		// Only the header is sent, it declares a 1TB payload.
+		header := []byte{finalBit | BinaryMessage, maskBit | 127, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}
+		binary.BigEndian.PutUint64(header[2:10], 1<<40)
+		c.conn.Write(header)
+		_, _, opcode, payload := c.readFrame()
+		So(opcode, ShouldEqual, CloseMessage)
+		So(binary.BigEndian.Uint16(payload), ShouldEqual, CloseMessageTooBig)
+	})
+
+	Convey("Handler returns", t, func() {
+		m := web.New()
+		m.Get("/ws", Upgrade(), func(conn *Conn) {
+			conn.WriteJSON(map[string]string{"hello": "world"})
+		})
+		srv := httptest.NewServer(m)
+		defer srv.Close()
+
+		c, err := dial(srv.URL, nil)
+		So(err, ShouldBeNil)
+		defer c.conn.Close()
+
+		_, _, opcode, payload := c.readFrame()
+		So(opcode, ShouldEqual, TextMessage)
+		So(string(payload), ShouldEqual, `{"hello":"world"}`)
+
+		_, _, opcode, payload = c.readFrame()
+		So(opcode, ShouldEqual, CloseMessage)
+		So(binary.BigEndian.Uint16(payload), ShouldEqual, CloseNormalClosure)
+	})
+}
+
+func Test_Compression(t *testing.T) {
+	Convey("Negotiate permessage-deflate", t, func() {
+		So(negotiateDeflate(http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"}}), ShouldBeTrue)
+		So(negotiateDeflate(http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; server_max_window_bits=10"}}), ShouldBeFalse)
+		So(negotiateDeflate(http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; server_max_window_bits=10, permessage-deflate"}}), ShouldBeTrue)
+		So(negotiateDeflate(http.Header{"Sec-Websocket-Extensions": {"x-webkit-deflate-frame"}}), ShouldBeFalse)
+	})
+
+	Convey("Echo compressed messages", t, func() {
+		srv := echoServer(Options{EnableCompression: true})
+		defer srv.Close()
+
+		c, err := dial(srv.URL, http.Header{"Sec-Websocket-Extensions": {"permessage-deflate"}})
+		So(err, ShouldBeNil)
+		defer c.conn.Close()
+		So(c.resp.Header.Get("Sec-WebSocket-Extensions"), ShouldStartWith, "permessage-deflate")
+
+		text := strings.Repeat("compress me ", 100)
+		buf := new(bytes.Buffer)
+		fw, _ := flate.NewWriter(buf, flate.BestCompression)
+		fw.Write([]byte(text))
+		fw.Flush()
+		c.writeFrame(true, true, TextMessage, bytes.TrimSuffix(buf.Bytes(), []byte{0, 0, 0xff, 0xff}))
+
+		_, rsv1, opcode, payload := c.readFrame()
+		So(rsv1, ShouldBeTrue)
+		So(opcode, ShouldEqual, TextMessage)
+		p, err := decompress(payload, 0)
+		So(err, ShouldBeNil)
+		So(string(p), ShouldEqual, text)
+	})
+}
@@ -0,0 +1,232 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package webtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+type file struct {
+	field, filename string
+	content         []byte
+}
+
+// Request builds a request to be sent by Client.
+type Request struct {
+	c       *Client
+	method  string
+	path    string
+	header  http.Header
+	query   url.Values
+	cookies []*http.Cookie
+	session map[interface{}]interface{}
+	csrf    bool
+
+	contentType string
+	body        []byte
+	form        url.Values
+	files       []file
+	err         error
+}
+
+// Header sets a request header.
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// Query adds a query parameter.
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// Cookie adds a cookie to this request only, cookies in jar of client are sent as well.
+func (r *Request) Cookie(name, value string) *Request {
+	r.cookies = append(r.cookies, &http.Cookie{Name: name, Value: value})
+	return r
+}
+
+// Body sets raw body with content type.
+func (r *Request) Body(contentType string, data []byte) *Request {
+	r.contentType = contentType
+	r.body = data
+	return r
+}
+
+// JSON sets body to JSON encoding of v.
+func (r *Request) JSON(v interface{}) *Request {
+	data, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+	}
+	return r.Body("application/json", data)
+}
+
+// FormValue adds a form field, body is encoded as "application/x-www-form-urlencoded",
+// or "multipart/form-data" if any file is attached.
+func (r *Request) FormValue(key, value string) *Request {
+	if r.form == nil {
+		r.form = make(url.Values)
+	}
+	r.form.Add(key, value)
+	return r
+}
+
+// Form adds form fields.
+func (r *Request) Form(values url.Values) *Request {
+	for k, vs := range values {
+		for _, v := range vs {
+			r.FormValue(k, v)
+		}
+	}
+	return r
+}
+
+// File attaches a file, body is encoded as "multipart/form-data".
+func (r *Request) File(field, filename string, content []byte) *Request {
+	r.files = append(r.files, file{field, filename, content})
+	return r
+}
+
+// Session sets a session value before the request is handled,
+// session.Sessioner must be used by the application.
+func (r *Request) Session(key, value interface{}) *Request {
+	if r.session == nil {
+		r.session = make(map[interface{}]interface{})
+	}
+	r.session[key] = value
+	return r
+}
+
+// NoCSRF disables filling CSRF token for this request.
+func (r *Request) NoCSRF() *Request {
+	r.csrf = false
+	return r
+}
+
+func (r *Request) encodeBody() (io.Reader, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	switch {
+	case len(r.files) > 0:
+		buf := new(bytes.Buffer)
+		w := multipart.NewWriter(buf)
+		for k, vs := range r.form {
+			for _, v := range vs {
+				if err := w.WriteField(k, v); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, f := range r.files {
+			fw, err := w.CreateFormFile(f.field, f.filename)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = fw.Write(f.content); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		r.contentType = w.FormDataContentType()
+		return buf, nil
+	case r.form != nil:
+		r.contentType = "application/x-www-form-urlencoded"
+		return strings.NewReader(r.form.Encode()), nil
+	case r.body != nil:
+		return bytes.NewReader(r.body), nil
+	}
+	return nil, nil
+}
+
+// Do sends the request to application and returns the response,
+// cookies set by the response are stored into jar of client.
+func (r *Request) Do() *Response {
+	c := r.c
+	c.t.Helper()
+
+	u, err := url.Parse(c.BaseURL + r.path)
+	if err != nil {
+		c.t.Fatalf("webtest: invalid path %q: %v", r.path, err)
+	}
+	if len(r.query) > 0 {
+		q := u.Query()
+		for k, vs := range r.query {
+			for _, v := range vs {
+				q.Add(k, v)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	body, err := r.encodeBody()
+	if err != nil {
+		c.t.Fatalf("webtest: encode body of %s %s: %v", r.method, r.path, err)
+	}
+	req := httptest.NewRequest(r.method, u.String(), body)
+	for k, vs := range r.header {
+		req.Header[k] = vs
+	}
+	if len(r.contentType) > 0 && len(req.Header.Get("Content-Type")) == 0 {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	for _, cookie := range c.Jar.Cookies(u) {
+		req.AddCookie(cookie)
+	}
+	for _, cookie := range r.cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp := &Response{
+		ResponseRecorder: httptest.NewRecorder(),
+		t:                c.t,
+	}
+	s := &state{
+		session: r.session,
+		csrf:    r.csrf && !isSafeMethod(r.method),
+		resp:    resp,
+	}
+	req = withState(req, s)
+	resp.Request = req
+
+	c.m.ServeHTTP(resp.ResponseRecorder, req)
+
+	for _, msg := range resp.errors {
+		c.t.Errorf("webtest: %s %s: %s", r.method, r.path, msg)
+	}
+	if cookies := resp.Result().Cookies(); len(cookies) > 0 {
+		c.Jar.SetCookies(u, cookies)
+	}
+	return resp
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package webtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Response is the recorded response of a request.
+type Response struct {
+	*httptest.ResponseRecorder
+	t      testing.TB
+	errors []string
+
+	// Request is the request sent to application.
+	Request *http.Request
+	// TemplateSet and Template are names of the last rendered HTML template.
+	TemplateSet string
+	Template    string
+	// TemplateData is data passed to the last rendered HTML template.
+	TemplateData interface{}
+}
+
+// JSON decodes body into v.
+func (r *Response) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body.Bytes(), v)
+}
+
+// JSONPath returns the value at path of JSON body, path is separated by dots and
+// array elements are selected by index, e.g. "data.items.0.name" or "data.items[0].name".
+// Objects are map[string]interface{} and numbers are float64 as decoded by encoding/json.
+func (r *Response) JSONPath(path string) (interface{}, error) {
+	var v interface{}
+	if err := r.JSON(&v); err != nil {
+		return nil, err
+	}
+	return lookupPath(v, path)
+}
+
+func lookupPath(v interface{}, path string) (interface{}, error) {
+	path = strings.Replace(strings.Replace(path, "[", ".", -1), "]", "", -1)
+	if len(path) == 0 {
+		return v, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			next, ok := val[key]
+			if !ok {
+				return nil, fmt.Errorf("key %q is not found", key)
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(val) {
+				return nil, fmt.Errorf("index %q is out of range", key)
+			}
+			v = val[i]
+		default:
+			return nil, fmt.Errorf("cannot select %q from %T", key, v)
+		}
+	}
+	return v, nil
+}
+
+// Data returns value of key in data of the last rendered template, nil if absent.
+func (r *Response) Data(key string) interface{} {
+	switch data := r.TemplateData.(type) {
+	case map[string]interface{}:
+		return data[key]
+	case nil:
+		return nil
+	}
+	val := reflect.ValueOf(r.TemplateData)
+	if val.Kind() == reflect.Map && val.Type().Key().Kind() == reflect.String {
+		if v := val.MapIndex(reflect.ValueOf(key).Convert(val.Type().Key())); v.IsValid() {
+			return v.Interface()
+		}
+	}
+	return nil
+}
+
+// ExpectStatus reports an error if status code is not equal to status.
+func (r *Response) ExpectStatus(status int) *Response {
+	r.t.Helper()
+	if r.Code != status {
+		r.t.Errorf("webtest: %s %s: expect status %d, got %d", r.Request.Method, r.Request.URL.Path, status, r.Code)
+	}
+	return r
+}
+
+// ExpectHeader reports an error if value of header is not equal to value.
+func (r *Response) ExpectHeader(key, value string) *Response {
+	r.t.Helper()
+	if got := r.Header().Get(key); got != value {
+		r.t.Errorf("webtest: %s %s: expect header %s %q, got %q", r.Request.Method, r.Request.URL.Path, key, value, got)
+	}
+	return r
+}
+
+// ExpectBodyContains reports an error if body does not contain s.
+func (r *Response) ExpectBodyContains(s string) *Response {
+	r.t.Helper()
+	if !strings.Contains(r.Body.String(), s) {
+		r.t.Errorf("webtest: %s %s: expect body contains %q, got %q", r.Request.Method, r.Request.URL.Path, s, r.Body.String())
+	}
+	return r
+}
+
+// ExpectJSON reports an error if value at path of JSON body is not equal to value,
+// value is compared after encoding and decoding as JSON, so 1 equals to 1.0.
+func (r *Response) ExpectJSON(path string, value interface{}) *Response {
+	r.t.Helper()
+	got, err := r.JSONPath(path)
+	if err != nil {
+		r.t.Errorf("webtest: %s %s: JSON path %q: %v", r.Request.Method, r.Request.URL.Path, path, err)
+		return r
+	}
+	want, err := normalize(value)
+	if err != nil {
+		r.t.Errorf("webtest: cannot encode %v as JSON: %v", value, err)
+		return r
+	}
+	if !reflect.DeepEqual(got, want) {
+		r.t.Errorf("webtest: %s %s: expect JSON %q to be %v, got %v", r.Request.Method, r.Request.URL.Path, path, want, got)
+	}
+	return r
+}
+
+// ExpectTemplate reports an error if name of the last rendered template is not equal to name.
+func (r *Response) ExpectTemplate(name string) *Response {
+	r.t.Helper()
+	if r.Template != name {
+		r.t.Errorf("webtest: %s %s: expect template %q, got %q", r.Request.Method, r.Request.URL.Path, name, r.Template)
+	}
+	return r
+}
+
+// ExpectData reports an error if value of key in template data is not equal to value.
+func (r *Response) ExpectData(key string, value interface{}) *Response {
+	r.t.Helper()
+	if got := r.Data(key); !reflect.DeepEqual(got, value) {
+		r.t.Errorf("webtest: %s %s: expect data %q to be %v, got %v", r.Request.Method, r.Request.URL.Path, key, value, got)
+	}
+	return r
+}
+
+func normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
@@ -0,0 +1,240 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package webtest is a harness for testing handlers and applications of net/web.
+//
+// Example:
+//
+//	c := webtest.New(t, m)
+//	c.Post("/users").JSON(User{Name: "Joe"}).Do().
+//		ExpectStatus(http.StatusCreated).
+//		ExpectJSON("data.name", "Joe")
+//	c.Get("/users").Do().ExpectTemplate("users/list")
+package webtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"landzero.net/x/net/web"
+	"landzero.net/x/net/web/csrf"
+	"landzero.net/x/net/web/session"
+)
+
+const _VERSION = "0.1.0"
+
+func Version() string {
+	return _VERSION
+}
+
+// Client sends requests to a *web.Web and keeps cookies across calls.
+type Client struct {
+	t testing.TB
+	m *web.Web
+	// BaseURL is the scheme and host of requests. Default is "http://example.com".
+	BaseURL string
+	// Jar keeps cookies set by responses.
+	Jar http.CookieJar
+	// CSRF fills CSRF token into header of unsafe requests automatically. Default is true.
+	CSRF bool
+}
+
+var (
+	installLock sync.Mutex
+	installed   = make(map[*web.Web]bool)
+)
+
+// New returns a client of m, it reports failures of expectations to t.
+// A middleware is appended to m to record rendered templates and to prepare session
+// and CSRF token, so New should be called after global middlewares are used,
+// e.g. Renderer, Sessioner and Csrfer.
+func New(t testing.TB, m *web.Web) *Client {
+	installLock.Lock()
+	if !installed[m] {
+		installed[m] = true
+		m.Use(prepare)
+	}
+	installLock.Unlock()
+
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		t:       t,
+		m:       m,
+		BaseURL: "http://example.com",
+		Jar:     jar,
+		CSRF:    true,
+	}
+}
+
+// Cookie returns value of cookie in jar by name, or empty string if it's not found.
+func (c *Client) Cookie(name string) string {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return ""
+	}
+	for _, cookie := range c.Jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// NewRequest returns a request builder of method and path, path may contain query string.
+func (c *Client) NewRequest(method, path string) *Request {
+	return &Request{
+		c:      c,
+		method: method,
+		path:   path,
+		header: make(http.Header),
+		query:  make(url.Values),
+		csrf:   c.CSRF,
+	}
+}
+
+// Get is a shortcut for c.NewRequest("GET", path).
+func (c *Client) Get(path string) *Request {
+	return c.NewRequest("GET", path)
+}
+
+// Post is a shortcut for c.NewRequest("POST", path).
+func (c *Client) Post(path string) *Request {
+	return c.NewRequest("POST", path)
+}
+
+// Put is a shortcut for c.NewRequest("PUT", path).
+func (c *Client) Put(path string) *Request {
+	return c.NewRequest("PUT", path)
+}
+
+// Patch is a shortcut for c.NewRequest("PATCH", path).
+func (c *Client) Patch(path string) *Request {
+	return c.NewRequest("PATCH", path)
+}
+
+// Delete is a shortcut for c.NewRequest("DELETE", path).
+func (c *Client) Delete(path string) *Request {
+	return c.NewRequest("DELETE", path)
+}
+
+// Head is a shortcut for c.NewRequest("HEAD", path).
+func (c *Client) Head(path string) *Request {
+	return c.NewRequest("HEAD", path)
+}
+
+// Options is a shortcut for c.NewRequest("OPTIONS", path).
+func (c *Client) Options(path string) *Request {
+	return c.NewRequest("OPTIONS", path)
+}
+
+type contextKey struct{}
+
+// state is shared by request and prepare middleware.
+type state struct {
+	session map[interface{}]interface{}
+	csrf    bool
+	resp    *Response
+}
+
+// prepare seeds session, fills CSRF token and records rendered templates.
+func prepare(ctx *web.Context) {
+	s, ok := ctx.Req.Context().Value(contextKey{}).(*state)
+	if !ok {
+		return
+	}
+
+	if len(s.session) > 0 {
+		if sess, ok := getService(ctx, (*session.Store)(nil)).(session.Store); ok {
+			for k, v := range s.session {
+				sess.Set(k, v)
+			}
+		} else {
+			s.resp.errors = append(s.resp.errors, "session is not available, use session.Sessioner before webtest.New")
+		}
+	}
+
+	if s.csrf {
+		if x, ok := getService(ctx, (*csrf.CSRF)(nil)).(csrf.CSRF); ok && len(x.GetToken()) > 0 &&
+			len(ctx.Req.Header.Get(x.GetHeaderName())) == 0 {
+			ctx.Req.Header.Set(x.GetHeaderName(), x.GetToken())
+		}
+	}
+
+	if _, ok := ctx.Render.(*web.DummyRender); !ok && ctx.Render != nil {
+		r := &recordRender{Render: ctx.Render, resp: s.resp}
+		ctx.Render = r
+		ctx.MapTo(r, (*web.Render)(nil))
+	}
+}
+
+// getService returns value mapped into injector by interface pointer type, or nil.
+func getService(ctx *web.Context, ifacePtr interface{}) interface{} {
+	val := ctx.GetVal(reflect.TypeOf(ifacePtr).Elem())
+	if !val.IsValid() {
+		return nil
+	}
+	switch val.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Func:
+		if val.IsNil() {
+			return nil
+		}
+	}
+	return val.Interface()
+}
+
+// recordRender records name and data of rendered templates.
+type recordRender struct {
+	web.Render
+	resp *Response
+}
+
+func (r *recordRender) record(setName, tplName string, data interface{}) {
+	r.resp.TemplateSet = setName
+	r.resp.Template = tplName
+	r.resp.TemplateData = data
+}
+
+func (r *recordRender) HTML(status int, name string, data interface{}, htmlOpt ...web.HTMLOptions) {
+	r.record(web.DEFAULT_TPL_SET_NAME, name, data)
+	r.Render.HTML(status, name, data, htmlOpt...)
+}
+
+func (r *recordRender) HTMLSet(status int, setName, tplName string, data interface{}, htmlOpt ...web.HTMLOptions) {
+	r.record(setName, tplName, data)
+	r.Render.HTMLSet(status, setName, tplName, data, htmlOpt...)
+}
+
+func (r *recordRender) Negotiate(status int, v interface{}) {
+	r.Render.Negotiate(status, v)
+	view, ok := v.(web.View)
+	if p, isPtr := v.(*web.View); isPtr && p != nil {
+		view, ok = *p, true
+	}
+	if ok && strings.HasPrefix(r.Header().Get("Content-Type"), "text/html") {
+		if len(view.Set) == 0 {
+			view.Set = web.DEFAULT_TPL_SET_NAME
+		}
+		r.record(view.Set, view.Name, view.Data)
+	}
+}
+
+func withState(req *http.Request, s *state) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), contextKey{}, s))
+}
@@ -0,0 +1,186 @@
+// Copyright 2018 The Web Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package webtest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"landzero.net/x/net/web"
+	"landzero.net/x/net/web/csrf"
+	"landzero.net/x/net/web/session"
+)
+
+// recordT records failures instead of failing the test.
+type recordT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordT) Helper() {}
+
+func (t *recordT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, format)
+}
+
+func Test_Version(t *testing.T) {
+	Convey("Check package version", t, func() {
+		So(Version(), ShouldEqual, _VERSION)
+	})
+}
+
+func Test_lookupPath(t *testing.T) {
+	Convey("Look up value by JSON path", t, func() {
+		v := map[string]interface{}{
+			"data": map[string]interface{}{
+				"items": []interface{}{map[string]interface{}{"name": "a"}},
+			},
+		}
+		val, err := lookupPath(v, "data.items.0.name")
+		So(err, ShouldBeNil)
+		So(val, ShouldEqual, "a")
+		val, err = lookupPath(v, "data.items[0].name")
+		So(err, ShouldBeNil)
+		So(val, ShouldEqual, "a")
+		_, err = lookupPath(v, "data.items.1")
+		So(err, ShouldNotBeNil)
+		_, err = lookupPath(v, "data.missing")
+		So(err, ShouldNotBeNil)
+		_, err = lookupPath(v, "data.items.0.name.x")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func Test_Client(t *testing.T) {
+	Convey("Build requests and assert responses", t, func() {
+		m := web.New()
+		m.Use(web.Renderer(web.RenderOptions{Directory: "../fixtures/basic"}))
+		m.Post("/json", func(ctx *web.Context) {
+			data, _ := ioutil.ReadAll(ctx.Req.Body().ReadCloser())
+			ctx.Resp.Header().Set("X-Type", ctx.Req.Header.Get("Content-Type"))
+			ctx.JSON(http.StatusCreated, map[string]interface{}{
+				"raw":   string(data),
+				"query": ctx.Query("q"),
+				"items": []int{1, 2},
+			})
+		})
+		m.Post("/form", func(ctx *web.Context) {
+			f, h, err := ctx.Req.FormFile("file")
+			So(err, ShouldBeNil)
+			defer f.Close()
+			data, _ := ioutil.ReadAll(f)
+			ctx.PlainText(http.StatusOK, []byte(ctx.Req.FormValue("name")+":"+h.Filename+":"+string(data)))
+		})
+		m.Post("/urlencoded", func(ctx *web.Context) {
+			ctx.PlainText(http.StatusOK, []byte(ctx.Req.FormValue("a")+ctx.Req.FormValue("b")))
+		})
+		m.Get("/cookie", func(ctx *web.Context) {
+			if v := ctx.GetCookie("seen"); len(v) > 0 {
+				ctx.PlainText(http.StatusOK, []byte(v))
+				return
+			}
+			ctx.SetCookie("seen", "yes")
+			ctx.PlainText(http.StatusOK, []byte("first"))
+		})
+		m.Get("/hello", func(ctx *web.Context) {
+			ctx.Data["Name"] = "world"
+			ctx.HTML(http.StatusOK, "hello", ctx.Data)
+		})
+		m.Get("/view", func() web.View {
+			return web.View{Name: "hello", Data: "view"}
+		})
+		c := New(t, m)
+
+		Convey("JSON body and assertions", func() {
+			c.Post("/json").Query("q", "x").JSON(map[string]string{"a": "b"}).Do().
+				ExpectStatus(http.StatusCreated).
+				ExpectHeader("X-Type", "application/json").
+				ExpectJSON("raw", `{"a":"b"}`).
+				ExpectJSON("query", "x").
+				ExpectJSON("items", []int{1, 2}).
+				ExpectJSON("items[1]", 2).
+				ExpectBodyContains(`"query":"x"`)
+		})
+
+		Convey("Multipart and urlencoded form", func() {
+			c.Post("/form").FormValue("name", "joe").File("file", "a.txt", []byte("content")).Do().
+				ExpectStatus(http.StatusOK).
+				ExpectBodyContains("joe:a.txt:content")
+			c.Post("/urlencoded").Form(map[string][]string{"a": {"1"}, "b": {"2"}}).Do().
+				ExpectBodyContains("12")
+		})
+
+		Convey("Cookie jar persists across requests", func() {
+			c.Get("/cookie").Do().ExpectBodyContains("first")
+			So(c.Cookie("seen"), ShouldEqual, "yes")
+			c.Get("/cookie").Do().ExpectBodyContains("yes")
+			New(t, m).Get("/cookie").Cookie("seen", "given").Do().ExpectBodyContains("given")
+		})
+
+		Convey("Rendered template is recorded", func() {
+			resp := c.Get("/hello").Do().
+				ExpectTemplate("hello").
+				ExpectData("Name", "world")
+			So(resp.TemplateSet, ShouldEqual, web.DEFAULT_TPL_SET_NAME)
+			So(resp.Data("Missing"), ShouldBeNil)
+
+			c.Get("/view").Header("Accept", "text/html").Do().ExpectTemplate("hello")
+			resp = c.Get("/view").Header("Accept", "application/json").Do().ExpectJSON("", "view")
+			So(resp.Template, ShouldBeEmpty)
+		})
+
+		Convey("Failed expectations are reported", func() {
+			rt := &recordT{TB: t}
+			New(rt, m).Post("/json").JSON(nil).Do().
+				ExpectStatus(http.StatusOK).
+				ExpectHeader("X-Type", "text/plain").
+				ExpectJSON("query", "y").
+				ExpectJSON("missing", 1).
+				ExpectTemplate("hello").
+				ExpectData("Name", "world").
+				ExpectBodyContains("nothing")
+			So(len(rt.errors), ShouldEqual, 7)
+		})
+	})
+
+	Convey("Seed session and fill CSRF token", t, func() {
+		m := web.New()
+		m.Use(session.Sessioner())
+		m.Use(csrf.Csrfer())
+		m.Use(web.Renderer())
+		m.Get("/", func(ctx *web.Context, sess session.Store) {
+			ctx.PlainText(http.StatusOK, []byte(sess.Get("uid").(string)))
+		})
+		m.Post("/", csrf.Validate, func(ctx *web.Context) {
+			ctx.PlainText(http.StatusOK, []byte("ok"))
+		})
+		c := New(t, m)
+
+		c.Get("/").Session("uid", "joe").Do().ExpectBodyContains("joe")
+		c.Post("/").Do().ExpectStatus(http.StatusOK).ExpectBodyContains("ok")
+		resp := c.Post("/").NoCSRF().Do()
+		So(resp.Code, ShouldNotEqual, http.StatusOK)
+
+		Convey("Session is not available", func() {
+			m := web.New()
+			m.Get("/", func() {})
+			rt := &recordT{TB: t}
+			New(rt, m).Get("/").Session("uid", "joe").Do()
+			So(len(rt.errors), ShouldEqual, 1)
+		})
+	})
+}